@@ -0,0 +1,261 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// WithAuditLog records who changed what in an append-only audit log
+// at path. Each Write that changes the data appends a record with the
+// time, the actor given to WriteAs, and the change, as a patch to the
+// data before it. The first record in the log holds the whole data.
+//
+// Records hold the SHA-256 of the data before and after the change, and
+// of the record before them, so the log can be checked for gaps and
+// corruption. The sums are not keyed, so they do not show tampering:
+// anyone who can write the log can rewrite it with sums that match.
+// Keep the log where only the program can write it, or copy it to
+// storage that cannot be rewritten. A record is written before the
+// file, and if it cannot be written, the Write fails. The log is synced under the same SyncPolicy
+// as the file.
+//
+// WithAuditLog needs JSON, and cannot be used with WithGroupCommit,
+// which combines the Writes of different actors, or with WithEncryption
+// or WithCipher, as the log is not encrypted.
+func WithAuditLog(path string) Option {
+	return func(o *options) { o.auditLog = path }
+}
+
+// auditRecord is a line of the audit log. Sums are the hex SHA-256 of
+// the data in the canonical form made by canonicalJSON, and Prev is the
+// hex SHA-256 of the line before, without its newline.
+type auditRecord struct {
+	Time  time.Time       `json:"time"`
+	Actor string          `json:"actor,omitempty"`
+	Prev  string          `json:"prev,omitempty"`
+	Base  string          `json:"base"`
+	Sum   string          `json:"sum"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Patch json.RawMessage `json:"patch,omitempty"`
+}
+
+type actorKey struct{}
+
+// WriteAs is like Write, but records actor as the one making the change
+// in the log kept by WithAuditLog.
+func (p *JSONFile[Data]) WriteAs(actor string, fn func(*Data) error) error {
+	return p.WriteCtx(context.WithValue(context.Background(), actorKey{}, actor), fn)
+}
+
+// auditWrite records a Write in the audit log, if there is one.
+func (p *JSONFile[Data]) auditWrite(ctx context.Context, b []byte) error {
+	if p.opts.auditLog == "" {
+		return nil
+	}
+	if err := p.audit(ctx, b); err != nil {
+		return fmt.Errorf("JSONFile.Write: audit log: %w", err)
+	}
+	return nil
+}
+
+// audit appends a record of the change from the data in p.bytes to b,
+// made by a Write with ctx. It is called by write with p.writing held.
+func (p *JSONFile[Data]) audit(ctx context.Context, b []byte) error {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	rec := auditRecord{Time: time.Now(), Actor: actor}
+	base, err := canonicalJSON(p.bytes)
+	if err != nil {
+		return err
+	}
+	sum, err := canonicalJSON(b)
+	if err != nil {
+		return err
+	}
+	rec.Base, rec.Sum = sha256Hex(base), sha256Hex(sum)
+	if p.auditPrev == "" {
+		// Continue the log from its last complete record.
+		lines, size, err := readLines(p.opts.auditLog)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err := truncateFile(p.opts.auditLog, size); err != nil {
+			return err
+		}
+		if len(lines) > 0 {
+			p.auditPrev = sha256Hex(lines[len(lines)-1])
+		}
+	}
+	rec.Prev = p.auditPrev
+	if rec.Prev == "" {
+		rec.Data = sum // first record
+	} else if rec.Patch, err = diffJSON(p.bytes, b); err != nil {
+		return err
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p.opts.auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if err == nil && p.opts.sync.shouldSync(p.lastSync, rec.Time) {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		p.auditPrev = "" // start again from the log
+		return err
+	}
+	p.auditPrev = sha256Hex(line)
+	return nil
+}
+
+// canonicalJSON re-encodes the JSON document b with object keys sorted,
+// as applyPatch does, so the same data always has the same sum.
+func canonicalJSON(b []byte) ([]byte, error) {
+	v, err := jsonvalue.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditReport is the result of verifying an audit log.
+type AuditReport struct {
+	Records     int       // complete records in the log
+	Uncompleted int       // records of changes not written to the file
+	First, Last time.Time // times of the first and last records
+	Sum         string    // hex SHA-256 of the data after the last record
+}
+
+// VerifyAuditLog checks that the audit log read from log, as written by
+// WithAuditLog, has no gaps or corrupt records, and that replaying it
+// from its first record, which holds the whole data, reproduces data,
+// the current data of the file. If data is nil the last check is
+// skipped.
+//
+// Each record must follow from the one before: its prev must be the
+// SHA-256 of the record before, its base the sum of the data so far,
+// and applying its patch must give its sum. A record whose change
+// failed to reach the file, so that the next record starts from the same
+// data it did, is counted as uncompleted. The error from VerifyAuditLog
+// reports the first record that does not follow.
+func VerifyAuditLog(log io.Reader, data []byte) (AuditReport, error) {
+	r, err := verifyAuditLog(log, data)
+	if err != nil {
+		return r, fmt.Errorf("jsonfile.VerifyAuditLog: %w", err)
+	}
+	return r, nil
+}
+
+func verifyAuditLog(log io.Reader, data []byte) (r AuditReport, err error) {
+	b, err := io.ReadAll(log)
+	if err != nil {
+		return r, err
+	}
+	var state, before []byte // the data after the record, and before it
+	var prevLine []byte
+	for n := 1; ; n++ {
+		line, rest, ok := bytes.Cut(b, []byte("\n"))
+		if !ok {
+			break // a record cut short, or the end
+		}
+		b = rest
+		var rec auditRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return r, fmt.Errorf("record %d: %w", n, err)
+		}
+		if n == 1 {
+			if rec.Prev != "" || rec.Data == nil {
+				return r, errors.New("record 1: does not hold the whole data, the start of the log is missing")
+			}
+			if state, err = canonicalJSON(rec.Data); err != nil {
+				return r, fmt.Errorf("record 1: %w", err)
+			}
+			r.First = rec.Time
+		} else {
+			if rec.Prev != sha256Hex(prevLine) {
+				return r, fmt.Errorf("record %d: prev does not match record %d, a record is missing or corrupt", n, n-1)
+			}
+			switch rec.Base {
+			case sha256Hex(state):
+			case sha256Hex(before):
+				state = before // the change before was never completed
+				r.Uncompleted++
+			default:
+				return r, fmt.Errorf("record %d: base does not match the data, a change is missing from the log", n)
+			}
+			before = state
+			if state, err = applyPatch(state, rec.Patch); err != nil {
+				return r, fmt.Errorf("record %d: %w", n, err)
+			}
+		}
+		if sha256Hex(state) != rec.Sum {
+			return r, fmt.Errorf("record %d: patch does not give its sum, the record is corrupt", n)
+		}
+		r.Records++
+		r.Last, r.Sum = rec.Time, rec.Sum
+		prevLine = line
+	}
+	if r.Records == 0 {
+		return r, errors.New("log is empty")
+	}
+	if data == nil {
+		return r, nil
+	}
+	cur, err := canonicalJSON(data)
+	if err != nil {
+		return r, fmt.Errorf("data: %w", err)
+	}
+	switch sha256Hex(cur) {
+	case r.Sum:
+	case sha256Hex(before):
+		r.Uncompleted++
+		r.Sum = sha256Hex(before)
+	default:
+		return r, errors.New("data does not match the log, a change is missing from it")
+	}
+	return r, nil
+}
+
+// VerifyAudit checks the log kept by WithAuditLog against the data,
+// as VerifyAuditLog does.
+func (p *JSONFile[Data]) VerifyAudit() (AuditReport, error) {
+	if p.opts.auditLog == "" {
+		return AuditReport{}, errors.New("JSONFile.VerifyAudit: no audit log, use WithAuditLog")
+	}
+	p.writing <- struct{}{}
+	defer func() { <-p.writing }()
+	f, err := os.Open(p.opts.auditLog)
+	if err != nil {
+		return AuditReport{}, fmt.Errorf("JSONFile.VerifyAudit: %w", err)
+	}
+	defer f.Close()
+	r, err := verifyAuditLog(f, p.bytes)
+	if err != nil {
+		return r, fmt.Errorf("JSONFile.VerifyAudit: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val  int
+		Name string
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testaudit.json")
+	log := filepath.Join(dir, "audit.log")
+	db, err := New[DB](path, WithAuditLog(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WriteAs("alice", func(db *DB) error { db.Val = 1; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WriteAs("alice", func(db *DB) error { db.Val = 1; return nil }); err != nil {
+		t.Fatal(err) // no change, no record
+	}
+	db.Close()
+
+	db, err = Load[DB](path, WithAuditLog(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.WriteAs("bob", func(db *DB) error { db.Name = "b"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, _, err := readLines(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recs []auditRecord
+	for _, line := range lines {
+		var rec auditRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("got %d records, want 3: %q", len(recs), lines)
+	}
+	for i, actor := range []string{"", "alice", "bob"} {
+		if recs[i].Actor != actor {
+			t.Errorf("record %d actor %q, want %q", i, recs[i].Actor, actor)
+		}
+	}
+	if recs[0].Data == nil || recs[0].Prev != "" {
+		t.Errorf("first record: %+v", recs[0])
+	}
+	for i := 1; i < len(recs); i++ {
+		if recs[i].Prev != sha256Hex(lines[i-1]) {
+			t.Errorf("record %d prev does not match record %d", i, i-1)
+		}
+		if recs[i].Base != recs[i-1].Sum {
+			t.Errorf("record %d base does not match record %d sum", i, i-1)
+		}
+	}
+	if got, want := string(recs[2].Patch), `{"set":{"/Name":"b"}}`; got != want {
+		t.Errorf("patch %s, want %s", got, want)
+	}
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val  int
+		Name string
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testverify.json")
+	log := filepath.Join(dir, "audit.log")
+	db, err := New[DB](path, WithAuditLog(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := 1; i <= 3; i++ {
+		i := i
+		mustWrite(t, db, func(db *DB) { db.Val = i })
+	}
+	r, err := db.VerifyAudit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Records != 4 || r.Uncompleted != 0 {
+		t.Errorf("report %+v, want 4 records", r)
+	}
+
+	b, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	verify := func(log []byte, data string) error {
+		_, err := VerifyAuditLog(bytes.NewReader(log), []byte(data))
+		return err
+	}
+	if err := verify(b, `{"Name":"","Val":3}`); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+	if err := verify(b, `{"Name":"","Val":4}`); err == nil {
+		t.Error("no error for data not in the log")
+	}
+	if err := verify(bytes.Join(lines[1:], nil), `{"Name":"","Val":3}`); err == nil {
+		t.Error("no error for a log missing its start")
+	}
+	dropped := bytes.Join([][]byte{lines[0], lines[1], lines[3]}, nil)
+	if err := verify(dropped, `{"Name":"","Val":3}`); err == nil {
+		t.Error("no error for a log missing a record")
+	}
+	altered := bytes.Replace(b, []byte(`"/Val":2`), []byte(`"/Val":5`), 1)
+	if err := verify(altered, `{"Name":"","Val":3}`); err == nil {
+		t.Error("no error for an altered record")
+	}
+	torn := append(bytes.Clone(b), `{"time":`...)
+	if err := verify(torn, `{"Name":"","Val":3}`); err != nil {
+		t.Errorf("record cut short: %v", err)
+	}
+	// The last change was recorded but never reached the file.
+	if r, err := VerifyAuditLog(bytes.NewReader(b), []byte(`{"Name":"","Val":2}`)); err != nil || r.Uncompleted != 1 {
+		t.Errorf("uncompleted change: %+v, %v", r, err)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WithAutosave makes Write change the data in memory immediately but
+// delay writing it to the file by up to window, so that all the Writes
+// made within the window are saved by one write to the file. This suits
+// data that changes many times a second, such as counters or game
+// state, at the cost of losing up to window of changes in a crash.
+//
+// Flush writes pending changes immediately, as does Close. Errors writing the file
+// in the background are not reported, but the changes stay pending
+// and are retried by the next Write or Flush.
+func WithAutosave(window time.Duration) Option {
+	return func(o *options) { o.autosave = window }
+}
+
+// deferWrite makes data, encoded as b, the current data and schedules
+// it to be written to the file. It is called with p.writing held.
+func (p *JSONFile[Data]) deferWrite(data *Data, b []byte) {
+	p.publish(data, b)
+	p.dirty = true
+	if p.flushTimer == nil {
+		p.flushTimer = time.AfterFunc(p.opts.autosave, func() {
+			p.writing <- struct{}{}
+			defer func() { <-p.writing }()
+			if p.closed {
+				return
+			}
+			p.flushTimer = nil
+			p.flush() // best effort, retried by the next Write or Flush
+		})
+	}
+}
+
+// Flush writes any changes delayed by WithAutosave to the file.
+func (p *JSONFile[Data]) Flush() error {
+	p.writing <- struct{}{}
+	defer func() { <-p.writing }()
+	if err := p.flush(); err != nil {
+		return fmt.Errorf("JSONFile.Flush: %w", err)
+	}
+	return nil
+}
+
+// flush writes the data to the file if it has unsaved changes.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) flush() error {
+	if !p.dirty {
+		return nil
+	}
+	if err := p.writeFile(p.bytes, true); err != nil {
+		return err
+	}
+	p.dirty = false
+	p.afterCommit(context.Background(), p.data, p.bytes)
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAutosave(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testautosave.json")
+	db, err := New[DB](path, WithAutosave(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		mustWrite(t, db, func(db *DB) {
+			db.Val = i
+		})
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 3 {
+			t.Errorf("Val=%d, want 3", db.Val)
+		}
+	})
+	if b, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if got, want := string(b), `{"Val":0}`; got != want {
+		t.Errorf("before Flush file=%s, want %s", got, want)
+	}
+
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	db2, err := Load[DB](path, WithSharedLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db2.Read(func(db *DB) {
+		if db.Val != 3 {
+			t.Errorf("after Flush Val=%d, want 3", db.Val)
+		}
+	})
+}
+
+func TestAutosaveTimer(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testautosave.json")
+	db, err := New[DB](path, WithAutosave(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) {
+		db.Val = 1
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) == `{"Val":1}` {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("file=%s, autosave did not write", b)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat names backup files. It has a fixed width so backup
+// names sort by time.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+type backupOptions struct {
+	dir       string
+	interval  time.Duration
+	retention int
+	chain     int // see WithDifferentialBackups
+}
+
+// WithScheduledBackups copies the file into the directory dir at most
+// once per interval, keeping the newest retention copies.
+//
+// A backup is taken when the file is loaded or written and the newest
+// backup in dir is older than interval. A file that is not written is
+// not backed up again. Each backup is read back and checked before
+// older backups are removed. A failed scheduled backup does not fail
+// the Write that triggered it. It is retried on the next Write, and
+// Backup can be used to take a backup and see any error.
+func WithScheduledBackups(dir string, interval time.Duration, retention int) Option {
+	return func(o *options) {
+		o.backup = backupOptions{dir: dir, interval: interval, retention: retention}
+	}
+}
+
+// Backups returns the paths of the backups of the file made by
+// WithScheduledBackups, oldest first. Backups stored as patches by
+// WithDifferentialBackups are read with OpenRevision.
+func (p *JSONFile[Data]) Backups() ([]string, error) {
+	if p.opts.backup.dir == "" {
+		return nil, nil
+	}
+	names, err := p.backupNames()
+	if err != nil {
+		return nil, fmt.Errorf("JSONFile.Backups: %w", err)
+	}
+	for i, name := range names {
+		names[i] = filepath.Join(p.opts.backup.dir, name)
+	}
+	return names, nil
+}
+
+// Backup takes a backup of the file now, into the directory configured
+// with WithScheduledBackups.
+func (p *JSONFile[Data]) Backup() error {
+	if p.opts.backup.dir == "" {
+		return errors.New("JSONFile.Backup: no backup directory configured")
+	}
+	p.writing <- struct{}{}
+	defer func() { <-p.writing }()
+	if err := p.backup(p.bytes, time.Now()); err != nil {
+		return fmt.Errorf("JSONFile.Backup: %w", err)
+	}
+	return nil
+}
+
+// scheduledBackup takes a backup of b if one is due.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) scheduledBackup(b []byte) {
+	if p.opts.backup.dir == "" {
+		return
+	}
+	now := time.Now()
+	if p.lastBackup.IsZero() {
+		p.lastBackup = p.newestBackup()
+	}
+	if now.Sub(p.lastBackup) < p.opts.backup.interval {
+		return
+	}
+	p.backup(b, now) // best effort, retried on the next write
+}
+
+// backup writes b to a new backup file, verifies it, and removes
+// backups beyond the retention count.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) backup(b []byte, now time.Time) error {
+	dir := p.opts.backup.dir
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	b, err := p.encodeFile(b) // backups are copies of the file
+	if err != nil {
+		return err
+	}
+	names, err := p.backupNames()
+	if err != nil {
+		return err
+	}
+	name := p.backupPrefix() + now.UTC().Format(backupTimeFormat)
+	content := b
+	if n := len(names); n > 0 && trailingPatches(names) < p.opts.backup.chain {
+		prev, err := p.readBackup(names, n-1)
+		if err != nil {
+			return err
+		}
+		if content, err = diffJSON(prev, b); err != nil {
+			return err
+		}
+		name += patchSuffix
+	}
+	path := filepath.Join(dir, name)
+	if err := atomicWrite(osFS{}, path, content, true, p.opts.syncDir, nil); err != nil {
+		return err
+	}
+	names = append(names, name)
+	got, err := p.readBackup(names, len(names)-1)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	ok := bytes.Equal(got, b) && p.opts.validFile(got)
+	if isPatch(name) {
+		ok = jsonEqual(got, b) // patched backups have sorted keys
+	}
+	if !ok {
+		os.Remove(path)
+		return fmt.Errorf("verify: backup %s does not match", path)
+	}
+	p.lastBackup = now
+
+	if p.opts.backup.retention <= 0 {
+		return nil
+	}
+	for len(names) > p.opts.backup.retention {
+		if len(names) > 1 && isPatch(names[1]) {
+			// names[1] depends on names[0], make it a full backup.
+			if names[1], err = p.consolidate(names, 1); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+func (p *JSONFile[Data]) backupPrefix() string {
+	return filepath.Base(p.path) + "."
+}
+
+// backupNames returns the names of the backup files, oldest first.
+func (p *JSONFile[Data]) backupNames() ([]string, error) {
+	entries, err := os.ReadDir(p.opts.backup.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	prefix := p.backupPrefix()
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) || !e.Type().IsRegular() {
+			continue
+		}
+		if p.backupTime(name).IsZero() {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// newestBackup returns the time of the newest backup, or the zero time.
+func (p *JSONFile[Data]) newestBackup() time.Time {
+	names, err := p.backupNames()
+	if err != nil || len(names) == 0 {
+		return time.Time{}
+	}
+	return p.backupTime(names[len(names)-1])
+}
+
+// backupTime returns the time in a backup name, or the zero time.
+func (p *JSONFile[Data]) backupTime(name string) time.Time {
+	s := strings.TrimSuffix(strings.TrimPrefix(name, p.backupPrefix()), patchSuffix)
+	t, _ := time.Parse(backupTimeFormat, s)
+	return t
+}
@@ -0,0 +1,96 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduledBackups(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testbackup.json")
+	backupDir := filepath.Join(dir, "backups")
+	db, err := New[DB](path, WithScheduledBackups(backupDir, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		mustWrite(t, db, func(db *DB) { db.Val = i })
+	}
+
+	backups, err := db.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, want 2: %v", len(backups), backups)
+	}
+	for i, want := range []string{`{"Val":3}`, `{"Val":4}`} {
+		b, err := os.ReadFile(backups[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("backup %d = %s, want %s", i, b, want)
+		}
+	}
+}
+
+func TestBackupInterval(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testbackup.json")
+	backupDir := filepath.Join(dir, "backups")
+	opt := WithScheduledBackups(backupDir, time.Hour, 10)
+	db, err := New[DB](path, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+
+	// Reloading finds the recent backup and does not take another.
+	db, err = Load[DB](path, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+	if backups, _ := db.Backups(); len(backups) != 1 {
+		t.Fatalf("got %d backups, want 1: %v", len(backups), backups)
+	}
+
+	if err := db.Backup(); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := db.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, want 2: %v", len(backups), backups)
+	}
+	if b, _ := os.ReadFile(backups[1]); string(b) != `{"Val":2}` {
+		t.Errorf("manual backup = %s, want {\"Val\":2}", b)
+	}
+}
+
+func TestBackupNotConfigured(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testbackup.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Backup(); err == nil {
+		t.Error("Backup succeeded without a backup directory")
+	}
+}
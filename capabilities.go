@@ -0,0 +1,93 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+// Capabilities reports the optional features in use by a JSONFile, so
+// generic tools can adapt to how its file is written, and refuse
+// operations that are unsafe on it.
+type Capabilities struct {
+	// FormatVersion is the version of the file format described in
+	// FORMAT.md that the file is written in.
+	FormatVersion int
+
+	Codec       bool // the data is encoded WithCodec, not as JSON
+	HuJSON      bool // the file is HuJSON, WithHuJSON
+	Envelope    bool // the data is wrapped in an envelope
+	Schema      bool // the envelope holds a schema fingerprint
+	Checksum    bool // the envelope holds a checksum, WithChecksum
+	HMAC        bool // the checksum is an HMAC, with a key
+	Clock       bool // the envelope holds a stamp, WithClock
+	FieldCodecs bool // values are replaced by WithFieldCodec
+	Compression bool // the file is compressed, WithCompression
+	Encryption  bool // the file is encrypted, WithEncryption or WithCipher
+
+	Journal     bool // recent changes are in a journal, WithJournal
+	Autosave    bool // Writes are delayed, WithAutosave
+	GroupCommit bool // Writes are combined, WithGroupCommit
+	Watching    bool // the JSONFile reloads changes made by others, Watch
+
+	// Lock is "exclusive" or "shared" if the JSONFile holds a lock on
+	// the file, or "" if it does not. A JSONFile with a shared lock is
+	// read only.
+	Lock string
+
+	RecoveryBackup      bool // WithRecoveryBackup
+	RotatedBackups      int  // versions kept by WithBackups
+	DifferentialBackups bool // WithDifferentialBackups
+	ScheduledBackups    bool // WithScheduledBackups
+	RevisionFiles       int  // revisions kept by WithRevisionFiles
+	History             bool // WithHistory
+	AuditLog            bool // WithAuditLog
+	Git                 bool // WithGit
+	Mirrors             int  // files written by WithMirror
+
+	UnknownFields bool // WithUnknownFields
+	Conflicts     bool // WithConflictDetection
+}
+
+// Capabilities reports the optional features in use.
+func (p *JSONFile[Data]) Capabilities() Capabilities {
+	o := &p.opts
+	c := Capabilities{
+		FormatVersion: envelopeVersion,
+
+		Codec:       !o.isJSON(),
+		HuJSON:      o.hujson,
+		Envelope:    o.useEnvelope(),
+		Schema:      o.schema.enabled,
+		Checksum:    o.checksum.enabled,
+		HMAC:        o.checksum.enabled && o.checksum.key != nil,
+		Clock:       o.clock != nil,
+		FieldCodecs: len(o.fieldCodecs) > 0,
+		Compression: o.compression != nil,
+		Encryption:  o.encrypted(),
+
+		Journal:     o.journal > 0,
+		Autosave:    o.autosave > 0,
+		GroupCommit: o.groupCommit,
+
+		RecoveryBackup:      o.recoveryBackup,
+		RotatedBackups:      o.rotatedBackups,
+		DifferentialBackups: o.backup.chain > 0,
+		ScheduledBackups:    o.backup.dir != "",
+		RevisionFiles:       o.revisionFiles,
+		History:             o.history,
+		AuditLog:            o.auditLog != "",
+		Git:                 o.gitRepo != "",
+		Mirrors:             len(o.mirrors),
+
+		UnknownFields: o.unknownFields,
+		Conflicts:     o.detectConflicts,
+	}
+	switch o.lock {
+	case lockExclusive:
+		c.Lock = "exclusive"
+	case lockShared:
+		c.Lock = "shared"
+	}
+	p.mu.RLock()
+	c.Watching = p.watches > 0
+	p.mu.RUnlock()
+	return c
+}
@@ -0,0 +1,49 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val int
+	}
+
+	dir := t.TempDir()
+	db, err := New[DB](filepath.Join(dir, "testcaps.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got, want := db.Capabilities(), (Capabilities{FormatVersion: 1}); got != want {
+		t.Errorf("plain file: %+v", got)
+	}
+
+	key := make([]byte, 32)
+	db2, err := New[DB](filepath.Join(dir, "testcaps2.json"), WithChecksum(key), WithCompression(Gzip), WithExclusiveLock(), WithBackups(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := db2.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := db2.Capabilities()
+	if !c.Envelope || !c.Checksum || !c.HMAC || !c.Compression || c.Encryption || c.Lock != "exclusive" || c.RotatedBackups != 3 || !c.Watching {
+		t.Errorf("capabilities: %+v", c)
+	}
+	cancel()
+	for range ch {
+	}
+	if db2.Capabilities().Watching {
+		t.Error("still watching after the Watch is done")
+	}
+}
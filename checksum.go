@@ -0,0 +1,102 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCorrupt is returned by Load when the file is damaged: it is not
+// valid JSON, as when it was truncated, or its data does not match the
+// checksum recorded by WithChecksum.
+var ErrCorrupt = errors.New("jsonfile: file is corrupt")
+
+type checksumOptions struct {
+	enabled bool
+	key     []byte // for HMAC-SHA256, or nil
+}
+
+// WithChecksum records a checksum of the data in the file on each
+// Write, and checks it when the file is read. A file whose data does
+// not match, from bit rot or an edit by hand, fails to load with an
+// error wrapping ErrCorrupt.
+//
+// If key is nil, the checksum is the SHA-256 of the data. Otherwise it
+// is the HMAC-SHA256 of the data with key, which also catches changes
+// made by programs that do not have the key. A file with no SHA-256
+// checksum is read without a check, so that checksums can be added to
+// an existing file by its next Write. A file with no HMAC fails to
+// load with an error wrapping ErrCorrupt, as removing it would
+// otherwise bypass the check; open existing files WithUnsignedFiles
+// until their next Write adds one.
+//
+// The checksum is kept in an envelope around the data, so the file is
+// no longer the plain encoding of Data. WithChecksum cannot be used
+// with WithHuJSON, as edits to comments and spacing would not match.
+func WithChecksum(key []byte) Option {
+	return func(o *options) {
+		o.checksum = checksumOptions{enabled: true, key: append([]byte(nil), key...)}
+	}
+}
+
+// WithUnsignedFiles reads files with no HMAC, with WithChecksum and a
+// key, so that an HMAC can be added to an existing file by its next
+// Write. Remove the option once the files have one, as it lets anyone
+// who can write them change the data.
+func WithUnsignedFiles() Option {
+	return func(o *options) { o.unsigned = true }
+}
+
+// sum returns the checksum of the data b, for an envelope.
+func (o *options) sum(b []byte) string {
+	if o.checksum.key == nil {
+		sum := sha256.Sum256(b)
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, o.checksum.key)
+	mac.Write(b)
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkSum checks the checksum stored in an envelope for the data b.
+func (o *options) checkSum(stored string, b []byte) error {
+	if stored == "" {
+		if o.checksum.key != nil && !o.unsigned {
+			return fmt.Errorf("%w: file has no HMAC checksum", ErrCorrupt)
+		}
+		return nil
+	}
+	alg, _, _ := strings.Cut(stored, ":")
+	switch {
+	case alg == "sha256" && o.checksum.key == nil:
+	case alg == "sha256" && o.checksum.key != nil:
+		return fmt.Errorf("%w: file has a SHA-256 checksum, want HMAC-SHA256", ErrCorrupt)
+	case alg == "hmac-sha256" && o.checksum.key != nil:
+	case alg == "hmac-sha256":
+		return nil // cannot be checked without the key
+	default:
+		return fmt.Errorf("%w: unknown checksum %q", ErrCorrupt, stored)
+	}
+	if want := o.sum(b); !hmac.Equal([]byte(stored), []byte(want)) {
+		return fmt.Errorf("%w: data does not match checksum", ErrCorrupt)
+	}
+	return nil
+}
+
+// checkValid returns an error wrapping ErrCorrupt if b is not valid
+// JSON.
+func checkValid(b []byte) error {
+	if json.Valid(b) {
+		return nil
+	}
+	var v json.RawMessage
+	err := json.Unmarshal(b, &v) // for a useful message
+	return fmt.Errorf("%w: %v", ErrCorrupt, err)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testchecksum.json")
+	db, err := New[DB](path, WithChecksum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 12 })
+	if err := Verify(path); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if _, err := Load[DB](path, WithChecksum(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bit rot that leaves valid JSON.
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotten := bytes.Replace(b, []byte(`"Val":12`), []byte(`"Val":13`), 1)
+	if err := os.WriteFile(path, rotten, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load[DB](path, WithChecksum(nil)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Load of changed data err=%v, want %v", err, ErrCorrupt)
+	}
+
+	// Truncation is reported as corruption with or without a checksum.
+	if err := os.WriteFile(path, b[:len(b)/2], 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load[DB](path); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Load of truncated file err=%v, want %v", err, ErrCorrupt)
+	}
+}
+
+func TestChecksumHMAC(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testhmac.json")
+	key := []byte("key")
+	db, err := New[DB](path, WithChecksum(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if _, err := Load[DB](path, WithChecksum(key)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load[DB](path, WithChecksum([]byte("other"))); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Load with another key err=%v, want %v", err, ErrCorrupt)
+	}
+	if db, err := Load[DB](path, WithChecksum(nil)); err != nil {
+		t.Errorf("Load without the key: %v", err)
+	} else {
+		db.Read(func(db *DB) {
+			if db.Val != 1 {
+				t.Errorf("Load without the key: Val=%d, want 1", db.Val)
+			}
+		})
+	}
+
+	// A file with no HMAC is refused, unless allowed, so the check
+	// cannot be bypassed by removing it.
+	for _, forged := range []string{`{"Val":2}`, `{"jsonfile":1,"data":{"Val":2}}`} {
+		if err := os.WriteFile(path, []byte(forged), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load[DB](path, WithChecksum(key)); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("Load of %s err=%v, want %v", forged, err, ErrCorrupt)
+		}
+	}
+	db, err = Load[DB](path, WithChecksum(key), WithUnsignedFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 3 })
+	if _, err := Load[DB](path, WithChecksum(key)); err != nil {
+		t.Errorf("Load after the HMAC is added: %v", err)
+	}
+
+	// A SHA-256 checksum, which anyone can compute, is refused.
+	db, err = New[DB](path, WithChecksum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load[DB](path, WithChecksum(key)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Load of SHA-256 file with a key err=%v, want %v", err, ErrCorrupt)
+	}
+}
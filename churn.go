@@ -0,0 +1,62 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import "time"
+
+// churnWindow is the period over which WithChurnAlert counts writes.
+const churnWindow = time.Minute
+
+type churnOptions struct {
+	perMinute int
+	alert     func(writesPerMinute int)
+}
+
+// WithChurnAlert calls alert when the file is written more than
+// perMinute times in a minute. A high write rate usually means a
+// feedback loop is rewriting the file, which otherwise only shows up
+// as disk wear.
+//
+// Only writes that change the file are counted. alert is called once
+// each time the rate rises above the limit, and again only once the
+// rate has fallen back below it. It is called from Write and must not
+// call Write.
+func WithChurnAlert(perMinute int, alert func(writesPerMinute int)) Option {
+	return func(o *options) { o.churn = churnOptions{perMinute: perMinute, alert: alert} }
+}
+
+// churn tracks recent write times for WithChurnAlert.
+type churn struct {
+	times   []time.Time // writes in the last churnWindow, oldest first
+	alerted bool
+}
+
+// add records a write at now and returns the number of writes in
+// the last minute.
+func (c *churn) add(now time.Time) int {
+	cutoff := now.Add(-churnWindow)
+	i := 0
+	for i < len(c.times) && !c.times[i].After(cutoff) {
+		i++
+	}
+	c.times = append(c.times[i:], now)
+	return len(c.times)
+}
+
+// recordChurn records a write for WithChurnAlert.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) recordChurn(now time.Time) {
+	o := p.opts.churn
+	if o.alert == nil {
+		return
+	}
+	n := p.churn.add(now)
+	switch {
+	case n > o.perMinute && !p.churn.alerted:
+		p.churn.alerted = true
+		o.alert(n)
+	case n <= o.perMinute:
+		p.churn.alerted = false
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChurnAlert(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	var alerts []int
+	path := filepath.Join(t.TempDir(), "testchurn.json")
+	db, err := New[DB](path, WithChurnAlert(3, func(n int) { alerts = append(alerts, n) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		mustWrite(t, db, func(db *DB) { db.Val = i })
+	}
+	mustWrite(t, db, func(db *DB) {}) // no change, not counted
+
+	// New's write plus five more: the alert fires once, at the fourth.
+	if len(alerts) != 1 || alerts[0] != 4 {
+		t.Errorf("alerts=%v, want [4]", alerts)
+	}
+}
+
+func TestChurnWindow(t *testing.T) {
+	t.Parallel()
+	var c churn
+	now := time.Now()
+	c.add(now.Add(-2 * time.Minute))
+	c.add(now.Add(-30 * time.Second))
+	if n := c.add(now); n != 2 {
+		t.Errorf("writes in window=%d, want 2", n)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// staleTempAge is how old a temporary file must be before New and Load
+// remove it when they do not hold a lock. No Write takes this long.
+const staleTempAge = time.Hour
+
+// removeStaleTemps removes the temporary files left next to the file
+// by Writes interrupted by a crash. Holding a lock, no other program
+// using this package can be writing the file, so every temporary file
+// is stale. Otherwise only old ones are removed.
+// It is called by New and Load, and errors are ignored.
+func (p *JSONFile[Data]) removeStaleTemps() {
+	fsys := p.opts.fileSystem()
+	dir, base := splitPath(fsys, p.path)
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return
+	}
+	prefix := base + ".tmp"
+	for _, e := range entries {
+		name := e.Name()
+		suffix, ok := strings.CutPrefix(name, prefix)
+		if !ok || suffix == "" || strings.Trim(suffix, "0123456789") != "" || !e.Type().IsRegular() {
+			continue // not made by os.CreateTemp in atomicWrite
+		}
+		if p.lock == nil {
+			fi, err := e.Info()
+			if err != nil || time.Since(fi.ModTime()) < staleTempAge {
+				continue
+			}
+		}
+		fsys.Remove(joinPath(fsys, dir, name))
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoveStaleTemps(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testcleanup.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	db.Close()
+
+	old := time.Now().Add(-2 * staleTempAge)
+	touch := func(name string, mtime time.Time) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("{"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	stale := touch("testcleanup.json.tmp123", old)
+	recent := touch("testcleanup.json.tmp456", time.Now())
+	other := touch("testcleanup.json.tmpl", old)
+
+	exists := func(p string) bool {
+		_, err := os.Stat(p)
+		return err == nil
+	}
+
+	// Without a lock only old temporary files are removed.
+	if _, err := Load[DB](path); err != nil {
+		t.Fatal(err)
+	}
+	if exists(stale) {
+		t.Error("stale temporary file not removed")
+	}
+	if !exists(recent) {
+		t.Error("recent temporary file removed without a lock")
+	}
+	if !exists(other) {
+		t.Error("non-temporary file removed")
+	}
+
+	// Holding the lock, every temporary file is stale.
+	db, err = Load[DB](path, WithExclusiveLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if exists(recent) {
+		t.Error("temporary file not removed under the lock")
+	}
+	if !exists(other) {
+		t.Error("non-temporary file removed")
+	}
+}
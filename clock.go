@@ -0,0 +1,173 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithClock stamps each change to the data with a time from c, so that
+// changes made on several replicas of a file, by jsonfilesync or a
+// replication scheme of the application, can be put in order. The
+// stamp is stored in the file's envelope, reported in each ChangeEvent
+// and by Stamp, and observed by c when the file is read, so a stamp is
+// always after those of the data it replaces.
+//
+// Use an HLC for c, shared by all the files of a replica.
+func WithClock(c Clock) Option {
+	return func(o *options) { o.clock = c }
+}
+
+// A Clock provides stamps that order changes across replicas.
+// Its methods are called concurrently.
+type Clock interface {
+	// Now returns a stamp after any it has returned or observed.
+	Now() Stamp
+
+	// Observe records a stamp made by another replica.
+	Observe(s Stamp)
+}
+
+// A Stamp is a time from a Clock. Stamps are ordered by Wall, then
+// Logical, then Node, so no two replicas make equal stamps.
+//
+// A Stamp is encoded as text as its wall time in 16 hex digits, its
+// logical counter in 8 hex digits, and its node, separated by dashes.
+// Stamps of the same node sort as text in their order.
+type Stamp struct {
+	Wall    int64  // Unix time in nanoseconds
+	Logical uint32 // orders stamps with the same Wall
+	Node    string // the replica that made the stamp
+}
+
+// IsZero reports whether s is the zero Stamp, the stamp of data
+// written without a Clock.
+func (s Stamp) IsZero() bool { return s == Stamp{} }
+
+// Compare returns -1 if s is before t, 1 if s is after t, and 0 if
+// they are the same.
+func (s Stamp) Compare(t Stamp) int {
+	switch {
+	case s.Wall != t.Wall:
+		return cmpInt(s.Wall, t.Wall)
+	case s.Logical != t.Logical:
+		return cmpInt(s.Logical, t.Logical)
+	}
+	return strings.Compare(s.Node, t.Node)
+}
+
+func cmpInt[T int64 | uint32](a, b T) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
+
+func (s Stamp) String() string {
+	if s.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%016x-%08x-%s", uint64(s.Wall), s.Logical, s.Node)
+}
+
+// ParseStamp parses a Stamp in the form made by String.
+func ParseStamp(text string) (Stamp, error) {
+	if text == "" {
+		return Stamp{}, nil
+	}
+	wall, rest, ok1 := strings.Cut(text, "-")
+	logical, node, ok2 := strings.Cut(rest, "-")
+	w, err1 := strconv.ParseUint(wall, 16, 64)
+	l, err2 := strconv.ParseUint(logical, 16, 32)
+	if !ok1 || !ok2 || len(wall) != 16 || len(logical) != 8 || err1 != nil || err2 != nil {
+		return Stamp{}, fmt.Errorf("jsonfile.ParseStamp: invalid stamp %q", text)
+	}
+	return Stamp{Wall: int64(w), Logical: uint32(l), Node: node}, nil
+}
+
+func (s Stamp) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+func (s *Stamp) UnmarshalText(text []byte) (err error) {
+	*s, err = ParseStamp(string(text))
+	return err
+}
+
+// An HLC is a hybrid logical clock: its stamps follow the wall clock,
+// but never go backward, and are after any stamp it observes, even from
+// a replica whose wall clock is ahead.
+type HLC struct {
+	node string
+	now  func() time.Time // for tests
+
+	mu   sync.Mutex
+	last Stamp
+}
+
+// NewHLC returns a hybrid logical clock for the replica named node.
+// The name must be unique among replicas.
+func NewHLC(node string) *HLC {
+	return &HLC{node: node, now: time.Now}
+}
+
+// Now returns a new stamp.
+func (c *HLC) Now() Stamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if wall := c.now().UnixNano(); wall > c.last.Wall {
+		c.last = Stamp{Wall: wall}
+	} else {
+		c.last.Logical++
+	}
+	c.last.Node = c.node
+	return c.last
+}
+
+// Observe advances the clock past s.
+func (c *HLC) Observe(s Stamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.Compare(c.last) > 0 {
+		c.last.Wall, c.last.Logical = s.Wall, s.Logical
+	}
+}
+
+// clockStamp returns the stamp in an envelope, and observes it.
+func (o *options) clockStamp(text string) (Stamp, error) {
+	if o.clock == nil || text == "" {
+		return Stamp{}, nil
+	}
+	s, err := ParseStamp(text)
+	if err != nil {
+		return Stamp{}, errors.New("invalid envelope: bad hlc stamp")
+	}
+	o.clock.Observe(s)
+	return s, nil
+}
+
+// Stamp returns the stamp of the data, from WithClock. It is zero if
+// the data was written without a Clock.
+func (p *JSONFile[Data]) Stamp() Stamp {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.stamp
+}
+
+// Clock returns the Clock from WithClock, or nil.
+// Sync protocols use it to observe the stamps of other replicas.
+func (p *JSONFile[Data]) Clock() Clock {
+	return p.opts.clock
+}
+
+// setStamp sets the stamp of the data.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) setStamp(s Stamp) {
+	p.mu.Lock()
+	p.stamp = s
+	p.mu.Unlock()
+}
@@ -0,0 +1,109 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHLC(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(100, 0)
+	c := NewHLC("a")
+	c.now = func() time.Time { return now }
+
+	s1 := c.Now()
+	s2 := c.Now()
+	now = now.Add(-time.Second) // the wall clock goes backward
+	s3 := c.Now()
+	c.Observe(Stamp{Wall: time.Unix(200, 0).UnixNano(), Logical: 7, Node: "b"})
+	s4 := c.Now()
+	now = time.Unix(300, 0)
+	s5 := c.Now()
+
+	stamps := []Stamp{s1, s2, s3, s4, s5}
+	for i := 1; i < len(stamps); i++ {
+		if stamps[i].Compare(stamps[i-1]) <= 0 {
+			t.Errorf("stamp %d %v is not after %v", i, stamps[i], stamps[i-1])
+		}
+		if stamps[i].String() <= stamps[i-1].String() {
+			t.Errorf("stamp %d %q does not sort after %q", i, stamps[i], stamps[i-1])
+		}
+	}
+	if want := (Stamp{Wall: time.Unix(200, 0).UnixNano(), Logical: 8, Node: "a"}); s4 != want {
+		t.Errorf("after observe: %+v, want %+v", s4, want)
+	}
+	if s5.Logical != 0 {
+		t.Errorf("logical counter not reset: %+v", s5)
+	}
+	for _, s := range stamps {
+		got, err := ParseStamp(s.String())
+		if err != nil || got != s {
+			t.Errorf("ParseStamp(%q) = %+v, %v", s, got, err)
+		}
+	}
+	for _, bad := range []string{"1-2-a", "zzzzzzzzzzzzzzzz-00000000-a", "0000000000000001"} {
+		if _, err := ParseStamp(bad); err == nil {
+			t.Errorf("ParseStamp(%q): no error", bad)
+		}
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val int
+	}
+
+	path := filepath.Join(t.TempDir(), "testclock.json")
+	clock := NewHLC("node1")
+	db, err := New[DB](path, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, cancel := db.Subscribe()
+	defer cancel()
+	before := db.Stamp()
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	ev := <-ch
+	if ev.Stamp.Compare(before) <= 0 || ev.Stamp != db.Stamp() || ev.Stamp.Node != "node1" {
+		t.Errorf("event stamp %v, db stamp %v, before %v", ev.Stamp, db.Stamp(), before)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"hlc":"`+db.Stamp().String()+`"`) {
+		t.Errorf("file does not hold the stamp: %s", b)
+	}
+	if err := Verify(path); err != nil {
+		t.Error(err)
+	}
+	stamp := db.Stamp()
+	db.Close()
+
+	// A replica with a clock behind the file's stamps after it.
+	behind := NewHLC("node2")
+	behind.now = func() time.Time { return time.Unix(1, 0) }
+	db, err = Load[DB](path, WithClock(behind))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.Stamp() != stamp {
+		t.Errorf("loaded stamp %v, want %v", db.Stamp(), stamp)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+	if db.Stamp().Compare(stamp) <= 0 {
+		t.Errorf("stamp %v is not after the loaded %v", db.Stamp(), stamp)
+	}
+
+	if _, err := New[DB](path+"2", WithClock(clock), WithJournal(1<<20)); err == nil {
+		t.Error("WithClock and WithJournal: no error")
+	}
+}
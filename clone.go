@@ -0,0 +1,195 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// A Cloner copies itself. If *Data implements Cloner, Write calls
+// Clone to copy the data instead of decoding it from JSON.
+//
+// Clone must return a *Data, or a Data, that shares no memory with
+// the receiver. For large types a hand-written Clone is much faster
+// than a JSON round trip, and it can keep values that JSON does not,
+// such as unexported fields. The data seen by Read is then what Clone
+// returns, not what Load would return from the file.
+type Cloner interface {
+	Clone() any
+}
+
+// copyData returns a copy of src, which is encoded as b, that shares
+// no memory with it.
+//
+// Types made only of plain values are copied directly, which is much
+// cheaper than decoding b. Other types are decoded from b, so that the
+// copy is exactly what Load would return, unless they are a Cloner.
+// The plain types are those encoding/json round trips exactly, so
+// with WithJSONv2 or WithCodec every type is decoded. Data holding a
+// string that is not valid UTF-8, which encoding/json replaces, is
+// decoded too.
+func copyData[Data any](o *options, src *Data, b []byte) (*Data, error) {
+	if c, ok := any(src).(Cloner); ok {
+		switch v := c.Clone().(type) {
+		case *Data:
+			return v, nil
+		case Data:
+			return &v, nil
+		default:
+			return nil, fmt.Errorf("Clone returned %T, want %T", v, src)
+		}
+	}
+	data := new(Data)
+	if o.codec == nil && plainType(reflect.TypeOf(data).Elem()) {
+		if deepCopy(reflect.ValueOf(data).Elem(), reflect.ValueOf(src).Elem()) {
+			return data, nil
+		}
+		data = new(Data) // a string is not valid UTF-8
+	}
+	if len(b) == 0 {
+		return data, nil // a new file
+	}
+	if err := o.unmarshal(b, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+var plainTypes sync.Map // reflect.Type -> bool
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// plainType reports whether values of type t survive a JSON round trip
+// unchanged, so that a deep copy is equivalent to encoding and decoding.
+func plainType(t reflect.Type) bool {
+	if v, ok := plainTypes.Load(t); ok {
+		return v.(bool)
+	}
+	plainTypes.Store(t, true) // assume true while visiting recursive types
+	plain := isPlain(t)
+	plainTypes.Store(t, plain)
+	return plain
+}
+
+func isPlain(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	for _, it := range []reflect.Type{marshalerType, unmarshalerType, textMarshalType, textUnmarshType} {
+		if t.Implements(it) || pt.Implements(it) {
+			return false
+		}
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return plainType(t.Elem())
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && plainType(t.Key()) && plainType(t.Elem())
+	case reflect.Struct:
+		// Embedded fields are excluded, as encoding/json drops those
+		// it finds ambiguous, and so are fields of the same name,
+		// which it drops too.
+		names := make(map[string]bool)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" {
+				name = f.Name
+			}
+			if !f.IsExported() || f.Anonymous || name == "-" || names[name] || !plainType(f.Type) {
+				return false
+			}
+			names[name] = true
+		}
+		return true
+	}
+	return false // interfaces, channels, functions, complex numbers
+}
+
+// deepCopy copies src into dst, which must have a plain type, as
+// decoding the JSON encoding of src would. It reports false, leaving
+// dst partly copied, if src holds a string that is not valid UTF-8.
+func deepCopy(dst, src reflect.Value) bool {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if encodesNull(src) {
+			return true // decoding null leaves the pointer nil
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		return deepCopy(dst.Elem(), src.Elem())
+	case reflect.Slice:
+		if src.IsNil() {
+			return true
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			if !deepCopy(dst.Index(i), src.Index(i)) {
+				return false
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			if !deepCopy(dst.Index(i), src.Index(i)) {
+				return false
+			}
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return true
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			if !utf8.ValidString(iter.Key().String()) {
+				return false
+			}
+			v := reflect.New(src.Type().Elem()).Elem()
+			if !deepCopy(v, iter.Value()) {
+				return false
+			}
+			dst.SetMapIndex(iter.Key(), v)
+		}
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if !deepCopy(dst.Field(i), src.Field(i)) {
+				return false
+			}
+		}
+	case reflect.String:
+		if !utf8.ValidString(src.String()) {
+			return false
+		}
+		dst.Set(src)
+	default:
+		dst.Set(src)
+	}
+	return true
+}
+
+// encodesNull reports whether v, of a plain type, is encoded as null.
+func encodesNull(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer:
+		return v.IsNil() || encodesNull(v.Elem())
+	case reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type rawValue struct{ json.RawMessage }
+
+func TestPlainType(t *testing.T) {
+	t.Parallel()
+	type Node struct {
+		Name string
+		Kids []*Node
+	}
+	for _, tt := range []struct {
+		v    any
+		want bool
+	}{
+		{struct{ A, B int }{}, true},
+		{map[string][]float64{}, true},
+		{Node{}, true},
+		{struct{ T time.Time }{}, false},
+		{struct{ Node }{}, false},
+		{struct {
+			A int `json:"B"`
+			B int
+		}{}, false},
+		{struct {
+			A int `json:"a,omitempty"`
+			B int
+		}{}, true},
+		{struct{ a int }{}, false},
+		{struct {
+			A int `json:"-"`
+		}{}, false},
+		{struct{ V any }{}, false},
+		{struct{ R rawValue }{}, false},
+		{map[int]string{}, false},
+	} {
+		if got := plainType(reflect.TypeOf(tt.v)); got != tt.want {
+			t.Errorf("plainType(%T) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+// checkCopy checks that copyData copies v as a JSON round trip does.
+func checkCopy[T any](t *testing.T, v T) {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := new(T)
+	if err := json.Unmarshal(b, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := copyData(&options{}, &v, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("copyData(%T) = %+v, want %+v as from %s", v, *got, *want, b)
+	}
+}
+
+func TestCopyData(t *testing.T) {
+	t.Parallel()
+	type Inner struct{ A, B int }
+	type Outer struct{ Inner }
+	n, empty := 1, []int(nil)
+	p := &n
+	checkCopy(t, struct {
+		S    string
+		M    map[string]string
+		Keys map[string]int
+	}{S: "a\xffb\xc0", M: map[string]string{"k": "\xfe"}, Keys: map[string]int{"\xff": 1}})
+	checkCopy(t, struct{ S []string }{S: []string{"ok", "bad\xff"}})
+	checkCopy(t, struct{ T time.Time }{T: time.Now()})
+	checkCopy(t, struct{ T time.Time }{T: time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("X", 3600))})
+	checkCopy(t, Outer{Inner{1, 2}})
+	checkCopy(t, struct {
+		PP    **int
+		NilPP **int
+		PS    *[]int
+		E     []int
+		N     []int
+	}{PP: &p, NilPP: new(*int), PS: &empty, E: []int{}})
+	checkCopy(t, struct {
+		A map[string]*int
+		F []float32
+	}{A: map[string]*int{"x": nil, "y": &n}, F: []float32{0.1, -0}})
+}
+
+func TestWriteCopies(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Vals  []int
+		Names map[string]*string
+	}
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testcopy.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "a"
+	mustWrite(t, db, func(db *DB) {
+		db.Vals = []int{1, 2}
+		db.Names = map[string]*string{"a": &name}
+	})
+	var before *DB
+	db.Read(func(db *DB) { before = db })
+
+	mustWrite(t, db, func(db *DB) {
+		db.Vals[0] = 10
+		*db.Names["a"] = "b"
+	})
+	if before.Vals[0] != 1 || *before.Names["a"] != "a" {
+		t.Errorf("Write changed the previous data: %v, %q", before.Vals, *before.Names["a"])
+	}
+	db.Read(func(db *DB) {
+		if db.Vals[0] != 10 || *db.Names["a"] != "b" {
+			t.Errorf("Vals=%v Names[a]=%q, want 10, b", db.Vals, *db.Names["a"])
+		}
+	})
+}
+
+type clonedDB struct {
+	Val    int
+	cache  map[string]int // not in the file
+	clones *int
+}
+
+func (db *clonedDB) Clone() any {
+	if db.clones != nil {
+		*db.clones++
+	}
+	c := &clonedDB{Val: db.Val, cache: make(map[string]int), clones: db.clones}
+	for k, v := range db.cache {
+		c.cache[k] = v
+	}
+	return c
+}
+
+func TestCloner(t *testing.T) {
+	t.Parallel()
+
+	db, err := New[clonedDB](filepath.Join(t.TempDir(), "testclone.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clones := 0
+	db.data.clones = &clones
+	mustWrite(t, db, func(db *clonedDB) {
+		db.Val = 1
+		db.cache["a"] = 1
+	})
+	if clones == 0 {
+		t.Error("Write did not call Clone")
+	}
+	db.Read(func(db *clonedDB) {
+		if db.Val != 1 || db.cache["a"] != 1 {
+			t.Errorf("Val=%d cache=%v, want 1, map[a:1]", db.Val, db.cache)
+		}
+	})
+}
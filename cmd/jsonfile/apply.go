@@ -0,0 +1,390 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"crawshaw.dev/jsonfile"
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// An op is a line of an apply script: a JSON Patch (RFC 6902)
+// operation, or, with no "op", a set of the value at path that adds
+// or replaces it, creating missing or null parent objects.
+type op struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// A document is the data of a file, loaded by the jsonfile package
+// with the options the file is written with.
+type document struct {
+	db   *jsonfile.JSONFile[json.RawMessage]
+	data any
+	raw  []byte // data as it is in the file
+}
+
+// readDocument reads the file at path. opts are those the file needs
+// that cannot be found from it, from fileFlags. Nothing is changed on
+// disk and no locks are taken.
+func readDocument(path string, opts ...jsonfile.Option) (*document, error) {
+	return loadDocument(path, false, opts)
+}
+
+// openDocument loads the file at path to change it with write. It must
+// be closed.
+func openDocument(path string, opts ...jsonfile.Option) (*document, error) {
+	return loadDocument(path, true, opts)
+}
+
+func loadDocument(path string, writable bool, opts []jsonfile.Option) (*document, error) {
+	format, err := jsonfile.ReadFormat(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var db *jsonfile.JSONFile[json.RawMessage]
+	if writable {
+		db, err = jsonfile.Load[json.RawMessage](path, format.Options()...)
+	} else {
+		db, err = jsonfile.LoadFS[json.RawMessage](os.DirFS(filepath.Dir(path)), filepath.Base(path), format.Options()...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{db: db}
+	db.Read(func(raw *json.RawMessage) { doc.raw = bytes.Clone(*raw) })
+	if !writable {
+		db.Close()
+		doc.db = nil
+	}
+	if doc.data, err = jsonvalue.Decode(doc.raw); err != nil {
+		doc.close()
+		return nil, err
+	}
+	return doc, nil
+}
+
+// write replaces the data of the file with doc.data, as a Write by a
+// program does, recording a new checksum.
+func (doc *document) write() error {
+	b := mustEncode(doc.data)
+	return doc.db.Write(func(raw *json.RawMessage) error {
+		*raw = b
+		return nil
+	})
+}
+
+// close closes the file of a document from openDocument.
+// It may be called more than once.
+func (doc *document) close() error {
+	if doc.db == nil {
+		return nil
+	}
+	return doc.db.Close()
+}
+
+// readScript reads the operations of an apply script, one JSON object
+// per line. Blank lines are ignored.
+func readScript(path string) ([]op, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ops []op
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 64<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var o op
+		d := json.NewDecoder(bytes.NewReader(s.Bytes()))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&o); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if err := o.check(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ops = append(ops, o)
+	}
+	return ops, s.Err()
+}
+
+// check reports whether o has the members its operation needs.
+func (o *op) check() error {
+	if o.Path == nil {
+		return errors.New(`missing "path"`)
+	}
+	switch o.Op {
+	case "", "add", "replace", "test":
+		if o.Value == nil {
+			return errors.New(`missing "value"`)
+		}
+	case "remove":
+	case "move", "copy":
+		if o.From == nil {
+			return errors.New(`missing "from"`)
+		}
+	default:
+		return fmt.Errorf("unknown op %q", o.Op)
+	}
+	return nil
+}
+
+// apply applies o to v and returns the result. v may be modified.
+func (o *op) apply(v any) (any, error) {
+	path, err := parsePointer(*o.Path)
+	if err != nil {
+		return nil, err
+	}
+	var val any
+	if o.Value != nil {
+		if val, err = jsonvalue.Decode(o.Value); err != nil {
+			return nil, err
+		}
+	}
+	if len(path) == 0 && (o.Op == "" || o.Op == "add" || o.Op == "replace") {
+		return val, nil // the whole document
+	}
+	switch o.Op {
+	case "":
+		return update(v, path, true, func(c any, tok string) (any, error) { return set(c, tok, val) })
+	case "add":
+		return update(v, path, false, func(c any, tok string) (any, error) { return add(c, tok, val) })
+	case "remove":
+		return update(v, path, false, remove)
+	case "replace":
+		return update(v, path, false, func(c any, tok string) (any, error) {
+			if _, err := get(c, tok); err != nil {
+				return nil, err
+			}
+			return set(c, tok, val)
+		})
+	case "test":
+		got, err := lookup(v, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(got, val) {
+			return nil, fmt.Errorf("test failed: %s is %s", *o.Path, mustEncode(got))
+		}
+		return v, nil
+	}
+	from, err := parsePointer(*o.From)
+	if err != nil {
+		return nil, err
+	}
+	if val, err = lookup(v, from); err != nil {
+		return nil, err
+	}
+	if val, err = jsonvalue.Decode(mustEncode(val)); err != nil { // copy it
+		return nil, err
+	}
+	if o.Op == "move" {
+		if strings.HasPrefix(*o.Path+"/", *o.From+"/") && *o.Path != *o.From {
+			return nil, errors.New("cannot move a value into itself")
+		}
+		if v, err = update(v, from, false, remove); err != nil {
+			return nil, err
+		}
+	}
+	if len(path) == 0 {
+		return val, nil
+	}
+	return update(v, path, false, func(c any, tok string) (any, error) { return add(c, tok, val) })
+}
+
+func mustEncode(v any) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its tokens.
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", s)
+	}
+	toks := strings.Split(s[1:], "/")
+	for i, tok := range toks {
+		toks[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+	}
+	return toks, nil
+}
+
+// update calls leaf with the container holding the value at path, and
+// the last token of path, and stores the container leaf returns in its
+// place. If create is set, missing or null objects on the way are
+// created.
+// Only remove reaches update with the empty path, so it is an error.
+func update(v any, path []string, create bool, leaf func(c any, tok string) (any, error)) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+	if len(path) == 1 {
+		return leaf(v, path[0])
+	}
+	child, err := get(v, path[0])
+	if (err != nil || child == nil) && create {
+		child, err = map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if child, err = update(child, path[1:], create, leaf); err != nil {
+		return nil, err
+	}
+	return set(v, path[0], child)
+}
+
+func lookup(v any, path []string) (any, error) {
+	for _, tok := range path {
+		var err error
+		if v, err = get(v, tok); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func index(s []any, tok string, end bool) (int, error) {
+	if end && tok == "-" {
+		return len(s), nil
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || strings.Trim(tok, "0123456789") != "" || (tok != "0" && tok[0] == '0') || i > len(s) || (i == len(s) && !end) {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	return i, nil
+}
+
+func get(c any, tok string) (any, error) {
+	switch c := c.(type) {
+	case map[string]any:
+		v, ok := c[tok]
+		if !ok {
+			return nil, fmt.Errorf("no member %q", tok)
+		}
+		return v, nil
+	case []any:
+		i, err := index(c, tok, false)
+		if err != nil {
+			return nil, err
+		}
+		return c[i], nil
+	}
+	return nil, fmt.Errorf("cannot find %q in a value that is not an object or array", tok)
+}
+
+// set adds or replaces a member of an object, or replaces an element
+// of an array, or appends to it if tok is "-".
+func set(c any, tok string, v any) (any, error) {
+	switch c := c.(type) {
+	case map[string]any:
+		c[tok] = v
+		return c, nil
+	case []any:
+		i, err := index(c, tok, true)
+		if err != nil {
+			return nil, err
+		}
+		if i == len(c) {
+			return append(c, v), nil
+		}
+		c[i] = v
+		return c, nil
+	}
+	return nil, fmt.Errorf("cannot set %q in a value that is not an object or array", tok)
+}
+
+// add is set, except that it inserts into arrays.
+func add(c any, tok string, v any) (any, error) {
+	s, ok := c.([]any)
+	if !ok {
+		return set(c, tok, v)
+	}
+	i, err := index(s, tok, true)
+	if err != nil {
+		return nil, err
+	}
+	s = append(s, nil)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s, nil
+}
+
+func remove(c any, tok string) (any, error) {
+	if _, err := get(c, tok); err != nil {
+		return nil, err
+	}
+	switch c := c.(type) {
+	case map[string]any:
+		delete(c, tok)
+		return c, nil
+	case []any:
+		i, _ := index(c, tok, false)
+		return append(c[:i], c[i+1:]...), nil
+	}
+	panic("unreachable")
+}
+
+func apply(args []string) error {
+	fs := newFlagSet("apply", "[-n] [-key|-keyhex keyfile] [-hmac|-hmachex keyfile] [-dict dict] <path> <script.jsonl>")
+	dryRun := fs.Bool("n", false, "print the result instead of writing it")
+	ff := addFileFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path, script := fs.Arg(0), fs.Arg(1)
+
+	ops, err := readScript(script)
+	if err != nil {
+		return err
+	}
+	opts, err := ff.options()
+	if err != nil {
+		return err
+	}
+	doc, err := openDocument(path, opts...)
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+	for i, o := range ops {
+		if doc.data, err = o.apply(doc.data); err != nil {
+			return fmt.Errorf("operation %d: %w", i+1, err)
+		}
+	}
+	if *dryRun {
+		os.Stdout.Write(mustEncode(doc.data))
+		fmt.Println()
+		return nil
+	}
+	if err := doc.write(); err != nil {
+		return err
+	}
+	if err := doc.close(); err != nil {
+		return err
+	}
+	fmt.Printf("applied %d operations to %s\n", len(ops), path)
+	return nil
+}
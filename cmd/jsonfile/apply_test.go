@@ -0,0 +1,182 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+	type Limits struct{ MaxUsers int }
+	type DB struct {
+		Version int
+		Users   []string
+		Limits  *Limits
+		Old     string
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+	db, err := jsonfile.New[DB](path, jsonfile.WithSchemaFingerprint(nil), jsonfile.WithChecksum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Write(func(db *DB) error {
+		db.Version = 2
+		db.Users = []string{"a", "b", "c"}
+		db.Old = "x"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	script := filepath.Join(dir, "script.jsonl")
+	writeScript := func(lines ...string) {
+		t.Helper()
+		if err := os.WriteFile(script, []byte(strings.Join(lines, "\n")), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeScript(
+		`{"op":"test","path":"/Version","value":2}`,
+		`{"op":"remove","path":"/Users/1"}`,
+		`{"op":"add","path":"/Users/0","value":"z"}`,
+		``,
+		`{"path":"/Limits/MaxUsers","value":100}`,
+		`{"op":"move","from":"/Old","path":"/Users/-"}`,
+	)
+	if err := apply([]string{path, script}); err != nil {
+		t.Fatal(err)
+	}
+	db, err = jsonfile.Load[DB](path, jsonfile.WithSchemaFingerprint(nil), jsonfile.WithChecksum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if got := strings.Join(db.Users, ","); got != "z,a,c,x" {
+			t.Errorf("Users=%s", got)
+		}
+		if db.Limits == nil || db.Limits.MaxUsers != 100 || db.Old != "" {
+			t.Errorf("Limits=%v, Old=%q", db.Limits, db.Old)
+		}
+	})
+	db.Close()
+
+	// A failed operation leaves the file as it was.
+	before, _ := os.ReadFile(path)
+	writeScript(
+		`{"path":"/Version","value":3}`,
+		`{"op":"test","path":"/Version","value":2}`,
+	)
+	if err := apply([]string{path, script}); err == nil {
+		t.Error("apply with a failed test succeeded")
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("file changed by failed apply: %s", after)
+	}
+
+	writeScript(`{"op":"frob","path":"/Version"}`)
+	if err := apply([]string{path, script}); err == nil {
+		t.Error("apply with an unknown op succeeded")
+	}
+}
+
+// journaled writes a file at path with Val 1 and a journal that sets
+// it to 2, as a program that crashed before Close leaves them.
+func journaled(t *testing.T, path string) {
+	t.Helper()
+	type DB struct{ Val int }
+	db, err := jsonfile.NewWithDefault(path, DB{Val: 1}, jsonfile.WithJournal(1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Write(func(db *DB) error { db.Val = 2; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	wal, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if err := os.WriteFile(path, file, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".wal", wal, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyJournal(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path, script := filepath.Join(dir, "db.json"), filepath.Join(dir, "script.jsonl")
+	journaled(t, path)
+	if err := os.WriteFile(script, []byte(`{"op":"test","path":"/Val","value":2}`+"\n"+`{"path":"/Name","value":"x"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := apply([]string{path, script}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Errorf("journal not compacted by apply: %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"Name":"x","Val":2`) {
+		t.Errorf("applied file %s", b)
+	}
+}
+
+func TestApplyOps(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		doc, op, want string
+	}{
+		{`{"a":1}`, `{"op":"add","path":"","value":[1]}`, `[1]`},
+		{`{"a":{"b":1}}`, `{"op":"copy","from":"/a","path":"/c"}`, `{"a":{"b":1},"c":{"b":1}}`},
+		{`{"a/b":1}`, `{"op":"replace","path":"/a~1b","value":2}`, `{"a/b":2}`},
+		{`[1,2]`, `{"op":"add","path":"/-","value":3}`, `[1,2,3]`},
+		{`{"a":1}`, `{"op":"replace","path":"/b","value":2}`, ``},
+		{`{"a":{}}`, `{"op":"move","from":"/a","path":"/a/b"}`, ``},
+		{`[1]`, `{"op":"remove","path":"/01"}`, ``},
+		{`{}`, `{"op":"remove","path":""}`, ``},
+	}
+	for _, tt := range tests {
+		doc, err := jsonvalue.Decode([]byte(tt.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var o op
+		if err := json.Unmarshal([]byte(tt.op), &o); err != nil {
+			t.Fatal(err)
+		}
+		got, err := o.apply(doc)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s on %s = %s, want error", tt.op, tt.doc, mustEncode(got))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s on %s: %v", tt.op, tt.doc, err)
+		} else if string(mustEncode(got)) != tt.want {
+			t.Errorf("%s on %s = %s, want %s", tt.op, tt.doc, mustEncode(got), tt.want)
+		}
+	}
+}
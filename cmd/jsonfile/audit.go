@@ -0,0 +1,52 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// audit checks an audit log written with jsonfile.WithAuditLog against
+// the file it records. It only reads.
+func audit(args []string) error {
+	fs := newFlagSet("audit", "verify [-key|-keyhex keyfile] [-hmac|-hmachex keyfile] [-dict dict] <log> <path>")
+	ff := addFileFlags(fs)
+	if len(args) == 0 || args[0] != "verify" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fs.Parse(args[1:])
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	opts, err := ff.options()
+	if err != nil {
+		return err
+	}
+	doc, err := readDocument(fs.Arg(1), opts...)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := jsonfile.VerifyAuditLog(f, mustEncode(doc.data))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d records from %s to %s\n", r.Records, r.First.Format(time.RFC3339), r.Last.Format(time.RFC3339))
+	if r.Uncompleted > 0 {
+		fmt.Printf("%d records of changes not written to the file\n", r.Uncompleted)
+	}
+	fmt.Printf("log reproduces the file, sha256 %s\n", r.Sum)
+	return nil
+}
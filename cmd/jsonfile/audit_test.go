@@ -0,0 +1,44 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestAuditVerify(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val int
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+	log := filepath.Join(dir, "audit.log")
+	db, err := jsonfile.New[DB](path, jsonfile.WithAuditLog(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		i := i
+		if err := db.WriteAs("alice", func(db *DB) error { db.Val = i; return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	if err := audit([]string{"verify", log, path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"Val":4}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit([]string{"verify", log, path}); err == nil {
+		t.Error("no error for a change missing from the log")
+	}
+}
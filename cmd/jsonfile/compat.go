@@ -0,0 +1,63 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// compat compares two schemas saved as JSON from jsonfile.SchemaOf,
+// failing if files with the old one do not load with the new one.
+func compat(args []string) error {
+	fs := newFlagSet("compat", "[-migrated fingerprint,...] <old.json> <new.json>")
+	migrated := fs.String("migrated", "", "comma-separated fingerprints of schemas with a registered migration")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	old, err := readSchema(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	new, err := readSchema(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	var fps []string
+	if *migrated != "" {
+		fps = strings.Split(*migrated, ",")
+	}
+
+	r := jsonfile.CheckCompat(old, new, fps...)
+	fmt.Printf("schema %s to %s\n", r.Old, r.New)
+	for _, c := range r.Changes {
+		fmt.Printf("\t%s\n", c)
+	}
+	if r.Migrated {
+		fmt.Printf("migration registered for %s\n", r.Old)
+	}
+	if r.Err() != nil {
+		return errors.New("breaking changes without a migration")
+	}
+	return nil
+}
+
+func readSchema(path string) (*jsonfile.Schema, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := new(jsonfile.Schema)
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestCompat(t *testing.T) {
+	t.Parallel()
+	type V1 struct {
+		Name  string
+		Count int32
+	}
+	type V2 struct {
+		Name  string
+		Count int64
+		Tags  []string
+	}
+	type V3 struct {
+		Name  int
+		Count int64
+	}
+
+	dir := t.TempDir()
+	save := func(name string, s *jsonfile.Schema) string {
+		t.Helper()
+		b, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	v1 := save("v1.json", jsonfile.SchemaOf[V1]())
+	v2 := save("v2.json", jsonfile.SchemaOf[V2]())
+	v3 := save("v3.json", jsonfile.SchemaOf[V3]())
+
+	if err := compat([]string{v1, v2}); err != nil {
+		t.Errorf("v1 to v2: %v", err)
+	}
+	if err := compat([]string{v2, v3}); err == nil {
+		t.Error("v2 to v3: no error for breaking changes")
+	}
+	fp := jsonfile.SchemaOf[V2]().Fingerprint()
+	if err := compat([]string{"-migrated", fp, v2, v3}); err != nil {
+		t.Errorf("v2 to v3 with migration: %v", err)
+	}
+}
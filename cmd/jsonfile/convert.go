@@ -0,0 +1,82 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// convert rewrites a file as plain JSON, compressed, or encrypted,
+// reading and writing it with the options of the jsonfile package.
+func convert(args []string) error {
+	fs := newFlagSet("convert", "[-key|-keyhex keyfile] [-hmac|-hmachex keyfile] [-dict dict] [-compress none|gzip|dict] [-encrypt|-encrypthex keyfile] <path>")
+	ff := addFileFlags(fs)
+	compress := fs.String("compress", "none", "compress the result with `method`: none, gzip, or dict, with the dictionary of -dict")
+	encrypt := addKeyFlag(fs, "encrypt", "encrypt the result with the key")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+
+	from, err := ff.options()
+	if err != nil {
+		return err
+	}
+	format, err := jsonfile.ReadFormat(path, from...)
+	if err != nil {
+		return err
+	}
+	// The result keeps the envelope of the file. Its journal, which the
+	// result includes, is removed, as it cannot be used with encryption.
+	to := append(format.Options(), jsonfile.WithJournal(0))
+	switch *compress {
+	case "none":
+		to = append(to, jsonfile.WithCompression(nil))
+	case "gzip":
+		to = append(to, jsonfile.WithCompression(jsonfile.Gzip))
+	case "dict":
+		if *ff.dict == "" {
+			return errors.New("-compress dict needs -dict")
+		}
+		d, err := os.ReadFile(*ff.dict)
+		if err != nil {
+			return err
+		}
+		to = append(to, jsonfile.WithCompression(jsonfile.DeflateDict(d)))
+	default:
+		return fmt.Errorf("unknown compression %q", *compress)
+	}
+	if k, err := encrypt.read(); err != nil {
+		return err
+	} else if k == nil {
+		to = append(to, jsonfile.WithCipher(nil))
+	} else {
+		to = append(to, jsonfile.WithEncryption(k))
+	}
+
+	// The data is kept as it was written, so fields the program's Data
+	// type knows nothing of survive the conversion.
+	doc, err := readDocument(path, from...)
+	if err != nil {
+		return err
+	}
+	dst, err := jsonfile.NewWithDefault(path, json.RawMessage(doc.raw), to...)
+	if err != nil {
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if format.Journal {
+		return os.Remove(path + ".wal")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path, keyPath := filepath.Join(dir, "db.json"), filepath.Join(dir, "key")
+	key := bytes.Repeat([]byte{7}, 32)
+	if err := os.WriteFile(keyPath, []byte("0707070707070707070707070707070707070707070707070707070707070707\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	type data struct{ Name string }
+	db, err := jsonfile.NewWithDefault(path, data{Name: "Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Compress and encrypt, then convert back to plain JSON.
+	if err := convert([]string{"-compress", "gzip", "-encrypthex", keyPath, path}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isPlainJSON(b) {
+		t.Errorf("converted file is plain JSON: %q", b)
+	}
+	db, err = jsonfile.Load[data](path, jsonfile.WithCompression(jsonfile.Gzip), jsonfile.WithEncryption(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(d *data) {
+		if d.Name != "Alice" {
+			t.Errorf("encrypted Name=%q, want Alice", d.Name)
+		}
+	})
+	db.Close()
+	if err := convert([]string{path}); err == nil {
+		t.Error("convert of an encrypted file without -key succeeded")
+	}
+	if err := convert([]string{"-keyhex", keyPath, path}); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if !isPlainJSON(b) || !bytes.Contains(b, []byte(`"Name":"Alice"`)) {
+		t.Errorf("plain file %q", b)
+	}
+
+	// The journal is included in the result, and removed.
+	journaled(t, path)
+	if err := convert([]string{"-compress", "gzip", path}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Errorf("journal not removed by convert: %v", err)
+	}
+	db2, err := jsonfile.Load[struct{ Val int }](path, jsonfile.WithCompression(jsonfile.Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db2.Read(func(d *struct{ Val int }) {
+		if d.Val != 2 {
+			t.Errorf("converted Val=%d, want 2 from the journal", d.Val)
+		}
+	})
+	db2.Close()
+
+	if err := convert([]string{"-compress", "dict", path}); err == nil {
+		t.Error("-compress dict without -dict succeeded")
+	}
+	if err := convert([]string{"-compress", "zip", path}); err == nil {
+		t.Error("-compress zip succeeded")
+	}
+
+	// A raw key is used as it is, even if it is valid hex.
+	rawKey := []byte("0123456789abcdef0123456789abcdef")
+	if err := os.WriteFile(keyPath, rawKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := convert([]string{"-encrypt", keyPath, path}); err != nil {
+		t.Fatal(err)
+	}
+	db2, err = jsonfile.Load[struct{ Val int }](path, jsonfile.WithEncryption(rawKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db2.Close()
+	if err := convert([]string{"-key", keyPath, "-keyhex", keyPath, path}); err == nil {
+		t.Error("convert with -key and -keyhex succeeded")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// decodeStats reports the decoding issues in event logs written by
+// jsonfile.WithEvents with jsonfile.WithDecodeStats, most frequent
+// first.
+func decodeStats(args []string) error {
+	fs := newFlagSet("decode-stats", "[-file path] <events>...")
+	file := fs.String("file", "", "only count reads of the data file at `path`")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return printDecodeStats(os.Stdout, fs.Args(), *file)
+}
+
+// printDecodeStats writes to w the counts of issues in the event logs
+// named by names, for reads of the data file at file, or of any file.
+func printDecodeStats(w io.Writer, names []string, file string) error {
+	type issue struct{ kind, path string }
+	counts := make(map[issue]int)
+	reads := 0
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		s := bufio.NewScanner(f)
+		s.Buffer(nil, 16<<20)
+		for s.Scan() {
+			var ev jsonfile.Event
+			if json.Unmarshal(s.Bytes(), &ev) != nil || ev.Event != "decoded" {
+				continue // other events, or a line cut short
+			}
+			if file != "" && ev.Path != file {
+				continue
+			}
+			reads++
+			for _, p := range ev.Unknown {
+				counts[issue{"unknown", p}]++
+			}
+			for _, p := range ev.Mismatched {
+				counts[issue{"mismatched", p}]++
+			}
+		}
+		err = s.Err()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	issues := make([]issue, 0, len(counts))
+	for is := range counts {
+		issues = append(issues, is)
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		return a.kind < b.kind
+	})
+	fmt.Fprintf(w, "%d reads with issues\n", reads)
+	for _, is := range issues {
+		fmt.Fprintf(w, "%8d  %-10s  %s\n", counts[is], is.kind, is.path)
+	}
+	return nil
+}
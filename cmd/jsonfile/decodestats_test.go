@@ -0,0 +1,47 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestDecodeStats(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Name string
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+	if err := os.WriteFile(path, []byte(`{"Name":"a","Old":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	events, err := os.Create(filepath.Join(dir, "events.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+	db, err := jsonfile.Load[DB](path, jsonfile.WithDecodeStats(), jsonfile.WithEvents(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	var out bytes.Buffer
+	if err := printDecodeStats(&out, []string{events.Name()}, path); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2 reads with issues\n       2  unknown     /Old\n"; out.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// dict trains a compression dictionary for jsonfile.DeflateDict from
+// a set of files, and reports how much it saves on them.
+func dict(args []string) error {
+	fs := newFlagSet("dict", "[-size bytes] -o <dict> <file>...")
+	size := fs.Int("size", 32<<10, "most bytes in the dictionary")
+	out := fs.String("o", "", "write the dictionary to this `file`")
+	fs.Parse(args)
+	if fs.NArg() == 0 || *out == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var samples [][]byte
+	for _, path := range fs.Args() {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !validJSON(b) || !isPlainJSON(b) {
+			return fmt.Errorf("%s: not an uncompressed JSON file", path)
+		}
+		samples = append(samples, b)
+	}
+
+	d := jsonfile.TrainDictionary(samples, *size)
+	if err := replaceFile(*out, d); err != nil {
+		return err
+	}
+	var total, alone, with int
+	plain, dicted := jsonfile.DeflateDict(nil), jsonfile.DeflateDict(d)
+	for _, b := range samples {
+		cb, err := plain.Compress(b)
+		if err != nil {
+			return err
+		}
+		db, err := dicted.Compress(b)
+		if err != nil {
+			return err
+		}
+		total, alone, with = total+len(b), alone+len(cb), with+len(db)
+	}
+	fmt.Printf("wrote %d byte dictionary to %s\n", len(d), *out)
+	fmt.Printf("%d files, %d bytes: %d bytes compressed alone, %d bytes with the dictionary\n", len(samples), total, alone, with)
+	return nil
+}
+
+// isPlainJSON reports whether b starts as JSON does, not with the
+// magic bytes of compression or encryption.
+func isPlainJSON(b []byte) bool {
+	for _, c := range b {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{', '[', '"', 't', 'f', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			return true
+		}
+		return false
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDict(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	var args []string
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("tenant%d.json", i))
+		b := fmt.Sprintf(`{"Name":"tenant-%d","Plan":"team","Settings":{"notifications":true,"darkMode":false}}`, i)
+		if err := os.WriteFile(path, []byte(b), 0600); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, path)
+	}
+	out := filepath.Join(dir, "dict")
+	if err := dict(append([]string{"-size", "1024", "-o", out}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	d, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d) == 0 || len(d) > 1024 {
+		t.Errorf("dictionary of %d bytes", len(d))
+	}
+
+	gz := filepath.Join(dir, "x.json")
+	if err := os.WriteFile(gz, []byte("jfz\x01xxxxxxxxx"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := dict([]string{"-o", out, gz}); err == nil {
+		t.Error("no error for a compressed file")
+	}
+}
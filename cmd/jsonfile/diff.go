@@ -0,0 +1,119 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// diff lists the changes from one version of a file to another.
+func diff(args []string) error {
+	fs := newFlagSet("diff", "[-gen n] [-key|-keyhex keyfile] [-hmac|-hmachex keyfile] [-dict dict] <path> [<new>]")
+	gen := fs.Uint64("gen", 0, "compare version `n` in the history log with the file")
+	ff := addFileFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 || fs.NArg() == 2 && *gen != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+	opts, err := ff.options()
+	if err != nil {
+		return err
+	}
+	cur, err := readDocument(path, opts...)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fs.NArg() == 2:
+		newer, err := readDiffable(fs.Arg(1), opts)
+		if err != nil {
+			return err
+		}
+		return printDiff(os.Stdout, path, fs.Arg(1), cur.data, newer.data)
+	case *gen != 0:
+		old, err := historyVersion(path, *gen)
+		if err != nil {
+			return err
+		}
+		return printDiff(os.Stdout, fmt.Sprintf("%s gen %d", path, *gen), path, old, cur.data)
+	default:
+		backup, err := newestBackup(path)
+		if err != nil {
+			return err
+		}
+		old, err := readDiffable(backup, opts)
+		if err != nil {
+			return err
+		}
+		return printDiff(os.Stdout, backup, path, old.data, cur.data)
+	}
+}
+
+// readDiffable reads a file to compare, which may be a full backup but
+// not a differential one.
+func readDiffable(path string, opts []jsonfile.Option) (*document, error) {
+	if strings.HasSuffix(path, ".patch") {
+		return nil, fmt.Errorf("%s is a differential backup, read it with jsonfile.OpenRevision", path)
+	}
+	return readDocument(path, opts...)
+}
+
+// newestBackup returns the newest recovery backup or rotated backup
+// of the file at path.
+func newestBackup(path string) (string, error) {
+	cands, err := inventory(path, "")
+	if err != nil {
+		return "", err
+	}
+	for _, c := range cands { // newest first
+		if c.Kind == "bak" && c.Problem == "" {
+			return c.Path, nil
+		}
+	}
+	return "", errors.New("no backup of the file to compare with, give one or use -gen")
+}
+
+// historyVersion returns the data of version gen in the history log.
+func historyVersion(path string, gen uint64) (any, error) {
+	var data any
+	found := false
+	err := replayHistory(path, func(_, cur *version) error {
+		if cur.gen == gen {
+			data, found = cur.data, true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no version %d in the history log", gen)
+	}
+	return data, nil
+}
+
+// printDiff writes the changes from old, named oldName, to new.
+func printDiff(w io.Writer, oldName, newName string, old, new any) error {
+	var changes []string
+	diffValues(&changes, old, new)
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName)
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "no changes")
+		return err
+	}
+	for _, c := range changes {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	path := writeHistory(t)
+
+	backup, err := newestBackup(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup != path+".bak" {
+		t.Errorf("newest backup %s", backup)
+	}
+	old, err := readDiffable(backup, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := readDocument(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := printDiff(&buf, backup, path, old.data, cur.data); err != nil {
+		t.Fatal(err)
+	}
+	want := "--- " + backup + "\n+++ " + path + "\n~ /Name: \"a\" -> \"b\"\n- /Tags/x: 1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("diff with backup:\n%s\nwant:\n%s", got, want)
+	}
+
+	v2, err := historyVersion(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := printDiff(&buf, "gen 2", path, v2, cur.data); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, "+ /Tags: {\"y\":2}\n") || !strings.Contains(got, `~ /Name: "a" -> "b"`) {
+		t.Errorf("diff with gen 2:\n%s", got)
+	}
+	if _, err := historyVersion(path, 9); err == nil {
+		t.Error("historyVersion of a missing version succeeded")
+	}
+
+	buf.Reset()
+	if err := printDiff(&buf, path, path, cur.data, cur.data); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.HasSuffix(got, "no changes\n") {
+		t.Errorf("diff of the same data:\n%s", got)
+	}
+	if _, err := readDiffable(path+".20240102T030405.000000000Z.patch", nil); err == nil {
+		t.Error("readDiffable of a differential backup succeeded")
+	}
+}
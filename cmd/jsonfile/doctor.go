@@ -0,0 +1,223 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// A candidate is a copy of a file that may be used to recover it.
+type candidate struct {
+	Path    string
+	Kind    string // "primary", "temp", "bak", "corrupt", "rev", or "backup"
+	ModTime time.Time
+	Size    int64
+	Problem string // why the copy is not usable, or ""
+}
+
+// inventory finds every copy of the file at path: the file itself,
+// temporary files left by an interrupted Write, the copies kept by
+// jsonfile.WithRecoveryBackup, WithBackups, and LoadWithRecovery, and
+// backups in backupDir, if set. The result is sorted with usable
+// copies first, newest first.
+func inventory(path, backupDir string) ([]candidate, error) {
+	var cands []candidate
+	add := func(p, kind string) {
+		c := candidate{Path: p, Kind: kind}
+		fi, err := os.Stat(p)
+		if err != nil {
+			c.Problem = err.Error()
+			cands = append(cands, c)
+			return
+		}
+		c.ModTime, c.Size = fi.ModTime(), fi.Size()
+		b, err := os.ReadFile(p)
+		switch {
+		case err != nil:
+			c.Problem = err.Error()
+		case len(b) == 0:
+			c.Problem = "empty"
+		case !validJSON(b):
+			c.Problem = "invalid JSON"
+		case kind == "backup" && strings.HasSuffix(p, ".patch"):
+			c.Problem = "differential backup, read with OpenRevision"
+		}
+		cands = append(cands, c)
+	}
+
+	add(path, "primary")
+	temps, err := filepath.Glob(globEscape(path) + ".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range temps {
+		add(p, "temp")
+	}
+	for _, ext := range []string{".bak", ".corrupt"} {
+		if _, err := os.Stat(path + ext); err == nil {
+			add(path+ext, ext[1:])
+		}
+	}
+	revs, err := filepath.Glob(globEscape(path) + ".rev-*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range revs {
+		add(p, "rev")
+	}
+	rotated, err := filepath.Glob(globEscape(path) + ".[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range rotated {
+		if strings.Trim(p[len(path)+1:], "0123456789") == "" {
+			add(p, "bak")
+		}
+	}
+	if backupDir != "" {
+		entries, err := os.ReadDir(backupDir)
+		if err != nil {
+			return nil, err
+		}
+		prefix := filepath.Base(path) + "."
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), prefix) && e.Type().IsRegular() {
+				add(filepath.Join(backupDir, e.Name()), "backup")
+			}
+		}
+	}
+
+	sort.SliceStable(cands, func(i, j int) bool {
+		if (cands[i].Problem == "") != (cands[j].Problem == "") {
+			return cands[i].Problem == ""
+		}
+		return cands[i].ModTime.After(cands[j].ModTime)
+	})
+	return cands, nil
+}
+
+// validJSON reports whether b holds JSON, decompressing it if it was
+// written WithCompression(jsonfile.Gzip). Files encrypted by
+// WithEncryption or with age, or compressed by jsonfile.DeflateDict,
+// cannot be checked, and are reported valid.
+func validJSON(b []byte) bool {
+	if bytes.HasPrefix(b, []byte("jfe\x01")) || bytes.HasPrefix(b, []byte("jfz\x01")) || bytes.HasPrefix(b, []byte("age-encryption.org/")) || bytes.HasPrefix(b, []byte("-----BEGIN AGE ENCRYPTED FILE-----")) {
+		return true
+	}
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return false
+		}
+		if b, err = io.ReadAll(r); err != nil {
+			return false
+		}
+	}
+	return json.Valid(b)
+}
+
+func globEscape(path string) string {
+	r := strings.NewReplacer(`*`, `\*`, `?`, `\?`, `[`, `\[`)
+	return r.Replace(path)
+}
+
+// restore atomically replaces the file at path with the copy at src.
+func restore(path, src string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if !validJSON(b) {
+		return fmt.Errorf("%s: invalid JSON", src)
+	}
+	return replaceFile(path, b)
+}
+
+// replaceFile atomically replaces the file at path with b, as the
+// jsonfile package does.
+func replaceFile(path string, b []byte) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func printInventory(w io.Writer, cands []candidate) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "#\tKIND\tMODIFIED\tSIZE\tSTATUS\tPATH\n")
+	for i, c := range cands {
+		status, mod := "ok", "-"
+		if c.Problem != "" {
+			status = c.Problem
+		}
+		if !c.ModTime.IsZero() {
+			mod = c.ModTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\n", i, c.Kind, mod, c.Size, status, c.Path)
+	}
+	tw.Flush()
+}
+
+func doctor(args []string) error {
+	fs := newFlagSet("doctor", "[-backups dir] [-restore n] <path>")
+	backupDir := fs.String("backups", "", "directory of backups made by WithScheduledBackups")
+	restoreN := fs.Int("restore", -1, "restore the copy numbered `n` in the listing")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+
+	cands, err := inventory(path, *backupDir)
+	if err != nil {
+		return err
+	}
+	if *restoreN < 0 {
+		printInventory(os.Stdout, cands)
+		return nil
+	}
+	if *restoreN >= len(cands) {
+		return fmt.Errorf("no copy numbered %d", *restoreN)
+	}
+	c := cands[*restoreN]
+	if c.Problem != "" {
+		return fmt.Errorf("cannot restore %s: %s", c.Path, c.Problem)
+	}
+	if c.Kind == "primary" {
+		return errors.New("the primary file is already in place")
+	}
+	if err := restore(path, c.Path); err != nil {
+		return err
+	}
+	fmt.Printf("restored %s from %s\n", path, c.Path)
+	return nil
+}
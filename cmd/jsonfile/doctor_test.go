@@ -0,0 +1,66 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestDoctor(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+	backupDir := filepath.Join(dir, "backups")
+	db, err := jsonfile.New[DB](path, jsonfile.WithScheduledBackups(backupDir, 0, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Write(func(db *DB) error { db.Val = 1; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the primary and leave a stale temp file.
+	if err := os.WriteFile(path, []byte(`{"Val":`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".tmp123", nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	cands, err := inventory(path, backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cands) != 4 {
+		t.Fatalf("got %d candidates, want 4: %+v", len(cands), cands)
+	}
+	best := cands[0]
+	if best.Kind != "backup" || best.Problem != "" {
+		t.Fatalf("best candidate %+v, want usable backup", best)
+	}
+	for _, c := range cands[2:] {
+		if c.Problem == "" {
+			t.Errorf("candidate %s usable, want problem", c.Path)
+		}
+	}
+
+	if err := restore(path, best.Path); err != nil {
+		t.Fatal(err)
+	}
+	db, err = jsonfile.Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 1 {
+			t.Errorf("restored Val=%d, want 1", db.Val)
+		}
+	})
+}
@@ -0,0 +1,183 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// backupTimeFormat is the time format of backup names, from FORMAT.md.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// An editor edits a file, as the edit command does.
+type editor struct {
+	edit      func(path string) error // runs the editor on path
+	in        *bufio.Reader           // answers to questions
+	out       io.Writer
+	backupDir string
+	yes       bool              // write without asking
+	opts      []jsonfile.Option // to read the file, from fileFlags
+}
+
+func (e *editor) ask(question string) bool {
+	if e.yes {
+		return true
+	}
+	fmt.Fprintf(e.out, "%s [y/n] ", question)
+	line, _ := e.in.ReadString('\n')
+	return strings.HasPrefix(strings.TrimSpace(strings.ToLower(line)), "y")
+}
+
+// run edits the file at path. The data is edited as indented JSON in
+// a temporary file. The result must be valid JSON, and the file must
+// not have changed while it was edited.
+func (e *editor) run(path string) error {
+	orig, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	doc, err := openDocument(path, e.opts...)
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+	text, err := json.MarshalIndent(doc.data, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp("", "jsonfile-edit-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(text, '\n'))
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+
+	var data any
+	for {
+		if err := e.edit(tmp.Name()); err != nil {
+			return fmt.Errorf("editor: %w", err)
+		}
+		b, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		if data, err = jsonvalue.Decode(b); err == nil {
+			break
+		}
+		fmt.Fprintf(e.out, "invalid JSON: %v\n", err)
+		if e.yes || !e.ask("edit again?") {
+			return errors.New("file not changed")
+		}
+	}
+
+	var changes []string
+	diffValues(&changes, doc.data, data)
+	if len(changes) == 0 {
+		fmt.Fprintln(e.out, "no changes")
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Fprintln(e.out, c)
+	}
+	if !e.ask(fmt.Sprintf("write %d changes to %s?", len(changes), path)) {
+		return errors.New("file not changed")
+	}
+
+	doc.data = data
+	if now, err := os.ReadFile(path); err != nil {
+		return err
+	} else if !bytes.Equal(now, orig) {
+		return errors.New("file changed while it was edited, not written")
+	}
+	dir := e.backupDir
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+	backup := filepath.Join(dir, filepath.Base(path)+"."+time.Now().UTC().Format(backupTimeFormat))
+	if err := replaceFile(backup, orig); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	if err := doc.write(); err != nil {
+		return err
+	}
+	if err := doc.close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "wrote %s, backup in %s\n", path, backup)
+	return nil
+}
+
+// diffValues appends to changes a line for each value that differs
+// between a and b, named by its JSON Pointer.
+func diffValues(changes *[]string, a, b any) {
+	jsonvalue.Diff(a, b, func(op, ptr string, old, new any) error {
+		switch op {
+		case "add":
+			*changes = append(*changes, fmt.Sprintf("+ %s: %s", ptr, mustEncode(new)))
+		case "remove":
+			*changes = append(*changes, fmt.Sprintf("- %s: %s", ptr, mustEncode(old)))
+		default:
+			if ptr == "" {
+				ptr = `""`
+			}
+			*changes = append(*changes, fmt.Sprintf("~ %s: %s -> %s", ptr, mustEncode(old), mustEncode(new)))
+		}
+		return nil
+	})
+}
+
+func edit(args []string) error {
+	fs := newFlagSet("edit", "[-backups dir] [-y] [-key|-keyhex keyfile] [-hmac|-hmachex keyfile] [-dict dict] <path>")
+	backupDir := fs.String("backups", "", "directory to back up the file in, by default its own")
+	yes := fs.Bool("y", false, "write the changes without asking")
+	ff := addFileFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	opts, err := ff.options()
+	if err != nil {
+		return err
+	}
+	e := &editor{
+		edit:      runEditor,
+		in:        bufio.NewReader(os.Stdin),
+		out:       os.Stdout,
+		backupDir: *backupDir,
+		yes:       *yes,
+		opts:      opts,
+	}
+	return e.run(fs.Arg(0))
+}
+
+// runEditor runs $EDITOR, or vi, on path.
+func runEditor(path string) error {
+	args := strings.Fields(os.Getenv("EDITOR"))
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
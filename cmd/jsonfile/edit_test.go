@@ -0,0 +1,74 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestEdit(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Name string
+		Vals []int
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+	backupDir := filepath.Join(dir, "backups")
+	db, err := jsonfile.New[DB](path, jsonfile.WithScheduledBackups(backupDir, time.Hour, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Write(func(db *DB) error { db.Name = "a"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	edits := []string{`{"Name": "b", "Vals": [`, `{"Name": "b", "Vals": [1]}`}
+	var out bytes.Buffer
+	e := &editor{
+		edit: func(p string) error {
+			b := edits[0]
+			edits = edits[1:]
+			return os.WriteFile(p, []byte(b), 0666)
+		},
+		in:        bufio.NewReader(strings.NewReader("y\ny\n")),
+		out:       &out,
+		backupDir: backupDir,
+	}
+	if err := e.run(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"invalid JSON", `~ /Name: "a" -> "b"`, "~ /Vals: null -> [1]"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, out.String())
+		}
+	}
+
+	db, err = jsonfile.Load[DB](path, jsonfile.WithScheduledBackups(backupDir, time.Hour, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Name != "b" || len(db.Vals) != 1 {
+			t.Errorf("got %+v after edit", *db)
+		}
+	})
+	names, err := db.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Errorf("backups %v, want the edit's and the package's", names)
+	}
+}
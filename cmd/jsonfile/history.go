@@ -0,0 +1,94 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// A version is the data of a file as recorded by a history log.
+type version struct {
+	gen  uint64
+	time time.Time
+	data any
+}
+
+// replayHistory calls fn with each version in the history log of the
+// file at path, and the version before it, or nil for the first.
+// fn must not modify the versions.
+func replayHistory(path string, fn func(prev, cur *version) error) error {
+	versions, err := jsonfile.ReadHistory(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s has no history log; it is kept by jsonfile.WithHistory", path)
+	} else if err != nil {
+		return err
+	}
+	var prev *version
+	for _, v := range versions {
+		cur := &version{gen: v.Gen, time: v.Time}
+		if cur.data, err = jsonvalue.Decode(v.Data); err != nil {
+			return fmt.Errorf("history gen %d: %w", v.Gen, err)
+		}
+		if err := fn(prev, cur); err != nil {
+			return err
+		}
+		prev = cur
+	}
+	return nil
+}
+
+// printHistory writes the last n versions in the history log of the
+// file at path, or all of them if n is 0, each with what changed.
+func printHistory(w io.Writer, path string, n int) error {
+	type entry struct {
+		cur     *version
+		first   bool
+		changes []string
+	}
+	var entries []entry
+	err := replayHistory(path, func(prev, cur *version) error {
+		e := entry{cur: cur, first: prev == nil}
+		if prev != nil {
+			diffValues(&e.changes, prev.data, cur.data)
+		}
+		entries = append(entries, e)
+		if n > 0 && len(entries) > n {
+			entries = entries[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		fmt.Fprintf(bw, "gen %d\t%s\n", e.cur.gen, e.cur.time.UTC().Format(time.RFC3339Nano))
+		if e.first {
+			fmt.Fprintf(bw, "\tfirst version: %s\n", mustEncode(e.cur.data))
+		}
+		for _, c := range e.changes {
+			fmt.Fprintf(bw, "\t%s\n", c)
+		}
+	}
+	return bw.Flush()
+}
+
+func history(args []string) error {
+	fs := newFlagSet("history", "[-n count] <path>")
+	n := fs.Int("n", 0, "list only the last `count` versions")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return printHistory(os.Stdout, fs.Arg(0), *n)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+type historyDB struct {
+	Name string
+	Tags map[string]int `json:",omitempty"`
+}
+
+// writeHistory writes a file with a history log of four versions.
+func writeHistory(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "db.json")
+	db, err := jsonfile.New[historyDB](path, jsonfile.WithHistory(), jsonfile.WithRecoveryBackup())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range []func(*historyDB){
+		func(db *historyDB) { db.Name = "a" },
+		func(db *historyDB) { db.Tags = map[string]int{"x": 1, "y": 2} },
+		func(db *historyDB) { delete(db.Tags, "x"); db.Name = "b" },
+	} {
+		if err := db.Write(func(db *historyDB) error { fn(db); return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+	return path
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	path := writeHistory(t)
+
+	var buf strings.Builder
+	if err := printHistory(&buf, path, 0); err != nil {
+		t.Fatal(err)
+	}
+	got := regexp.MustCompile(`\t\d{4}-[^\n]*`).ReplaceAllString(buf.String(), "\tTIME")
+	want := `gen 1	TIME
+	first version: {"Name":""}
+gen 2	TIME
+	~ /Name: "" -> "a"
+gen 3	TIME
+	+ /Tags: {"x":1,"y":2}
+gen 4	TIME
+	~ /Name: "a" -> "b"
+	- /Tags/x: 1
+`
+	if got != want {
+		t.Errorf("history:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := printHistory(&buf, path, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.HasPrefix(got, "gen 4\t") || strings.Contains(got, "gen 3") {
+		t.Errorf("history -n 1:\n%s", got)
+	}
+
+	if err := printHistory(&buf, filepath.Join(t.TempDir(), "none.json"), 0); err == nil {
+		t.Error("history of a file without a log succeeded")
+	}
+}
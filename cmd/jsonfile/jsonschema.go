@@ -0,0 +1,36 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+)
+
+// jsonSchema writes the JSON Schema of a schema saved as JSON from
+// jsonfile.SchemaOf.
+func jsonSchema(args []string) error {
+	fs := newFlagSet("jsonschema", "[-o out.json] <schema.json>")
+	out := fs.String("o", "", "write the JSON Schema to `file` instead of stdout")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	s, err := readSchema(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, s.JSONSchema(), "", "\t"); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	if *out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(*out, buf.Bytes(), 0666)
+}
@@ -0,0 +1,50 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestJSONSchema(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Name  string
+		Count uint8
+	}
+	dir := t.TempDir()
+	b, err := json.Marshal(jsonfile.SchemaOf[DB]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, out := filepath.Join(dir, "schema.json"), filepath.Join(dir, "out.json")
+	if err := os.WriteFile(in, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := jsonSchema([]string{"-o", out, in}); err != nil {
+		t.Fatal(err)
+	}
+	b, err = os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Type       string
+		Properties map[string]struct {
+			Type    string
+			Maximum int
+		}
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "object" || got.Properties["Name"].Type != "string" || got.Properties["Count"].Maximum != 255 {
+		t.Errorf("JSON Schema:\n%s", b)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Command jsonfile inspects and repairs files written by the jsonfile
+// package.
+//
+// Usage:
+//
+//	jsonfile <command> [flags] <path>
+//
+// The commands are:
+//
+//	apply        apply a script of changes to a file
+//	audit        verify an audit log against its file
+//	compat       check that files load after a change to the Data type
+//	convert      rewrite a file as plain JSON, compressed, or encrypted
+//	decode-stats count the decoding issues in event logs
+//	dict         train a compression dictionary from files
+//	diff         list the changes between two versions of a file
+//	doctor       list recoverable copies of a file and restore one
+//	edit         edit a file in $EDITOR
+//	history      list the versions of a file in its history log
+//	jsonschema   write the JSON Schema of a Data type
+//	migrate-dir  apply a script of changes to every file in a directory
+//	show         print the data of a file as indented JSON
+//	validate     check that a file loads, and matches a Data type
+//
+// The script given to apply has one operation per line, either a JSON
+// Patch (RFC 6902) operation or a set of the value at a JSON Pointer:
+//
+//	{"op":"test","path":"/Version","value":2}
+//	{"op":"remove","path":"/Users/3"}
+//	{"path":"/Limits/MaxUsers","value":100}
+//
+// The operations are all applied, or none are.
+//
+// Edit opens the data of a file as indented JSON in $EDITOR, checks
+// that the result is valid JSON, lists the changes, and after asking,
+// writes the file and keeps a backup that doctor can restore.
+//
+// Audit verify replays an audit log written with jsonfile.WithAuditLog
+// from its first record and checks that no record is missing or
+// corrupt and that the log reproduces the data of the file.
+//
+// Compat compares the schemas of two versions of a Data type, saved as
+// JSON from jsonfile.SchemaOf, and fails if files written with the old
+// one may not load with the new one. It is meant to run in CI.
+//
+// The commands read and write files with the jsonfile package, which
+// finds from a file its envelope, checksum and gzip compression. What
+// cannot be found from the file is given with flags: -key for a file
+// encrypted with jsonfile.WithEncryption, -hmac for one with an HMAC
+// checksum from jsonfile.WithChecksum, and -dict for one compressed
+// with jsonfile.DeflateDict, each naming a file. A key file holds the
+// raw bytes of the key; for one that holds it hex encoded, use -keyhex
+// or -hmachex instead.
+//
+// Convert loads a file with the jsonfile package and writes it again
+// with other options, atomically replacing it. Without flags it writes
+// plain JSON. -compress gzip or dict compresses it, with jsonfile.Gzip
+// or jsonfile.DeflateDict, and -encrypt or -encrypthex encrypts it, as
+// jsonfile.WithEncryption does, with the key in a file. Backups are not
+// converted. The jsonfile package cannot read zstd
+// without a dependency, so programs using a zstd adapter should
+// convert with a small program of their own.
+//
+// Decode-stats reads event logs written by jsonfile.WithEvents with
+// jsonfile.WithDecodeStats, and lists the members found in files with
+// no field in the Data type, or of the wrong type, by how many reads
+// found them. Fix or remove them before using jsonfile.WithStrictDecoding.
+//
+// Dict trains a dictionary for jsonfile.DeflateDict from a set of
+// uncompressed files, such as the files of a jsonfile.Dir, and reports
+// the space it saves on them.
+//
+// Diff lists the changes from the data of one file to another, such as
+// from a backup to the file. Given one file, it compares its newest
+// recovery or rotated backup with it, and with -gen, a version from
+// its history log.
+//
+// History lists the versions of a file recorded by
+// jsonfile.WithHistory, with the time each was written and what it
+// changed.
+//
+// Jsonschema writes a JSON Schema of the JSON encoding of a Data type,
+// from its schema saved as JSON from jsonfile.SchemaOf, for programs in
+// other languages and for validation tools.
+//
+// Migrate-dir applies an apply script to every file of a jsonfile.Dir,
+// a directory of files named <name>.json. With -journal, it records
+// each file done, and a run that is interrupted or fails on some files
+// can be repeated to finish the rest. jsonfile.MigrateAll can use the
+// same journal.
+//
+// Show prints the data of a file, without its envelope, as indented
+// JSON with the members of objects in the order of the file.
+//
+// Validate loads a file as the jsonfile package does, checking its
+// envelope and checksum, without changing it. With -schema, it also
+// checks the data against the JSON encoding of a Data type, from its
+// schema saved as JSON from jsonfile.SchemaOf, and warns if the file
+// was written by a different type.
+//
+// Programs with the file open should be stopped before apply and edit
+// are used, or their next Write will undo the changes.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"crawshaw.dev/jsonfile"
+)
+
+type command struct {
+	name  string
+	short string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"apply", "apply a script of changes to a file", apply},
+	{"audit", "verify an audit log against its file", audit},
+	{"compat", "check that files load after a change to the Data type", compat},
+	{"convert", "rewrite a file as plain JSON, compressed, or encrypted", convert},
+	{"decode-stats", "count the decoding issues in event logs", decodeStats},
+	{"dict", "train a compression dictionary from files", dict},
+	{"diff", "list the changes between two versions of a file", diff},
+	{"doctor", "list recoverable copies of a file and restore one", doctor},
+	{"edit", "edit a file in $EDITOR", edit},
+	{"history", "list the versions of a file in its history log", history},
+	{"jsonschema", "write the JSON Schema of a Data type", jsonSchema},
+	{"migrate-dir", "apply a script of changes to every file in a directory", migrateDir},
+	{"show", "print the data of a file as indented JSON", show},
+	{"validate", "check that a file loads, and matches a Data type", validate},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: jsonfile <command> [flags] <path>\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-12s %s\n", c.name, c.short)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+	}
+	name, args := flag.Arg(0), flag.Args()[1:]
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(args); err != nil {
+				fmt.Fprintf(os.Stderr, "jsonfile %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "jsonfile: unknown command %q\n", name)
+	usage()
+}
+
+// newFlagSet returns a FlagSet for the named command that takes a
+// single path argument.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: jsonfile %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// fileFlags are the flags giving what is needed to read a file that
+// cannot be found from the file itself: its keys and dictionary.
+type fileFlags struct {
+	key, hmac *keyFlag
+	dict      *string
+}
+
+func addFileFlags(fs *flag.FlagSet) *fileFlags {
+	return &fileFlags{
+		key:  addKeyFlag(fs, "key", "decrypt the file with the key"),
+		hmac: addKeyFlag(fs, "hmac", "check the HMAC checksum of the file with the key"),
+		dict: fs.String("dict", "", "decompress the file with the dictionary in `file`"),
+	}
+}
+
+// options returns the jsonfile options the flags give.
+func (f *fileFlags) options() ([]jsonfile.Option, error) {
+	var opts []jsonfile.Option
+	if k, err := f.key.read(); err != nil {
+		return nil, err
+	} else if k != nil {
+		opts = append(opts, jsonfile.WithEncryption(k))
+	}
+	if k, err := f.hmac.read(); err != nil {
+		return nil, err
+	} else if k != nil {
+		opts = append(opts, jsonfile.WithChecksum(k))
+	}
+	if *f.dict != "" {
+		d, err := os.ReadFile(*f.dict)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, jsonfile.WithCompression(jsonfile.DeflateDict(d)))
+	}
+	return opts, nil
+}
+
+// A keyFlag is a pair of flags naming a file holding a key: -name for
+// a file of its raw bytes, and -namehex for one of its hex encoding,
+// with or without a newline. A key that happens to be valid hex is
+// then never mistaken for its encoding.
+type keyFlag struct {
+	name     string
+	raw, hex *string
+}
+
+func addKeyFlag(fs *flag.FlagSet, name, usage string) *keyFlag {
+	return &keyFlag{
+		name: name,
+		raw:  fs.String(name, "", usage+" in `file`, as raw bytes"),
+		hex:  fs.String(name+"hex", "", usage+" in `file`, hex encoded"),
+	}
+}
+
+// read returns the key in the file named by the flags, or nil if
+// neither is set.
+func (f *keyFlag) read() ([]byte, error) {
+	switch {
+	case *f.raw != "" && *f.hex != "":
+		return nil, fmt.Errorf("-%s and -%shex cannot be used together", f.name, f.name)
+	case *f.raw != "":
+		return os.ReadFile(*f.raw)
+	case *f.hex != "":
+		b, err := os.ReadFile(*f.hex)
+		if err != nil {
+			return nil, err
+		}
+		k, err := hex.DecodeString(string(bytes.TrimSpace(b)))
+		if err != nil {
+			return nil, fmt.Errorf("-%shex: %s: %w", f.name, *f.hex, err)
+		}
+		return k, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,195 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// migrateDir applies an apply script to every file of a jsonfile.Dir,
+// keeping a journal of the files done so an interrupted run can resume.
+func migrateDir(args []string) error {
+	fs := newFlagSet("migrate-dir", "[-j n] [-journal file] [-backups dir] [-key|-keyhex keyfile] [-hmac|-hmachex keyfile] [-dict dict] <dir> <script.jsonl>")
+	parallel := fs.Int("j", 1, "number of files to migrate at once")
+	journal := fs.String("journal", "", "record the files done in this `file`, and skip those it lists")
+	backupDir := fs.String("backups", "", "copy each file to this `dir` before changing it")
+	ff := addFileFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	dir, script := fs.Arg(0), fs.Arg(1)
+	opts, err := ff.options()
+	if err != nil {
+		return err
+	}
+
+	ops, err := readScript(script)
+	if err != nil {
+		return err
+	}
+	names, err := dirFiles(dir)
+	if err != nil {
+		return err
+	}
+	done := make(map[string]bool)
+	if *journal != "" {
+		if done, err = readJournal(*journal); err != nil {
+			return err
+		}
+	}
+	if *backupDir != "" {
+		if err := os.MkdirAll(*backupDir, 0777); err != nil {
+			return err
+		}
+	}
+
+	var (
+		mu     sync.Mutex // guards the following
+		n      int
+		failed int
+	)
+	finish := func(name, result string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil && *journal != "" && !done[name] {
+			err = appendJournal(*journal, name)
+		}
+		if err != nil {
+			failed++
+			result = err.Error()
+		}
+		n++
+		fmt.Printf("[%d/%d] %s: %s\n", n, len(names), name, result)
+	}
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < max(*parallel, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				changed, err := migrateFile(filepath.Join(dir, name+".json"), ops, *backupDir, opts)
+				result := "unchanged"
+				if changed {
+					result = "migrated"
+				}
+				finish(name, result, err)
+			}
+		}()
+	}
+	for _, name := range names {
+		if done[name] {
+			finish(name, "done before", nil)
+			continue
+		}
+		work <- name
+	}
+	close(work)
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(names))
+	}
+	return nil
+}
+
+// migrateFile applies ops to the file at path, read with opts,
+// reporting whether they changed it.
+func migrateFile(path string, ops []op, backupDir string, opts []jsonfile.Option) (bool, error) {
+	orig, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	doc, err := openDocument(path, opts...)
+	if err != nil {
+		return false, err
+	}
+	defer doc.close()
+	before := mustEncode(doc.data)
+	for i, o := range ops {
+		if doc.data, err = o.apply(doc.data); err != nil {
+			return false, fmt.Errorf("operation %d: %w", i+1, err)
+		}
+	}
+	if bytes.Equal(mustEncode(doc.data), before) {
+		return false, nil
+	}
+	if backupDir != "" {
+		if err := replaceFile(filepath.Join(backupDir, filepath.Base(path)), orig); err != nil {
+			return false, fmt.Errorf("backup: %w", err)
+		}
+	}
+	if err := doc.write(); err != nil {
+		return false, err
+	}
+	return true, doc.close()
+}
+
+// dirFiles returns the names of the files of a jsonfile.Dir, sorted,
+// as jsonfile.Dir.List does.
+func dirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if ok && name != "" && e.Type().IsRegular() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readJournal returns the names listed in a journal of migrate-dir or
+// jsonfile.MigrateAll, which can be used by either. A last line without
+// a newline was cut short, and is removed.
+func readJournal(path string) (map[string]bool, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]bool), nil
+	} else if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool)
+	lines := strings.Split(string(b), "\n")
+	for _, line := range lines[:len(lines)-1] {
+		done[line] = true
+	}
+	if torn := lines[len(lines)-1]; torn != "" {
+		if err := os.Truncate(path, int64(len(b)-len(torn))); err != nil {
+			return nil, err
+		}
+	}
+	return done, nil
+}
+
+func appendJournal(path, name string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(name + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateDir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for i := 0; i < 4; i++ {
+		b := fmt.Sprintf(`{"Name":"t%d","Version":1}`, i)
+		if i == 2 {
+			b = `{"Name":"t2","Version":2}`
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("t%d.json", i)), []byte(b), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := filepath.Join(t.TempDir(), "script.jsonl")
+	if err := os.WriteFile(script, []byte(`{"op":"test","path":"/Version","value":1}`+"\n"+`{"path":"/Version","value":2}`+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	journal := filepath.Join(t.TempDir(), "journal")
+	backups := filepath.Join(t.TempDir(), "backups")
+
+	// t2 fails the test operation, and is not in the journal.
+	if err := migrateDir([]string{"-j", "2", "-journal", journal, "-backups", backups, dir, script}); err == nil {
+		t.Error("no error for a failed file")
+	}
+	for _, name := range []string{"t0", "t1", "t3"} {
+		b, err := os.ReadFile(filepath.Join(dir, name+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf(`{"Name":"%s","Version":2}`, name); string(b) != want {
+			t.Errorf("%s: %s, want %s", name, b, want)
+		}
+		if _, err := os.Stat(filepath.Join(backups, name+".json")); err != nil {
+			t.Error(err)
+		}
+	}
+	done, err := readJournal(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 3 || done["t2"] {
+		t.Errorf("journal: %v", done)
+	}
+
+	// With t2 fixed, the run finishes.
+	if err := os.WriteFile(filepath.Join(dir, "t2.json"), []byte(`{"Name":"t2","Version":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateDir([]string{"-journal", journal, dir, script}); err != nil {
+		t.Fatal(err)
+	}
+	if done, _ := readJournal(journal); len(done) != 4 {
+		t.Errorf("journal: %v", done)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+)
+
+// show writes the data of a file as indented JSON, without its
+// envelope, keeping the order of object members.
+func show(args []string) error {
+	fs := newFlagSet("show", "[-o out.json] [-key|-keyhex keyfile] [-hmac|-hmachex keyfile] [-dict dict] <path>")
+	out := fs.String("o", "", "write the data to `file` instead of stdout")
+	ff := addFileFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	opts, err := ff.options()
+	if err != nil {
+		return err
+	}
+	doc, err := readDocument(fs.Arg(0), opts...)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, doc.raw, "", "\t"); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	if *out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(*out, buf.Bytes(), 0666)
+}
@@ -0,0 +1,62 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestShow(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path, out, keyPath := filepath.Join(dir, "db.json"), filepath.Join(dir, "out.json"), filepath.Join(dir, "key")
+	key := bytes.Repeat([]byte{7}, 32)
+	if err := os.WriteFile(keyPath, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := jsonfile.NewWithDefault(path, json.RawMessage(`{"Z":1,"A":[true]}`), jsonfile.WithChecksum(nil), jsonfile.WithEncryption(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := show([]string{"-o", out, path}); err == nil {
+		t.Error("show of an encrypted file without -key succeeded")
+	}
+	if err := show([]string{"-key", keyPath, "-o", out, path}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "{\n\t\"Z\": 1,\n\t\"A\": [\n\t\ttrue\n\t]\n}\n"; got != want {
+		t.Errorf("show wrote %q, want %q", got, want)
+	}
+}
+
+func TestShowJournal(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path, out := filepath.Join(dir, "db.json"), filepath.Join(dir, "out.json")
+	journaled(t, path)
+	if err := show([]string{"-o", out, path}); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(out); err != nil {
+		t.Fatal(err)
+	} else if got, want := string(b), "{\n\t\"Val\": 2\n}\n"; got != want {
+		t.Errorf("show wrote %q, want %q", got, want)
+	}
+	if _, err := os.Stat(path + ".wal"); err != nil {
+		t.Errorf("journal: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// validate checks that a file loads, and with -schema, that its data
+// matches a Data type, from its schema saved as JSON from
+// jsonfile.SchemaOf.
+func validate(args []string) error {
+	fs := newFlagSet("validate", "[-schema schema.json] [-key|-keyhex keyfile] [-hmac|-hmachex keyfile] [-dict dict] <path>")
+	schemaPath := fs.String("schema", "", "check the data against the schema of a Data type in `file`")
+	ff := addFileFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+	fileOpts, err := ff.options()
+	if err != nil {
+		return err
+	}
+
+	format, err := jsonfile.ReadFormat(path, fileOpts...)
+	if err != nil {
+		return err
+	}
+	opts := format.Options()
+	var schema *jsonfile.Schema
+	if *schemaPath != "" {
+		if schema, err = readSchema(*schemaPath); err != nil {
+			return err
+		}
+		opts = append(opts, jsonfile.WithJSONSchema(schema.JSONSchema()))
+	}
+	// LoadFS reads the file as a program would, checking its envelope
+	// and checksum, but takes no locks and changes nothing.
+	if _, err := jsonfile.LoadFS[any](os.DirFS(filepath.Dir(path)), filepath.Base(path), opts...); err != nil {
+		return err
+	}
+	if schema != nil && format.Schema != "" && format.Schema != schema.Fingerprint() {
+		fmt.Printf("warning: %s was written by a type with schema %s, not %s\n", path, format.Schema, schema.Fingerprint())
+	}
+	fmt.Printf("%s is valid\n", path)
+	return nil
+}
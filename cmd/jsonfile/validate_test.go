@@ -0,0 +1,70 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Name  string
+		Count uint8
+	}
+	dir := t.TempDir()
+	b, err := json.Marshal(jsonfile.SchemaOf[DB]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := filepath.Join(dir, "schema.json")
+	if err := os.WriteFile(schema, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "db.json")
+	db, err := jsonfile.New[DB](path, jsonfile.WithSchemaFingerprint(nil), jsonfile.WithChecksum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Write(func(db *DB) error { db.Count = 200; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := validate([]string{path}); err != nil {
+		t.Errorf("validate: %v", err)
+	}
+	if err := validate([]string{"-schema", schema, path}); err != nil {
+		t.Errorf("validate -schema: %v", err)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"Name":"a","Count":300}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := validate([]string{bad}); err != nil {
+		t.Errorf("validate without schema: %v", err)
+	}
+	if err := validate([]string{"-schema", schema, bad}); err == nil {
+		t.Error("validate -schema of out of range Count succeeded")
+	}
+
+	// A changed byte no longer matches the checksum.
+	b, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = []byte(string(b[:len(b)-3]) + "1}}")
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := validate([]string{path}); err == nil {
+		t.Errorf("validate of corrupt file succeeded:\n%s", b)
+	}
+}
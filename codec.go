@@ -0,0 +1,157 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// A Codec encodes the data for the file, and decodes it.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(b []byte, v any) error
+}
+
+// WithCodec stores the data in the file encoded by c instead of as
+// JSON, so the same atomic writes, locking, and backups can be used for
+// YAML, TOML, CBOR, or MessagePack files. Most encoding packages need
+// only a small adapter:
+//
+//	type yamlCodec struct{}
+//
+//	func (yamlCodec) Marshal(v any) ([]byte, error)   { return yaml.Marshal(v) }
+//	func (yamlCodec) Unmarshal(b []byte, v any) error { return yaml.Unmarshal(b, v) }
+//
+// c must encode equal values to equal bytes, as Write skips writing
+// data whose encoding has not changed. Options that work on the JSON
+// in the file, WithFieldCodec, WithSchemaFingerprint, WithChecksum, and
+// WithDifferentialBackups, cannot be used with WithCodec.
+func WithCodec(c Codec) Option {
+	return func(o *options) { o.codec = c }
+}
+
+// isJSON reports whether the data is encoded as JSON.
+func (o *options) isJSON() bool {
+	switch o.codec.(type) {
+	case nil, jsonV2Codec:
+		return true
+	}
+	return false
+}
+
+// check reports an error for options that cannot be used together.
+func (o *options) check() error {
+	if err := o.checkFS(); err != nil {
+		return err
+	}
+	if !o.isJSON() && (len(o.fieldCodecs) > 0 || o.schema.enabled || o.checksum.enabled || o.backup.chain > 0 || o.hujson || o.clock != nil || o.writeStats || o.noEscapeHTML || o.trailingNewline || o.canonical) {
+		return errors.New("WithCodec cannot be used with options that need JSON")
+	}
+	if o.canonical && o.hujson {
+		return errors.New("WithCanonical cannot be used with WithHuJSON")
+	}
+	if o.hujson && o.backup.chain > 0 {
+		return errors.New("WithHuJSON cannot be used with WithDifferentialBackups")
+	}
+	if o.hujson && o.checksum.enabled {
+		return errors.New("WithHuJSON cannot be used with WithChecksum")
+	}
+	if o.compression != nil && (o.hujson || o.backup.chain > 0) {
+		return errors.New("WithCompression cannot be used with WithHuJSON or WithDifferentialBackups")
+	}
+	if o.encryption.err != nil {
+		return o.encryption.err
+	}
+	if o.jsonSchemaErr != nil {
+		return o.jsonSchemaErr
+	}
+	if o.jsonSchema != nil && !o.isJSON() {
+		return errors.New("WithJSONSchema cannot be used with WithCodec")
+	}
+	if o.encrypted() && (o.hujson || o.backup.chain > 0) {
+		return errors.New("WithCipher and WithEncryption cannot be used with WithHuJSON or WithDifferentialBackups")
+	}
+	if o.unknownFields && !o.isJSON() {
+		return errors.New("WithUnknownFields cannot be used with WithCodec")
+	}
+	if o.strict && (!o.isJSON() || o.unknownFields) {
+		return errors.New("WithStrictDecoding cannot be used with WithCodec or WithUnknownFields")
+	}
+	if o.useNumber && o.codec != nil {
+		return errors.New("WithUseNumber cannot be used with WithCodec")
+	}
+	if o.decodeStats != nil && !o.isJSON() {
+		return errors.New("WithDecodeStats cannot be used with WithCodec")
+	}
+	if o.diffs && !o.isJSON() {
+		return errors.New("WithDiffs cannot be used with WithCodec")
+	}
+	if o.history && (!o.isJSON() || o.encrypted()) {
+		return errors.New("WithHistory cannot be used with WithCodec, WithEncryption, or WithCipher")
+	}
+	if o.auditLog != "" && (!o.isJSON() || o.groupCommit || o.encrypted()) {
+		return errors.New("WithAuditLog cannot be used with WithCodec, WithGroupCommit, WithEncryption, or WithCipher")
+	}
+	if o.replica != nil && o.journal > 0 {
+		return errors.New("WithReplica cannot be used with WithJournal")
+	}
+	if o.journal > 0 && (!o.isJSON() || o.hujson || o.autosave > 0 || o.encrypted() || o.clock != nil || o.writeStats) {
+		return errors.New("WithJournal cannot be used with WithCodec, WithHuJSON, WithAutosave, WithEncryption, WithCipher, WithClock, or WithWriteStats")
+	}
+	return nil
+}
+
+// marshal encodes the data v.
+func (o *options) marshal(v any) ([]byte, error) {
+	var b []byte
+	var err error
+	switch o.codec.(type) {
+	case nil:
+		b, err = o.marshalJSON(v)
+	case jsonV2Codec:
+		b, err = marshalV2(v, !o.noEscapeHTML)
+	default:
+		return o.codec.Marshal(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o.canonicalize(b)
+}
+
+// unmarshal decodes the data in b into v.
+func (o *options) unmarshal(b []byte, v any) error {
+	if o.strict {
+		return o.unmarshalStrict(b, v)
+	}
+	if o.codec != nil {
+		return o.codec.Unmarshal(b, v)
+	}
+	if o.useNumber {
+		return o.decode(b, v)
+	}
+	return json.Unmarshal(b, v)
+}
+
+// decode decodes b into v with a json.Decoder, which rejects unknown
+// object members for WithStrictDecoding and uses json.Number for
+// WithUseNumber.
+func (o *options) decode(b []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	if o.strict {
+		d.DisallowUnknownFields()
+	}
+	if o.useNumber {
+		d.UseNumber()
+	}
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if d.More() {
+		return errors.New("invalid JSON: data after value")
+	}
+	return nil
+}
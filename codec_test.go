@@ -0,0 +1,71 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/gob"
+	"path/filepath"
+	"testing"
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(b []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+func TestCodec(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Name string
+		Vals []int
+	}
+
+	path := filepath.Join(t.TempDir(), "testcodec.gob")
+	db, err := New[DB](path, WithCodec(gobCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) {
+		db.Name = "a"
+		db.Vals = []int{1, 2}
+	})
+	if err := db.Scrub(false); err != nil {
+		t.Errorf("Scrub: %v", err)
+	}
+
+	db, err = Load[DB](path, WithCodec(gobCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Name != "a" || len(db.Vals) != 2 {
+			t.Errorf("got %+v after Load", *db)
+		}
+	})
+	if _, err := Load[DB](path); err == nil {
+		t.Error("Load of a gob file as JSON succeeded")
+	}
+
+	if _, err := New[DB](filepath.Join(t.TempDir(), "bad.gob"), WithCodec(gobCodec{}), WithSchemaFingerprint(nil)); err == nil {
+		t.Error("New with WithCodec and WithSchemaFingerprint succeeded")
+	}
+}
+
+func TestNewMap(t *testing.T) {
+	t.Parallel()
+
+	db, err := New[map[string]int](filepath.Join(t.TempDir(), "testmap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *map[string]int) { (*db)["a"] = 1 })
+}
@@ -0,0 +1,210 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// CompatReport is the result of CheckCompat.
+type CompatReport struct {
+	Old, New string // fingerprints of the schemas compared
+	Changes  []SchemaChange
+
+	// Migrated is set if a migration is registered for files with
+	// the old schema, so breaking changes are handled.
+	Migrated bool
+}
+
+// A SchemaChange is a difference between two schemas.
+type SchemaChange struct {
+	Path     string // JSON Pointer to the value, with * for any element or key
+	Change   string
+	Breaking bool // files with the old schema may fail to load, or lose data
+}
+
+func (c SchemaChange) String() string {
+	s := c.Path + ": " + c.Change
+	if c.Path == "" {
+		s = "/: " + c.Change
+	}
+	if c.Breaking {
+		s += " (breaking)"
+	}
+	return s
+}
+
+// Breaking reports whether any change is breaking.
+func (r CompatReport) Breaking() bool {
+	return slices.ContainsFunc(r.Changes, func(c SchemaChange) bool { return c.Breaking })
+}
+
+// Err returns an error listing the breaking changes, if there are any
+// and no migration is registered for the old schema.
+func (r CompatReport) Err() error {
+	if r.Migrated || !r.Breaking() {
+		return nil
+	}
+	var msgs []string
+	for _, c := range r.Changes {
+		if c.Breaking {
+			msgs = append(msgs, c.String())
+		}
+	}
+	return fmt.Errorf("jsonfile.CheckCompat: schema %s to %s: %s", r.Old, r.New, strings.Join(msgs, "; "))
+}
+
+// CheckCompat reports whether files written by a program with the Data
+// type described by old load under one with the Data type described by
+// new, as decoded by encoding/json. It is meant to run in CI, comparing
+// the Schema saved by the released version with the one being built,
+// and failing the build if Err is not nil.
+//
+// A change is breaking if values in old files fail to decode, such as
+// a string field becoming an int, or if data in them is dropped, such
+// as a removed field. Fields added and numbers widened are reported,
+// but are not breaking. migrated lists the fingerprints of schemas
+// for which the program registers a migration, such as with
+// WithLegacyDecoder: breaking changes from those are handled.
+func CheckCompat(old, new *Schema, migrated ...string) CompatReport {
+	r := CompatReport{Old: old.Fingerprint(), New: new.Fingerprint()}
+	r.Migrated = slices.Contains(migrated, r.Old)
+	c := compatChecker{
+		report:  &r,
+		oldRefs: structsOf(old, nil),
+		newRefs: structsOf(new, nil),
+		seen:    make(map[[2]*Schema]bool),
+	}
+	c.compare("", old, new)
+	return r
+}
+
+type compatChecker struct {
+	report           *CompatReport
+	oldRefs, newRefs []*Schema           // structs by number, for refs
+	seen             map[[2]*Schema]bool // pairs of structs compared
+}
+
+// structsOf appends the structs in s to refs, numbered in order of
+// appearance as refs refer to them.
+func structsOf(s *Schema, refs []*Schema) []*Schema {
+	if s == nil {
+		return refs
+	}
+	if s.Kind == "struct" {
+		refs = append(refs, s)
+		for _, f := range s.Fields {
+			refs = structsOf(f.Type, refs)
+		}
+		return refs
+	}
+	return structsOf(s.Elem, structsOf(s.Key, refs))
+}
+
+func (c *compatChecker) add(path, change string, breaking bool) {
+	c.report.Changes = append(c.report.Changes, SchemaChange{Path: path, Change: change, Breaking: breaking})
+}
+
+func resolve(s *Schema, refs []*Schema) *Schema {
+	if s.Kind == "ref" && s.Ref >= 0 && s.Ref < len(refs) {
+		return refs[s.Ref]
+	}
+	return s
+}
+
+func (c *compatChecker) compare(path string, old, new *Schema) {
+	old, new = resolve(old, c.oldRefs), resolve(new, c.newRefs)
+	if new.Kind == "interface" {
+		return // anything decodes
+	}
+	if old.Kind != new.Kind {
+		c.compareKinds(path, old.Kind, new.Kind, false)
+		return
+	}
+	switch old.Kind {
+	case "slice":
+		c.compare(path+"/*", old.Elem, new.Elem)
+	case "map":
+		if old.Key.Kind != new.Key.Kind {
+			c.compareKinds(path+"/*", old.Key.Kind, new.Key.Kind, true)
+		}
+		c.compare(path+"/*", old.Elem, new.Elem)
+	case "struct":
+		pair := [2]*Schema{old, new}
+		if c.seen[pair] {
+			return
+		}
+		c.seen[pair] = true
+		c.compareFields(path, old.Fields, new.Fields)
+	}
+}
+
+func (c *compatChecker) compareFields(path string, old, new []SchemaField) {
+	for _, of := range old {
+		fpath := path + "/" + jsonvalue.EscapePointer(of.Name)
+		i := slices.IndexFunc(new, func(nf SchemaField) bool { return nf.Name == of.Name })
+		if i < 0 {
+			// encoding/json matches names without regard to case.
+			i = slices.IndexFunc(new, func(nf SchemaField) bool { return strings.EqualFold(nf.Name, of.Name) })
+			if i < 0 {
+				c.add(fpath, "field removed, its data is dropped", true)
+				continue
+			}
+			c.add(fpath, fmt.Sprintf("field renamed to %q, matched without regard to case", new[i].Name), false)
+		}
+		c.compare(fpath, of.Type, new[i].Type)
+	}
+	for _, nf := range new {
+		if !slices.ContainsFunc(old, func(of SchemaField) bool { return strings.EqualFold(nf.Name, of.Name) }) {
+			c.add(path+"/"+jsonvalue.EscapePointer(nf.Name), "field added", false)
+		}
+	}
+}
+
+// numBits holds the integer and floating-point kinds, with their size.
+// Sizes of int, uint, and uintptr are taken as 64 bits.
+var numBits = map[string]int{
+	"int": 64, "int8": 8, "int16": 16, "int32": 32, "int64": 64,
+	"uint": 64, "uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64, "uintptr": 64,
+	"float32": 32, "float64": 64,
+}
+
+// compareKinds reports the change from values, or map keys if isKey
+// is set, of kind old to kind new.
+func (c *compatChecker) compareKinds(path, old, new string, isKey bool) {
+	change := fmt.Sprintf("type changed from %s to %s", old, new)
+	if isKey {
+		change = "key " + change
+	}
+	c.add(path, change, !widens(old, new, isKey))
+}
+
+// widens reports whether every value of kind old, as encoded in JSON,
+// decodes as kind new.
+func widens(old, new string, isKey bool) bool {
+	ob, oldNum := numBits[old]
+	nb, newNum := numBits[new]
+	isFloat := func(k string) bool { return strings.HasPrefix(k, "float") }
+	isUint := func(k string) bool { return strings.HasPrefix(k, "uint") }
+	switch {
+	case isKey && new == "string":
+		return true // keys are encoded as strings
+	case old == "time" && new == "string":
+		return true // times are encoded as strings
+	case !oldNum || !newNum:
+		return false
+	case isFloat(new):
+		return !isFloat(old) || nb >= ob // if with rounding
+	case isFloat(old):
+		return false
+	case isUint(old) == isUint(new):
+		return nb >= ob
+	default:
+		return isUint(old) && nb > ob // negative numbers do not fit a uint
+	}
+}
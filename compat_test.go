@@ -0,0 +1,80 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckCompat(t *testing.T) {
+	t.Parallel()
+	type Item struct {
+		ID    uint16
+		Price float32
+	}
+	type Node struct {
+		Name string
+		Kids []*Node
+	}
+	type Old struct {
+		Name    string
+		Items   map[string]Item
+		Created time.Time
+		Removed bool
+		Score   int
+		Tree    Node
+		Any     any
+		Big     int64
+		id      int
+	}
+	type New struct {
+		NAME    string `json:"name"`
+		Items   map[string]Item
+		Created string
+		Score   float64
+		Tree    Node
+		Any     map[string]int
+		Big     int32
+		Added   []string
+	}
+
+	// The Schema survives a trip through JSON, as saved by CI.
+	b, err := json.Marshal(SchemaOf[Old]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := new(Schema)
+	if err := json.Unmarshal(b, old); err != nil {
+		t.Fatal(err)
+	}
+	if old.Fingerprint() != (&JSONFile[Old]{data: new(Old)}).fingerprint() {
+		t.Error("Schema fingerprint does not match WithSchemaFingerprint")
+	}
+
+	r := CheckCompat(old, SchemaOf[New]())
+	want := []SchemaChange{
+		{Path: "/Name", Change: `field renamed to "name", matched without regard to case`},
+		{Path: "/Created", Change: "type changed from time to string"},
+		{Path: "/Removed", Change: "field removed, its data is dropped", Breaking: true},
+		{Path: "/Score", Change: "type changed from int to float64"},
+		{Path: "/Any", Change: "type changed from interface to map", Breaking: true},
+		{Path: "/Big", Change: "type changed from int64 to int32", Breaking: true},
+		{Path: "/Added", Change: "field added"},
+	}
+	if !reflect.DeepEqual(r.Changes, want) {
+		t.Errorf("changes:\n%v\nwant:\n%v", r.Changes, want)
+	}
+	if r.Err() == nil {
+		t.Error("Err is nil for breaking changes")
+	}
+	if r := CheckCompat(old, SchemaOf[New](), r.Old); !r.Migrated || r.Err() != nil {
+		t.Errorf("with migration: Migrated=%v, Err=%v", r.Migrated, r.Err())
+	}
+	if r := CheckCompat(old, old); len(r.Changes) != 0 || r.Old != r.New {
+		t.Errorf("same schema: %+v", r)
+	}
+}
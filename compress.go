@@ -0,0 +1,92 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// A Compression compresses the file, for WithCompression.
+type Compression interface {
+	// Magic returns the bytes every compressed file starts with.
+	// Files that do not start with them are read uncompressed.
+	Magic() []byte
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+// Gzip compresses the file with gzip (RFC 1952).
+var Gzip Compression = gzipCompression{}
+
+// WithCompression stores the file compressed by c. It is decompressed
+// by Load, so large files can be kept small on disk. Files that are not
+// compressed are still read, so compression can be turned on for an
+// existing file, and is applied by its next Write.
+//
+// The package provides Gzip. Other formats need only a small adapter,
+// such as this one for zstd using github.com/klauspost/compress/zstd:
+//
+//	type zstdCompression struct{}
+//
+//	var (
+//		zenc, _ = zstd.NewWriter(nil)
+//		zdec, _ = zstd.NewReader(nil)
+//	)
+//
+//	func (zstdCompression) Magic() []byte                       { return []byte{0x28, 0xb5, 0x2f, 0xfd} }
+//	func (zstdCompression) Compress(b []byte) ([]byte, error)   { return zenc.EncodeAll(b, nil), nil }
+//	func (zstdCompression) Decompress(b []byte) ([]byte, error) { return zdec.DecodeAll(b, nil) }
+//
+// Backups are compressed too. WithCompression cannot be used with
+// WithHuJSON or WithDifferentialBackups.
+func WithCompression(c Compression) Option {
+	return func(o *options) { o.compression = c }
+}
+
+// compress returns the file contents b compressed, if compression is
+// enabled.
+func (o *options) compress(b []byte) ([]byte, error) {
+	if o.compression == nil {
+		return b, nil
+	}
+	return o.compression.Compress(b)
+}
+
+// decompress returns the file contents b decompressed, if they are
+// compressed.
+func (o *options) decompress(b []byte) ([]byte, error) {
+	c := o.compression
+	if c == nil || !bytes.HasPrefix(b, c.Magic()) {
+		return b, nil
+	}
+	return c.Decompress(b)
+}
+
+type gzipCompression struct{}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+func (gzipCompression) Magic() []byte { return gzipMagic }
+
+func (gzipCompression) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompression) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Text string }
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testcompress.json")
+	text := strings.Repeat("compressible ", 1000)
+	if err := os.WriteFile(path, []byte(`{"Text":"plain"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// An uncompressed file is read, then compressed by the next Write.
+	db, err := Load[DB](path, WithCompression(Gzip), WithScheduledBackups(filepath.Join(dir, "backups"), 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Text != "plain" {
+			t.Errorf("Text=%q, want plain", db.Text)
+		}
+	})
+	mustWrite(t, db, func(db *DB) { db.Text = text })
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, gzipMagic) || len(b) > len(text)/10 {
+		t.Errorf("file is not compressed, %d bytes", len(b))
+	}
+	if err := Verify(path); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := db.Scrub(false); err != nil {
+		t.Errorf("Scrub: %v", err)
+	}
+	if err := db.Backup(); err != nil {
+		t.Errorf("Backup: %v", err)
+	}
+
+	db, err = Load[DB](path, WithCompression(Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Text != text {
+			t.Errorf("Text=%.20q after Load", db.Text)
+		}
+	})
+
+	if _, err := Load[DB](path, WithCompression(Gzip), WithHuJSON()); err == nil {
+		t.Error("Load with WithCompression and WithHuJSON succeeded")
+	}
+}
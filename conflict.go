@@ -0,0 +1,107 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// ErrConflict is returned by Write when the file was modified by
+// another program since it was loaded or last written.
+var ErrConflict = errors.New("jsonfile: file modified externally")
+
+// WithConflictDetection makes Write check that the file on disk has not
+// been modified by another program since it was loaded or last written
+// by this JSONFile. If it has, Write returns an error wrapping
+// ErrConflict and does not replace the file.
+//
+// The check compares the file's size and modification time, and its
+// SHA-256 checksum if either differs or the modification time is too
+// recent to be reliable. It is made just before the new
+// file is renamed into place, so it narrows but does not close the
+// window for lost updates. Use WithExclusiveLock to rule them out.
+func WithConflictDetection() Option {
+	return func(o *options) { o.detectConflicts = true }
+}
+
+// racyWindow is how close a file modification time can be to the
+// time it was observed and still be ambiguous: on file systems with
+// coarse timestamps another write in the same tick would not change it.
+const racyWindow = 2 * time.Second
+
+// fileState identifies the contents of a file on disk.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+	racy    bool // modTime too recent to trust, always compare sum
+}
+
+func newFileState(fi fs.FileInfo, b []byte) fileState {
+	return fileState{
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+		sum:     sha256.Sum256(b),
+		racy:    time.Since(fi.ModTime()) < racyWindow,
+	}
+}
+
+// readFile reads the file at path in fsys and returns its contents and
+// state.
+func readFile(fsys fs.FS, path string) ([]byte, fileState, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, fileState{}, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fileState{}, err
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fileState{}, err
+	}
+	return b, newFileState(fi, b), nil
+}
+
+// statFile returns the state of the file at path in fsys, which
+// contains b.
+func statFile(fsys fs.StatFS, path string, b []byte) (fileState, error) {
+	fi, err := fsys.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return newFileState(fi, b), nil
+}
+
+// checkConflict reports ErrConflict if the file on disk does not match
+// p.diskState. It is called with p.writing held.
+func (p *JSONFile[Data]) checkConflict() error {
+	if p.diskState == (fileState{}) {
+		return nil // file created by New
+	}
+	fsys := p.opts.fileSystem()
+	fi, err := fsys.Stat(p.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrConflict
+	} else if err != nil {
+		return err
+	}
+	if !p.diskState.racy && fi.Size() == p.diskState.size && fi.ModTime().Equal(p.diskState.modTime) {
+		return nil
+	}
+	b, err := fs.ReadFile(fsys, p.path)
+	if err != nil {
+		return err
+	}
+	if sha256.Sum256(b) != p.diskState.sum {
+		return ErrConflict
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConflictDetection(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testconflict.json")
+	db, err := New[DB](path, WithConflictDetection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+
+	other, err := Load[DB](path, WithConflictDetection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, other, func(db *DB) { db.Val = 2 })
+
+	// Same size, so only the checksum tells the files apart.
+	if err := db.Write(func(db *DB) error {
+		db.Val = 3
+		return nil
+	}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Write err=%v, want %v", err, ErrConflict)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 1 {
+			t.Errorf("Val = %d after conflict, want 1", db.Val)
+		}
+	})
+	if b, _ := os.ReadFile(path); string(b) != `{"Val":2}` {
+		t.Errorf("file = %s after conflict, want {\"Val\":2}", b)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp*"); len(matches) > 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+
+	// Touching the file without changing it is not a conflict.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, other, func(db *DB) { db.Val = 4 })
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Write(func(db *DB) error {
+		db.Val = 5
+		return nil
+	}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Write after remove err=%v, want %v", err, ErrConflict)
+	}
+}
+
+func TestNoConflictDetection(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testconflict.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, other, func(db *DB) { db.Val = 2 })
+	mustWrite(t, db, func(db *DB) { db.Val = 3 }) // last writer wins
+}
@@ -0,0 +1,58 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"fmt"
+)
+
+// criticalWriteFailures is how many writes of the file in a row must
+// fail for the failure to be critical.
+const criticalWriteFailures = 3
+
+// WithOnCriticalError calls fn when the JSONFile meets a failure that
+// retrying will not fix and that callers may not see, so the
+// application can alert an operator apart from ordinary errors:
+//
+//   - Load finds the file corrupt, such as by a checksum mismatch,
+//     even if LoadWithRecovery then recovers an older copy
+//   - Scrub finds the file does not match memory and cannot repair it
+//   - writes of the file fail several times in a row, as when the disk
+//     is full, including writes delayed by WithAutosave, whose errors
+//     are otherwise not reported
+//
+// fn is called with the error, which wraps ErrCorrupt, ErrDiverged, or
+// the error of the last write. It may be called while a Write is in
+// progress, so it must not block or call methods of the JSONFile.
+func WithOnCriticalError(fn func(err error)) Option {
+	return func(o *options) { o.onCritical = fn }
+}
+
+// critical reports err to WithOnCriticalError.
+func (p *JSONFile[Data]) critical(err error) {
+	if p.opts.onCritical != nil {
+		p.opts.onCritical(err)
+	}
+}
+
+// countWrite counts the writes of the file for Stat, and counts those
+// that fail in a row, reporting it when there are
+// criticalWriteFailures of them. Conflicts are not failures of the
+// file. It is called with p.writing held.
+func (p *JSONFile[Data]) countWrite(err error) {
+	p.counters.countWriteResult(err)
+	switch {
+	case err == nil:
+		p.writeFailures = 0
+	case errors.Is(err, ErrConflict):
+		p.event(Event{Event: "conflicted", Error: err.Error()})
+	default:
+		p.event(Event{Event: "failed", Error: err.Error()})
+		p.writeFailures++
+		if p.writeFailures == criticalWriteFailures {
+			p.critical(fmt.Errorf("JSONFile.Write: %s: %d writes in a row failed: %w", p.path, p.writeFailures, err))
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestOnCriticalError(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val int
+	}
+	var mu sync.Mutex
+	var criticals []error
+	onCritical := WithOnCriticalError(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		criticals = append(criticals, err)
+	})
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(criticals)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testcritical.json")
+	db, err := New[DB](path, WithChecksum(nil), onCritical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+
+	// Writes fail while the directory is gone, and one failure in a
+	// row of several is critical.
+	if err := os.Rename(dir, dir+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i < 2+2*criticalWriteFailures; i++ {
+		i := i
+		if err := db.Write(func(db *DB) error { db.Val = i; return nil }); err == nil {
+			t.Fatal("Write succeeded without its directory")
+		}
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("%d critical errors after failed writes, want 1: %v", n, criticals)
+	}
+	if err := os.Rename(dir+".moved", dir); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 100 })
+	db.Close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)-3] ^= 1 // a digit of Val
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load[DB](path, WithChecksum(nil), onCritical); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Load: %v, want ErrCorrupt", err)
+	}
+	if n := count(); n != 2 || !errors.Is(criticals[1], ErrCorrupt) {
+		t.Errorf("critical errors %v, want a corrupt file", criticals)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+type decodeStats struct {
+	mu         sync.Mutex
+	unknown    map[string]uint64
+	mismatched map[string]uint64
+}
+
+// WithDecodeStats counts, each time the file is read, the members of
+// objects in it that the Data type has no field for, which decoding
+// drops, and the values of the wrong JSON type for their field, which
+// make decoding fail. The counts are reported in the UnknownFields and
+// TypeMismatches of Stat, and each read that finds any is reported by
+// a "decoded" Event, which the jsonfile command's decode-stats
+// aggregates. Use it to find and clean up old fields before turning on
+// WithStrictDecoding.
+//
+// As for WithReadSampling, array indexes and map keys in the paths
+// are counted together as "*".
+func WithDecodeStats() Option {
+	return func(o *options) { o.decodeStats = new(decodeStats) }
+}
+
+// recordDecode counts the decoding issues of b, read from the file.
+// It is called before b is decoded, so issues that make decoding fail
+// are counted too.
+func (p *JSONFile[Data]) recordDecode(b []byte) {
+	s := p.opts.decodeStats
+	if s == nil {
+		return
+	}
+	v, err := jsonvalue.Decode(b)
+	if err != nil {
+		return
+	}
+	unknown, mismatched := make(map[string]uint64), make(map[string]uint64)
+	decodeIssues(v, reflect.TypeOf(p.data).Elem(), "", unknown, mismatched)
+	if len(unknown) == 0 && len(mismatched) == 0 {
+		return
+	}
+	s.mu.Lock()
+	s.unknown = addCounts(s.unknown, unknown)
+	s.mismatched = addCounts(s.mismatched, mismatched)
+	s.mu.Unlock()
+	p.event(Event{Event: "decoded", Unknown: sortedKeys(unknown), Mismatched: sortedKeys(mismatched)})
+}
+
+func (s *decodeStats) counts() (unknown, mismatched map[string]uint64) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return addCounts(nil, s.unknown), addCounts(nil, s.mismatched)
+}
+
+func addCounts(dst, src map[string]uint64) map[string]uint64 {
+	if dst == nil {
+		dst = make(map[string]uint64, len(src))
+	}
+	for k, n := range src {
+		dst[k] += n
+	}
+	return dst
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// decodeIssues counts the members of v, decoded by jsonvalue.Decode, that
+// encoding/json would drop or fail on when decoding into a value of
+// type t, by their paths below path.
+func decodeIssues(v any, t reflect.Type, path string, unknown, mismatched map[string]uint64) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if v == nil || t.Kind() == reflect.Interface {
+		return
+	}
+	if pt := reflect.PointerTo(t); pt.Implements(jsonUnmarshalerType) || pt.Implements(textUnmarshalerType) {
+		return // decoded by its own rules
+	}
+	ok := true
+	switch v := v.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			for k, e := range v {
+				f, found := jsonField(t, k)
+				switch {
+				case !found:
+					unknown[path+"/"+jsonvalue.EscapePointer(k)]++
+				case !f.quoted:
+					decodeIssues(e, f.typ, path+"/"+jsonvalue.EscapePointer(f.name), unknown, mismatched)
+				}
+			}
+		case reflect.Map:
+			for _, e := range v {
+				decodeIssues(e, t.Elem(), path+"/*", unknown, mismatched)
+			}
+		default:
+			ok = false
+		}
+	case []any:
+		if ok = t.Kind() == reflect.Slice || t.Kind() == reflect.Array; ok {
+			for _, e := range v {
+				decodeIssues(e, t.Elem(), path+"/*", unknown, mismatched)
+			}
+		}
+	case string:
+		ok = t.Kind() == reflect.String || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+	case json.Number:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			_, err := strconv.ParseInt(string(v), 10, t.Bits())
+			ok = err == nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			_, err := strconv.ParseUint(string(v), 10, t.Bits())
+			ok = err == nil
+		case reflect.Float32, reflect.Float64:
+		default:
+			ok = false
+		}
+	case bool:
+		ok = t.Kind() == reflect.Bool
+	}
+	if !ok {
+		if path == "" {
+			path = "/"
+		}
+		mismatched[path]++
+	}
+}
+
+type jsonFieldInfo struct {
+	name   string
+	typ    reflect.Type
+	quoted bool // encoded as a string by the ",string" option
+}
+
+// jsonField returns the field of the struct type t that encoding/json
+// decodes the member name into, looking in embedded structs, and
+// matching the name exactly or else without case.
+func jsonField(t reflect.Type, name string) (jsonFieldInfo, bool) {
+	var fold jsonFieldInfo
+	found := false
+	var visit func(t reflect.Type) bool
+	visit = func(t reflect.Type) bool {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() && !f.Anonymous || tag == "-" {
+				continue
+			}
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if f.Anonymous && tag == "" && ft.Kind() == reflect.Struct {
+				if visit(ft) {
+					return true
+				}
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if tag == "" {
+				tag = f.Name
+			}
+			info := jsonFieldInfo{name: tag, typ: f.Type, quoted: strings.Contains(","+opts+",", ",string,")}
+			if tag == name {
+				fold, found = info, true
+				return true
+			}
+			if !found && strings.EqualFold(tag, name) {
+				fold, found = info, true
+			}
+		}
+		return false
+	}
+	visit(t)
+	return fold, found
+}
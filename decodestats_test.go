@@ -0,0 +1,69 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeStats(t *testing.T) {
+	t.Parallel()
+	type Base struct {
+		ID int
+	}
+	type User struct {
+		Name string
+		Age  uint8
+	}
+	type DB struct {
+		Base
+		Users   []User
+		Groups  map[string][]string
+		Created time.Time
+		Count   int64 `json:",string"`
+		Any     any
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testdecodestats.json")
+	const file = `{"ID":1,"users":[{"Nmae":"a"},{"Nmae":"b","Age":2}],"Groups":{"x":["a"]},"Created":"2024-01-02T03:04:05Z","Count":"3","Any":{"z":1},"Old":true}`
+	if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Load[DB](path, WithDecodeStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	st := db.Stat()
+	want := map[string]uint64{"/Users/*/Nmae": 4, "/Old": 2}
+	if !reflect.DeepEqual(st.UnknownFields, want) || len(st.TypeMismatches) != 0 {
+		t.Errorf("unknown fields %v, want %v; type mismatches %v", st.UnknownFields, want, st.TypeMismatches)
+	}
+
+	// Type mismatches fail Load, but are reported as an event.
+	if err := os.WriteFile(path, []byte(`{"ID":"1","Users":[{"Age":300}],"Groups":{"x":"a"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var events bytes.Buffer
+	if _, err := Load[DB](path, WithDecodeStats(), WithEvents(&events)); err == nil {
+		t.Fatal("Load: no error for type mismatches")
+	}
+	var ev Event
+	if err := json.Unmarshal(bytes.SplitN(events.Bytes(), []byte("\n"), 2)[0], &ev); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/Groups/*", "/ID", "/Users/*/Age"}; ev.Event != "decoded" || !reflect.DeepEqual(ev.Mismatched, want) {
+		t.Errorf("event %+v, want mismatched %v", ev, want)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// dictMagic starts files compressed by DeflateDict. It is followed by
+// the ID of the dictionary, then a raw DEFLATE stream (RFC 1951).
+var dictMagic = []byte("jfz\x01")
+
+const (
+	dictIDSize = 8
+
+	// dictMaxSize is the most of a dictionary DEFLATE can use, the size
+	// of its window. Only the end of a longer dictionary is used.
+	dictMaxSize = 32 << 10
+)
+
+// DeflateDict returns a Compression, for WithCompression, that
+// compresses with DEFLATE using dict as a preset dictionary. For many
+// small files with the same structure, such as the files of a Dir,
+// this is many times smaller than compressing each file alone, which
+// has little to work with. Train a dictionary from existing files with
+// TrainDictionary, and keep it: files cannot be read without it.
+//
+// Compressed files start with an ID of the dictionary, so reading a
+// file compressed with another one is an error. Only the last 32KB of
+// dict is used. A dictionary made by TrainDictionary also suits zstd,
+// as a raw content dictionary, with an adapter like the one shown for
+// WithCompression.
+func DeflateDict(dict []byte) Compression {
+	if len(dict) > dictMaxSize {
+		dict = dict[len(dict)-dictMaxSize:]
+	}
+	sum := sha256.Sum256(dict)
+	magic := append(append([]byte{}, dictMagic...), sum[:dictIDSize]...)
+	return &dictCompression{dict: bytes.Clone(dict), magic: magic}
+}
+
+type dictCompression struct {
+	dict  []byte
+	magic []byte
+}
+
+// Magic returns the bytes that start the files compressed with this
+// dictionary, which include its ID.
+func (c *dictCompression) Magic() []byte { return c.magic }
+
+func (c *dictCompression) Compress(b []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(append([]byte{}, c.magic...))
+	w, err := flate.NewWriterDict(buf, flate.BestCompression, c.dict)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *dictCompression) Decompress(b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, c.magic) {
+		return nil, errors.New("compressed with a different dictionary")
+	}
+	r := flate.NewReaderDict(bytes.NewReader(b[len(c.magic):]), c.dict)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("deflate: %w", err)
+	}
+	return b, nil
+}
+
+// Dictionary training picks segments of the samples made of the
+// substrings, of length dictKmer, found in the most samples.
+const (
+	dictKmer    = 6
+	dictSegment = 48
+)
+
+// TrainDictionary returns a dictionary of at most size bytes for
+// DeflateDict, made from the substrings most common across samples,
+// which should be the uncompressed contents of typical files. If size
+// is zero or more than 32KB, it is 32KB. More samples make a better
+// dictionary; a few hundred is plenty.
+//
+// Training follows the approach of the zstd COVER algorithm: the
+// samples are split into as many parts as there are segments in the
+// dictionary, the segment in each that covers the most substrings
+// found in several samples is chosen, and the best segments are put
+// last, where DEFLATE reaches them most cheaply.
+func TrainDictionary(samples [][]byte, size int) []byte {
+	if size <= 0 || size > dictMaxSize {
+		size = dictMaxSize
+	}
+
+	// freq counts the samples each k-mer is found in.
+	freq := make(map[string]int)
+	total := 0
+	for _, s := range samples {
+		seen := make(map[string]bool)
+		for i := 0; i+dictKmer <= len(s); i++ {
+			k := string(s[i : i+dictKmer])
+			if !seen[k] {
+				seen[k] = true
+				freq[k]++
+			}
+		}
+		total += len(s)
+	}
+	for k, n := range freq {
+		if n < 2 {
+			delete(freq, k) // nothing to share
+		}
+	}
+
+	type segment struct {
+		b     []byte
+		score int
+	}
+	var segs []segment
+	epochs := max(size/dictSegment, 1)
+	epochSize := max(total/epochs, dictSegment)
+	si, off := 0, 0 // sample being searched, and offset in it
+	pos := 0        // offset of the start of the sample in all samples
+	for e := 0; e < epochs && si < len(samples); e++ {
+		// Find the best segment starting in bytes [e*epochSize,
+		// (e+1)*epochSize) of the samples.
+		end := (e + 1) * epochSize
+		var best segment
+		for si < len(samples) && pos+off < end {
+			s := samples[si]
+			i := off
+			for ; i < len(s) && pos+i < end; i++ {
+				if i+dictSegment > len(s) {
+					continue
+				}
+				score := 0
+				for j := i; j+dictKmer <= i+dictSegment; j++ {
+					score += freq[string(s[j:j+dictKmer])]
+				}
+				if score > best.score {
+					best = segment{b: s[i : i+dictSegment], score: score}
+				}
+			}
+			if i < len(s) {
+				off = i // rest of the sample is in the next epoch
+				break
+			}
+			pos, si, off = pos+len(s), si+1, 0
+		}
+		if best.score == 0 {
+			continue
+		}
+		for j := 0; j+dictKmer <= len(best.b); j++ {
+			delete(freq, string(best.b[j:j+dictKmer])) // covered
+		}
+		segs = append(segs, best)
+	}
+
+	// Best last, and if over size, dropped from the front.
+	sort.SliceStable(segs, func(i, j int) bool { return segs[i].score < segs[j].score })
+	var dict []byte
+	for _, s := range segs {
+		dict = append(dict, s.b...)
+	}
+	if len(dict) > size {
+		dict = dict[len(dict)-size:]
+	}
+	return dict
+}
@@ -0,0 +1,82 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeflateDict(t *testing.T) {
+	t.Parallel()
+	type Tenant struct {
+		Name     string
+		Plan     string
+		Settings map[string]bool
+		Created  string
+	}
+
+	var samples [][]byte
+	for i := 0; i < 200; i++ {
+		b, err := json.Marshal(Tenant{
+			Name:     fmt.Sprintf("tenant-%d", i),
+			Plan:     []string{"free", "team", "enterprise"}[i%3],
+			Settings: map[string]bool{"notifications": i%2 == 0, "darkMode": i%5 == 0, "betaFeatures": false},
+			Created:  fmt.Sprintf("2024-01-%02dT00:00:00Z", i%28+1),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples = append(samples, b)
+	}
+	dict := TrainDictionary(samples, 4<<10)
+	if len(dict) == 0 || len(dict) > 4<<10 {
+		t.Fatalf("dictionary of %d bytes", len(dict))
+	}
+
+	var alone, with int
+	for _, b := range samples {
+		cb, err := DeflateDict(nil).Compress(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := DeflateDict(dict).Compress(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		alone, with = alone+len(cb), with+len(db)
+	}
+	if with*2 > alone {
+		t.Errorf("compressed %d bytes with the dictionary, %d without", with, alone)
+	}
+
+	path := filepath.Join(t.TempDir(), "testdict.json")
+	db, err := New[Tenant](path, WithCompression(DeflateDict(dict)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *Tenant) { db.Name = "tenant-x"; db.Plan = "team" })
+	db.Close()
+	if err := Verify(path); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	db, err = Load[Tenant](path, WithCompression(DeflateDict(dict)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *Tenant) {
+		if db.Name != "tenant-x" {
+			t.Errorf("Name=%q", db.Name)
+		}
+	})
+	db.Close()
+
+	other := bytes.Repeat([]byte("x"), 100)
+	if _, err := Load[Tenant](path, WithCompression(DeflateDict(other))); err == nil {
+		t.Error("Load with another dictionary succeeded")
+	}
+}
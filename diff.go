@@ -0,0 +1,61 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// WithDiffs adds to each ChangeEvent a JSON Patch of the change made to
+// the data, for audit logs, replication, or updating a user interface
+// without sending all of the data.
+func WithDiffs() Option {
+	return func(o *options) { o.diffs = true }
+}
+
+// A PatchOp is an operation of a JSON Patch (RFC 6902). Paths are JSON
+// Pointers (RFC 6901). A slice of PatchOps encodes as a JSON Patch.
+type PatchOp struct {
+	Op    string          `json:"op"` // "add", "remove", or "replace"
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// jsonPatch returns a JSON Patch that turns the JSON document a into b.
+// Objects are compared member by member, in order of their keys, and
+// any other changed value is replaced whole.
+func jsonPatch(a, b []byte) ([]PatchOp, error) {
+	var ops []PatchOp
+	err := diffDocuments(a, b, func(op, ptr string, _, v any) error {
+		var raw json.RawMessage
+		if op != "remove" {
+			var err error
+			if raw, err = json.Marshal(v); err != nil {
+				return err
+			}
+		}
+		ops = append(ops, PatchOp{Op: op, Path: ptr, Value: raw})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// diffDocuments calls fn with each change that turns the JSON document
+// a into b, as jsonvalue.Diff does.
+func diffDocuments(a, b []byte, fn func(op, ptr string, old, new any) error) error {
+	av, err := jsonvalue.Decode(a)
+	if err != nil {
+		return err
+	}
+	bv, err := jsonvalue.Decode(b)
+	if err != nil {
+		return err
+	}
+	return jsonvalue.Diff(av, bv, fn)
+}
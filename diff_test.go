@@ -0,0 +1,47 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffs(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Name  string
+		Tags  []string          `json:",omitempty"`
+		Attrs map[string]string `json:",omitempty"`
+	}
+
+	path := filepath.Join(t.TempDir(), "testdiffs.json")
+	db, err := New[DB](path, WithDiffs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustWrite(t, db, func(db *DB) {
+		db.Name = "a"
+		db.Attrs = map[string]string{"x/y": "1", "gone": "2"}
+	})
+
+	events, cancel := db.Subscribe()
+	defer cancel()
+	mustWrite(t, db, func(db *DB) {
+		db.Name = "b"
+		db.Tags = []string{"t"}
+		db.Attrs = map[string]string{"x/y": "3"}
+	})
+	ev := <-events
+	b, err := json.Marshal(ev.Diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"op":"remove","path":"/Attrs/gone"},{"op":"replace","path":"/Attrs/x~1y","value":"3"},{"op":"replace","path":"/Name","value":"b"},{"op":"add","path":"/Tags","value":["t"]}]`
+	if string(b) != want {
+		t.Errorf("diff:\n%s\nwant:\n%s", b, want)
+	}
+}
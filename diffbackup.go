@@ -0,0 +1,193 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// patchSuffix ends the names of differential backups.
+const patchSuffix = ".patch"
+
+// WithDifferentialBackups makes WithScheduledBackups store most backups
+// as patches: a full copy of the file is followed by up to chain
+// backups that record only what changed since the backup before them.
+// For a large file with small changes this takes a fraction of the
+// space of full copies.
+//
+// Patches are named like full backups with ".patch" appended, and are
+// read with OpenRevision. When retention removes the full copy a patch
+// depends on, the patch is first rewritten as a full copy, so each
+// remaining backup can still be read. A revision read from a patch
+// has the same data as the file had, but its object keys are sorted.
+func WithDifferentialBackups(chain int) Option {
+	return func(o *options) { o.backup.chain = chain }
+}
+
+// backupPatch is the contents of a differential backup. Paths are JSON
+// Pointers (RFC 6901) into the previous backup. Objects are compared key
+// by key, any other changed value is replaced whole.
+type backupPatch struct {
+	Set    map[string]json.RawMessage `json:"set,omitempty"`
+	Delete []string                   `json:"delete,omitempty"`
+}
+
+func isPatch(name string) bool { return strings.HasSuffix(name, patchSuffix) }
+
+// readBackup returns the contents of the backup names[i], applying
+// patches to the full backup before it as needed.
+func (p *JSONFile[Data]) readBackup(names []string, i int) ([]byte, error) {
+	j := i
+	for j >= 0 && isPatch(names[j]) {
+		j--
+	}
+	if j < 0 {
+		return nil, fmt.Errorf("backup %s has no full backup before it", names[i])
+	}
+	b, err := os.ReadFile(filepath.Join(p.opts.backup.dir, names[j]))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names[j+1 : i+1] {
+		pb, err := os.ReadFile(filepath.Join(p.opts.backup.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if b, err = applyPatch(b, pb); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return b, nil
+}
+
+// trailingPatches counts the patches at the end of names.
+func trailingPatches(names []string) int {
+	n := 0
+	for i := len(names) - 1; i >= 0 && isPatch(names[i]); i-- {
+		n++
+	}
+	return n
+}
+
+// consolidate rewrites the patch names[i] as a full backup and returns
+// its new name.
+func (p *JSONFile[Data]) consolidate(names []string, i int) (string, error) {
+	b, err := p.readBackup(names, i)
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(names[i], patchSuffix)
+	if err := atomicWrite(osFS{}, filepath.Join(p.opts.backup.dir, name), b, true, p.opts.syncDir, nil); err != nil {
+		return "", err
+	}
+	if err := os.Remove(filepath.Join(p.opts.backup.dir, names[i])); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// diffJSON returns a patch that turns the JSON document a into b.
+func diffJSON(a, b []byte) ([]byte, error) {
+	patch := backupPatch{Set: make(map[string]json.RawMessage)}
+	err := diffDocuments(a, b, func(op, ptr string, _, v any) error {
+		if op == "remove" {
+			patch.Delete = append(patch.Delete, ptr)
+			return nil
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		patch.Set[ptr] = raw
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(patch.Delete)
+	return json.Marshal(patch)
+}
+
+// applyPatch applies a patch made by diffJSON to the JSON document b.
+func applyPatch(b, pb []byte) ([]byte, error) {
+	var patch backupPatch
+	if err := json.Unmarshal(pb, &patch); err != nil {
+		return nil, err
+	}
+	root, err := jsonvalue.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, ptr := range patch.Delete {
+		parent, key, err := pointerParent(root, ptr)
+		if err != nil {
+			return nil, err
+		}
+		delete(parent, key)
+	}
+	ptrs := make([]string, 0, len(patch.Set))
+	for ptr := range patch.Set {
+		ptrs = append(ptrs, ptr)
+	}
+	sort.Strings(ptrs)
+	for _, ptr := range ptrs {
+		v, err := jsonvalue.Decode(patch.Set[ptr])
+		if err != nil {
+			return nil, err
+		}
+		if ptr == "" {
+			root = v
+			continue
+		}
+		parent, key, err := pointerParent(root, ptr)
+		if err != nil {
+			return nil, err
+		}
+		parent[key] = v
+	}
+	return json.Marshal(root)
+}
+
+// pointerParent returns the object holding the value at ptr, and the
+// key of the value in it.
+func pointerParent(root any, ptr string) (map[string]any, string, error) {
+	parts := strings.Split(ptr, "/")
+	if parts[0] != "" || len(parts) < 2 {
+		return nil, "", fmt.Errorf("invalid JSON pointer %q", ptr)
+	}
+	v := root
+	for _, part := range parts[1 : len(parts)-1] {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("JSON pointer %q: not an object", ptr)
+		}
+		v = m[jsonvalue.UnescapePointer(part)]
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, "", fmt.Errorf("JSON pointer %q: not an object", ptr)
+	}
+	return m, jsonvalue.UnescapePointer(parts[len(parts)-1]), nil
+}
+
+// jsonEqual reports whether two JSON documents hold the same values.
+func jsonEqual(a, b []byte) bool {
+	av, err := jsonvalue.Decode(a)
+	if err != nil {
+		return false
+	}
+	bv, err := jsonvalue.Decode(b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
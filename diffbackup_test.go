@@ -0,0 +1,101 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffJSON(t *testing.T) {
+	t.Parallel()
+	a := `{"keep":1,"gone":true,"a/b~c":{"x":1,"y":[1,2]},"n":12345678901234567890}`
+	b := `{"keep":1,"a/b~c":{"x":2,"y":[1,2,3]},"n":12345678901234567891,"new":null}`
+	patch, err := diffJSON([]byte(a), []byte(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(patch), "keep") {
+		t.Errorf("patch %s contains unchanged value", patch)
+	}
+	got, err := applyPatch([]byte(a), patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(got, []byte(b)) {
+		t.Errorf("applyPatch = %s, want %s", got, b)
+	}
+
+	// Replacing the whole document.
+	patch, err = diffJSON([]byte(a), []byte(`[1]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := applyPatch([]byte(a), patch); err != nil || string(got) != `[1]` {
+		t.Errorf("applyPatch = %s, %v, want [1]", got, err)
+	}
+}
+
+func TestDifferentialBackups(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Big []string
+		Val int
+	}
+
+	dir := t.TempDir()
+	backupDir := filepath.Join(dir, "backups")
+	db, err := New[DB](filepath.Join(dir, "testdiff.json"),
+		WithScheduledBackups(backupDir, 0, 4), WithDifferentialBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Big = make([]string, 100) })
+	var times []time.Time
+	for i := 1; i <= 6; i++ {
+		time.Sleep(time.Millisecond) // distinct backup names
+		mustWrite(t, db, func(db *DB) { db.Val = i })
+		times = append(times, time.Now())
+	}
+
+	backups, err := db.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Backups alternate a full copy and two patches, and hold Val 3
+	// to 6. Val 3 was a patch, consolidated when the full copy of
+	// Val 2 was removed.
+	var kinds []bool
+	for _, b := range backups {
+		kinds = append(kinds, isPatch(b))
+	}
+	if got, want := kinds, []bool{false, true, false, true}; !equalBools(got, want) {
+		t.Errorf("backup patches = %v, want %v: %v", got, want, backups)
+	}
+	for i := 3; i <= 6; i++ {
+		r, err := db.OpenRevision(times[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Read(func(db *DB) {
+			if db.Val != i || len(db.Big) != 100 {
+				t.Errorf("revision %d: Val=%d len(Big)=%d", i, db.Val, len(db.Big))
+			}
+		})
+	}
+}
+
+func equalBools(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Dir manages a set of named JSON files in one directory, opened with
+// the same options. Create a Dir with OpenDir and open the files in it
+// with Open.
+type Dir struct {
+	path string
+	opts []Option
+
+	mu    sync.Mutex
+	files map[string]any // name -> *JSONFile[Data]
+}
+
+// OpenDir returns a Dir for the existing directory at path. The options
+// are used for every file opened in the directory.
+func OpenDir(path string, opts ...Option) (*Dir, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("jsonfile.OpenDir: %w", err)
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("jsonfile.OpenDir: %s is not a directory", path)
+	}
+	return &Dir{path: path, opts: opts, files: make(map[string]any)}, nil
+}
+
+// Open opens the file with the given name in d, creating it if it does
+// not exist. The file is stored as name + ".json".
+//
+// Opening the same name again returns the same JSONFile, which must
+// have the same Data type, unless it has been closed, when the file is
+// loaded again. Extra options are added to the Dir's options when the
+// file is opened.
+func Open[Data any](d *Dir, name string, opts ...Option) (*JSONFile[Data], error) {
+	if err := validName(name); err != nil {
+		return nil, fmt.Errorf("jsonfile.Open: %w", err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if f, ok := d.openFile(name); ok {
+		p, ok := f.(*JSONFile[Data])
+		if !ok {
+			return nil, fmt.Errorf("jsonfile.Open: %q is open with type %T", name, f)
+		}
+		return p, nil
+	}
+
+	path := d.filePath(name)
+	opts = append(append([]Option{}, d.opts...), opts...)
+	p, err := Load[Data](path, opts...)
+	if errors.Is(err, os.ErrNotExist) {
+		p, err = New[Data](path, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.files[name] = p
+	return p, nil
+}
+
+// openFile returns the JSONFile opened for name, forgetting it if it
+// has been closed. It is called with d.mu held.
+func (d *Dir) openFile(name string) (any, bool) {
+	f, ok := d.files[name]
+	if ok && f.(dirFile).isClosed() {
+		delete(d.files, name)
+		return nil, false
+	}
+	return f, ok
+}
+
+// Close closes the JSONFiles opened in d, returning the errors of any
+// that fail. Opening a name again after Close loads the file again.
+func (d *Dir) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var errs []error
+	for name, f := range d.files {
+		if err := f.(dirFile).Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(d.files, name)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("Dir.Close: %w", err)
+	}
+	return nil
+}
+
+// List returns the names of the files in d, sorted.
+func (d *Dir) List() ([]string, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("Dir.List: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if ok && e.Type().IsRegular() && validName(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete deletes the named file from d, leaving a ".deleted" copy as
+// JSONFile.Delete does. A JSONFile previously opened for it is closed.
+func (d *Dir) Delete(name string) error {
+	if err := validName(name); err != nil {
+		return fmt.Errorf("Dir.Delete: %w", err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	if f, ok := d.openFile(name); ok {
+		err = f.(dirFile).Delete()
+	} else {
+		err = tombstone(d.filePath(name))
+	}
+	if err != nil {
+		return fmt.Errorf("Dir.Delete: %w", err)
+	}
+	delete(d.files, name)
+	return nil
+}
+
+func (d *Dir) filePath(name string) string {
+	return filepath.Join(d.path, name+".json")
+}
+
+// validName reports whether name can be used as a file name in a Dir.
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.ContainsRune(name, 0) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDir(t *testing.T) {
+	t.Parallel()
+	type Users struct{ Names []string }
+	type Settings struct{ Theme string }
+
+	path := t.TempDir()
+	d, err := OpenDir(path, WithExclusiveLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := Open[Users](d, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, users, func(u *Users) { u.Names = []string{"alice"} })
+	settings, err := Open[Settings](d, "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, settings, func(s *Settings) { s.Theme = "dark" })
+
+	if again, err := Open[Users](d, "users"); err != nil || again != users {
+		t.Errorf("second Open = %p, %v, want %p", again, err, users)
+	}
+	if _, err := Open[Settings](d, "users"); err == nil {
+		t.Error("Open with different type succeeded")
+	}
+	if _, err := Open[Users](d, "../users"); err == nil {
+		t.Error("Open with bad name succeeded")
+	}
+
+	names, err := d.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"settings", "users"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List=%v, want %v", names, want)
+	}
+
+	// The shared lock option was applied.
+	if _, err := Load[Users](filepath.Join(path, "users.json"), WithExclusiveLock()); !errors.Is(err, ErrLocked) {
+		t.Errorf("Load of open file err=%v, want %v", err, ErrLocked)
+	}
+
+	if err := d.Delete("users"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "users.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("users.json still exists: %v", err)
+	}
+	names, _ = d.List()
+	if want := []string{"settings"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List=%v after Delete, want %v", names, want)
+	}
+
+	// Deleted names can be opened again, starting empty.
+	users, err = Open[Users](d, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users.Read(func(u *Users) {
+		if len(u.Names) != 0 {
+			t.Errorf("reopened Names=%v, want empty", u.Names)
+		}
+	})
+}
+
+func TestOpenDirMissing(t *testing.T) {
+	t.Parallel()
+	if _, err := OpenDir(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenDir err=%v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestDirClose(t *testing.T) {
+	t.Parallel()
+	type Users struct{ Names []string }
+
+	path := t.TempDir()
+	d, err := OpenDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := Open[Users](d, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, users, func(u *Users) { u.Names = []string{"alice"} })
+
+	// A closed file is loaded again.
+	users.Close()
+	again, err := Open[Users](d, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == users {
+		t.Fatal("Open returned the closed JSONFile")
+	}
+	mustWrite(t, again, func(u *Users) { u.Names = append(u.Names, "bob") })
+
+	// A failed Delete leaves the file open in d.
+	if err := os.Chmod(path, 0500); err != nil {
+		t.Fatal(err)
+	}
+	err = d.Delete("users")
+	os.Chmod(path, 0700)
+	if err == nil {
+		t.Skip("Delete succeeded in a read-only directory, running as root?")
+	}
+	if f, err := Open[Users](d, "users"); err != nil || f != again {
+		t.Errorf("Open after failed Delete = %p, %v, want %p", f, err, again)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := again.Write(func(u *Users) error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Dir.Close err=%v, want %v", err, ErrClosed)
+	}
+	users, err = Open[Users](d, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer users.Close()
+	users.Read(func(u *Users) {
+		if want := []string{"alice", "bob"}; !reflect.DeepEqual(u.Names, want) {
+			t.Errorf("Names=%v, want %v", u.Names, want)
+		}
+	})
+}
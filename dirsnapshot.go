@@ -0,0 +1,154 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// dirFile is the part of a *JSONFile[Data], for any Data, used by Dir.
+type dirFile interface {
+	Close() error
+	Delete() error
+	isClosed() bool
+	pauseWrites() bool
+	resumeWrites()
+	pausedContents() ([]byte, uint64)
+	encodeFile(b []byte) ([]byte, error)
+}
+
+// pauseWrites waits for any Write in progress and holds off new ones
+// until resumeWrites. It reports false, holding nothing, if p is closed.
+func (p *JSONFile[Data]) pauseWrites() bool {
+	p.writing <- struct{}{}
+	if p.closed {
+		<-p.writing
+		return false
+	}
+	return true
+}
+
+func (p *JSONFile[Data]) resumeWrites() { <-p.writing }
+
+// pausedContents returns the data, including any changes delayed by
+// WithAutosave, and its generation. It is called between pauseWrites
+// and resumeWrites.
+func (p *JSONFile[Data]) pausedContents() ([]byte, uint64) { return p.bytes, p.gen }
+
+// dirManifest is the manifest.json of an archive written by SnapshotAll.
+type dirManifest struct {
+	Time  time.Time          `json:"time"`
+	Files []dirManifestEntry `json:"files"`
+}
+
+type dirManifestEntry struct {
+	Name     string `json:"name"`               // file name in the archive
+	Revision uint64 `json:"revision,omitempty"` // generation, as reported by Stat, if open
+	Open     bool   `json:"open"`               // whether the file was open in the Dir
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// SnapshotAll writes a tar archive of every file in d to w, for backing
+// up a whole application. The archive holds manifest.json, listing each
+// file with its size, SHA-256, and for files open in d, the generation
+// of its data, followed by the files as they would be written to disk.
+//
+// The open files are captured together: SnapshotAll waits for the
+// Writes in progress on all of them, takes their data, and lets Writes
+// continue before encoding and writing the archive, so the archive holds
+// a single point in time and Writes are paused only briefly. Files in
+// the directory not open in d are read from disk as they are.
+func (d *Dir) SnapshotAll(w io.Writer) error {
+	if err := d.snapshotAll(w); err != nil {
+		return fmt.Errorf("Dir.SnapshotAll: %w", err)
+	}
+	return nil
+}
+
+func (d *Dir) snapshotAll(w io.Writer) error {
+	names, err := d.List()
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	type captured struct {
+		f    dirFile
+		data []byte
+		gen  uint64
+	}
+	open := make(map[string]captured)
+	var paused []dirFile
+	for name, f := range d.files {
+		f := f.(dirFile)
+		if f.pauseWrites() {
+			paused = append(paused, f)
+			data, gen := f.pausedContents()
+			open[name] = captured{f: f, data: data, gen: gen}
+		}
+	}
+	now := time.Now()
+	for _, f := range paused {
+		f.resumeWrites()
+	}
+	d.mu.Unlock()
+
+	for name := range open {
+		names = append(names, name) // may be new, not yet on disk
+	}
+	sort.Strings(names)
+	manifest := dirManifest{Time: now.UTC()}
+	var contents [][]byte
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		ent := dirManifestEntry{Name: name + ".json"}
+		var b []byte
+		if c, ok := open[name]; ok {
+			ent.Open, ent.Revision = true, c.gen
+			b, err = c.f.encodeFile(c.data)
+		} else {
+			b, err = os.ReadFile(d.filePath(name))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		sum := sha256.Sum256(b)
+		ent.Size, ent.SHA256 = int64(len(b)), hex.EncodeToString(sum[:])
+		manifest.Files = append(manifest.Files, ent)
+		contents = append(contents, b)
+	}
+	mb, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	add := func(name string, b []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(b)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(b)
+		return err
+	}
+	if err := add("manifest.json", append(mb, '\n')); err != nil {
+		return err
+	}
+	for i, ent := range manifest.Files {
+		if err := add(ent.Name, contents[i]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
@@ -0,0 +1,80 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotAll(t *testing.T) {
+	t.Parallel()
+	type Users struct{ Names []string }
+
+	path := t.TempDir()
+	d, err := OpenDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := Open[Users](d, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, users, func(u *Users) { u.Names = []string{"alice"} })
+	if err := os.WriteFile(filepath.Join(path, "closed.json"), []byte(`{"a":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := d.SnapshotAll(&buf); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, users, func(u *Users) { u.Names = nil }) // not in the snapshot
+
+	files := make(map[string][]byte)
+	var order []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = b
+		order = append(order, hdr.Name)
+	}
+	if len(order) != 3 || order[0] != "manifest.json" {
+		t.Fatalf("archive holds %v", order)
+	}
+	var m dirManifest
+	if err := json.Unmarshal(files["manifest.json"], &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 2 || m.Files[0].Name != "closed.json" || m.Files[1].Name != "users.json" {
+		t.Fatalf("manifest: %+v", m.Files)
+	}
+	if ent := m.Files[1]; !ent.Open || ent.Revision != users.Stat().Generation-1 {
+		t.Errorf("users entry: %+v", ent)
+	}
+	if got := string(files["closed.json"]); got != `{"a":1}` {
+		t.Errorf("closed.json: %s", got)
+	}
+	var u Users
+	if err := json.Unmarshal(files["users.json"], &u); err != nil {
+		t.Fatal(err)
+	}
+	if len(u.Names) != 1 || u.Names[0] != "alice" {
+		t.Errorf("users.json: %s", files["users.json"])
+	}
+}
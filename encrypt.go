@@ -0,0 +1,242 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// A Cipher encrypts the file, for WithCipher.
+type Cipher interface {
+	// Magic returns the bytes every encrypted file starts with.
+	// Files that do not start with them are read unencrypted.
+	Magic() []byte
+	Encrypt(b []byte) ([]byte, error)
+	Decrypt(b []byte) ([]byte, error)
+}
+
+// encryptedMagic starts every file encrypted by WithEncryption. It
+// cannot start a JSON document or a gzip file.
+var encryptedMagic = []byte("jfe\x01")
+
+type encryptionOptions struct {
+	cipher Cipher
+	err    error // from WithEncryption
+}
+
+// WithEncryption encrypts the file with AES-GCM using key, which must
+// be 16, 24, or 32 bytes long to select AES-128, AES-192, or AES-256.
+// Each Write encrypts with a new random nonce. Load decrypts the file,
+// and fails if it was encrypted with another key. It is WithCipher
+// with a Cipher provided by the package.
+//
+// Files and backups encrypted with any of oldKeys are also decrypted,
+// so that programs can read the file while its key is changed by
+// RotateKey.
+func WithEncryption(key []byte, oldKeys ...[]byte) Option {
+	var e encryptionOptions
+	c, err := newAESGCM(key, oldKeys)
+	if err != nil {
+		e.err = fmt.Errorf("WithEncryption: %w", err)
+	} else {
+		e.cipher = c
+	}
+	return func(o *options) { o.encryption = e }
+}
+
+// WithCipher encrypts the file with c, after any compression. Load
+// decrypts it. A file that is not encrypted fails to load with an
+// error wrapping ErrCorrupt, as anyone able to write the file could
+// otherwise replace it with data of their own. To turn encryption on
+// for an existing file, open it WithUnencryptedFiles until its next
+// Write has encrypted it.
+//
+// Keys can be managed with age (age-encryption.org) using a small
+// adapter for filippo.io/age. Recipients can then be changed without
+// touching the application's code: the next Write encrypts the file to
+// the new ones.
+//
+//	type ageCipher struct {
+//		recipients []age.Recipient
+//		identities []age.Identity
+//	}
+//
+//	func (ageCipher) Magic() []byte { return []byte("age-encryption.org/") }
+//
+//	func (c ageCipher) Encrypt(b []byte) ([]byte, error) {
+//		var buf bytes.Buffer
+//		w, err := age.Encrypt(&buf, c.recipients...)
+//		if err != nil {
+//			return nil, err
+//		}
+//		if _, err := w.Write(b); err != nil {
+//			return nil, err
+//		}
+//		err = w.Close()
+//		return buf.Bytes(), err
+//	}
+//
+//	func (c ageCipher) Decrypt(b []byte) ([]byte, error) {
+//		r, err := age.Decrypt(bytes.NewReader(b), c.identities...)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return io.ReadAll(r)
+//	}
+//
+// Backups, commits made by WithGit, and copies made by Archive are
+// encrypted as the file is, but mirrors written by WithMirror are not.
+// WithCipher and WithEncryption cannot be used with WithHuJSON or
+// WithDifferentialBackups.
+func WithCipher(c Cipher) Option {
+	return func(o *options) { o.encryption = encryptionOptions{cipher: c} }
+}
+
+// WithUnencryptedFiles reads files and backups that are not encrypted,
+// with WithEncryption or WithCipher, so that encryption can be turned
+// on for an existing file. The next Write encrypts it. Remove the
+// option once the files are encrypted, as it lets anyone who can write
+// them replace the data.
+func WithUnencryptedFiles() Option {
+	return func(o *options) { o.unencrypted = true }
+}
+
+func (o *options) encrypted() bool {
+	return o.encryption.cipher != nil || o.encryption.err != nil
+}
+
+// encrypt returns the file contents b encrypted, if encryption is
+// enabled.
+func (o *options) encrypt(b []byte) ([]byte, error) {
+	if o.encryption.cipher == nil {
+		return b, nil
+	}
+	return o.encryption.cipher.Encrypt(b)
+}
+
+// decrypt returns the file contents b decrypted, if they are encrypted.
+func (o *options) decrypt(b []byte) ([]byte, error) {
+	c := o.encryption.cipher
+	if c == nil {
+		if bytes.HasPrefix(b, encryptedMagic) {
+			return nil, errors.New("file is encrypted, open it WithEncryption")
+		}
+		return b, nil
+	}
+	if !bytes.HasPrefix(b, c.Magic()) {
+		if o.unencrypted {
+			return b, nil
+		}
+		return nil, fmt.Errorf("%w: file is not encrypted", ErrCorrupt)
+	}
+	b, err := c.Decrypt(b)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return b, nil
+}
+
+// aesGCM is the Cipher of WithEncryption. An encrypted file is
+// encryptedMagic, the nonce, and the sealed contents.
+type aesGCM struct {
+	key  []byte
+	aead cipher.AEAD
+	old  []cipher.AEAD // also tried by Decrypt
+}
+
+func newAESGCM(key []byte, oldKeys [][]byte) (*aesGCM, error) {
+	newAEAD := func(key []byte) (cipher.AEAD, error) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	c := &aesGCM{key: bytes.Clone(key), aead: aead}
+	for _, k := range oldKeys {
+		aead, err := newAEAD(k)
+		if err != nil {
+			return nil, fmt.Errorf("old key: %w", err)
+		}
+		c.old = append(c.old, aead)
+	}
+	return c, nil
+}
+
+func (*aesGCM) Magic() []byte { return encryptedMagic }
+
+func (c *aesGCM) Encrypt(b []byte) ([]byte, error) {
+	n := len(encryptedMagic) + c.aead.NonceSize()
+	out := make([]byte, n, n+len(b)+c.aead.Overhead())
+	copy(out, encryptedMagic)
+	nonce := out[len(encryptedMagic):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(out, nonce, b, encryptedMagic), nil
+}
+
+func (c *aesGCM) Decrypt(b []byte) ([]byte, error) {
+	b = b[len(encryptedMagic):]
+	if len(b) < c.aead.NonceSize()+c.aead.Overhead() {
+		return nil, errors.New("file is truncated")
+	}
+	nonce, sealed := b[:c.aead.NonceSize()], b[c.aead.NonceSize():]
+	out, err := c.aead.Open(nil, nonce, sealed, encryptedMagic)
+	for _, aead := range c.old {
+		if err == nil {
+			break
+		}
+		out, err = aead.Open(nil, nonce, sealed, encryptedMagic)
+	}
+	return out, err
+}
+
+// RotateKey re-encrypts the file, opened WithEncryption(oldKey), with
+// newKey, atomically replacing it. Later Writes use newKey, and oldKey
+// is still used to decrypt backups made before the rotation. Other
+// programs using the file can open it with WithEncryption(newKey,
+// oldKey) before the rotation, so that they can read it before and
+// after.
+func (p *JSONFile[Data]) RotateKey(oldKey, newKey []byte) error {
+	p.writing <- struct{}{}
+	defer func() { <-p.writing }()
+	if p.closed {
+		return fmt.Errorf("JSONFile.RotateKey: %w", ErrClosed)
+	}
+	if p.readOnly {
+		return fmt.Errorf("JSONFile.RotateKey: %w", ErrReadOnly)
+	}
+	cur, ok := p.opts.encryption.cipher.(*aesGCM)
+	if !ok {
+		return errors.New("JSONFile.RotateKey: file not opened WithEncryption")
+	}
+	if subtle.ConstantTimeCompare(cur.key, oldKey) != 1 {
+		return errors.New("JSONFile.RotateKey: old key is not the file's key")
+	}
+	if err := p.flush(); err != nil {
+		return fmt.Errorf("JSONFile.RotateKey: %w", err)
+	}
+	c, err := newAESGCM(newKey, nil)
+	if err != nil {
+		return fmt.Errorf("JSONFile.RotateKey: %w", err)
+	}
+	c.old = append([]cipher.AEAD{cur.aead}, cur.old...)
+	p.opts.encryption.cipher = c
+	if err := p.writeFile(p.bytes, true); err != nil {
+		p.opts.encryption.cipher = cur
+		return fmt.Errorf("JSONFile.RotateKey: %w", err)
+	}
+	return nil
+}
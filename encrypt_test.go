@@ -0,0 +1,174 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryption(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Secret string }
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testencrypt.json")
+	key := bytes.Repeat([]byte{1}, 32)
+	opts := []Option{WithEncryption(key), WithCompression(Gzip), WithScheduledBackups(filepath.Join(dir, "backups"), 0, 0)}
+	db, err := New[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Secret = "hunter2" })
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, encryptedMagic) || bytes.Contains(b, []byte("hunter2")) {
+		t.Errorf("file is not encrypted: %q", b)
+	}
+	if err := Verify(path); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := db.Scrub(false); err != nil {
+		t.Errorf("Scrub: %v", err)
+	}
+	if err := db.Backup(); err != nil {
+		t.Errorf("Backup: %v", err)
+	}
+
+	db, err = Load[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Secret != "hunter2" {
+			t.Errorf("Secret=%q after Load", db.Secret)
+		}
+	})
+
+	if _, err := Load[DB](path); err == nil {
+		t.Error("Load without the key succeeded")
+	}
+	if _, err := Load[DB](path, WithEncryption(bytes.Repeat([]byte{2}, 32))); err == nil {
+		t.Error("Load with another key succeeded")
+	}
+	if _, err := Load[DB](path, WithEncryption([]byte("short"))); err == nil {
+		t.Error("Load with an invalid key succeeded")
+	}
+
+	// A file replaced with plain JSON is refused.
+	if err := os.WriteFile(path, []byte(`{"Secret":"forged"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load[DB](path, opts...); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Load of a plain file err=%v, want ErrCorrupt", err)
+	}
+}
+
+// xorCipher is a Cipher for tests.
+type xorCipher byte
+
+func (xorCipher) Magic() []byte { return []byte("xor:") }
+
+func (c xorCipher) Encrypt(b []byte) ([]byte, error) {
+	return append([]byte("xor:"), c.xor(b)...), nil
+}
+
+func (c xorCipher) Decrypt(b []byte) ([]byte, error) {
+	return c.xor(b[len("xor:"):]), nil
+}
+
+func (c xorCipher) xor(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, x := range b {
+		out[i] = x ^ byte(c)
+	}
+	return out
+}
+
+func TestCipher(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Secret string }
+
+	path := filepath.Join(t.TempDir(), "testcipher.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Secret = "hunter2" })
+
+	// Switching to a Cipher reads the plain file, when allowed, and
+	// encrypts it.
+	if _, err := Load[DB](path, WithCipher(xorCipher(0x20))); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Load of a plain file err=%v, want ErrCorrupt", err)
+	}
+	db, err = Load[DB](path, WithCipher(xorCipher(0x20)), WithUnencryptedFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Secret = "swordfish" })
+	if b, _ := os.ReadFile(path); !bytes.HasPrefix(b, []byte("xor:")) {
+		t.Errorf("file is not encrypted: %q", b)
+	}
+	db, err = Load[DB](path, WithCipher(xorCipher(0x20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Secret != "swordfish" {
+			t.Errorf("Secret=%q after Load", db.Secret)
+		}
+	})
+}
+
+func TestRotateKey(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Secret string }
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testrotate.json")
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 16)
+	backups := WithScheduledBackups(filepath.Join(dir, "backups"), 0, 0)
+	db, err := New[DB](path, WithEncryption(key1), backups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Secret = "hunter2" })
+	if err := db.RotateKey(key2, key2); err == nil {
+		t.Error("RotateKey with the wrong old key succeeded")
+	}
+	if err := db.RotateKey(key1, key2); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Scrub(false); err != nil {
+		t.Errorf("Scrub after RotateKey: %v", err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Secret = "swordfish" })
+
+	if _, err := Load[DB](path, WithEncryption(key1)); err == nil {
+		t.Error("Load with the old key succeeded")
+	}
+	db, err = Load[DB](path, WithEncryption(key2, key1), backups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Secret != "swordfish" {
+			t.Errorf("Secret=%q after Load", db.Secret)
+		}
+	})
+	// The first backup was encrypted with key1.
+	names, err := db.Backups()
+	if err != nil || len(names) == 0 {
+		t.Fatalf("Backups = %v, %v", names, err)
+	}
+	if _, err := Load[DB](names[0], WithEncryption(key2, key1)); err != nil {
+		t.Errorf("Load of a backup encrypted with the old key: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// WithEqual sets how a Write decides it changed nothing, in which case
+// the file is not written. By default the data must encode to the same
+// bytes as before, which misfires for types whose encoding varies from
+// one call to the next, such as those with a MarshalJSON that writes
+// map members in random order. With WithEqual, the data is unchanged
+// if equal reports that old, the data before the Write, and new, the
+// data after it, are equal. If equal is nil, every Write is written,
+// even if it changes nothing.
+//
+// equal must not modify the data.
+func WithEqual[Data any](equal func(old, new *Data) bool) Option {
+	return func(o *options) {
+		o.equalSet = true
+		o.equal = nil
+		if equal == nil {
+			return
+		}
+		o.equal = func(old, new any) (bool, error) {
+			a, ok1 := old.(*Data)
+			b, ok2 := new.(*Data)
+			if !ok1 || !ok2 {
+				return false, fmt.Errorf("WithEqual for %T used with %T", a, new)
+			}
+			return equal(a, b), nil
+		}
+	}
+}
+
+// unchanged reports whether data, encoded as b, is the same as the
+// data before a Write. It is called with p.writing held.
+func (p *JSONFile[Data]) unchanged(data *Data, b []byte) (bool, error) {
+	switch {
+	case !p.opts.equalSet:
+		return bytes.Equal(b, p.bytes), nil
+	case p.opts.equal == nil:
+		return false, nil
+	}
+	return p.opts.equal(p.data, data)
+}
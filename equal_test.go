@@ -0,0 +1,49 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val  int
+		Note string // not significant
+	}
+	path := filepath.Join(t.TempDir(), "testequal.json")
+	db, err := New[DB](path, WithEqual(func(old, new *DB) bool { return old.Val == new.Val }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	gen := db.Stat().Generation
+	mustWrite(t, db, func(db *DB) { db.Note = "ignored" })
+	if got := db.Stat().Generation; got != gen {
+		t.Errorf("Generation=%d after an equal Write, want %d", got, gen)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if got := db.Stat().Generation; got != gen+1 {
+		t.Errorf("Generation=%d after a change, want %d", got, gen+1)
+	}
+
+	// With a nil equal, every Write is written.
+	path = filepath.Join(t.TempDir(), "testequalnil.json")
+	db2, err := New[DB](path, WithEqual[DB](nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	gen = db2.Stat().Generation
+	res, err := db2.WriteInfo(context.Background(), func(db *DB) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Changed || db2.Stat().Generation != gen+1 {
+		t.Errorf("Write of no change with nil equal: %+v, want written", res)
+	}
+}
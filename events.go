@@ -0,0 +1,78 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// An Event is a line written by WithEvents.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"` // see WithEvents
+	Path     string    `json:"path"`
+	Revision uint64    `json:"revision,omitempty"` // generation of the data, for "wrote" and "reloaded"
+	Bytes    int       `json:"bytes,omitempty"`    // size of the data, for "wrote"
+	From     string    `json:"from,omitempty"`     // path of the copy, for "migrated" and "recovered"
+	Error    string    `json:"error,omitempty"`    // for "corrupted", "recovered", "conflicted", "failed", "rolledback", and "unreplicated"
+
+	// Unknown and Mismatched are the paths of members with no field
+	// and of the wrong type, for "decoded".
+	Unknown    []string `json:"unknown,omitempty"`
+	Mismatched []string `json:"mismatched,omitempty"`
+}
+
+type eventSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// WithEvents writes an Event to w as a line of JSON each time the file
+// changes state, so supervisors, wrappers, and tests can follow what
+// the JSONFile does without parsing logs. The events are:
+//
+//	created       New created the file
+//	opened        Load read the file
+//	migrated      Load converted the file from a legacy format, or a Registry migrated it
+//	wrote         the file was written with the data of a Revision
+//	rolledback    a Write's fn returned an Error, so the data was not changed
+//	conflicted    a write found the file changed by another program
+//	failed        writing the file failed with the Error
+//	reloaded      the data was replaced by the file's, by Reload or Watch
+//	corrupted     Load found the file corrupt
+//	recovered     LoadWithRecovery loaded the data From a recovery backup
+//	decoded       the file was read with the issues counted by WithDecodeStats
+//	unreplicated  WithReplica failed to copy a version, with the Error
+//	closed        Close, Delete, or Archive ended use of the file
+//
+// Errors writing to w are ignored. Programs should expect new events
+// and members to be added.
+func WithEvents(w io.Writer) Option {
+	sink := &eventSink{w: w}
+	return func(o *options) { o.events = sink }
+}
+
+// event writes ev, filling in its time and path.
+func (p *JSONFile[Data]) event(ev Event) {
+	sink := p.opts.events
+	if sink == nil && p.opts.logger == nil {
+		return
+	}
+	ev.Time = time.Now()
+	ev.Path = p.path
+	p.opts.logEvent(ev)
+	if sink == nil {
+		return
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.w.Write(append(b, '\n'))
+}
@@ -0,0 +1,58 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	var buf bytes.Buffer
+	path := filepath.Join(t.TempDir(), "testevents.json")
+	opts := []Option{WithEvents(&buf), WithRecoveryBackup()}
+	db, err := New[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	db.Close()
+	os.WriteFile(path, []byte("{"), 0666)
+	if _, err := Load[DB](path, opts...); err == nil {
+		t.Fatal("Load of corrupt file succeeded")
+	}
+	db, _, err = LoadWithRecovery[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var ev Event
+		if err := json.Unmarshal(s.Bytes(), &ev); err != nil {
+			t.Fatalf("%s: %v", s.Bytes(), err)
+		}
+		if ev.Path != path || ev.Time.IsZero() {
+			t.Errorf("event %s", s.Bytes())
+		}
+		got = append(got, ev.Event)
+		if ev.Event == "wrote" && ev.Revision == 0 {
+			t.Errorf("wrote event without revision: %s", s.Bytes())
+		}
+	}
+	// Load is tried first by LoadWithRecovery too.
+	want := "wrote created wrote closed corrupted corrupted recovered"
+	if strings.Join(got, " ") != want {
+		t.Errorf("events: %s\nwant:   %s", strings.Join(got, " "), want)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+type fieldCodec struct {
+	pointer string
+	encode  func([]byte) ([]byte, error)
+	decode  func([]byte) ([]byte, error)
+}
+
+// WithFieldCodec stores the value at pointer, a JSON Pointer (RFC 6901)
+// such as "/Secrets", encoded by encode. Use it to encrypt or compress
+// part of a file while the rest stays readable.
+//
+// encode is called with the JSON encoding of the value, and the bytes
+// it returns are stored in its place as a base64 JSON string. decode
+// reverses encode when the file is read. If the file has no value at
+// pointer, it is left alone. Codecs are applied in the order given,
+// so an earlier codec must not contain the pointer of a later one.
+func WithFieldCodec(pointer string, encode, decode func([]byte) ([]byte, error)) Option {
+	return func(o *options) {
+		o.fieldCodecs = append(o.fieldCodecs, fieldCodec{pointer, encode, decode})
+	}
+}
+
+// encodeFields applies the field codecs to the encoded data b.
+func (o *options) encodeFields(b []byte) ([]byte, error) {
+	for _, c := range o.fieldCodecs {
+		var err error
+		b, err = replaceAt(b, c.pointer, func(v []byte) ([]byte, error) {
+			enc, err := c.encode(v)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(enc) // []byte is encoded as base64
+		})
+		if err != nil {
+			return nil, fmt.Errorf("field codec %s: %w", c.pointer, err)
+		}
+	}
+	return b, nil
+}
+
+// decodeFields reverses encodeFields.
+func (o *options) decodeFields(b []byte) ([]byte, error) {
+	for i := len(o.fieldCodecs) - 1; i >= 0; i-- {
+		c := o.fieldCodecs[i]
+		var err error
+		b, err = replaceAt(b, c.pointer, func(v []byte) ([]byte, error) {
+			var enc []byte
+			if err := json.Unmarshal(v, &enc); err != nil {
+				return nil, err
+			}
+			dec, err := c.decode(enc)
+			if err != nil {
+				return nil, err
+			}
+			if !json.Valid(dec) {
+				return nil, errors.New("decoded value is not valid JSON")
+			}
+			return dec, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("field codec %s: %w", c.pointer, err)
+		}
+	}
+	return b, nil
+}
+
+// replaceAt returns the JSON document b with the value at pointer
+// replaced by fn of it. The rest of b is unchanged. If b has no value
+// at pointer, replaceAt returns b.
+func replaceAt(b []byte, pointer string, fn func([]byte) ([]byte, error)) ([]byte, error) {
+	start, end, err := findValue(b, pointer)
+	if err != nil || start < 0 {
+		return b, err
+	}
+	v, err := fn(b[start:end])
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(b)-(end-start)+len(v))
+	out = append(out, b[:start]...)
+	out = append(out, v...)
+	return append(out, b[end:]...), nil
+}
+
+// findValue returns the byte offsets of the value at pointer in the
+// JSON document b, or -1 if there is none.
+func findValue(b []byte, pointer string) (start, end int, err error) {
+	var parts []string
+	if pointer != "" {
+		if !strings.HasPrefix(pointer, "/") {
+			return 0, 0, fmt.Errorf("invalid JSON pointer %q", pointer)
+		}
+		parts = strings.Split(pointer[1:], "/")
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for _, part := range parts {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, err
+		}
+		switch tok {
+		case json.Delim('{'):
+			if !skipToKey(dec, jsonvalue.UnescapePointer(part)) {
+				return -1, -1, nil
+			}
+		case json.Delim('['):
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 || !skipToIndex(dec, n) {
+				return -1, -1, nil
+			}
+		default:
+			return -1, -1, nil // not a container
+		}
+	}
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		if err == io.EOF {
+			return -1, -1, nil
+		}
+		return 0, 0, err
+	}
+	end = int(dec.InputOffset())
+	return end - len(raw), end, nil
+}
+
+// skipToKey reads the object being decoded by dec up to the value of
+// key, reporting whether it was found.
+func skipToKey(dec *json.Decoder, key string) bool {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if tok == key {
+			return true
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// skipToIndex reads the array being decoded by dec up to element n,
+// reporting whether it was found.
+func skipToIndex(dec *json.Decoder, n int) bool {
+	for i := 0; dec.More(); i++ {
+		if i == n {
+			return true
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return false
+		}
+	}
+	return false
+}
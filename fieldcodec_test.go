@@ -0,0 +1,87 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplaceAt(t *testing.T) {
+	t.Parallel()
+	doc := `{"a": {"b~1": [1, {"c": "x"}]}, "d": 2}`
+	upper := func(v []byte) ([]byte, error) { return bytes.ToUpper(v), nil }
+	for _, tt := range []struct {
+		pointer, want string
+	}{
+		{"/a/b~01/1/c", `{"a": {"b~1": [1, {"c": "X"}]}, "d": 2}`},
+		{"/a/b~01/1", `{"a": {"b~1": [1, {"C": "X"}]}, "d": 2}`},
+		{"/d", doc},
+		{"/missing", doc},
+		{"/a/b~01/5", doc},
+		{"/d/x", doc},
+	} {
+		got, err := replaceAt([]byte(doc), tt.pointer, upper)
+		if err != nil {
+			t.Errorf("replaceAt(%q): %v", tt.pointer, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("replaceAt(%q) = %s, want %s", tt.pointer, got, tt.want)
+		}
+	}
+}
+
+func TestFieldCodec(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Name    string
+		Secrets map[string]string
+	}
+	// A reversible stand-in for encryption.
+	flip := func(b []byte) ([]byte, error) {
+		out := make([]byte, len(b))
+		for i, c := range b {
+			out[i] = c ^ 0xff
+		}
+		return out, nil
+	}
+
+	path := filepath.Join(t.TempDir(), "testcodec.json")
+	opt := WithFieldCodec("/Secrets", flip, flip)
+	db, err := New[DB](path, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) {
+		db.Name = "db"
+		db.Secrets = map[string]string{"key": "hunter2"}
+	})
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), `{"Name":"db","Secrets":"`) || strings.Contains(string(b), "hunter2") {
+		t.Errorf("file = %s, want Secrets encoded", b)
+	}
+	if err := db.Scrub(false); err != nil {
+		t.Errorf("Scrub: %v", err)
+	}
+
+	db, err = Load[DB](path, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Secrets["key"] != "hunter2" {
+			t.Errorf("Secrets = %v after Load", db.Secrets)
+		}
+	})
+	if _, err := Load[DB](path); err == nil {
+		t.Error("Load without the codec succeeded")
+	}
+}
@@ -0,0 +1,423 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The file holds the encoded data, after any field codecs, optionally
+// wrapped in an envelope that records facts about it:
+//
+//	{"jsonfile":1,"schema":"...","data":{...}}
+//
+// The envelope is read only with an option that writes one, so data
+// with a "jsonfile" member of its own is not mistaken for it. Without
+// such an option, a file that looks like an envelope fails to load
+// rather than having its data replaced by the envelope on the next
+// Write. Files without the "jsonfile" key are read as the data itself,
+// so an envelope can be added to an existing file by its next Write. The
+// file is then compressed, by WithCompression, and encrypted, by
+// WithEncryption.
+// FORMAT.md describes the format for other implementations.
+
+// envelopeVersion is the value of the "jsonfile" key of an envelope.
+const envelopeVersion = 1
+
+type envelope struct {
+	Version int             `json:"jsonfile"`
+	Schema  string          `json:"schema,omitempty"`
+	Sum     string          `json:"sum,omitempty"`
+	HLC     string          `json:"hlc,omitempty"`
+	Stats   *WriteStats     `json:"stats,omitempty"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// useEnvelope reports whether the file is written in an envelope.
+func (o *options) useEnvelope() bool {
+	return o.schema.enabled || o.checksum.enabled || o.clock != nil || o.writeStats
+}
+
+// encodeFile returns the contents of the file holding the data encoded
+// as b. It is the inverse of decodeFile.
+func (p *JSONFile[Data]) encodeFile(b []byte) ([]byte, error) {
+	b, err := p.opts.encodeFields(b)
+	if err != nil {
+		return nil, err
+	}
+	if p.opts.useEnvelope() {
+		env := envelope{Version: envelopeVersion, Data: b}
+		if p.opts.schema.enabled {
+			env.Schema = p.fingerprint()
+		}
+		if p.opts.checksum.enabled {
+			env.Sum = p.opts.sum(b)
+		}
+		if p.opts.clock != nil {
+			env.HLC = p.stamp.String()
+		}
+		if p.opts.writeStats {
+			stats := p.writeStats
+			env.Stats = &stats
+		}
+		if b, err = p.opts.marshalJSON(env); err != nil {
+			return nil, err
+		}
+	}
+	if p.opts.hujson {
+		return p.encodeHuJSON(b)
+	}
+	if p.opts.trailingNewline {
+		b = append(b[:len(b):len(b)], '\n')
+	}
+	if b, err = p.opts.compress(b); err != nil {
+		return nil, err
+	}
+	return p.opts.encrypt(b)
+}
+
+// decodeFile returns the encoded data held in the file contents b.
+func (p *JSONFile[Data]) decodeFile(b []byte) ([]byte, error) {
+	b, _, err := p.decodeFileMeta(b)
+	return b, err
+}
+
+// fileMeta are the facts about the data kept in the envelope.
+type fileMeta struct {
+	stamp Stamp      // from WithClock
+	stats WriteStats // from WithWriteStats
+}
+
+// decodeFileMeta is decodeFile that also returns the facts about the
+// data in the envelope.
+func (p *JSONFile[Data]) decodeFileMeta(b []byte) ([]byte, fileMeta, error) {
+	b, err := p.opts.decrypt(b)
+	if err == nil {
+		b, err = p.opts.decompress(b)
+	}
+	if err != nil {
+		return nil, fileMeta{}, err
+	}
+	if !p.opts.isJSON() {
+		return b, fileMeta{}, nil
+	}
+	if p.opts.trailingNewline {
+		b = bytes.TrimSuffix(b, []byte("\n"))
+	}
+	if p.opts.hujson {
+		if b, err = standardizeHuJSON(b); err != nil {
+			return nil, fileMeta{}, err
+		}
+	}
+	if err := checkValid(b); err != nil {
+		return nil, fileMeta{}, err
+	}
+	var env envelope
+	var ok bool
+	if p.opts.useEnvelope() {
+		if env, ok, err = parseEnvelope(b); err != nil {
+			return nil, fileMeta{}, err
+		}
+	} else if isEnvelope(b) {
+		// Read as data, the envelope would replace the data
+		// on the next Write.
+		return nil, fileMeta{}, errors.New("file is in an envelope, read it with the option that wrote it: WithChecksum, WithSchemaFingerprint, WithClock, or WithWriteStats")
+	}
+	var meta fileMeta
+	if ok {
+		if err := p.opts.checkSum(env.Sum, env.Data); err != nil {
+			return nil, fileMeta{}, err
+		}
+		if err := p.checkSchema(env.Schema); err != nil {
+			return nil, fileMeta{}, err
+		}
+		if meta.stamp, err = p.opts.clockStamp(env.HLC); err != nil {
+			return nil, fileMeta{}, err
+		}
+		if env.Stats != nil {
+			meta.stats = *env.Stats
+		}
+		b = env.Data
+	} else if err := p.opts.checkSum("", b); err != nil {
+		return nil, fileMeta{}, err
+	}
+	b, err = p.opts.decodeFields(b)
+	return b, meta, err
+}
+
+// parseEnvelope reports whether b is an envelope, and returns it.
+func parseEnvelope(b []byte) (env envelope, ok bool, err error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || b[0] != '{' || !bytes.Contains(b, []byte(`"jsonfile"`)) {
+		return env, false, nil // fast path for plain files
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(b, &top); err != nil {
+		return env, false, err
+	}
+	if _, ok := top["jsonfile"]; !ok {
+		return env, false, nil
+	}
+	if err := json.Unmarshal(b, &env); err != nil {
+		return env, false, fmt.Errorf("invalid envelope: %w", err)
+	}
+	if env.Version != envelopeVersion {
+		return env, false, fmt.Errorf("unknown file format version %d", env.Version)
+	}
+	if len(env.Data) == 0 {
+		return env, false, errors.New("invalid envelope: no data")
+	}
+	return env, true, nil
+}
+
+// isEnvelope reports whether b looks like an envelope written by an
+// option the reader does not have: a top-level object with "jsonfile"
+// set to the envelope version and a "data" member. Other objects with
+// a "jsonfile" member are data.
+func isEnvelope(b []byte) bool {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || b[0] != '{' || !bytes.Contains(b, []byte(`"jsonfile"`)) {
+		return false
+	}
+	var top struct {
+		Version json.RawMessage `json:"jsonfile"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &top); err != nil {
+		return false
+	}
+	return string(top.Version) == "1" && len(top.Data) > 0
+}
+
+// Verify checks that the file at path is a valid jsonfile file, as
+// described in FORMAT.md. It does not decode values stored by
+// WithFieldCodec, decrypt files written WithEncryption, check an
+// HMAC made by WithChecksum, or compare a schema fingerprint with a
+// Data type.
+// Tools can use it to check files written by other implementations.
+func Verify(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("jsonfile.Verify: %w", err)
+	}
+	if err := verify(b); err != nil {
+		return fmt.Errorf("jsonfile.Verify: %s: %w", path, err)
+	}
+	return nil
+}
+
+func verify(b []byte) error {
+	if bytes.HasPrefix(b, encryptedMagic) {
+		// The contents cannot be checked without the key.
+		if len(b) < len(encryptedMagic)+12+16 {
+			return errors.New("encrypted file is truncated")
+		}
+		return nil
+	}
+	if bytes.HasPrefix(b, dictMagic) {
+		// The contents cannot be checked without the dictionary.
+		if len(b) < len(dictMagic)+dictIDSize+1 {
+			return errors.New("compressed file is truncated")
+		}
+		return nil
+	}
+	if bytes.HasPrefix(b, gzipMagic) {
+		var err error
+		if b, err = Gzip.Decompress(b); err != nil {
+			return fmt.Errorf("invalid gzip: %w", err)
+		}
+	}
+	if !json.Valid(b) {
+		return errors.New("not valid JSON")
+	}
+	env, ok, err := parseEnvelope(b)
+	if err != nil || !ok {
+		return err
+	}
+	if env.Schema != "" {
+		if _, err := hex.DecodeString(env.Schema); err != nil || len(env.Schema) != 16 {
+			return fmt.Errorf("invalid schema fingerprint %q", env.Schema)
+		}
+	}
+	if _, err := ParseStamp(env.HLC); err != nil {
+		return fmt.Errorf("invalid hlc stamp %q", env.HLC)
+	}
+	if alg, sum, _ := strings.Cut(env.Sum, ":"); env.Sum != "" {
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != 64 || (alg != "sha256" && alg != "hmac-sha256") {
+			return fmt.Errorf("invalid checksum %q", env.Sum)
+		}
+		var o options // HMACs cannot be checked without the key
+		if alg == "sha256" && o.checkSum(env.Sum, env.Data) != nil {
+			return errors.New("data does not match checksum")
+		}
+	}
+	return nil
+}
+
+// A Format is how a file is written, as far as can be told from its
+// contents, for tools that work on files written by other programs,
+// such as cmd/jsonfile. Read it with ReadFormat.
+type Format struct {
+	Encrypted  bool   // by WithEncryption or WithCipher
+	Compressed bool   // by WithCompression
+	Envelope   bool   // the data is in an envelope
+	Schema     string // the fingerprint of WithSchemaFingerprint, or ""
+	Checksum   string // "sha256" or "hmac-sha256", by WithChecksum, or ""
+	Clock      bool   // the envelope holds a stamp of WithClock
+	WriteStats bool   // the envelope holds WithWriteStats
+	Journal    bool   // the path with ".wal" appended exists, from WithJournal
+
+	opts   []Option
+	gzip   bool // Options adds Gzip
+	sha256 bool // Options adds WithChecksum(nil)
+}
+
+// ReadFormat reads the file at path to find how it is written. opts
+// are the options that cannot be found from the file: WithEncryption
+// or WithCipher to decrypt it, WithCompression for a compression
+// other than Gzip, and WithChecksum with the key of an HMAC. ReadFormat
+// fails if the file needs one that is missing. Unlike Load, it takes
+// a file with a "jsonfile" member to be in an envelope, as Verify does.
+func ReadFormat(path string, opts ...Option) (Format, error) {
+	f := Format{opts: opts}
+	o := newOptions(opts)
+	if err := o.check(); err != nil {
+		return Format{}, fmt.Errorf("jsonfile.ReadFormat: %w", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Format{}, fmt.Errorf("jsonfile.ReadFormat: %w", err)
+	}
+	if err := f.read(&o, b); err != nil {
+		return Format{}, fmt.Errorf("jsonfile.ReadFormat: %s: %w", path, err)
+	}
+	if _, err := os.Stat(path + ".wal"); err == nil {
+		f.Journal = true
+	}
+	return f, nil
+}
+
+// read sets f from the file contents b.
+func (f *Format) read(o *options, b []byte) error {
+	c := o.encryption.cipher
+	f.Encrypted = bytes.HasPrefix(b, encryptedMagic) || c != nil && bytes.HasPrefix(b, c.Magic())
+	b, err := o.decrypt(b)
+	if err != nil {
+		return err
+	}
+	comp := o.compression
+	if comp == nil && bytes.HasPrefix(b, gzipMagic) {
+		comp, f.gzip = Gzip, true
+	}
+	switch {
+	case comp != nil && bytes.HasPrefix(b, comp.Magic()):
+		f.Compressed = true
+		if b, err = comp.Decompress(b); err != nil {
+			return err
+		}
+	case bytes.HasPrefix(b, dictMagic):
+		return errors.New("file is compressed with a dictionary, read it WithCompression(DeflateDict(dict))")
+	}
+	if err := checkValid(b); err != nil {
+		return err
+	}
+	env, ok, err := parseEnvelope(b)
+	if err != nil || !ok {
+		return err
+	}
+	f.Envelope = true
+	f.Schema = env.Schema
+	f.Checksum, _, _ = strings.Cut(env.Sum, ":")
+	f.Clock = env.HLC != ""
+	f.WriteStats = env.Stats != nil
+	if f.Checksum == "hmac-sha256" && o.checksum.key == nil {
+		return errors.New("file has an HMAC checksum, read it WithChecksum(key)")
+	}
+	f.sha256 = f.Checksum == "sha256" && !o.checksum.enabled
+	return nil
+}
+
+// Options returns the options to read the file as it is, and to write
+// it the same way: the options given to ReadFormat and those found
+// from the file. New stamps are made by an HLC for the replica named
+// "jsonfile", and Writes compact any journal into the file. The schema
+// fingerprint is not kept, as it is of the program's Data type: the
+// program's next Write records it again.
+func (f Format) Options() []Option {
+	opts := append([]Option(nil), f.opts...)
+	if f.gzip {
+		opts = append(opts, WithCompression(Gzip))
+	}
+	if f.sha256 {
+		opts = append(opts, WithChecksum(nil))
+	}
+	if f.Clock {
+		opts = append(opts, WithClock(NewHLC("jsonfile")))
+	}
+	if f.WriteStats {
+		opts = append(opts, WithWriteStats())
+	}
+	if f.Journal {
+		opts = append(opts, WithJournal(1))
+	}
+	return opts
+}
+
+// readFile reads and decodes the file.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) readFile() ([]byte, fileState, error) {
+	raw, state, err := readFile(p.opts.fileSystem(), p.path)
+	if err != nil {
+		return nil, fileState{}, err
+	}
+	b, meta, err := p.decodeFileMeta(raw)
+	if err != nil {
+		return nil, fileState{}, err
+	}
+	p.fileMeta = meta
+	if p.opts.journal > 0 {
+		if b, err = p.replayJournal(b, state); err != nil {
+			return nil, fileState{}, err
+		}
+	}
+	if p.opts.hujson {
+		p.hujsonText = raw
+	}
+	return b, state, nil
+}
+
+// sameData reports whether b, decoded from the file, holds the data
+// in memory. It is called with p.writing held.
+func (p *JSONFile[Data]) sameData(b []byte) bool {
+	if bytes.Equal(b, p.bytes) {
+		return true
+	}
+	// HuJSON files keep their own order of object members.
+	return p.opts.hujson && jsonEqual(b, p.bytes)
+}
+
+// validFile reports whether b looks like the contents of the file,
+// without decoding it.
+func (o *options) validFile(b []byte) bool {
+	b, err := o.decrypt(b)
+	if err == nil {
+		b, err = o.decompress(b)
+	}
+	switch {
+	case err != nil:
+		return false
+	case !o.isJSON():
+		return true
+	case o.hujson:
+		_, err := standardizeHuJSON(b)
+		return err == nil
+	}
+	return json.Valid(b)
+}
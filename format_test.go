@@ -0,0 +1,209 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFormatConformance checks the cases in testdata/format, which
+// FORMAT.md offers to other implementations.
+func TestFormatConformance(t *testing.T) {
+	t.Parallel()
+	files, err := filepath.Glob(filepath.Join("testdata", "format", "*valid-*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no conformance files")
+	}
+	for _, file := range files {
+		wantValid := strings.HasPrefix(filepath.Base(file), "valid-")
+		err := Verify(file)
+		if wantValid && err != nil {
+			t.Errorf("Verify(%s): %v", file, err)
+		} else if !wantValid && err == nil {
+			t.Errorf("Verify(%s) succeeded, want error", file)
+		}
+	}
+}
+
+func TestPatchConformance(t *testing.T) {
+	t.Parallel()
+	b, err := os.ReadFile(filepath.Join("testdata", "format", "patches.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []struct {
+		Name              string
+		Base, Patch, Want json.RawMessage
+	}
+	if err := json.Unmarshal(b, &cases); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		got, err := applyPatch(c.Base, c.Patch)
+		if err != nil {
+			t.Errorf("%s: %v", c.Name, err)
+			continue
+		}
+		if !jsonEqual(got, c.Want) {
+			t.Errorf("%s: got %s, want %s", c.Name, got, c.Want)
+		}
+	}
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testformat.json")
+	db, err := New[DB](path, WithSchemaFingerprint(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if err := Verify(path); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSONFileMember(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Version int `json:"jsonfile"`
+		Val     int
+	}
+
+	// Data with a "jsonfile" member round trips without an envelope.
+	path := filepath.Join(t.TempDir(), "testjsonfile.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Version, db.Val = 2, 3 })
+	db.Close()
+	db, err = Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.Read(func(db *DB) {
+		if db.Version != 2 || db.Val != 3 {
+			t.Errorf("Version=%d Val=%d, want 2, 3", db.Version, db.Val)
+		}
+	})
+
+	// So does an existing plain file that is not an envelope.
+	path = filepath.Join(t.TempDir(), "testconfig.json")
+	if err := os.WriteFile(path, []byte(`{"jsonfile":"my tool config","x":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load[map[string]any](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cfg.Close()
+	cfg.Read(func(cfg *map[string]any) {
+		if (*cfg)["jsonfile"] != "my tool config" || (*cfg)["x"] != 1.0 {
+			t.Errorf("data %v", *cfg)
+		}
+	})
+}
+
+func TestUndeclaredEnvelope(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	// A file in an envelope, loaded without the option that wrote it,
+	// fails rather than being read as data and overwritten.
+	for _, opt := range []Option{WithChecksum(nil), WithSchemaFingerprint(nil), WithWriteStats()} {
+		path := filepath.Join(t.TempDir(), "testenvelope.json")
+		db, err := New[DB](path, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustWrite(t, db, func(db *DB) { db.Val = 1 })
+		db.Close()
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if db, err := Load[DB](path); err == nil {
+			db.Close()
+			t.Errorf("Load of %s succeeded, want error", want)
+		} else if !strings.Contains(err.Error(), "envelope") {
+			t.Errorf("Load error %q does not mention the envelope", err)
+		}
+		if got, err := os.ReadFile(path); err != nil || string(got) != string(want) {
+			t.Errorf("file changed to %s, %v", got, err)
+		}
+	}
+}
+
+func TestReadFormat(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "testreadformat.json")
+	db, err := New[DB](path, WithChecksum(nil), WithWriteStats(), WithSchemaFingerprint(nil), WithCompression(Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	db.Close()
+	f, err := ReadFormat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Compressed || !f.Envelope || f.Checksum != "sha256" || !f.WriteStats || f.Schema == "" || f.Encrypted || f.Clock {
+		t.Errorf("ReadFormat = %+v", f)
+	}
+	m, err := Load[map[string]any](path, f.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, m, func(data *map[string]any) { (*data)["Val"] = 2 })
+	m.Close()
+	db, err = Load[DB](path, WithChecksum(nil), WithWriteStats(), WithCompression(Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 2 {
+			t.Errorf("Val=%d, want 2", db.Val)
+		}
+	})
+	db.Close()
+
+	key := []byte("0123456789abcdef")
+	path = filepath.Join(dir, "testreadformat-hmac.json")
+	db, err = New[DB](path, WithChecksum(key), WithEncryption(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if _, err := ReadFormat(path); err == nil {
+		t.Error("ReadFormat of an encrypted file without its key succeeded")
+	}
+	if _, err := ReadFormat(path, WithEncryption(key)); err == nil {
+		t.Error("ReadFormat of an HMAC file without its key succeeded")
+	}
+	if f, err := ReadFormat(path, WithEncryption(key), WithChecksum(key)); err != nil || !f.Encrypted || f.Checksum != "hmac-sha256" {
+		t.Errorf("ReadFormat = %+v, %v", f, err)
+	}
+
+	path = filepath.Join(dir, "testreadformat-plain.json")
+	if err := os.WriteFile(path, []byte(`{"Val":3}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := ReadFormat(path); err != nil || f.Envelope || f.Compressed || f.Encrypted {
+		t.Errorf("ReadFormat of a plain file = %+v, %v", f, err)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// A WriteFS is a file system a JSONFile can keep its file in, with the
+// operations it needs to read the file and replace it atomically. Names
+// are as for fs.FS: slash-separated, and relative to the root.
+type WriteFS interface {
+	fs.StatFS
+
+	// CreateTemp creates a new file in the directory dir, with a name
+	// that begins with prefix and is not in use, as os.CreateTemp.
+	CreateTemp(dir, prefix string) (File, error)
+
+	// Rename replaces the file newname with oldname, atomically.
+	Rename(oldname, newname string) error
+
+	// Remove removes the file name.
+	Remove(name string) error
+}
+
+// A File is a file being written in a WriteFS. An *os.File is a File.
+type File interface {
+	io.WriteCloser
+	Sync() error
+	Name() string // the name of the file in its WriteFS
+}
+
+// WithFS keeps the file in fsys instead of the operating system's file
+// system, so a JSONFile can be used with an in-memory file system in
+// tests, or with another backend. The path given to New or Load is a
+// name in fsys. To read a file from a read-only fs.FS, such as an
+// embed.FS, use LoadFS.
+//
+// Watch polls fsys for changes, and WithMirror writes its copies in
+// fsys. Options that keep other files, such as backups and the log of
+// WithAuditLog, or take locks cannot be used with WithFS, nor can
+// WithSyncDir.
+func WithFS(fsys WriteFS) Option {
+	return func(o *options) { o.fsys = fsys }
+}
+
+// LoadFS loads an existing JSONFile from the file name in fsys, such as
+// a default configuration embedded in the program with embed.FS. The
+// JSONFile is read-only: Writes fail with ErrReadOnly. WithJournal
+// replays the journal next to the file in fsys.
+func LoadFS[Data any](fsys fs.FS, name string, opts ...Option) (*JSONFile[Data], error) {
+	opts = append(opts[:len(opts):len(opts)], WithFS(readOnlyFS{fsys}))
+	p := newJSONFile[Data](name, opts)
+	p.readOnly = true
+	if err := p.load(); err != nil {
+		return nil, fmt.Errorf("jsonfile.LoadFS: %w", err)
+	}
+	return p, nil
+}
+
+// fileSystem returns the file system the file is kept in.
+func (o *options) fileSystem() WriteFS {
+	if o.fsys == nil {
+		return osFS{}
+	}
+	return o.fsys
+}
+
+// checkFS reports an error for options that cannot be used with WithFS.
+func (o *options) checkFS() error {
+	if o.fsys == nil {
+		return nil
+	}
+	// A journal is only read by LoadFS, which replays it as Load does.
+	_, readOnly := untraced(o.fsys).(readOnlyFS)
+	if o.syncDir || o.lock != lockNone || o.backup.dir != "" || o.backup.chain > 0 || o.recoveryBackup || o.rotatedBackups > 0 ||
+		o.revisionFiles > 0 || o.history || o.journal > 0 && !readOnly || o.auditLog != "" || o.legacy != nil || o.gitRepo != "" {
+		return errors.New("WithFS cannot be used with WithSyncDir, locks, or options that keep other files")
+	}
+	return nil
+}
+
+// osFS is the operating system's file system, with names that are file
+// paths.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error)     { return os.Open(name) }
+func (osFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+func (osFS) Rename(oldname, newname string) error  { return os.Rename(oldname, newname) }
+func (osFS) Remove(name string) error              { return os.Remove(name) }
+
+func (osFS) CreateTemp(dir, prefix string) (File, error) {
+	f, err := os.CreateTemp(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+// readOnlyFS is the WriteFS of LoadFS.
+type readOnlyFS struct{ fs.FS }
+
+func (f readOnlyFS) Stat(name string) (fs.FileInfo, error) { return fs.Stat(f.FS, name) }
+
+func (readOnlyFS) CreateTemp(dir, prefix string) (File, error) {
+	return nil, &fs.PathError{Op: "createtemp", Path: dir, Err: ErrReadOnly}
+}
+
+func (readOnlyFS) Rename(oldname, newname string) error {
+	return &fs.PathError{Op: "rename", Path: newname, Err: ErrReadOnly}
+}
+
+func (readOnlyFS) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: ErrReadOnly}
+}
+
+// splitPath splits name in fsys into its directory and base name.
+func splitPath(fsys fs.FS, name string) (dir, base string) {
+	if _, ok := untraced(fsys).(osFS); ok {
+		return filepath.Dir(name), filepath.Base(name)
+	}
+	return path.Dir(name), path.Base(name)
+}
+
+// joinPath joins a directory and a base name in fsys.
+func joinPath(fsys fs.FS, dir, base string) string {
+	if _, ok := untraced(fsys).(osFS); ok {
+		return filepath.Join(dir, base)
+	}
+	return path.Join(dir, base)
+}
@@ -0,0 +1,181 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// memFS is an in-memory WriteFS.
+type memFS struct {
+	mu    sync.Mutex
+	files fstest.MapFS
+	n     int
+}
+
+func (m *memFS) Open(name string) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshot().Open(name)
+}
+
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshot().Stat(name)
+}
+
+// snapshot returns a copy of the files, which can be read while they
+// are changed. It is called with m.mu held.
+func (m *memFS) snapshot() fstest.MapFS {
+	files := make(fstest.MapFS, len(m.files))
+	for name, f := range m.files {
+		c := *f
+		files[name] = &c
+	}
+	return files
+}
+
+func (m *memFS) CreateTemp(dir, prefix string) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.n++
+	name := fmt.Sprintf("%s/%s%d", dir, prefix, m.n)
+	if dir == "." {
+		name = fmt.Sprintf("%s%d", prefix, m.n)
+	}
+	m.files[name] = &fstest.MapFile{ModTime: time.Now()}
+	return &memFile{fs: m, name: name}, nil
+}
+
+func (m *memFS) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[oldname]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	delete(m.files, oldname)
+	m.files[newname] = f
+	return nil
+}
+
+func (m *memFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, name)
+	return nil
+}
+
+type memFile struct {
+	fs   *memFS
+	name string
+	buf  bytes.Buffer
+}
+
+func (f *memFile) Write(b []byte) (int, error) { return f.buf.Write(b) }
+func (f *memFile) Sync() error                 { return nil }
+func (f *memFile) Name() string                { return f.name }
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	f.fs.files[f.name] = &fstest.MapFile{Data: f.buf.Bytes(), ModTime: time.Now()}
+	return nil
+}
+
+func TestWithFS(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+	mem := &memFS{files: make(fstest.MapFS)}
+	db, err := New[DB]("data/db.json", WithFS(mem), WithChecksum(nil), WithConflictDetection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+	db.Close()
+	if len(mem.files) != 1 {
+		t.Errorf("files %v, want only data/db.json", mem.files)
+	}
+	if f := mem.files["data/db.json"]; f == nil || verify(f.Data) != nil {
+		t.Errorf("data/db.json is not a valid file: %v", f)
+	}
+
+	db, err = Load[DB]("data/db.json", WithFS(mem), WithChecksum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.Read(func(db *DB) {
+		if db.Val != 2 {
+			t.Errorf("Val=%d, want 2", db.Val)
+		}
+	})
+
+	if _, err := New[DB]("x.json", WithFS(mem), WithBackups(2)); err == nil {
+		t.Error("WithFS used with WithBackups")
+	}
+	if _, err := New[DB]("x.json", WithFS(mem), WithAuditLog("x.audit")); err == nil {
+		t.Error("WithFS used with WithAuditLog")
+	}
+}
+
+func TestWithFSPreflightMirror(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+	mem := &memFS{files: make(fstest.MapFS)}
+	opts := []Option{WithFS(mem), WithMirror("data/db.pretty.json", func(v any) ([]byte, error) {
+		return json.MarshalIndent(v, "", "\t")
+	})}
+	if err := Preflight[DB]("data/db.json", opts...).Err(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := New[DB]("data/db.json", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	db.Close()
+	if f := mem.files["data/db.pretty.json"]; f == nil || string(f.Data) != "{\n\t\"Val\": 1\n}" {
+		t.Errorf("mirror in the WriteFS: %v", f)
+	}
+	r := Preflight[DB]("data/db.json", opts...)
+	if err := r.Err(); err != nil || !r.Exists {
+		t.Errorf("Preflight of the file in the WriteFS: Exists=%v, %v", r.Exists, err)
+	}
+	if len(mem.files) != 2 {
+		t.Errorf("files %v, want the file and its mirror", mem.files)
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+	fsys := fstest.MapFS{"defaults.json": {Data: []byte(`{"Val":7}`)}}
+	db, err := LoadFS[DB](fsys, "defaults.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.Read(func(db *DB) {
+		if db.Val != 7 {
+			t.Errorf("Val=%d, want 7", db.Val)
+		}
+	})
+	if err := db.Write(func(db *DB) error { db.Val = 8; return nil }); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Write err=%v, want %v", err, ErrReadOnly)
+	}
+	if _, err := LoadFS[DB](fsys, "missing.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadFS of missing file err=%v, want %v", err, fs.ErrNotExist)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// CommitInfo describes who made a Write and why.
+// Attach it to the context passed to WriteCtx with NewCommitContext.
+type CommitInfo struct {
+	Author string // "Name <email>", or a plain name
+	Reason string
+}
+
+type commitInfoKey struct{}
+
+// NewCommitContext returns a copy of ctx carrying info.
+func NewCommitContext(ctx context.Context, info CommitInfo) context.Context {
+	return context.WithValue(ctx, commitInfoKey{}, info)
+}
+
+// CommitInfoFromContext returns the CommitInfo attached to ctx, if any.
+func CommitInfoFromContext(ctx context.Context) (CommitInfo, bool) {
+	info, ok := ctx.Value(commitInfoKey{}).(CommitInfo)
+	return info, ok
+}
+
+// WithGit commits the file to the git repository in repoDir after each
+// Write that changes it, giving a full history with diffs for files
+// that are written rarely, such as configuration. The file must be
+// inside the repository's work tree. The git command must be installed.
+//
+// The CommitInfo attached to the WriteCtx context, if any, is used for
+// the commit's author and message. As with WithMirror, a failed commit
+// does not fail the Write. The change is included in the next commit.
+func WithGit(repoDir string) Option {
+	return func(o *options) { o.gitRepo = repoDir }
+}
+
+// gitCommit commits the file to the repository given by WithGit.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) gitCommit(ctx context.Context, gen uint64) error {
+	repo, err := filepath.Abs(p.opts.gitRepo)
+	if err != nil {
+		return err
+	}
+	path, err := filepath.Abs(p.path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(repo, path)
+	if err != nil {
+		return err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("git: %s is not in %s", p.path, repo)
+	}
+
+	info, _ := CommitInfoFromContext(ctx)
+	msg := info.Reason
+	if msg == "" {
+		msg = fmt.Sprintf("jsonfile: update %s (generation %d)", filepath.Base(path), gen)
+	}
+	args := []string{"commit", "--quiet", "--message", msg}
+	if info.Author != "" {
+		author := info.Author
+		if !strings.Contains(author, "<") {
+			author += " <>"
+		}
+		args = append(args, "--author", author)
+	}
+	args = append(args, "--", rel)
+
+	if err := git(context.WithoutCancel(ctx), repo, "add", "--", rel); err != nil {
+		return err
+	}
+	return git(context.WithoutCancel(ctx), repo, args...)
+}
+
+func git(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGit(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	type DB struct{ Val int }
+
+	repo := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "--quiet")
+	run("config", "user.name", "Test")
+	run("config", "user.email", "test@example.com")
+
+	db, err := New[DB](filepath.Join(repo, "config.json"), WithGit(repo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewCommitContext(context.Background(), CommitInfo{
+		Author: "Alice <alice@example.com>",
+		Reason: "Set Val to 1",
+	})
+	if err := db.WriteCtx(ctx, func(db *DB) error { db.Val = 1; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) {}) // no change, no commit
+
+	got := run("log", "--format=%an|%s")
+	want := "Alice|Set Val to 1\nTest|jsonfile: update config.json (generation 1)"
+	if got != want {
+		t.Errorf("git log:\n%s\nwant:\n%s", got, want)
+	}
+	if got := run("show", "HEAD:config.json"); got != `{"Val":1}` {
+		t.Errorf("committed file = %s, want {\"Val\":1}", got)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// WithGroupCommit makes concurrent Writes share a single write to the
+// file. Writes that arrive while another is in progress are queued,
+// then applied in order to one copy of the data, which is encoded and
+// written to the file once. Under heavy write load this replaces a
+// decode, encode, and rename per Write with one for each group.
+//
+// Each Write still sees the changes of the Writes queued before it,
+// and an error from one fn only discards that fn's changes. If writing
+// the group to the file fails, every Write in it returns the error.
+// Options that read the WriteCtx context, such as WithGit, see the
+// context of the Write that performs the group's write. If a fn
+// panics, nothing in the group is written: its Write panics with the
+// same value, and the others return an error.
+func WithGroupCommit() Option {
+	return func(o *options) { o.groupCommit = true }
+}
+
+// groupReq is a Write queued for group commit.
+type groupReq[Data any] struct {
+	fn       func(*Data) error
+	done     chan error
+	panicked any // recovered from fn, set before done is sent
+}
+
+// errGroupPanic is returned by the Writes of a group in which another
+// Write's fn panicked.
+var errGroupPanic = errors.New("JSONFile.Write: a Write in the same group panicked")
+
+func (p *JSONFile[Data]) groupWrite(ctx context.Context, fn func(*Data) error) error {
+	req := &groupReq[Data]{fn: fn, done: make(chan error, 1)}
+	p.groupMu.Lock()
+	p.group = append(p.group, req)
+	p.groupMu.Unlock()
+
+	select {
+	case err := <-req.done:
+		return req.result(err) // committed by another Write
+	case p.writing <- struct{}{}:
+	case <-ctx.Done():
+		if p.dequeue(req) {
+			return fmt.Errorf("JSONFile.Write: %w", ctx.Err())
+		}
+		return req.result(<-req.done) // already taken by another Write
+	}
+	defer func() { <-p.writing }()
+
+	select {
+	case err := <-req.done:
+		return req.result(err) // committed while we waited
+	default:
+	}
+	p.groupMu.Lock()
+	reqs := p.group
+	p.group = nil
+	p.groupMu.Unlock()
+	p.commitGroup(ctx, reqs)
+	return req.result(<-req.done)
+}
+
+// result returns err, the result sent to req, or panics in the
+// goroutine of the Write if its fn panicked.
+func (req *groupReq[Data]) result(err error) error {
+	if req.panicked != nil {
+		panic(req.panicked)
+	}
+	return err
+}
+
+// call calls req.fn, recovering a panic into req.panicked.
+func (req *groupReq[Data]) call(data *Data) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			req.panicked = v
+			err = errGroupPanic
+		}
+	}()
+	return req.fn(data)
+}
+
+// checkGroupWrite checks data after a Write in a group changed it, as
+// write does, so only the invalid Writes of a group fail. It returns
+// the encoding of data if it was needed for the check or by next.
+func (p *JSONFile[Data]) checkGroupWrite(data *Data, next bool) ([]byte, error) {
+	if err := p.opts.validate(data); err != nil {
+		return nil, fmt.Errorf("JSONFile.Write: %w", err)
+	}
+	if !next && p.opts.jsonSchema == nil {
+		return nil, nil
+	}
+	b, err := p.opts.marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.opts.checkJSONSchema(b); err != nil {
+		return nil, fmt.Errorf("JSONFile.Write: %w", err)
+	}
+	return b, nil
+}
+
+// dequeue removes req from the queue, reporting whether it was there.
+func (p *JSONFile[Data]) dequeue(req *groupReq[Data]) bool {
+	p.groupMu.Lock()
+	defer p.groupMu.Unlock()
+	for i, r := range p.group {
+		if r == req {
+			p.group = append(p.group[:i], p.group[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// commitGroup applies reqs to one copy of the data, writes it, and
+// sends each request its result. It is called with p.writing held.
+func (p *JSONFile[Data]) commitGroup(ctx context.Context, reqs []*groupReq[Data]) {
+	errs := make([]error, len(reqs))
+	_, err := p.write(ctx, func(data *Data) error {
+		good := p.bytes // encoding of data before the current fn
+		for i, r := range reqs {
+			err := r.call(data)
+			if r.panicked != nil {
+				return err // write none of the group
+			}
+			var b []byte
+			if err == nil {
+				b, err = p.checkGroupWrite(data, i < len(reqs)-1)
+			}
+			if err != nil {
+				if !errors.Is(err, SkipWrite) {
+					errs[i] = err
+				}
+				var zero Data
+				*data = zero
+				if err := p.opts.unmarshal(good, data); err != nil {
+					return err
+				}
+				continue
+			}
+			if b != nil {
+				good = b
+			}
+		}
+		return nil
+	})
+	for i, r := range reqs {
+		if errs[i] != nil {
+			p.opts.rollback(ctx, errs[i])
+			r.done <- errs[i]
+		} else {
+			r.done <- err
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestGroupCommit(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Vals []int }
+
+	path := filepath.Join(t.TempDir(), "testgroup.json")
+	db, err := New[DB](path, WithGroupCommit())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold the write lock so Writes queue up behind it.
+	db.writing <- struct{}{}
+	rollbackErr := errors.New("rollback")
+	const n = 10
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = db.Write(func(db *DB) error {
+				db.Vals = append(db.Vals, i)
+				if i%2 == 1 {
+					return rollbackErr
+				}
+				return nil
+			})
+		}(i)
+	}
+	for {
+		db.groupMu.Lock()
+		queued := len(db.group)
+		db.groupMu.Unlock()
+		if queued == n {
+			break
+		}
+	}
+	<-db.writing
+	wg.Wait()
+
+	for i, err := range errs {
+		if i%2 == 1 && !errors.Is(err, rollbackErr) {
+			t.Errorf("Write %d err=%v, want %v", i, err, rollbackErr)
+		} else if i%2 == 0 && err != nil {
+			t.Errorf("Write %d err=%v", i, err)
+		}
+	}
+	if gen := db.Stat().Generation; gen != 2 {
+		t.Errorf("Generation=%d, want 2: writes were not grouped", gen)
+	}
+	db, err = Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if len(db.Vals) != n/2 {
+			t.Fatalf("Vals=%v, want %d even values", db.Vals, n/2)
+		}
+		for _, v := range db.Vals {
+			if v%2 != 0 {
+				t.Errorf("Vals=%v contains rolled back value %d", db.Vals, v)
+			}
+		}
+	})
+}
+
+func TestGroupCommitSerial(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testgroup.json"), WithGroupCommit())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		mustWrite(t, db, func(db *DB) { db.Val += i })
+	}
+	if err := db.WriteIfGeneration(db.Stat().Generation, func(db *DB) error { db.Val++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 7 {
+			t.Errorf("Val=%d, want 7", db.Val)
+		}
+	})
+}
+
+func TestGroupCommitPanic(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Vals []int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testgroup.json"), WithGroupCommit())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.writing <- struct{}{}
+	const n = 3
+	errs := make([]error, n)
+	panics := make([]any, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { panics[i] = recover() }()
+			errs[i] = db.Write(func(db *DB) error {
+				if i == 1 {
+					panic("boom")
+				}
+				db.Vals = append(db.Vals, i)
+				return nil
+			})
+		}(i)
+	}
+	for {
+		db.groupMu.Lock()
+		queued := len(db.group)
+		db.groupMu.Unlock()
+		if queued == n {
+			break
+		}
+	}
+	<-db.writing
+	wg.Wait() // does not deadlock
+
+	for i := 0; i < n; i++ {
+		if i == 1 {
+			if panics[i] != "boom" {
+				t.Errorf("Write %d panicked with %v, want boom", i, panics[i])
+			}
+		} else if panics[i] != nil || !errors.Is(errs[i], errGroupPanic) {
+			t.Errorf("Write %d err=%v, panic %v, want %v", i, errs[i], panics[i], errGroupPanic)
+		}
+	}
+	if gen := db.Stat().Generation; gen != 1 {
+		t.Errorf("Generation=%d, want 1: the group was written", gen)
+	}
+	mustWrite(t, db, func(db *DB) { db.Vals = append(db.Vals, n) })
+	db.Read(func(db *DB) {
+		if len(db.Vals) != 1 || db.Vals[0] != n {
+			t.Errorf("Vals=%v, want [%d]", db.Vals, n)
+		}
+	})
+}
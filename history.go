@@ -0,0 +1,257 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// WithHistory records every version of the data written, with the time
+// of the Write, in a history log, the path with ".history" appended.
+// Past versions are read with ReadAt and ReadGeneration, which answer
+// questions like when a field changed, or all together with
+// ReadHistory. Most versions are stored as what changed since the one
+// before, but the log is never trimmed, so it suits small files that
+// change at a modest rate.
+//
+// Like WithMirror, the log is written on a best-effort basis: a failure
+// does not fail the Write. WithHistory needs JSON, and cannot be used
+// with WithEncryption or WithCipher, as the log is not encrypted.
+func WithHistory() Option {
+	return func(o *options) { o.history = true }
+}
+
+// historyRecord is a line of the history log. The first record written
+// by each JSONFile holds the whole data, the ones after it a patch to
+// the data of the record before, as made by diffJSON.
+type historyRecord struct {
+	Gen   uint64          `json:"gen"`
+	Time  time.Time       `json:"time"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Patch json.RawMessage `json:"patch,omitempty"`
+}
+
+func (p *JSONFile[Data]) historyPath() string { return p.path + ".history" }
+
+// recordHistory appends b, the data of a Write made at time now, to the
+// history log, ignoring errors. It is called with p.writing held.
+func (p *JSONFile[Data]) recordHistory(b []byte, now time.Time) {
+	rec := historyRecord{Time: now}
+	if p.historyLast == nil {
+		// Continue the log from its last complete record.
+		recs, size, err := readHistory(p.historyPath())
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		if len(recs) > 0 {
+			p.historyGen = recs[len(recs)-1].Gen
+		}
+		if err := truncateFile(p.historyPath(), size); err != nil {
+			return
+		}
+		rec.Data = b
+	} else {
+		patch, err := diffJSON(p.historyLast, b)
+		if err != nil {
+			return
+		}
+		rec.Patch = patch
+	}
+	rec.Gen = p.historyGen + 1
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(p.historyPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+	_, err = f.Write(append(line, '\n'))
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		p.historyLast = nil // start again from the log
+		return
+	}
+	p.historyGen, p.historyLast = rec.Gen, b
+}
+
+// truncateFile truncates the file at path, if it exists, to size bytes.
+func truncateFile(path string, size int64) error {
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Size() == size {
+		return nil
+	}
+	return os.Truncate(path, size)
+}
+
+// readHistory reads the history log at path, returning its records and
+// the size of the log up to the end of the last complete record.
+func readHistory(path string) (recs []historyRecord, size int64, err error) {
+	lines, _, err := readLines(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, line := range lines {
+		var rec historyRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			break
+		}
+		recs = append(recs, rec)
+		size += int64(len(line) + 1)
+	}
+	return recs, size, nil
+}
+
+// readLines reads the file at path as lines ending in newlines, without
+// them, stopping at a final line cut short. It returns the size of the
+// file up to the end of the last complete line.
+func readLines(path string) (lines [][]byte, size int64, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	for {
+		line, rest, ok := bytes.Cut(b, []byte("\n"))
+		if !ok {
+			return lines, size, nil
+		}
+		lines = append(lines, line)
+		size += int64(len(line) + 1)
+		b = rest
+	}
+}
+
+// ReadAt calls fn with a copy of the data as it was at time at,
+// according to the log kept by WithHistory. Changes fn makes to the
+// data are discarded. If no version was written at or before at,
+// ReadAt returns an error that can be checked with
+// errors.Is(err, os.ErrNotExist).
+func (p *JSONFile[Data]) ReadAt(at time.Time, fn func(data *Data)) error {
+	data, _, err := p.historyVersion(func(rec historyRecord) bool { return !rec.Time.After(at) })
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("JSONFile.ReadAt: no version at or before %s: %w", at.Format(time.RFC3339), err)
+	} else if err != nil {
+		return fmt.Errorf("JSONFile.ReadAt: %w", err)
+	}
+	fn(data)
+	return nil
+}
+
+// ReadGeneration calls fn with a copy of version gen of the data in the
+// log kept by WithHistory. Versions are numbered from 1 in the order
+// they were written, and unlike the generations reported by Stat, the
+// numbering continues when the file is loaded again. Changes fn makes
+// to the data are discarded. If there is no such version,
+// ReadGeneration returns an error that can be checked with
+// errors.Is(err, os.ErrNotExist).
+func (p *JSONFile[Data]) ReadGeneration(gen uint64, fn func(data *Data)) error {
+	data, found, err := p.historyVersion(func(rec historyRecord) bool { return rec.Gen <= gen })
+	if err == nil && found != gen {
+		err = os.ErrNotExist
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("JSONFile.ReadGeneration: no generation %d: %w", gen, err)
+	} else if err != nil {
+		return fmt.Errorf("JSONFile.ReadGeneration: %w", err)
+	}
+	fn(data)
+	return nil
+}
+
+// historyVersion returns the data and generation of the last record
+// of the history log for which before reports true. The log is read
+// holding p.writing, so no record is half written.
+func (p *JSONFile[Data]) historyVersion(before func(historyRecord) bool) (*Data, uint64, error) {
+	if !p.opts.history {
+		return nil, 0, errors.New("history not enabled, use WithHistory")
+	}
+	p.writing <- struct{}{}
+	recs, _, err := readHistory(p.historyPath())
+	<-p.writing
+	if err != nil {
+		return nil, 0, err
+	}
+	var b []byte
+	var gen uint64
+	err = replayHistory(recs, func(rec historyRecord, data []byte) bool {
+		if !before(rec) {
+			return false // records are in order of generation and time
+		}
+		b, gen = data, rec.Gen
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if b == nil {
+		return nil, 0, os.ErrNotExist
+	}
+	data := new(Data)
+	if err := p.opts.unmarshal(b, data); err != nil {
+		return nil, 0, err
+	}
+	return data, gen, nil
+}
+
+// replayHistory calls fn with each record of recs and the data of its
+// version, from the data of the first record and the patches after it,
+// until fn returns false.
+func replayHistory(recs []historyRecord, fn func(rec historyRecord, data []byte) bool) error {
+	var b []byte
+	for _, rec := range recs {
+		var err error
+		if rec.Data != nil {
+			b = rec.Data
+		} else if b == nil {
+			return fmt.Errorf("generation %d: patch without data before it", rec.Gen)
+		} else if b, err = applyPatch(b, rec.Patch); err != nil {
+			return fmt.Errorf("generation %d: %w", rec.Gen, err)
+		}
+		if !fn(rec, b) {
+			break
+		}
+	}
+	return nil
+}
+
+// A Version is a version of the data in the log kept by WithHistory.
+type Version struct {
+	Gen  uint64          // numbered as for ReadGeneration
+	Time time.Time       // of the Write
+	Data json.RawMessage // the JSON encoding of the data
+}
+
+// ReadHistory returns the versions of the data in the log kept by
+// WithHistory for the file at path, oldest first, for tools that do
+// not have the program's Data type, such as cmd/jsonfile. Object
+// members in versions after the first of each load are sorted. If
+// there is no log, ReadHistory returns an error that can be checked
+// with errors.Is(err, os.ErrNotExist).
+func ReadHistory(path string) ([]Version, error) {
+	recs, _, err := readHistory(path + ".history")
+	if err != nil {
+		return nil, fmt.Errorf("jsonfile.ReadHistory: %w", err)
+	}
+	var versions []Version
+	err = replayHistory(recs, func(rec historyRecord, data []byte) bool {
+		versions = append(versions, Version{Gen: rec.Gen, Time: rec.Time, Data: data})
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("jsonfile.ReadHistory: %w", err)
+	}
+	return versions, nil
+}
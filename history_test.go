@@ -0,0 +1,90 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val  int
+		Name string
+	}
+
+	path := filepath.Join(t.TempDir(), "testhistory.json")
+	db, err := New[DB](path, WithHistory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Name = "a" })
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	middle := time.Now()
+	time.Sleep(time.Millisecond)
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+	db.Close()
+
+	// Generations continue after the file is loaded again.
+	db, err = Load[DB](path, WithHistory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustWrite(t, db, func(db *DB) { db.Name = "b" })
+
+	want := []DB{{}, {Name: "a"}, {Val: 1, Name: "a"}, {Val: 2, Name: "a"}, {Val: 2, Name: "b"}}
+	for i, w := range want {
+		gen := uint64(i + 1)
+		var got DB
+		if err := db.ReadGeneration(gen, func(db *DB) { got = *db }); err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Errorf("generation %d: %+v, want %+v", gen, got, w)
+		}
+	}
+	if err := db.ReadGeneration(6, func(*DB) {}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadGeneration(6) err=%v, want ErrNotExist", err)
+	}
+
+	versions, err := ReadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != len(want) {
+		t.Fatalf("ReadHistory: %d versions, want %d", len(versions), len(want))
+	}
+	for i, v := range versions {
+		var got DB
+		if err := json.Unmarshal(v.Data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if v.Gen != uint64(i+1) || got != want[i] {
+			t.Errorf("ReadHistory version %d: gen %d, %+v, want %+v", i, v.Gen, got, want[i])
+		}
+	}
+	if !versions[2].Time.Before(middle) || versions[3].Time.Before(middle) {
+		t.Errorf("ReadHistory times %v, %v around %v", versions[2].Time, versions[3].Time, middle)
+	}
+	if _, err := ReadHistory(path + ".x"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadHistory with no log err=%v, want ErrNotExist", err)
+	}
+
+	var got DB
+	if err := db.ReadAt(middle, func(db *DB) { got = *db }); err != nil {
+		t.Fatal(err)
+	}
+	if got != want[2] {
+		t.Errorf("ReadAt: %+v, want %+v", got, want[2])
+	}
+	if err := db.ReadAt(middle.Add(-time.Hour), func(*DB) {}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadAt before history err=%v, want ErrNotExist", err)
+	}
+}
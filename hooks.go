@@ -0,0 +1,92 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"fmt"
+)
+
+// Hooks are functions called at points in each Write, so cross-cutting
+// concerns such as metrics, cache invalidation, and notifications can
+// be attached to a JSONFile once instead of at every call to Write.
+// Any of them can be nil. They are called while the file is held for
+// writing, with the context of the Write.
+type Hooks[Data any] struct {
+	// BeforeWrite is called with the data after the function given
+	// to Write has changed it, before it is validated and written.
+	// It may change the data further. If it returns an error, the
+	// Write fails with it and the data is unchanged. With
+	// WithGroupCommit, it is called once for the group.
+	BeforeWrite func(ctx context.Context, data *Data) error
+
+	// AfterCommit is called with the new data once it is written to
+	// the file. With WithAutosave, that is when the changes are
+	// flushed. It must not modify the data.
+	AfterCommit func(ctx context.Context, data *Data)
+
+	// OnRollback is called when a Write fails, with its error, leaving
+	// the data unchanged.
+	OnRollback func(ctx context.Context, err error)
+}
+
+// WithHooks calls the Hooks h in each Write. WithHooks can be used more
+// than once, and the hooks are called in the order given.
+func WithHooks[Data any](h Hooks[Data]) Option {
+	var hk hooks
+	if h.BeforeWrite != nil {
+		hk.before = func(ctx context.Context, v any) error {
+			data, ok := v.(*Data)
+			if !ok {
+				return fmt.Errorf("WithHooks for %T used with %T", data, v)
+			}
+			return h.BeforeWrite(ctx, data)
+		}
+	}
+	if h.AfterCommit != nil {
+		hk.after = func(ctx context.Context, v any) {
+			if data, ok := v.(*Data); ok {
+				h.AfterCommit(ctx, data)
+			}
+		}
+	}
+	hk.rollback = h.OnRollback
+	return func(o *options) { o.hooks = append(o.hooks, hk) }
+}
+
+type hooks struct {
+	before   func(ctx context.Context, data any) error
+	after    func(ctx context.Context, data any)
+	rollback func(ctx context.Context, err error)
+}
+
+// beforeWrite runs the BeforeWrite hooks on data.
+func (o *options) beforeWrite(ctx context.Context, data any) error {
+	for _, h := range o.hooks {
+		if h.before != nil {
+			if err := h.before(ctx, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// afterCommit runs the AfterCommit hooks on data.
+func (o *options) afterCommit(ctx context.Context, data any) {
+	for _, h := range o.hooks {
+		if h.after != nil {
+			h.after(ctx, data)
+		}
+	}
+}
+
+// rollback runs the OnRollback hooks for a Write that failed with err.
+func (o *options) rollback(ctx context.Context, err error) {
+	for _, h := range o.hooks {
+		if h.rollback != nil {
+			h.rollback(ctx, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val     int
+		Changes int
+	}
+
+	var calls []string
+	errTooBig := errors.New("too big")
+	path := filepath.Join(t.TempDir(), "db.json")
+	db, err := New[DB](path, WithHooks(Hooks[DB]{
+		BeforeWrite: func(ctx context.Context, data *DB) error {
+			calls = append(calls, "before")
+			if data.Val > 10 {
+				return errTooBig
+			}
+			data.Changes++
+			return nil
+		},
+		AfterCommit: func(ctx context.Context, data *DB) {
+			calls = append(calls, "commit")
+		},
+		OnRollback: func(ctx context.Context, err error) {
+			calls = append(calls, "rollback: "+err.Error())
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(calls, " "); got != "before commit" {
+		t.Errorf("New calls: %s", got)
+	}
+	calls = nil
+
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if err := db.Write(func(db *DB) error { db.Val = 11; return nil }); !errors.Is(err, errTooBig) {
+		t.Errorf("Write err=%v, want %v", err, errTooBig)
+	}
+	if err := db.Write(func(db *DB) error { return errors.New("fn failed") }); err == nil {
+		t.Error("failing Write succeeded")
+	}
+	if err := db.Write(func(db *DB) error { return SkipWrite }); err != nil {
+		t.Error(err)
+	}
+
+	want := "before commit before rollback: too big rollback: fn failed"
+	if got := strings.Join(calls, " "); got != want {
+		t.Errorf("calls: %s\nwant:  %s", got, want)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 1 || db.Changes != 2 {
+			t.Errorf("data=%+v, want Val 1 and Changes 2", *db)
+		}
+	})
+}
@@ -0,0 +1,427 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// WithHuJSON reads and writes the file as HuJSON, JSON with comments
+// and trailing commas, for configuration files edited by people:
+//
+//	{
+//		// How often to poll, in seconds.
+//		"Interval": 30,
+//		"Hosts": [
+//			"a.example.com",
+//			"b.example.com", // backup
+//		],
+//	}
+//
+// Write keeps the comments and layout of the file for the values it
+// does not remove: changed values are replaced in place, and new
+// object members and array elements are added after the existing
+// ones. A new file is written indented.
+//
+// A HuJSON file is not valid JSON, so Verify and other JSON tools
+// reject it. WithHuJSON cannot be used with WithCodec or
+// WithDifferentialBackups.
+func WithHuJSON() Option {
+	return func(o *options) { o.hujson = true }
+}
+
+// encodeHuJSON returns the HuJSON file for the JSON document b,
+// keeping what it can of the previous contents of the file.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) encodeHuJSON(b []byte) ([]byte, error) {
+	if len(p.hujsonText) == 0 {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", "\t"); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	}
+	old, err := parseHuJSON(p.hujsonText)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseHuJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	old.value = huMerge(old.value, v.value)
+	return old.bytes(false), nil
+}
+
+// standardizeHuJSON returns the HuJSON document b as compact JSON.
+func standardizeHuJSON(b []byte) ([]byte, error) {
+	doc, err := parseHuJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	out := doc.bytes(true)
+	if !json.Valid(out) {
+		return nil, errors.New("hujson: invalid JSON value")
+	}
+	return out, nil
+}
+
+// A huDoc is a parsed HuJSON document that can be written back out
+// with its comments and whitespace, its extra.
+type huDoc struct {
+	before []byte
+	value  *huNode
+	after  []byte
+}
+
+// A huNode is a literal, object, or array.
+type huNode struct {
+	lit      []byte // literal, including the quotes of a string
+	open     byte   // '{' or '[', or 0 for a literal
+	items    []huItem
+	trailing bool   // a comma follows the last item
+	tail     []byte // extra before the closing bracket
+}
+
+// A huItem is an object member or array element.
+type huItem struct {
+	before []byte // extra before the item
+	key    []byte // name of an object member, quoted
+	colon  []byte // colon and extra between the key and value
+	value  *huNode
+	after  []byte // extra between the value and the comma
+	line   []byte // a comment after the comma, to the end of the line
+}
+
+func (d *huDoc) bytes(standard bool) []byte {
+	var buf bytes.Buffer
+	if !standard {
+		buf.Write(d.before)
+	}
+	d.value.write(&buf, standard)
+	if !standard {
+		buf.Write(d.after)
+	}
+	return buf.Bytes()
+}
+
+func (n *huNode) write(buf *bytes.Buffer, standard bool) {
+	if n.open == 0 {
+		buf.Write(n.lit)
+		return
+	}
+	buf.WriteByte(n.open)
+	for i, it := range n.items {
+		if !standard {
+			buf.Write(it.before)
+		}
+		if it.key != nil {
+			buf.Write(it.key)
+			if standard {
+				buf.WriteByte(':')
+			} else {
+				buf.Write(it.colon)
+			}
+		}
+		it.value.write(buf, standard)
+		if !standard {
+			buf.Write(it.after)
+		}
+		if i < len(n.items)-1 || n.trailing && !standard {
+			buf.WriteByte(',')
+		}
+		if !standard {
+			buf.Write(it.line)
+		}
+	}
+	if !standard {
+		buf.Write(n.tail)
+	}
+	if n.open == '{' {
+		buf.WriteByte('}')
+	} else {
+		buf.WriteByte(']')
+	}
+}
+
+type huParser struct {
+	b []byte
+	i int
+}
+
+func parseHuJSON(b []byte) (*huDoc, error) {
+	p := &huParser{b: b}
+	doc := new(huDoc)
+	var err error
+	if doc.before, err = p.extra(); err != nil {
+		return nil, err
+	}
+	if doc.value, err = p.value(); err != nil {
+		return nil, err
+	}
+	if doc.after, err = p.extra(); err != nil {
+		return nil, err
+	}
+	if p.i != len(b) {
+		return nil, p.errorf("unexpected %q after value", b[p.i])
+	}
+	return doc, nil
+}
+
+func (p *huParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("hujson: offset %d: %s", p.i, fmt.Sprintf(format, args...))
+}
+
+// extra skips whitespace and comments.
+func (p *huParser) extra() ([]byte, error) {
+	start := p.i
+	for p.i < len(p.b) {
+		switch c := p.b[p.i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.i++
+		case bytes.HasPrefix(p.b[p.i:], []byte("//")):
+			if n := bytes.IndexByte(p.b[p.i:], '\n'); n >= 0 {
+				p.i += n + 1
+			} else {
+				p.i = len(p.b)
+			}
+		case bytes.HasPrefix(p.b[p.i:], []byte("/*")):
+			n := bytes.Index(p.b[p.i+2:], []byte("*/"))
+			if n < 0 {
+				return nil, p.errorf("unterminated comment")
+			}
+			p.i += 2 + n + 2
+		default:
+			return p.b[start:p.i], nil
+		}
+	}
+	return p.b[start:p.i], nil
+}
+
+func (p *huParser) value() (*huNode, error) {
+	if p.i >= len(p.b) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch c := p.b[p.i]; c {
+	case '{', '[':
+		return p.composite(c)
+	case '"':
+		lit, err := p.str()
+		return &huNode{lit: lit}, err
+	}
+	start := p.i
+	for p.i < len(p.b) && isLiteralByte(p.b[p.i]) {
+		p.i++
+	}
+	if p.i == start {
+		return nil, p.errorf("unexpected %q", p.b[p.i])
+	}
+	return &huNode{lit: p.b[start:p.i]}, nil
+}
+
+func isLiteralByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '+' || c == '.'
+}
+
+func (p *huParser) str() ([]byte, error) {
+	start := p.i
+	for p.i++; p.i < len(p.b); p.i++ {
+		switch p.b[p.i] {
+		case '\\':
+			p.i++
+		case '"':
+			p.i++
+			return p.b[start:p.i], nil
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+func (p *huParser) composite(open byte) (*huNode, error) {
+	closing := byte(']')
+	if open == '{' {
+		closing = '}'
+	}
+	n := &huNode{open: open}
+	p.i++
+	for {
+		before, err := p.extra()
+		if err != nil {
+			return nil, err
+		}
+		if len(n.items) > 0 {
+			last := &n.items[len(n.items)-1]
+			last.line, before = splitLine(before)
+		}
+		if p.i >= len(p.b) {
+			return nil, p.errorf("unexpected end of input")
+		}
+		if p.b[p.i] == closing {
+			n.tail = before
+			n.trailing = len(n.items) > 0
+			p.i++
+			return n, nil
+		}
+		it := huItem{before: before}
+		if open == '{' {
+			if p.b[p.i] != '"' {
+				return nil, p.errorf("object key must be a string")
+			}
+			if it.key, err = p.str(); err != nil {
+				return nil, err
+			}
+			start := p.i
+			if _, err := p.extra(); err != nil {
+				return nil, err
+			}
+			if p.i >= len(p.b) || p.b[p.i] != ':' {
+				return nil, p.errorf("missing colon")
+			}
+			p.i++
+			if _, err := p.extra(); err != nil {
+				return nil, err
+			}
+			it.colon = p.b[start:p.i]
+		}
+		if it.value, err = p.value(); err != nil {
+			return nil, err
+		}
+		if it.after, err = p.extra(); err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, it)
+		if p.i >= len(p.b) {
+			return nil, p.errorf("unexpected end of input")
+		}
+		switch p.b[p.i] {
+		case ',':
+			p.i++
+		case closing:
+			// Keep the extra with the node, so items can be added.
+			last := &n.items[len(n.items)-1]
+			last.line, n.tail = splitLine(it.after)
+			last.after = nil
+			p.i++
+			return n, nil
+		default:
+			return nil, p.errorf("unexpected %q", p.b[p.i])
+		}
+	}
+}
+
+// splitLine splits a line comment that ends the line of the item
+// before extra from the rest of it.
+func splitLine(extra []byte) (line, rest []byte) {
+	i := bytes.IndexByte(extra, '\n')
+	if i < 0 || !bytes.HasPrefix(bytes.TrimLeft(extra[:i], " \t"), []byte("//")) {
+		return nil, extra
+	}
+	return extra[:i+1], extra[i+1:]
+}
+
+// huMerge returns the value v laid out like old where they match.
+func huMerge(old, v *huNode) *huNode {
+	if old.open != v.open {
+		return v
+	}
+	if old.open == 0 {
+		if bytes.Equal(old.lit, v.lit) {
+			return old
+		}
+		return v
+	}
+	out := &huNode{open: old.open, trailing: old.trailing, tail: old.tail}
+	if old.open == '{' {
+		newItems := make(map[string]huItem, len(v.items))
+		for _, it := range v.items {
+			newItems[huKey(it.key)] = it
+		}
+		for _, it := range old.items {
+			nit, ok := newItems[huKey(it.key)]
+			if !ok {
+				continue // removed
+			}
+			delete(newItems, huKey(it.key))
+			if len(out.items) == 0 {
+				it.before = old.items[0].before // first members were removed
+			}
+			it.value = huMerge(it.value, nit.value)
+			out.items = append(out.items, it)
+		}
+		for _, it := range v.items {
+			if _, ok := newItems[huKey(it.key)]; ok {
+				out.items = append(out.items, huLike(it, old.items, lastItem(out.items)))
+			}
+		}
+	} else {
+		for i, it := range v.items {
+			if i < len(old.items) {
+				oit := old.items[i]
+				oit.value = huMerge(oit.value, it.value)
+				out.items = append(out.items, oit)
+			} else {
+				out.items = append(out.items, huLike(it, old.items, lastItem(out.items)))
+			}
+		}
+	}
+	if len(out.items) == 0 {
+		out.trailing = false
+	}
+	// The tail may rely on the last item ending its line.
+	oldEnds := bytes.HasSuffix(lastItem(old.items).line, []byte("\n"))
+	newEnds := bytes.HasSuffix(lastItem(out.items).line, []byte("\n"))
+	if oldEnds && !newEnds {
+		out.tail = append([]byte("\n"), out.tail...)
+	} else if newEnds && !oldEnds {
+		out.tail = bytes.TrimPrefix(out.tail, []byte("\n"))
+	}
+	return out
+}
+
+func lastItem(items []huItem) huItem {
+	if len(items) == 0 {
+		return huItem{}
+	}
+	return items[len(items)-1]
+}
+
+// huLike returns the new item it laid out like the last of items,
+// to follow prev.
+func huLike(it huItem, items []huItem, prev huItem) huItem {
+	if len(items) == 0 {
+		return it
+	}
+	last := items[len(items)-1]
+	indent := last.before
+	if i := bytes.LastIndexByte(indent, '\n'); i >= 0 {
+		indent = indent[i+1:]
+	}
+	if len(bytes.TrimSpace(indent)) == 0 {
+		newline := bytes.ContainsRune(last.before, '\n') || last.line != nil
+		switch {
+		case !newline:
+			it.before = indent // items on one line
+		case bytes.HasSuffix(prev.line, []byte("\n")):
+			it.before = indent
+		default:
+			it.before = append([]byte("\n"), indent...)
+		}
+	}
+	if it.key != nil && len(bytes.TrimSpace(last.colon)) == 1 {
+		it.colon = last.colon
+	}
+	return it
+}
+
+func huKey(quoted []byte) string {
+	var s string
+	if err := json.Unmarshal(quoted, &s); err != nil {
+		return string(quoted)
+	}
+	return s
+}
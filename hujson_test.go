@@ -0,0 +1,119 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStandardizeHuJSON(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct{ in, want string }{
+		{`{"a": 1}`, `{"a":1}`},
+		{"// c\n{\"a\": [1, 2,], /* x */ \"b\": \"//\",}\n", `{"a":[1,2],"b":"//"}`},
+		{`[]`, `[]`},
+		{`"s\"}"`, `"s\"}"`},
+	} {
+		got, err := standardizeHuJSON([]byte(tt.in))
+		if err != nil {
+			t.Errorf("standardizeHuJSON(%q): %v", tt.in, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("standardizeHuJSON(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []string{`{"a": 1`, `{"a" 1}`, `/* x`, `{a: 1}`, `[1,,]`, `{"a": tru}`} {
+		if _, err := standardizeHuJSON([]byte(in)); err == nil {
+			t.Errorf("standardizeHuJSON(%q) succeeded", in)
+		}
+	}
+}
+
+func TestHuJSON(t *testing.T) {
+	t.Parallel()
+	type Config struct {
+		Interval int
+		Hosts    []string
+		Debug    bool `json:",omitempty"`
+	}
+
+	path := filepath.Join(t.TempDir(), "config.hujson")
+	const orig = `{
+	// How often to poll, in seconds.
+	"Interval": 30,
+	"Hosts": [
+		"a.example.com", // primary
+	],
+	"Debug": true,
+}
+`
+	if err := os.WriteFile(path, []byte(orig), 0666); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Load[Config](path, WithHuJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(c *Config) {
+		c.Interval = 60
+		c.Hosts = append(c.Hosts, "b.example.com")
+		c.Debug = false
+	})
+	const want = `{
+	// How often to poll, in seconds.
+	"Interval": 60,
+	"Hosts": [
+		"a.example.com", // primary
+		"b.example.com",
+	],
+}
+`
+	if b, _ := os.ReadFile(path); string(b) != want {
+		t.Errorf("file:\n%s\nwant:\n%s", b, want)
+	}
+	if err := db.Scrub(false); err != nil {
+		t.Errorf("Scrub: %v", err)
+	}
+}
+
+func TestHuJSONMerge(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct{ old, v, want string }{
+		{"{\n\t\"a\": 1 // one\n}", `{"a":1,"b":2}`, "{\n\t\"a\": 1, // one\n\t\"b\": 2\n}"},
+		{`{"a": 1, "b": [1, 2]}`, `{"b":[1,3],"c":true}`, `{"b": [1, 3], "c": true}`},
+		{"[\n\t1, // one\n\t2, // two\n]", `[1]`, "[\n\t1, // one\n]"},
+		{`{"a": 1}`, `[1]`, `[1]`},
+	} {
+		old, err := parseHuJSON([]byte(tt.old))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := parseHuJSON([]byte(tt.v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		old.value = huMerge(old.value, v.value)
+		if got := string(old.bytes(false)); got != tt.want {
+			t.Errorf("merge %q into %q = %q, want %q", tt.v, tt.old, got, tt.want)
+		}
+	}
+}
+
+func TestHuJSONNew(t *testing.T) {
+	t.Parallel()
+	type Config struct{ Interval int }
+
+	path := filepath.Join(t.TempDir(), "config.hujson")
+	db, err := New[Config](path, WithHuJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(c *Config) { c.Interval = 1 })
+	if b, _ := os.ReadFile(path); string(b) != "{\n\t\"Interval\": 1\n}\n" {
+		t.Errorf("file = %q, want it indented", b)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package jsonvalue works on JSON documents decoded into generic
+// values, for the jsonfile package and the packages and commands
+// built on it.
+package jsonvalue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Decode decodes b into generic JSON values, keeping numbers exactly
+// as they are written, as json.Number.
+func Decode(b []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, errors.New("invalid JSON: data after value")
+	}
+	return v, nil
+}
+
+// MergePatch returns target with patch applied, as in RFC 7386.
+// It modifies target.
+func MergePatch(target, patch any) any {
+	pm, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	tm, ok := target.(map[string]any)
+	if !ok {
+		tm = make(map[string]any)
+	}
+	for k, v := range pm {
+		if v == nil {
+			delete(tm, k)
+		} else {
+			tm[k] = MergePatch(tm[k], v)
+		}
+	}
+	return tm
+}
+
+// Diff calls fn with each change that turns a into b, both decoded by
+// Decode: the op of a JSON Patch (RFC 6902) operation, "add" or
+// "remove" for an object member and "replace" for any other changed
+// value, the value's JSON Pointer (RFC 6901), and its old and new
+// values. Objects are compared member by member, in order of their
+// keys, and other values are replaced whole. Diff stops at the first
+// error from fn and returns it.
+func Diff(a, b any, fn func(op, ptr string, old, new any) error) error {
+	return diff("", a, b, fn)
+}
+
+func diff(ptr string, a, b any, fn func(op, ptr string, old, new any) error) error {
+	am, aok := a.(map[string]any)
+	bm, bok := b.(map[string]any)
+	if !aok || !bok {
+		if reflect.DeepEqual(a, b) {
+			return nil
+		}
+		return fn("replace", ptr, a, b)
+	}
+	keys := make([]string, 0, len(am)+len(bm))
+	for k := range am {
+		keys = append(keys, k)
+	}
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kptr := ptr + "/" + EscapePointer(k)
+		av, inA := am[k]
+		bv, inB := bm[k]
+		var err error
+		switch {
+		case !inB:
+			err = fn("remove", kptr, av, nil)
+		case !inA:
+			err = fn("add", kptr, nil, bv)
+		default:
+			err = diff(kptr, av, bv, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// EscapePointer escapes s as a reference token of a JSON Pointer.
+func EscapePointer(s string) string { return pointerEscaper.Replace(s) }
+
+// UnescapePointer returns the member name of the reference token s of
+// a JSON Pointer.
+func UnescapePointer(s string) string { return pointerUnescaper.Replace(s) }
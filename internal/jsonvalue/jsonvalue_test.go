@@ -0,0 +1,81 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonvalue
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatchRFC(t *testing.T) {
+	t.Parallel()
+	// The examples of RFC 7386, Appendix A.
+	tests := []struct{ target, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		target, _ := Decode([]byte(tt.target))
+		patch, _ := Decode([]byte(tt.patch))
+		want, _ := Decode([]byte(tt.want))
+		if got := MergePatch(target, patch); !reflect.DeepEqual(got, want) {
+			b, _ := json.Marshal(got)
+			t.Errorf("merge %s into %s: %s, want %s", tt.patch, tt.target, b, tt.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+	v, err := Decode([]byte(`{"n":12345678901234567890}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := v.(map[string]any)["n"]; n != json.Number("12345678901234567890") {
+		t.Errorf("n=%v (%T), want the number as written", n, n)
+	}
+	if _, err := Decode([]byte(`{} {}`)); err == nil {
+		t.Error("Decode of two values succeeded")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	a, _ := Decode([]byte(`{"a":1,"b":{"c":[1],"d":true},"e/f":"x"}`))
+	b, _ := Decode([]byte(`{"a":1,"b":{"c":[2],"g":null},"h":"y"}`))
+	var got []string
+	err := Diff(a, b, func(op, ptr string, old, new any) error {
+		o, _ := json.Marshal(old)
+		n, _ := json.Marshal(new)
+		got = append(got, op+" "+ptr+" "+string(o)+" "+string(n))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`replace /b/c [1] [2]`,
+		`remove /b/d true null`,
+		`add /b/g null null`,
+		`remove /e~1f "x" null`,
+		`add /h null "y"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WithJournal makes Write append what changed to a journal, the path
+// with ".wal" appended, instead of replacing the whole file. When the
+// journal would grow beyond maxBytes, the next Write compacts it into
+// the file. For large data with small changes this makes a Write cost
+// in proportion to the change, not the data. The journal is synced
+// under the same SyncPolicy as the file.
+//
+// Load, Reload, and Watch replay the journal on top of the file. A
+// record cut short by a crash is ignored, leaving the data as it was
+// after the Write before it. Programs reading the file without the
+// journal see the data as of the last compaction, which Close does.
+//
+// WithJournal needs JSON, and cannot be used with WithHuJSON,
+// WithAutosave, WithEncryption, or WithCipher.
+func WithJournal(maxBytes int64) Option {
+	return func(o *options) { o.journal = maxBytes }
+}
+
+// journalHeader is the first line of a journal. Base is the hex SHA-256
+// of the file the records apply to, so a journal left behind by a
+// crash after compaction is not applied to the compacted file.
+type journalHeader struct {
+	Base string `json:"base"`
+}
+
+func (p *JSONFile[Data]) journalPath() string { return p.path + ".wal" }
+
+// appendJournal records the change from the data in p.bytes to b,
+// the data of a Write, compacting the journal into the file if it is
+// full. An append is counted and observed as writeFile counts a write
+// of the file. It is called by write with p.writing held.
+func (p *JSONFile[Data]) appendJournal(b []byte) (err error) {
+	var rec []byte
+	compact := false
+	defer func() {
+		if !compact { // else counted by writeFile
+			p.countWrite(err)
+			p.observeWrite(p.writeStart, len(rec), err)
+		}
+	}()
+	patch, err := diffJSON(p.bytes, b)
+	if err != nil {
+		return err
+	}
+	if p.journalSize == 0 {
+		hdr, err := json.Marshal(journalHeader{Base: hex.EncodeToString(p.diskState.sum[:])})
+		if err != nil {
+			return err
+		}
+		rec = append(hdr, '\n')
+	}
+	rec = append(append(rec, patch...), '\n')
+	if p.diskState == (fileState{}) || p.journalSize+int64(len(rec)) > p.opts.journal {
+		compact = true
+		return p.writeFile(b, true) // no file yet, or compact
+	}
+	if p.opts.detectConflicts {
+		if err := p.checkConflict(); err != nil {
+			return err
+		}
+	}
+	if p.journalFile == nil {
+		f, err := os.OpenFile(p.journalPath(), os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		// Drop anything after the last complete record.
+		if err := f.Truncate(p.journalSize); err == nil {
+			_, err = f.Seek(p.journalSize, 0)
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+		p.journalFile = f
+	}
+	if _, err := p.journalFile.Write(rec); err != nil {
+		p.dropRecord()
+		return err
+	}
+	now := time.Now()
+	if p.opts.sync.shouldSync(p.lastSync, now) {
+		err := p.journalFile.Sync()
+		if err == nil && p.journalSize == 0 && p.opts.syncDir {
+			err = syncDir(filepath.Dir(p.path))
+		}
+		if err != nil {
+			p.dropRecord()
+			return err
+		}
+		p.lastSync = now
+	}
+	p.journalSize += int64(len(rec))
+	p.recordChurn(now)
+	return nil
+}
+
+// dropRecord removes what a failed Write appended to the journal after
+// its complete records, so it is not replayed by the next Load, nor
+// taken as the data the next record changes. If the journal cannot be
+// truncated, it is compacted, writing the data to the file, which the
+// journal then no longer applies to. It is called with p.writing held.
+func (p *JSONFile[Data]) dropRecord() {
+	err := p.journalFile.Truncate(p.journalSize)
+	if err == nil {
+		_, err = p.journalFile.Seek(p.journalSize, 0)
+	}
+	if err != nil {
+		p.closeJournal()
+		p.writeFile(p.bytes, false) // the Write has failed already
+	}
+}
+
+// compactJournal writes the data to the file if the journal holds
+// changes to it, so that the file is up to date for programs that read
+// it without the journal. It is called by Close with p.writing held.
+func (p *JSONFile[Data]) compactJournal() error {
+	if p.opts.journal == 0 || p.journalSize == 0 || p.readOnly {
+		return nil
+	}
+	return p.writeFile(p.bytes, true)
+}
+
+// replayJournal applies the journal to b, the data decoded from the
+// file in the given state. It is called by readFile with p.writing held.
+func (p *JSONFile[Data]) replayJournal(b []byte, state fileState) ([]byte, error) {
+	p.closeJournal()
+	p.journalSize = 0
+	j, err := fs.ReadFile(p.opts.fileSystem(), p.journalPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	} else if err != nil {
+		return nil, err
+	}
+	line, rest, ok := bytes.Cut(j, []byte("\n"))
+	var hdr journalHeader
+	if !ok || json.Unmarshal(line, &hdr) != nil || hdr.Base != hex.EncodeToString(state.sum[:]) {
+		return b, nil // not for this file
+	}
+	size := int64(len(line) + 1)
+	replayed, n := b, 0
+	for {
+		line, rest, ok = bytes.Cut(rest, []byte("\n"))
+		if !ok {
+			break // a record cut short, or the end
+		}
+		nb, err := applyPatch(replayed, line)
+		if err != nil {
+			break
+		}
+		replayed, n = nb, n+1
+		size += int64(len(line) + 1)
+	}
+	p.journalSize = size
+	if n == 0 {
+		return b, nil
+	}
+	// Re-encode as Write would, the patches sort object keys.
+	data := new(Data)
+	if err := p.opts.unmarshal(replayed, data); err != nil {
+		return nil, err
+	}
+	if b, err = p.opts.marshal(data); err != nil {
+		return nil, err
+	}
+	if p.opts.unknownFields {
+		b = findUnknown(replayed, b).merge(b)
+	}
+	return b, nil
+}
+
+// closeJournal closes the journal file, if open.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) closeJournal() {
+	if p.journalFile != nil {
+		p.journalFile.Close()
+		p.journalFile = nil
+	}
+}
+
+// removeJournal removes the journal after its records are written
+// to the file. It is called by writeFile with p.writing held.
+func (p *JSONFile[Data]) removeJournal() {
+	p.closeJournal()
+	p.journalSize = 0
+	os.Remove(p.journalPath())
+}
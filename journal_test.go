@@ -0,0 +1,208 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val  int
+		Name string
+		Tags map[string]string
+	}
+
+	path := filepath.Join(t.TempDir(), "testjournal.json")
+	opts := []Option{WithJournal(1 << 20)}
+	db, err := New[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Name = "journal" })
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		mustWrite(t, db, func(db *DB) {
+			db.Val = i
+			db.Tags = map[string]string{"n": "x"}
+		})
+	}
+	if b, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(b, file) {
+		t.Errorf("Writes replaced the file: %s", b)
+	}
+	wal, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// crash puts back the files as they were before Close, with torn
+	// appended to the journal.
+	crash := func(torn string) {
+		t.Helper()
+		if err := os.WriteFile(path, file, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+".wal", append(wal[:len(wal):len(wal)], torn...), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	load := func(opts ...Option) DB {
+		t.Helper()
+		db, err := Load[DB](path, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		var v DB
+		db.Read(func(db *DB) { v = *db })
+		return v
+	}
+
+	// Close compacts the journal into the file.
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Errorf("journal not removed by Close: %v", err)
+	}
+	if got := load(); got.Val != 3 || got.Name != "journal" || got.Tags["n"] != "x" {
+		t.Errorf("compacted %+v", got)
+	}
+
+	// After a crash the journal is replayed.
+	crash("")
+	if got := load(opts...); got.Val != 3 || got.Name != "journal" || got.Tags["n"] != "x" {
+		t.Errorf("replayed %+v", got)
+	}
+	crash("")
+	ro, err := LoadFS[DB](os.DirFS(filepath.Dir(path)), filepath.Base(path), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ro.Read(func(db *DB) {
+		if db.Val != 3 {
+			t.Errorf("LoadFS Val=%d, want 3 from the journal", db.Val)
+		}
+	})
+	ro.Close()
+	if _, err := os.Stat(path + ".wal"); err != nil {
+		t.Errorf("journal of a LoadFS file: %v", err)
+	}
+
+	// A record cut short by a crash is ignored.
+	crash(`{"set":{"/Val":4`)
+	if got := load(opts...); got.Val != 3 {
+		t.Errorf("Val=%d after torn record, want 3", got.Val)
+	}
+
+	// Writing after the torn record replaces it.
+	crash(`{"set":{"/Val":4`)
+	db, err = Load[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 5 })
+	if b, err := os.ReadFile(path + ".wal"); err != nil {
+		t.Fatal(err)
+	} else if bytes.Contains(b, []byte(`"/Val":4`)) {
+		t.Errorf("torn record kept: %s", b)
+	}
+	db.Close()
+	if got := load(opts...); got.Val != 5 {
+		t.Errorf("Val=%d, want 5", got.Val)
+	}
+
+	// A full journal is compacted into the file.
+	db, err = Load[DB](path, WithJournal(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 6 })
+	db.Close()
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Errorf("journal not removed by compaction: %v", err)
+	}
+	db, err = Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 6 {
+			t.Errorf("compacted Val=%d, want 6", db.Val)
+		}
+	})
+	db.Close()
+
+	// A journal left behind by a crash after compaction is stale.
+	if err := os.WriteFile(path+".wal", []byte(`{"base":"00"}`+"\n"+`{"set":{"/Val":7}}`+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := load(opts...); got.Val != 6 {
+		t.Errorf("Val=%d with stale journal, want 6", got.Val)
+	}
+}
+
+func TestJournalCounted(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	// Appends to the journal count as writes of the file.
+	path := filepath.Join(t.TempDir(), "testjournal.json")
+	obs := new(testObserver)
+	var churned, critical int
+	db, err := New[DB](path, WithJournal(1<<20), WithObserver(obs),
+		WithChurnAlert(2, func(int) { churned++ }),
+		WithOnCriticalError(func(error) { critical++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := 1; i <= 3; i++ {
+		mustWrite(t, db, func(db *DB) { db.Val = i })
+	}
+	if _, err := os.Stat(path + ".wal"); err != nil {
+		t.Fatalf("Writes not journaled: %v", err)
+	}
+	if st := db.Stat(); st.Writes != 4 {
+		t.Errorf("Stat().Writes=%d, want 4", st.Writes)
+	}
+	obs.mu.Lock()
+	if len(obs.writes) != 4 || obs.writes[3].Bytes == 0 {
+		t.Errorf("observed %+v, want 4 writes", obs.writes)
+	}
+	obs.mu.Unlock()
+	if churned != 1 {
+		t.Errorf("churn alert called %d times, want 1", churned)
+	}
+
+	// Failed appends are failed writes.
+	db.closeJournal()
+	if err := os.Remove(path + ".wal"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path+".wal", 0700); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < criticalWriteFailures; i++ {
+		if err := db.Write(func(db *DB) error { db.Val = 10 + i; return nil }); err == nil {
+			t.Fatal("Write succeeded with a directory for the journal")
+		}
+	}
+	if st := db.Stat(); st.WriteErrors != criticalWriteFailures {
+		t.Errorf("Stat().WriteErrors=%d, want %d", st.WriteErrors, criticalWriteFailures)
+	}
+	if critical != 1 {
+		t.Errorf("critical error reported %d times, want 1", critical)
+	}
+	os.Remove(path + ".wal")
+}
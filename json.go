@@ -0,0 +1,27 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+// WithJSONv2 encodes and decodes the data with encoding/json/v2, which
+// is much faster than encoding/json for large values. It is available
+// when the program is built with GOEXPERIMENT=jsonv2 and a Go release
+// that has it. Otherwise encoding/json is used.
+//
+// The v2 package encodes some values differently, nil slices and maps
+// as [] and {} for example, and matches field names exactly when
+// decoding. Check that existing files load as expected before
+// switching to it. As with encoding/json, HTML characters are escaped
+// unless WithoutHTMLEscaping is used, and WithCanonical applies.
+func WithJSONv2() Option {
+	return func(o *options) {
+		if haveJSONv2 {
+			o.codec = jsonV2Codec{}
+		}
+	}
+}
+
+type jsonV2Codec struct{}
+
+func (jsonV2Codec) Marshal(v any) ([]byte, error)   { return marshalV2(v, true) }
+func (jsonV2Codec) Unmarshal(b []byte, v any) error { return unmarshalV2(b, v) }
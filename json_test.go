@@ -0,0 +1,72 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONv2(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Name string
+		Vals []int
+	}
+
+	path := filepath.Join(t.TempDir(), "testv2.json")
+	db, err := New[DB](path, WithJSONv2())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Name = "a" })
+	want := `{"Name":"a","Vals":null}`
+	if haveJSONv2 {
+		want = `{"Name":"a","Vals":[]}`
+	}
+	if b, _ := os.ReadFile(path); string(b) != want {
+		t.Errorf("file = %s, want %s", b, want)
+	}
+
+	db, err = Load[DB](path, WithJSONv2())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Name != "a" {
+			t.Errorf("Name=%q, want a", db.Name)
+		}
+	})
+}
+
+func TestJSONv2Output(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		B string
+		A int
+	}
+
+	tests := []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{WithJSONv2(), WithCanonical()}, `{"A":1,"B":"\u003cb\u003e"}`},
+		{[]Option{WithJSONv2(), WithCanonical(), WithoutHTMLEscaping()}, `{"A":1,"B":"<b>"}`},
+		{[]Option{WithJSONv2()}, `{"B":"\u003cb\u003e","A":1}`},
+		{[]Option{WithJSONv2(), WithoutHTMLEscaping()}, `{"B":"<b>","A":1}`},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "testv2.json")
+		db, err := New[DB](path, test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustWrite(t, db, func(db *DB) { db.B, db.A = "<b>", 1 })
+		db.Close()
+		if b, _ := os.ReadFile(path); string(b) != test.want {
+			t.Errorf("file = %s, want %s", b, test.want)
+		}
+	}
+}
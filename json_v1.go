@@ -0,0 +1,13 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !goexperiment.jsonv2 || !go1.27
+
+package jsonfile
+
+const haveJSONv2 = false
+
+func marshalV2(v any, escapeHTML bool) ([]byte, error) { panic("jsonfile: no encoding/json/v2") }
+func unmarshalV2(b []byte, v any) error                { panic("jsonfile: no encoding/json/v2") }
+
+func unmarshalV2Strict(b []byte, v any) error { panic("jsonfile: no encoding/json/v2") }
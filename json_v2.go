@@ -0,0 +1,23 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build goexperiment.jsonv2 && go1.27
+
+package jsonfile
+
+import (
+	"encoding/json/jsontext"
+	jsonv2 "encoding/json/v2"
+)
+
+const haveJSONv2 = true
+
+func marshalV2(v any, escapeHTML bool) ([]byte, error) {
+	return jsonv2.Marshal(v, jsontext.EscapeForHTML(escapeHTML))
+}
+
+func unmarshalV2(b []byte, v any) error { return jsonv2.Unmarshal(b, v) }
+
+func unmarshalV2Strict(b []byte, v any) error {
+	return jsonv2.Unmarshal(b, v, jsonv2.RejectUnknownMembers(true))
+}
@@ -5,34 +5,134 @@
 package jsonfile
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sync"
+	"time"
 )
 
 // JSONFile holds a Go value of type Data and persists it to a JSON file.
 // Data is accessed and modified using the Read and Write methods.
 // Create a JSONFile using the New or Load functions.
 type JSONFile[Data any] struct {
-	path string
+	path     string
+	opts     options
+	readOnly bool
+	lock     *os.File // lock file held by WithExclusiveLock or WithSharedLock
+
+	// writing is a semaphore held for the duration of a Write.
+	// Fields written by Write are read by Write while holding it,
+	// and are also guarded by mu for other readers.
+	writing    chan struct{}
+	lastSync   time.Time     // guarded by writing
+	lastBackup time.Time     // guarded by writing
+	diskState  fileState     // guarded by writing
+	churn      churn         // guarded by writing
+	closed     bool          // guarded by writing
+	done       chan struct{} // closed when closed is set
+	dirty      bool          // data not yet written by WithAutosave, guarded by writing
+	hujsonText []byte        // last contents of the file with WithHuJSON, guarded by writing
+	flushTimer *time.Timer   // guarded by writing
+
+	journalFile *os.File    // open journal of WithJournal, guarded by writing
+	journalSize int64       // bytes of complete records in the journal, guarded by writing
+	historyGen  uint64      // generation of the last record of WithHistory, guarded by writing
+	historyLast []byte      // data of the last record of WithHistory, guarded by writing
+	auditPrev   string      // sum of the last record of WithAuditLog, guarded by writing
+	fileMeta    fileMeta    // envelope of the file last read, guarded by writing
+	replica     *replicator // of WithReplica, guarded by writing
+	writeStats  WriteStats  // of WithWriteStats, guarded by writing and mu
+	writeStart  time.Time   // when the last Write started, guarded by writing
+
+	writeFailures int             // writes of the file failed in a row, guarded by writing
+	counters      counters        // activity reported by Stat
+	traceCtx      context.Context // of the current Write or Load, for WithTracer, guarded by writing
+
+	unknown *unknownFields // members dropped by WithUnknownFields, guarded by writing
 
 	mu    sync.RWMutex
 	bytes []byte
 	data  *Data
+	gen   uint64        // incremented each time data changes
+	stamp Stamp         // stamp of data from WithClock
+	genCh chan struct{} // closed and replaced when gen changes
+
+	modTime time.Time // when data last changed
+	size    int64     // size of the file on disk
+	watches int       // running Watch calls
+
+	subMu sync.Mutex
+	subs  map[chan ChangeEvent[Data]]struct{}
+
+	groupMu sync.Mutex
+	group   []*groupReq[Data] // Writes queued by WithGroupCommit
+}
+
+func newJSONFile[Data any](path string, opts []Option) *JSONFile[Data] {
+	o := newOptions(opts)
+	return &JSONFile[Data]{
+		path:     path,
+		opts:     o,
+		readOnly: o.lock == lockShared,
+		writing:  make(chan struct{}, 1),
+		data:     new(Data),
+		genCh:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
 }
 
 // New creates a new empty JSONFile at the given path.
-func New[Data any](path string) (*JSONFile[Data], error) {
-	p := &JSONFile[Data]{path: path, bytes: []byte("{}"), data: new(Data)}
-	if err := p.Write(func(*Data) error { return nil }); err != nil {
+func New[Data any](path string, opts ...Option) (*JSONFile[Data], error) {
+	p := newJSONFile[Data](path, opts)
+	if err := p.create(func(*Data) error { return nil }); err != nil {
 		return nil, fmt.Errorf("jsonfile.New: %w", err)
 	}
 	return p, nil
 }
 
+// NewWithDefault creates a new JSONFile at path holding initial, so the
+// file starts in a meaningful default state rather than the zero value
+// of Data. It is New followed by a Write of initial, but the file is
+// never seen holding the zero value. The JSONFile keeps a copy of
+// initial, which the caller may go on using.
+func NewWithDefault[Data any](path string, initial Data, opts ...Option) (*JSONFile[Data], error) {
+	p := newJSONFile[Data](path, opts)
+	if err := p.create(func(data *Data) error { *data = initial; return nil }); err != nil {
+		return nil, fmt.Errorf("jsonfile.NewWithDefault: %w", err)
+	}
+	return p, nil
+}
+
+// create creates the file holding the data set by init.
+func (p *JSONFile[Data]) create(init func(*Data) error) error {
+	if err := p.opts.check(); err != nil {
+		return err
+	}
+	if p.readOnly {
+		return ErrReadOnly
+	}
+	if err := p.lockFile(); err != nil {
+		return err
+	}
+	p.removeStaleTemps()
+	if p.opts.isJSON() {
+		p.bytes = []byte("{}")
+		p.opts.unmarshal(p.bytes, p.data) // make a top-level map
+	}
+	if err := p.Write(init); err != nil {
+		p.unlockFile()
+		return err
+	}
+	if err := p.Flush(); err != nil { // New always creates the file
+		p.unlockFile()
+		return err
+	}
+	p.event(Event{Event: "created"})
+	return nil
+}
+
 // Load loads an existing JSONFileData from the given path.
 //
 // If the file does not exist, Load returns an error that can be
@@ -47,68 +147,429 @@ func New[Data any](path string) (*JSONFile[Data], error) {
 //	if os.IsNotExist(err) {
 //		db, err = jsonfile.New[Data](path)
 //	}
-func Load[Data any](path string) (*JSONFile[Data], error) {
-	p := &JSONFile[Data]{path: path, data: new(Data)}
-	var err error
-	p.bytes, err = os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("jsonfile.Load: %w", err)
-	}
-	if err := json.Unmarshal(p.bytes, p.data); err != nil {
+func Load[Data any](path string, opts ...Option) (*JSONFile[Data], error) {
+	p := newJSONFile[Data](path, opts)
+	if err := p.load(); err != nil {
 		return nil, fmt.Errorf("jsonfile.Load: %w", err)
 	}
 	return p, nil
 }
 
+// load reads the existing file.
+func (p *JSONFile[Data]) load() (err error) {
+	if err := p.opts.check(); err != nil {
+		return err
+	}
+	ctx, end := p.opts.startSpan(context.Background(), "jsonfile.Load")
+	defer func() { end(err) }()
+	p.traceCtx = ctx
+	defer func() { p.traceCtx = nil }()
+	if err := p.lockFile(); err != nil {
+		return err
+	}
+	if !p.readOnly {
+		p.removeStaleTemps()
+	}
+	legacy, err := p.loadLegacy()
+	if err == nil && !legacy {
+		end := p.span("jsonfile.read")
+		p.bytes, p.diskState, err = p.readFile()
+		end(err)
+		if err == nil {
+			p.recordDecode(p.bytes)
+			err = p.opts.checkJSONSchema(p.bytes)
+		}
+		if err == nil {
+			end := p.span("jsonfile.unmarshal")
+			err = p.opts.unmarshal(p.bytes, p.data)
+			end(err)
+		}
+		if err == nil {
+			err = p.rememberUnknown(p.bytes, p.data)
+		}
+		p.stamp, p.writeStats = p.fileMeta.stamp, p.fileMeta.stats
+	}
+	if err != nil {
+		if errors.Is(err, ErrCorrupt) {
+			p.event(Event{Event: "corrupted", Error: err.Error()})
+			p.critical(fmt.Errorf("jsonfile.Load: %s: %w", p.path, err))
+		}
+		p.unlockFile()
+		return err
+	}
+	if legacy {
+		p.event(Event{Event: "migrated", From: p.path + ".legacy"})
+	}
+	p.event(Event{Event: "opened"})
+	p.modTime, p.size = p.diskState.modTime, p.diskState.size
+	p.scheduledBackup(p.bytes)
+	return nil
+}
+
 // Read calls fn with the current copy of the data.
 func (p *JSONFile[Data]) Read(fn func(data *Data)) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	fn(p.data)
+	p.read(context.Background(), fn)
+}
+
+func (p *JSONFile[Data]) read(ctx context.Context, fn func(data *Data)) error {
+	return p.aroundRead(ctx, func(context.Context) error {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		fn(p.data)
+		return nil
+	})
 }
 
+// ReadCtx is like Read, but does not call fn if ctx is done.
+// Read waits only for a Write to swap in its new copy of the data,
+// never for a Write's file I/O, so ReadCtx checks ctx once.
+func (p *JSONFile[Data]) ReadCtx(ctx context.Context, fn func(data *Data)) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("JSONFile.ReadCtx: %w", err)
+	}
+	return p.read(ctx, fn)
+}
+
+// SkipWrite is returned by a Write fn that made no change to the data.
+// Write then returns nil without encoding the data to check for changes,
+// which for large data saves most of the cost of a Write that only
+// has to look at it.
+var SkipWrite = errors.New("jsonfile: skip write")
+
 // Write calls fn with a copy of the data, then writes the changes to the file.
 // If fn returns an error, Write does not change the file and returns the error.
+// If fn returns SkipWrite, Write returns nil without encoding the data.
 func (p *JSONFile[Data]) Write(fn func(*Data) error) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	return p.WriteCtx(context.Background(), fn)
+}
+
+// WriteBatch is like Write, but calls each of fns in turn on the same
+// copy of the data and writes the file once. If any fn returns an
+// error, WriteBatch stops, does not change the file, and returns it.
+// A fn that returns SkipWrite made no change, and WriteBatch goes on.
+func (p *JSONFile[Data]) WriteBatch(fns ...func(*Data) error) error {
+	return p.Write(func(data *Data) error {
+		for _, fn := range fns {
+			if err := fn(data); err != nil && !errors.Is(err, SkipWrite) {
+				return err
+			}
+		}
+		return nil
+	})
+}
 
-	data := new(Data) // operate on copy to allow concurrent reads and rollback
-	if err := json.Unmarshal(p.bytes, data); err != nil {
+// WriteCtx is like Write, but gives up if ctx is done while waiting
+// for another Write to finish, or before writing to the file.
+// In both cases WriteCtx returns an error wrapping ctx.Err().
+func (p *JSONFile[Data]) WriteCtx(ctx context.Context, fn func(*Data) error) error {
+	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("JSONFile.Write: %w", err)
 	}
-	if err := fn(data); err != nil {
+	return p.aroundWrite(ctx, func(ctx context.Context) error {
+		if p.opts.groupCommit {
+			return p.groupWrite(ctx, fn)
+		}
+		_, err := p.writeAlone(ctx, fn)
 		return err
+	})
+}
+
+// writeAlone performs a Write without group commit.
+func (p *JSONFile[Data]) writeAlone(ctx context.Context, fn func(*Data) error) (Result, error) {
+	select {
+	case p.writing <- struct{}{}:
+	case <-ctx.Done():
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", ctx.Err())
+	}
+	defer func() { <-p.writing }()
+	return p.write(ctx, fn)
+}
+
+// write is the body of Write. It is called with p.writing held.
+func (p *JSONFile[Data]) write(ctx context.Context, fn func(*Data) error) (_ Result, err error) {
+	if p.closed {
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", ErrClosed)
+	}
+	if p.readOnly {
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", ErrReadOnly)
 	}
-	b, err := json.Marshal(data)
+	p.writeStart, p.traceCtx = time.Now(), ctx
+	defer func() { p.writeStart, p.traceCtx = time.Time{}, nil }()
+	defer func() {
+		if err != nil {
+			p.opts.rollback(ctx, err)
+		}
+	}()
+
+	end := p.span("jsonfile.copy")
+	data, err := copyData(&p.opts, p.data, p.bytes) // operate on copy to allow concurrent reads and rollback
+	end(err)
 	if err != nil {
-		return fmt.Errorf("JSONFile.Write: %w", err)
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", err)
+	}
+	end = p.span("jsonfile.fn")
+	err = fn(data)
+	end(err)
+	if errors.Is(err, SkipWrite) {
+		return Result{Revision: p.gen}, nil
+	} else if err != nil {
+		p.event(Event{Event: "rolledback", Error: err.Error()})
+		return Result{}, err
+	}
+	if err := p.opts.beforeWrite(ctx, data); err != nil {
+		return Result{}, err
+	}
+	if err := p.opts.validate(data); err != nil {
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", err)
+	}
+	marshalStart, end := time.Now(), p.span("jsonfile.marshal")
+	b, err := p.opts.marshal(data)
+	end(err)
+	p.counters.marshal.Add(int64(time.Since(marshalStart)))
+	if err != nil {
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", err)
+	}
+	if p.unknown != nil {
+		if b, err = p.opts.canonicalize(p.unknown.merge(b)); err != nil {
+			return Result{}, fmt.Errorf("JSONFile.Write: %w", err)
+		}
+	}
+	if same, err := p.unchanged(data, b); err != nil {
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", err)
+	} else if same {
+		return Result{Revision: p.gen}, nil // no change
+	}
+	if err := p.opts.checkJSONSchema(b); err != nil {
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", err)
+	}
+	data, err = copyData(&p.opts, data, b) // avoid any aliased memory
+	if err != nil {
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", err)
+	}
+	if p.opts.clock != nil {
+		prev := p.stamp
+		p.setStamp(p.opts.clock.Now())
+		defer func() {
+			if err != nil {
+				p.setStamp(prev)
+			}
+		}()
+	}
+	if p.opts.autosave > 0 {
+		if err := p.auditWrite(ctx, b); err != nil {
+			return Result{}, err
+		}
+		p.deferWrite(data, b)
+		return Result{Revision: p.gen, Changed: true}, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", err)
 	}
-	if bytes.Equal(b, p.bytes) {
-		return nil // no change
+	if err := p.auditWrite(ctx, b); err != nil {
+		return Result{}, err
 	}
 
-	f, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".tmp")
+	if p.opts.journal > 0 {
+		err = p.appendJournal(b)
+	} else {
+		err = p.writeFile(b, true)
+	}
 	if err != nil {
-		return fmt.Errorf("JSONFile.Write: temp: %w", err)
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", err)
 	}
-	_, err = f.Write(b)
-	if err1 := f.Close(); err1 != nil && err == nil {
-		err = err1
+
+	p.publish(data, b)
+	p.afterCommit(ctx, data, b)
+	return Result{Revision: p.gen, BytesWritten: len(b), Changed: true}, nil
+}
+
+// afterCommit runs the steps that follow writing data, encoded as b,
+// to the file. It is called with p.writing held.
+func (p *JSONFile[Data]) afterCommit(ctx context.Context, data *Data, b []byte) {
+	p.event(Event{Event: "wrote", Revision: p.gen, Bytes: len(b)})
+	p.scheduledBackup(b)
+	if p.opts.history {
+		p.recordHistory(b, time.Now()) // best effort, see WithHistory
+	}
+	p.writeMirrors(data)
+	if p.opts.gitRepo != "" {
+		p.gitCommit(ctx, p.gen) // best effort, see WithGit
 	}
+	p.opts.afterCommit(ctx, data)
+}
+
+// Reload re-reads the file from disk, replacing the data in memory.
+// Use it to pick up changes made to the file by other programs.
+// If the file cannot be read or decoded, Reload returns an error and
+// the data in memory is unchanged. Otherwise, Reload discards any
+// changes not yet written by WithAutosave.
+func (p *JSONFile[Data]) Reload() error {
+	p.writing <- struct{}{}
+	defer func() { <-p.writing }()
+
+	b, state, err := p.readFile()
 	if err != nil {
-		return fmt.Errorf("JSONFile.Write: %w", err)
+		return fmt.Errorf("JSONFile.Reload: %w", err)
 	}
-	if err := os.Rename(f.Name(), p.path); err != nil {
-		return fmt.Errorf("JSONFile.Write: rename: %w", err)
+	if err := p.replaceData(b, state); err != nil {
+		return fmt.Errorf("JSONFile.Reload: %w", err)
 	}
+	p.dirty = false
+	return nil
+}
 
-	data = new(Data) // avoid any aliased memory
-	if err := json.Unmarshal(b, data); err != nil {
-		return fmt.Errorf("JSONFile.Write: %w", err)
+// replaceData decodes b, read from the file in the given state,
+// and makes it the data in memory.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) replaceData(b []byte, state fileState) error {
+	data := new(Data)
+	p.recordDecode(b)
+	if err := p.opts.checkJSONSchema(b); err != nil {
+		return err
+	}
+	if err := p.opts.unmarshal(b, data); err != nil {
+		return err
+	}
+	if err := p.rememberUnknown(b, data); err != nil {
+		return err
+	}
+	p.diskState = state
+	p.setStamp(p.fileMeta.stamp)
+	p.setWriteStats(p.fileMeta.stats)
+	p.publish(data, b)
+	p.event(Event{Event: "reloaded", Revision: p.gen})
+	return nil
+}
+
+// publish makes data, encoded as b, the current data and notifies
+// subscribers. data must not be modified after it is published.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) publish(data *Data, b []byte) {
+	var diff []PatchOp
+	if p.opts.diffs && p.subscribed() {
+		diff, _ = jsonPatch(p.bytes, b) // p.bytes is not changed while p.writing is held
 	}
 
+	p.mu.Lock()
 	p.data = data
 	p.bytes = b
+	p.gen++
+	gen := p.gen
+	close(p.genCh)
+	p.genCh = make(chan struct{})
+	p.modTime = time.Now()
+	p.size = int64(len(b))
+	stamp := p.stamp
+	p.mu.Unlock()
+
+	p.notify(ChangeEvent[Data]{Generation: gen, Data: data, Diff: diff, Stamp: stamp})
+}
+
+// writeFile atomically replaces the file at p.path with b.
+// If checkConflicts is set and conflict detection is enabled,
+// it fails if the file was modified externally.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) writeFile(b []byte, checkConflicts bool) (err error) {
+	start := p.writeStart
+	if start.IsZero() {
+		start = time.Now() // written outside of a Write, by WithAutosave
+	}
+	defer func() { p.countWrite(err); p.observeWrite(start, len(b), err) }()
+	ctx, end := p.opts.startSpan(p.traceContext(), "jsonfile.writeFile")
+	defer func() { end(err) }()
+	undoStats := p.countWriteStats()
+	defer func() {
+		if err != nil {
+			undoStats()
+		}
+	}()
+	b, err = p.encodeFile(b)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	doSync := p.opts.sync.shouldSync(p.lastSync, now)
+	fsys := p.opts.fileSystem()
+	if p.opts.tracer != nil {
+		fsys = tracingFS{WriteFS: fsys, ctx: ctx, tracer: p.opts.tracer}
+	}
+	var newState fileState
+	beforeRename := func(tmp string) error {
+		if checkConflicts && p.opts.detectConflicts {
+			if err := p.checkConflict(); err != nil {
+				return err
+			}
+		}
+		if p.opts.recoveryBackup || p.opts.rotatedBackups > 0 {
+			if err := p.keepPrevious(); err != nil {
+				return fmt.Errorf("keep previous version: %w", err)
+			}
+		}
+		if p.opts.revisionFiles > 0 {
+			if err := p.writeRevision(tmp); err != nil {
+				return fmt.Errorf("revision file: %w", err)
+			}
+		}
+		var err error
+		newState, err = statFile(fsys, tmp, b)
+		return err
+	}
+	if err := atomicWrite(fsys, p.path, b, doSync, doSync && p.opts.syncDir, beforeRename); err != nil {
+		return err
+	}
+	if doSync {
+		p.lastSync = now
+	}
+	p.diskState = newState
+	p.replicate(b)
+	if p.opts.journal > 0 {
+		p.removeJournal()
+	}
+	if p.opts.hujson {
+		p.hujsonText = b
+	}
+	p.recordChurn(now)
+	return nil
+}
+
+// atomicWrite replaces the file at path in fsys with b by writing a
+// temporary file and renaming it. If doSync is set the temporary file is synced
+// before the rename, and if syncParent is set the directory is synced
+// after it. If beforeRename is not nil, it is called with the name of
+// the complete temporary file, and an error from it aborts the write.
+func atomicWrite(fsys WriteFS, path string, b []byte, doSync, syncParent bool, beforeRename func(tmp string) error) (err error) {
+	dir, base := splitPath(fsys, path)
+	f, err := fsys.CreateTemp(dir, base+".tmp")
+	if err != nil {
+		return fmt.Errorf("temp: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			fsys.Remove(f.Name())
+		}
+	}()
+	_, err = f.Write(b)
+	if err == nil && doSync {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	if beforeRename != nil {
+		if err := beforeRename(f.Name()); err != nil {
+			return err
+		}
+	}
+	if err := fsys.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+	if syncParent {
+		if err := syncDir(dir); err != nil {
+			return fmt.Errorf("sync dir: %w", err)
+		}
+	}
 	return nil
 }
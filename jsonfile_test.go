@@ -4,6 +4,7 @@
 package jsonfile
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func mustWrite[Data any](t *testing.T, data *JSONFile[Data], fn func(db *Data)) {
@@ -180,3 +182,216 @@ func TestBadNew(t *testing.T) {
 		t.Fatalf("New err=%v, want %v", err, os.ErrExist)
 	}
 }
+
+func TestNewWithDefault(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Port  int
+		Hosts []string
+	}
+	path := filepath.Join(t.TempDir(), "testdefault.json")
+	initial := DB{Port: 8080, Hosts: []string{"a"}}
+	db, err := NewWithDefault(path, initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial.Hosts[0] = "changed"
+	db.Read(func(db *DB) {
+		if db.Port != 8080 || len(db.Hosts) != 1 || db.Hosts[0] != "a" {
+			t.Errorf("data = %+v, want the default", *db)
+		}
+	})
+	if gen := db.Stat().Generation; gen != 1 {
+		t.Errorf("Generation=%d, want 1", gen)
+	}
+	db.Close()
+
+	db, err = Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.Read(func(db *DB) {
+		if db.Port != 8080 {
+			t.Errorf("loaded Port=%d, want 8080", db.Port)
+		}
+	})
+}
+
+func TestWriteCtx(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testwritectx.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- db.Write(func(db *DB) error {
+			close(started)
+			<-release
+			db.Val = 1
+			return nil
+		})
+	}()
+	<-started
+
+	// Reads are not blocked by the pending Write.
+	db.Read(func(db *DB) {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = db.WriteCtx(ctx, func(db *DB) error {
+		t.Error("fn called after context deadline")
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WriteCtx err=%v, want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// A context canceled inside fn aborts before the file is written.
+	ctx, cancel = context.WithCancel(context.Background())
+	err = db.WriteCtx(ctx, func(db *DB) error {
+		db.Val = 2
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("WriteCtx err=%v, want %v", err, context.Canceled)
+	}
+	if err := db.ReadCtx(ctx, func(db *DB) { t.Error("fn called with canceled context") }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReadCtx err=%v, want %v", err, context.Canceled)
+	}
+	if err := db.ReadCtx(context.Background(), func(db *DB) {
+		if db.Val != 1 {
+			t.Errorf("Val = %d, want 1", db.Val)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	db2, err := Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	db2.Read(func(db *DB) {
+		if db.Val != 1 {
+			t.Errorf("Val = %d on disk, want 1", db.Val)
+		}
+	})
+}
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testreload.json")
+	db, err := New[DB](path, WithConflictDetection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+
+	if err := os.WriteFile(path, []byte(`{"Val":2}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 2 {
+			t.Errorf("Val = %d after Reload, want 2", db.Val)
+		}
+	})
+	mustWrite(t, db, func(db *DB) { db.Val++ }) // no conflict after Reload
+
+	if err := os.WriteFile(path, []byte(`not json`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Reload(); err == nil {
+		t.Fatal("Reload of bad file succeeded")
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 3 {
+			t.Errorf("Val = %d after failed Reload, want 3", db.Val)
+		}
+	})
+}
+
+func TestWriteBatch(t *testing.T) {
+	t.Parallel()
+	type DB struct{ A, B int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testbatch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setA := func(db *DB) error { db.A = 1; return nil }
+	setB := func(db *DB) error { db.B = db.A + 1; return nil }
+	if err := db.WriteBatch(setA, setB); err != nil {
+		t.Fatal(err)
+	}
+	if gen := db.Stat().Generation; gen != 2 {
+		t.Errorf("Generation=%d after batch, want 2 (one write)", gen)
+	}
+
+	rollbackErr := errors.New("rollback")
+	if err := db.WriteBatch(
+		func(db *DB) error { db.A = 10; return nil },
+		func(db *DB) error { return rollbackErr },
+		func(db *DB) error { t.Error("fn called after error"); return nil },
+	); !errors.Is(err, rollbackErr) {
+		t.Fatalf("WriteBatch err=%v, want %v", err, rollbackErr)
+	}
+	db.Read(func(db *DB) {
+		if db.A != 1 || db.B != 2 {
+			t.Errorf("got %+v after batch rollback, want {A:1 B:2}", *db)
+		}
+	})
+}
+
+func TestSkipWrite(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testskip.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := db.Stat().Generation
+	if err := db.Write(func(db *DB) error {
+		if db.Val == 0 {
+			return SkipWrite
+		}
+		t.Error("Val != 0")
+		return nil
+	}); err != nil {
+		t.Fatalf("Write err=%v, want nil for SkipWrite", err)
+	}
+	if got := db.Stat().Generation; got != gen {
+		t.Errorf("Generation=%d after SkipWrite, want %d", got, gen)
+	}
+
+	// In a batch, SkipWrite skips only its own fn.
+	if err := db.WriteBatch(
+		func(db *DB) error { return SkipWrite },
+		func(db *DB) error { db.Val = 1; return nil },
+	); err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 1 {
+			t.Errorf("Val=%d after batch, want 1", db.Val)
+		}
+	})
+}
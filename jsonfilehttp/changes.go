@@ -0,0 +1,99 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// heartbeat is how often Changes writes a comment to an idle stream,
+// so proxies do not close it.
+const heartbeat = 30 * time.Second
+
+// Changes returns an http.Handler that pushes the changes to db to
+// browsers as server-sent events, so a dashboard can use an
+// EventSource to update live without polling.
+//
+// A stream starts with a "snapshot" event holding the JSON document.
+// Each change is then sent as a "patch" event holding a JSON Patch
+// (RFC 6902) of it, if db was opened jsonfile.WithDiffs and no changes
+// were dropped, or else as another "snapshot". The id of each event is
+// the generation of the document.
+func Changes[Data any](db *jsonfile.JSONFile[Data]) http.Handler {
+	return &changes[Data]{db: db}
+}
+
+type changes[Data any] struct {
+	db *jsonfile.JSONFile[Data]
+}
+
+func (h *changes[Data]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	events, cancel := h.db.Subscribe() // before the snapshot, so no change is missed
+	defer cancel()
+
+	var data *Data
+	var gen uint64
+	h.db.ReadWithGeneration(func(d *Data, g uint64) { data, gen = d, g })
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := sendEvent(w, "snapshot", gen, data); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			switch {
+			case ev.Generation <= gen:
+				continue // already in the snapshot
+			case ev.Generation == gen+1 && ev.Diff != nil:
+				err = sendEvent(w, "patch", ev.Generation, ev.Diff)
+			default:
+				err = sendEvent(w, "snapshot", ev.Generation, ev.Data)
+			}
+			gen = ev.Generation
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// sendEvent writes a server-sent event with v encoded as JSON, which
+// is on one line.
+func sendEvent(w http.ResponseWriter, event string, id uint64, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", event, id, b)
+	return err
+}
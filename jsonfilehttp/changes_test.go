@@ -0,0 +1,97 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// readEvent reads the next server-sent event, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) (event, id, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, id, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	t.Parallel()
+	for _, diffs := range []bool{false, true} {
+		diffs := diffs
+		t.Run(map[bool]string{false: "snapshots", true: "diffs"}[diffs], func(t *testing.T) {
+			t.Parallel()
+			var opts []jsonfile.Option
+			if diffs {
+				opts = append(opts, jsonfile.WithDiffs())
+			}
+			db, err := jsonfile.New[testDB](filepath.Join(t.TempDir(), "db.json"), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := httptest.NewServer(Changes(db))
+			t.Cleanup(srv.Close)
+
+			res := do(t, "GET", srv.URL, "")
+			if got := res.Header.Get("Content-Type"); got != "text/event-stream" {
+				t.Fatalf("Content-Type %q", got)
+			}
+			r := bufio.NewReader(res.Body)
+			event, id, data := readEvent(t, r)
+			if event != "snapshot" || data != `{"Name":"","Count":0}` {
+				t.Errorf("first event %s %s", event, data)
+			}
+
+			if err := db.Write(func(db *testDB) error { db.Count = 3; return nil }); err != nil {
+				t.Fatal(err)
+			}
+			prev := id
+			event, id, data = readEvent(t, r)
+			if id == prev {
+				t.Errorf("id did not change from %s", prev)
+			}
+			if diffs {
+				if want := `[{"op":"replace","path":"/Count","value":3}]`; event != "patch" || data != want {
+					t.Errorf("change event %s %s, want patch %s", event, data, want)
+				}
+			} else if want := `{"Name":"","Count":3}`; event != "snapshot" || data != want {
+				t.Errorf("change event %s %s, want snapshot %s", event, data, want)
+			}
+			res.Body.Close() // ends the stream, so srv.Close does not wait
+		})
+	}
+}
+
+func TestChangesMethod(t *testing.T) {
+	t.Parallel()
+	db, err := jsonfile.New[testDB](filepath.Join(t.TempDir(), "db.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(Changes(db))
+	t.Cleanup(srv.Close)
+	if res := do(t, "PUT", srv.URL, "{}"); res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("PUT status %s, want 405", res.Status)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxAttempts bounds how many times Client.Write retries fn after
+// a concurrent change on the server.
+const maxAttempts = 10
+
+// clientTimeout bounds each request of a Client made by NewClient.
+const clientTimeout = 30 * time.Second
+
+// ErrConflict is returned by Client.Write when the remote document
+// kept changing underneath it.
+var ErrConflict = errors.New("jsonfilehttp: conflicting remote writes")
+
+// Client accesses a JSON document served by Handler.
+// It has the same Read and Write methods as jsonfile.JSONFile,
+// so code can use either a local file or a remote server.
+type Client[Data any] struct {
+	url  string
+	http *http.Client
+
+	writeMu sync.Mutex // held by Write, so Writes do not race each other
+
+	mu    sync.RWMutex // guards the copy of the document, not held during requests
+	etag  string
+	bytes []byte
+	data  *Data
+}
+
+// NewClient creates a Client for the document served at url and
+// fetches its current value. Each request the Client makes times out
+// after 30 seconds.
+func NewClient[Data any](url string) (*Client[Data], error) {
+	return NewClientWithHTTP[Data](url, &http.Client{Timeout: clientTimeout})
+}
+
+// NewClientWithHTTP is NewClient, making its requests with hc, which
+// sets the timeouts and transport to use.
+func NewClientWithHTTP[Data any](url string, hc *http.Client) (*Client[Data], error) {
+	c := &Client[Data]{url: url, http: hc, data: new(Data)}
+	if err := c.Refresh(); err != nil {
+		return nil, fmt.Errorf("jsonfilehttp.NewClientWithHTTP: %w", err)
+	}
+	return c, nil
+}
+
+// Refresh fetches the current value of the document from the server.
+func (c *Client[Data]) Refresh() error {
+	c.mu.RLock()
+	etag := c.etag
+	c.mu.RUnlock()
+	b, tag, err := fetch(c.http, c.url, etag)
+	if err != nil || b == nil {
+		return err
+	}
+	data := new(Data)
+	if err := json.Unmarshal(b, data); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.etag == etag { // else a concurrent Refresh or Write updated it
+		c.etag = tag
+		c.bytes = b
+		c.data = data
+	}
+	return nil
+}
+
+// Read calls fn with the most recently fetched copy of the document.
+// It does not contact the server: use Refresh to fetch changes.
+func (c *Client[Data]) Read(fn func(data *Data)) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	fn(c.data)
+}
+
+// Write calls fn with a copy of the data, then sends the changes to
+// the server. If fn returns an error, Write does not change the
+// document and returns the error.
+//
+// If the document changed on the server since it was last fetched,
+// Write fetches the new value and calls fn again, so fn may be called
+// more than once.
+func (c *Client[Data]) Write(fn func(*Data) error) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	for i := 0; i < maxAttempts; i++ {
+		c.mu.RLock()
+		etag, cur := c.etag, c.bytes
+		c.mu.RUnlock()
+		data := new(Data)
+		if err := json.Unmarshal(cur, data); err != nil {
+			return fmt.Errorf("jsonfilehttp.Client.Write: %w", err)
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("jsonfilehttp.Client.Write: %w", err)
+		}
+		if bytes.Equal(b, cur) {
+			return nil // no change
+		}
+		tag, err := put(c.http, c.url, etag, b)
+		if errors.Is(err, errPrecondition) {
+			if err := c.Refresh(); err != nil {
+				return fmt.Errorf("jsonfilehttp.Client.Write: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("jsonfilehttp.Client.Write: %w", err)
+		}
+
+		data = new(Data) // avoid any aliased memory
+		if err := json.Unmarshal(b, data); err != nil {
+			return fmt.Errorf("jsonfilehttp.Client.Write: %w", err)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.etag = tag
+		c.bytes = b
+		c.data = data
+		return nil
+	}
+	return ErrConflict
+}
+
+// fetch GETs the document at url. If etag is non-empty and the
+// document has not changed, fetch returns a nil b.
+func fetch(client *http.Client, url, etag string) (b []byte, newTag string, err error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, "", statusError(res)
+	}
+	b, err = io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, res.Header.Get("ETag"), nil
+}
+
+// put PUTs b to url if the document there still has the given etag.
+// It returns errPrecondition if the document has changed.
+func put(client *http.Client, url, etag string, b []byte) (newTag string, err error) {
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", etag)
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return res.Header.Get("ETag"), nil
+	case http.StatusPreconditionFailed:
+		return "", errPrecondition
+	default:
+		return "", statusError(res)
+	}
+}
+
+func statusError(res *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+}
@@ -0,0 +1,130 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+	db, srv := newTestServer(t)
+
+	c1, err := NewClient[testDB](srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := NewClient[testDB](srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c1.Write(func(db *testDB) error { db.Count++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	// c2 has a stale copy, so its first attempt conflicts and is retried.
+	calls := 0
+	if err := c2.Write(func(db *testDB) error { calls++; db.Count++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("fn called %d times, want 2", calls)
+	}
+
+	db.Read(func(db *testDB) {
+		if db.Count != 2 {
+			t.Errorf("server Count=%d, want 2", db.Count)
+		}
+	})
+	c1.Read(func(db *testDB) {
+		if db.Count != 1 {
+			t.Errorf("c1 Count=%d before Refresh, want its cached 1", db.Count)
+		}
+	})
+	if err := c1.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	c1.Read(func(db *testDB) {
+		if db.Count != 2 {
+			t.Errorf("c1 Count=%d, want 2", db.Count)
+		}
+	})
+
+	rollbackErr := errors.New("rollback")
+	if err := c1.Write(func(db *testDB) error {
+		db.Count = 100
+		return rollbackErr
+	}); !errors.Is(err, rollbackErr) {
+		t.Fatalf("Write err=%v, want %v", err, rollbackErr)
+	}
+	db.Read(func(db *testDB) {
+		if db.Count != 2 {
+			t.Errorf("server Count=%d after rollback, want 2", db.Count)
+		}
+	})
+}
+
+func TestClientUnreachable(t *testing.T) {
+	t.Parallel()
+	_, srv := newTestServer(t)
+	c, err := NewClient[testDB](srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(func(db *testDB) error { db.Name = "Alice"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	if err := c.Refresh(); err == nil {
+		t.Error("Refresh succeeded on closed server")
+	}
+	c.Read(func(db *testDB) {
+		if db.Name != "Alice" {
+			t.Errorf("cached Name=%q, want Alice", db.Name)
+		}
+	})
+}
+
+func TestClientTimeout(t *testing.T) {
+	t.Parallel()
+	db, _ := newTestServer(t)
+	var hang atomic.Bool
+	release := make(chan struct{})
+	h := Handler(db)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hang.Load() {
+			<-release
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	if err := db.Write(func(db *testDB) error { db.Name = "Alice"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithHTTP[testDB](srv.URL, &http.Client{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Refresh times out, and Read serves the cached copy meanwhile.
+	hang.Store(true)
+	done := make(chan error)
+	go func() { done <- c.Refresh() }()
+	c.Read(func(db *testDB) {
+		if db.Name != "Alice" {
+			t.Errorf("cached Name=%q, want Alice", db.Name)
+		}
+	})
+	if err := <-done; err == nil {
+		t.Error("Refresh of a hung server succeeded")
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// Follow keeps a read-only replica of the document served by Handler
+// at url, in the file at path, so reads of a small database can be
+// spread over several machines.
+//
+// Follow fetches the document, writes it to path, and loads it with
+// opts. Until ctx is done, it then waits for each change on the server
+// and reloads the JSONFile with it, so its Subscribe and
+// WaitForRevision report changes as they arrive. Writes to the
+// replica fail with jsonfile.ErrReadOnly.
+//
+// If the server cannot be reached at first but the file at path
+// exists, Follow loads it and follows the server once it is back.
+// While following, network errors are retried, waiting up to a minute
+// between attempts.
+func Follow[Data any](ctx context.Context, url, path string, opts ...jsonfile.Option) (*jsonfile.JSONFile[Data], error) {
+	f := &follower[Data]{url: url, path: path, http: http.DefaultClient}
+	b, err := f.poll(ctx, false)
+	if err == nil {
+		err = writeReplica(path, b)
+	} else if _, statErr := os.Stat(path); statErr == nil {
+		err = nil // follow from the earlier replica
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jsonfilehttp.Follow: %w", err)
+	}
+	f.db, err = jsonfile.LoadFS[Data](os.DirFS(filepath.Dir(path)), filepath.Base(path), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("jsonfilehttp.Follow: %w", err)
+	}
+	go f.run(ctx)
+	return f.db, nil
+}
+
+type follower[Data any] struct {
+	url  string
+	path string
+	http *http.Client
+	db   *jsonfile.JSONFile[Data]
+
+	// Touched only by poll and run.
+	etag   string
+	gen    uint64
+	synced bool // etag and gen are from the server
+}
+
+// maxFollowBackoff bounds the wait between attempts to reach the
+// server while following it.
+const maxFollowBackoff = time.Minute
+
+func (f *follower[Data]) run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		b, err := f.poll(ctx, f.synced)
+		if err == nil && b != nil {
+			if err = writeReplica(f.path, b); err == nil {
+				err = f.db.Reload()
+			}
+		}
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff = min(2*backoff, maxFollowBackoff)
+	}
+}
+
+// poll fetches the document. If wait is set, the server waits for a
+// change after the last fetched generation. poll returns a nil b if
+// the document has not changed.
+func (f *follower[Data]) poll(ctx context.Context, wait bool) (b []byte, err error) {
+	u := f.url
+	if wait {
+		parsed, err := url.Parse(f.url)
+		if err != nil {
+			return nil, err
+		}
+		q := parsed.Query()
+		q.Set("after", strconv.FormatUint(f.gen, 10))
+		parsed.RawQuery = q.Encode()
+		u = parsed.String()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.etag != "" {
+		req.Header.Set("If-None-Match", f.etag)
+	}
+	res, err := f.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		if b, err = io.ReadAll(res.Body); err != nil {
+			return nil, err
+		}
+		f.etag = res.Header.Get("ETag")
+	case http.StatusNotModified:
+	default:
+		return nil, statusError(res)
+	}
+	gen, err := strconv.ParseUint(res.Header.Get(generationHeader), 10, 64)
+	if err != nil {
+		return nil, errors.New("jsonfilehttp: server does not report generations")
+	}
+	f.gen = gen
+	f.synced = true
+	return b, nil
+}
+
+// writeReplica atomically replaces the file at path with b.
+func writeReplica(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails once renamed
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
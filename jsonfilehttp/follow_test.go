@@ -0,0 +1,125 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestFollow(t *testing.T) {
+	t.Parallel()
+	db, srv := newTestServer(t)
+	if err := db.Write(func(db *testDB) error { db.Name = "Alice"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel) // before srv.Close, which waits for the long poll
+	path := filepath.Join(t.TempDir(), "replica.json")
+	replica, err := Follow[testDB](ctx, srv.URL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica.Read(func(data *testDB) {
+		if data.Name != "Alice" {
+			t.Errorf("replica Name=%q, want Alice", data.Name)
+		}
+	})
+	if err := replica.Write(func(*testDB) error { return nil }); !errors.Is(err, jsonfile.ErrReadOnly) {
+		t.Errorf("replica Write err=%v, want ErrReadOnly", err)
+	}
+
+	ch, unsubscribe := replica.Subscribe()
+	defer unsubscribe()
+	if err := db.Write(func(db *testDB) error { db.Count = 7; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-ch:
+		if ev.Data.Count != 7 || ev.Data.Name != "Alice" {
+			t.Errorf("replica data=%+v", *ev.Data)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("replica did not follow the change")
+	}
+
+	// A new replica starts from the file when the server is unreachable.
+	cancel()
+	srv.Close()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	again, err := Follow[testDB](ctx, srv.URL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.Read(func(data *testDB) {
+		if data.Count != 7 {
+			t.Errorf("offline replica Count=%d, want 7", data.Count)
+		}
+	})
+	if _, err := Follow[testDB](ctx, srv.URL, filepath.Join(t.TempDir(), "none.json")); err == nil {
+		t.Error("Follow of unreachable server without a replica succeeded")
+	}
+}
+
+func TestHandlerLongPoll(t *testing.T) {
+	t.Parallel()
+	db, srv := newTestServer(t)
+
+	res := do(t, "GET", srv.URL, "")
+	gen, err := strconv.ParseUint(res.Header.Get(generationHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("GET generation: %v", err)
+	}
+	tag := res.Header.Get("ETag")
+
+	done := make(chan *http.Response, 1)
+	go func() {
+		req, _ := http.NewRequest("GET", srv.URL+"?after="+strconv.FormatUint(gen, 10), nil)
+		req.Header.Set("If-None-Match", tag)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			close(done)
+			return
+		}
+		res.Body.Close()
+		done <- res
+	}()
+	select {
+	case <-done:
+		t.Fatal("long poll returned before a change")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := db.Write(func(db *testDB) error { db.Count++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	res = <-done
+	if res == nil {
+		return
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("long poll status %s", res.Status)
+	}
+	if got := res.Header.Get(generationHeader); got != strconv.FormatUint(gen+1, 10) {
+		t.Errorf("long poll generation %s, want %d", got, gen+1)
+	}
+
+	// A stale If-None-Match returns at once.
+	res = do(t, "GET", srv.URL+"?after=1000", "", "If-None-Match", tag)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("stale long poll status %s", res.Status)
+	}
+	if res := do(t, "GET", srv.URL+"?after=x", ""); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad after status %s, want 400", res.Status)
+	}
+}
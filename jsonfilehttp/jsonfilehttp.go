@@ -0,0 +1,228 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package jsonfilehttp serves a jsonfile.JSONFile over HTTP and
+// provides a client for it.
+//
+// The server responds to GET with the current JSON document and an
+// ETag, indented for people to read if the query has a pretty
+// parameter. It responds to PUT by replacing the document, and to
+// PATCH by applying a JSON Merge Patch (RFC 7386) to it. A PUT or PATCH
+// with an If-Match header only succeeds if the document has not
+// changed since the ETag was issued. The ETag names the generation of
+// the document and the Handler that issued it, so ETags from before a
+// server restarts, when generations are counted again, do not match.
+//
+// Each GET response carries the generation of the document in a
+// Jsonfile-Generation header. A GET with an after=N query parameter
+// waits, for up to a minute, until the document is past generation N,
+// so a client can follow changes by long polling.
+//
+// Client and OfflineClient access a document served by Handler, and
+// Follow keeps a read-only replica of one. Changes pushes changes to a
+// document to browsers as server-sent events.
+package jsonfilehttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// maxBody bounds the size of a PUT request body.
+const maxBody = 64 << 20
+
+// longPoll bounds how long a GET with an after parameter waits for
+// the document to change.
+const longPoll = time.Minute
+
+// generationHeader reports the generation of the document.
+const generationHeader = "Jsonfile-Generation"
+
+var errPrecondition = errors.New("jsonfilehttp: precondition failed")
+
+// Handler returns an http.Handler serving db.
+func Handler[Data any](db *jsonfile.JSONFile[Data]) http.Handler {
+	var id [8]byte
+	rand.Read(id[:])
+	return &handler[Data]{db: db, id: hex.EncodeToString(id[:])}
+}
+
+type handler[Data any] struct {
+	db *jsonfile.JSONFile[Data]
+	id string // random, in the ETags of the handler
+}
+
+func (h *handler[Data]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET", "HEAD":
+		h.get(w, r)
+	case "PUT":
+		h.put(w, r)
+	case "PATCH":
+		h.patch(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, PATCH")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handler[Data]) get(w http.ResponseWriter, r *http.Request) {
+	if after := r.URL.Query().Get("after"); after != "" {
+		gen, err := strconv.ParseUint(after, 10, 64)
+		if err != nil {
+			http.Error(w, "bad after generation", http.StatusBadRequest)
+			return
+		}
+		h.wait(r, gen)
+	}
+	var b []byte
+	var gen uint64
+	var err error
+	h.db.ReadWithGeneration(func(data *Data, g uint64) { b, err = json.Marshal(data); gen = g })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tag := h.etag(gen)
+	w.Header().Set("ETag", tag)
+	w.Header().Set(generationHeader, strconv.FormatUint(gen, 10))
+	if r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == "HEAD" {
+		return
+	}
+	if _, pretty := r.URL.Query()["pretty"]; pretty {
+		var buf bytes.Buffer
+		json.Indent(&buf, b, "", "\t") // b is valid JSON
+		buf.WriteByte('\n')
+		b = buf.Bytes()
+	}
+	w.Write(b)
+}
+
+// wait waits until the document is past generation after, or for
+// longPoll. It does not wait if the client's copy, named by the
+// If-None-Match header, is already out of date: then the server may
+// have restarted and counted its generations again from the start.
+func (h *handler[Data]) wait(r *http.Request, after uint64) {
+	if tag := r.Header.Get("If-None-Match"); tag != "" && tag != h.etag(h.db.Stat().Generation) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), longPoll)
+	defer cancel()
+	h.db.WaitForRevision(ctx, after+1) // on timeout, respond with the current document
+}
+
+func (h *handler[Data]) put(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	newData := new(Data)
+	if err := json.Unmarshal(body, newData); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.update(w, r, func(data *Data, b []byte) error {
+		*data = *newData
+		return nil
+	})
+}
+
+// patch applies a JSON Merge Patch (RFC 7386) to the document.
+func (h *handler[Data]) patch(w http.ResponseWriter, r *http.Request) {
+	switch ct, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";"); strings.TrimSpace(ct) {
+	case "application/merge-patch+json", "application/json", "":
+	default:
+		w.Header().Set("Accept-Patch", "application/merge-patch+json")
+		http.Error(w, "unsupported patch format", http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	patch, err := jsonvalue.Decode(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.update(w, r, func(data *Data, b []byte) error {
+		doc, err := jsonvalue.Decode(b)
+		if err != nil {
+			return err
+		}
+		if b, err = json.Marshal(jsonvalue.MergePatch(doc, patch)); err != nil {
+			return err
+		}
+		patched := new(Data)
+		if err := json.Unmarshal(b, patched); err != nil {
+			return badRequest{err}
+		}
+		*data = *patched
+		return nil
+	})
+}
+
+// badRequest reports an update that was rejected because of the
+// request, not the server.
+type badRequest struct{ err error }
+
+func (e badRequest) Error() string { return e.err.Error() }
+
+// update changes the document with fn, which is passed the data and
+// its JSON encoding, and responds with the new ETag. If the request
+// has an If-Match header, the document is only changed if it has not
+// changed since that ETag was issued.
+func (h *handler[Data]) update(w http.ResponseWriter, r *http.Request, fn func(data *Data, b []byte) error) {
+	ifMatch := r.Header.Get("If-Match")
+	res, err := h.db.WriteInfo(r.Context(), func(data *Data) error {
+		// The generation does not change while a Write calls fn.
+		if ifMatch != "" && ifMatch != "*" && h.etag(h.db.Stat().Generation) != ifMatch {
+			return errPrecondition
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return fn(data, b)
+	})
+	var bad badRequest
+	switch {
+	case errors.Is(err, errPrecondition):
+		http.Error(w, "document has changed", http.StatusPreconditionFailed)
+		return
+	case errors.As(err, &bad):
+		http.Error(w, bad.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", h.etag(res.Revision))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// etag returns the strong entity tag of generation gen of the
+// document.
+func (h *handler[Data]) etag(gen uint64) string {
+	return fmt.Sprintf("%q", h.id+"-"+strconv.FormatUint(gen, 10))
+}
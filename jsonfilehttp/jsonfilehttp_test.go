@@ -0,0 +1,146 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+type testDB struct {
+	Name  string
+	Count int
+}
+
+func newTestServer(t *testing.T) (*jsonfile.JSONFile[testDB], *httptest.Server) {
+	t.Helper()
+	db, err := jsonfile.New[testDB](filepath.Join(t.TempDir(), "db.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(Handler(db))
+	t.Cleanup(srv.Close)
+	return db, srv
+}
+
+func do(t *testing.T, method, url, body string, header ...string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	db, srv := newTestServer(t)
+
+	res := do(t, "GET", srv.URL, "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET status %s", res.Status)
+	}
+	tag := res.Header.Get("ETag")
+	if tag == "" {
+		t.Fatal("GET: no ETag")
+	}
+	if res := do(t, "GET", srv.URL, "", "If-None-Match", tag); res.StatusCode != http.StatusNotModified {
+		t.Errorf("GET If-None-Match status %s, want 304", res.Status)
+	}
+
+	res = do(t, "PUT", srv.URL, `{"Name":"Alice","Count":1}`, "If-Match", tag)
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT status %s", res.Status)
+	}
+	newTag := res.Header.Get("ETag")
+	if newTag == tag {
+		t.Errorf("PUT did not change ETag")
+	}
+	db.Read(func(db *testDB) {
+		if db.Name != "Alice" || db.Count != 1 {
+			t.Errorf("after PUT db=%+v", *db)
+		}
+	})
+
+	// Stale ETag.
+	res = do(t, "PUT", srv.URL, `{"Name":"Bob"}`, "If-Match", tag)
+	if res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("stale PUT status %s, want 412", res.Status)
+	}
+
+	res = do(t, "GET", srv.URL, "")
+	if got := res.Header.Get("ETag"); got != newTag {
+		t.Errorf("GET ETag=%s, want %s", got, newTag)
+	}
+	b, _ := io.ReadAll(res.Body)
+	if got, want := string(b), `{"Name":"Alice","Count":1}`; got != want {
+		t.Errorf("GET body=%s, want %s", got, want)
+	}
+
+	// The same generation served by another Handler, as after the
+	// server restarts, has another ETag.
+	srv2 := httptest.NewServer(Handler(db))
+	defer srv2.Close()
+	if res := do(t, "PUT", srv2.URL, `{"Name":"Bob"}`, "If-Match", newTag); res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT with the ETag of another Handler status %s, want 412", res.Status)
+	}
+
+	if res := do(t, "PUT", srv.URL, `not json`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad PUT status %s, want 400", res.Status)
+	}
+	if res := do(t, "DELETE", srv.URL, ""); res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE status %s, want 405", res.Status)
+	}
+}
+
+func TestHandlerPatch(t *testing.T) {
+	t.Parallel()
+	db, srv := newTestServer(t)
+	if err := db.Write(func(db *testDB) error { db.Name = "Alice"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	tag := do(t, "GET", srv.URL, "").Header.Get("ETag")
+
+	res := do(t, "PATCH", srv.URL, `{"Count":5}`, "Content-Type", "application/merge-patch+json", "If-Match", tag)
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("PATCH status %s", res.Status)
+	}
+	db.Read(func(db *testDB) {
+		if db.Name != "Alice" || db.Count != 5 {
+			t.Errorf("after PATCH db=%+v", *db)
+		}
+	})
+	if res := do(t, "PATCH", srv.URL, `{"Count":6}`, "If-Match", tag); res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("stale PATCH status %s, want 412", res.Status)
+	}
+	if res := do(t, "PATCH", srv.URL, `{"Count":"six"}`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("mistyped PATCH status %s, want 400", res.Status)
+	}
+	if res := do(t, "PATCH", srv.URL, `[]`, "Content-Type", "application/json-patch+json"); res.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("JSON Patch status %s, want 415", res.Status)
+	}
+	if res := do(t, "PATCH", srv.URL, `{"Name":null}`); res.StatusCode != http.StatusNoContent {
+		t.Errorf("PATCH null status %s", res.Status)
+	}
+
+	res = do(t, "GET", srv.URL+"?pretty", "")
+	b, _ := io.ReadAll(res.Body)
+	if got, want := string(b), "{\n\t\"Name\": \"\",\n\t\"Count\": 5\n}\n"; got != want {
+		t.Errorf("pretty GET body=%q, want %q", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the buckets of
+// the write duration histogram.
+var durationBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics is a jsonfile.Observer that serves the metrics of the writes
+// it observes in the Prometheus text format, for a /metrics endpoint.
+// Pass it to each file with jsonfile.WithObserver. The metrics are
+// labeled with the path of the file:
+//
+//	jsonfile_writes_total                 writes of the file that succeeded
+//	jsonfile_write_errors_total           writes that failed, including conflicts
+//	jsonfile_write_conflicts_total        writes that failed with jsonfile.ErrConflict
+//	jsonfile_written_bytes_total          bytes written
+//	jsonfile_write_duration_seconds       histogram of the durations of the writes
+//
+// A program that uses a Prometheus client library can instead
+// implement jsonfile.Observer with its own collectors.
+type Metrics struct {
+	mu    sync.Mutex
+	files map[string]*fileMetrics
+}
+
+type fileMetrics struct {
+	writes, errors, conflicts, bytes uint64
+	buckets                          []uint64 // by durationBuckets
+	count                            uint64
+	sum                              float64 // seconds
+}
+
+// ObserveWrite records a write.
+func (m *Metrics) ObserveWrite(w jsonfile.WriteObservation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.files == nil {
+		m.files = make(map[string]*fileMetrics)
+	}
+	f := m.files[w.Path]
+	if f == nil {
+		f = &fileMetrics{buckets: make([]uint64, len(durationBuckets))}
+		m.files[w.Path] = f
+	}
+	switch {
+	case w.Err == nil:
+		f.writes++
+		f.bytes += uint64(w.Bytes)
+	case errors.Is(w.Err, jsonfile.ErrConflict):
+		f.conflicts++
+		f.errors++
+	default:
+		f.errors++
+	}
+	secs := w.Duration.Seconds()
+	for i, le := range durationBuckets {
+		if secs <= le {
+			f.buckets[i]++
+		}
+	}
+	f.count++
+	f.sum += secs
+}
+
+// ServeHTTP writes the metrics.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	m.write(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func (m *Metrics) write(buf *bytes.Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	paths := make([]string, 0, len(m.files))
+	for path := range m.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	counter := func(name, help string, value func(*fileMetrics) uint64) {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, path := range paths {
+			fmt.Fprintf(buf, "%s{path=%s} %d\n", name, label(path), value(m.files[path]))
+		}
+	}
+	counter("jsonfile_writes_total", "Writes of the file that succeeded.", func(f *fileMetrics) uint64 { return f.writes })
+	counter("jsonfile_write_errors_total", "Writes of the file that failed.", func(f *fileMetrics) uint64 { return f.errors })
+	counter("jsonfile_write_conflicts_total", "Writes of the file that failed because another program changed it.", func(f *fileMetrics) uint64 { return f.conflicts })
+	counter("jsonfile_written_bytes_total", "Bytes written to the file.", func(f *fileMetrics) uint64 { return f.bytes })
+
+	const hist = "jsonfile_write_duration_seconds"
+	fmt.Fprintf(buf, "# HELP %s Duration of the writes of the file.\n# TYPE %s histogram\n", hist, hist)
+	for _, path := range paths {
+		f, l := m.files[path], label(path)
+		for i, le := range durationBuckets {
+			fmt.Fprintf(buf, "%s_bucket{path=%s,le=\"%s\"} %d\n", hist, l, strconv.FormatFloat(le, 'g', -1, 64), f.buckets[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket{path=%s,le=\"+Inf\"} %d\n", hist, l, f.count)
+		fmt.Fprintf(buf, "%s_sum{path=%s} %s\n", hist, l, strconv.FormatFloat(f.sum, 'g', -1, 64))
+		fmt.Fprintf(buf, "%s_count{path=%s} %d\n", hist, l, f.count)
+	}
+}
+
+// label quotes s as a Prometheus label value.
+func label(s string) string {
+	var b bytes.Buffer
+	b.WriteByte('"')
+	for _, c := range []byte(s) {
+		switch c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
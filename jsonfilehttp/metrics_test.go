@@ -0,0 +1,63 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	m := new(Metrics)
+	path := filepath.Join(t.TempDir(), "db.json")
+	db, err := jsonfile.New[testDB](path, jsonfile.WithObserver(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Write(func(db *testDB) error { db.Count = 1; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	m.ObserveWrite(jsonfile.WriteObservation{Path: path, Duration: 2 * time.Second, Err: fmt.Errorf("x: %w", jsonfile.ErrConflict)})
+
+	srv := httptest.NewServer(m)
+	t.Cleanup(srv.Close)
+	res := do(t, "GET", srv.URL, "")
+	if got := res.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type %q", got)
+	}
+	b, _ := io.ReadAll(res.Body)
+	body := string(b)
+	l := label(path)
+	for _, want := range []string{
+		"# TYPE jsonfile_writes_total counter\n",
+		"jsonfile_writes_total{path=" + l + "} 2\n",
+		"jsonfile_write_errors_total{path=" + l + "} 1\n",
+		"jsonfile_write_conflicts_total{path=" + l + "} 1\n",
+		fmt.Sprintf("jsonfile_written_bytes_total{path=%s} %d\n", l, len(`{"Name":"","Count":0}`)+len(`{"Name":"","Count":1}`)),
+		"# TYPE jsonfile_write_duration_seconds histogram\n",
+		"jsonfile_write_duration_seconds_bucket{path=" + l + ",le=\"1\"} 2\n",
+		"jsonfile_write_duration_seconds_bucket{path=" + l + ",le=\"2.5\"} 3\n",
+		"jsonfile_write_duration_seconds_bucket{path=" + l + ",le=\"+Inf\"} 3\n",
+		"jsonfile_write_duration_seconds_count{path=" + l + "} 3\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsLabel(t *testing.T) {
+	t.Parallel()
+	if got, want := label("a\\b\"c\nd"), `"a\\b\"c\nd"`; got != want {
+		t.Errorf("label=%s, want %s", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// A ConflictFunc merges changes made locally by an OfflineClient with
+// changes made on the server in the meantime. base is the value both
+// sides started from. ConflictFunc stores the merged value in remote.
+type ConflictFunc[Data any] func(base, local, remote *Data) error
+
+// OfflineClient is a Client that keeps a copy of the remote document
+// in a local jsonfile, so it keeps working without a connection.
+//
+// Reads are served from the local copy. Writes are applied to the
+// local copy and sent to the server by Sync. If the server document
+// changed while the client was offline, Sync calls a ConflictFunc to
+// merge the two.
+type OfflineClient[Data any] struct {
+	url     string
+	http    *http.Client
+	resolve ConflictFunc[Data]
+	cache   *jsonfile.JSONFile[offlineState[Data]]
+
+	syncMu sync.Mutex // held while syncing with the server
+}
+
+// offlineState is the content of an OfflineClient cache file.
+type offlineState[Data any] struct {
+	ETag    string          // server ETag of Base
+	Base    json.RawMessage // last value exchanged with the server
+	Local   Data            // Base with local changes applied
+	Pending bool            // Local has changes the server has not seen
+}
+
+// NewOfflineClient creates an OfflineClient for the document served
+// at url, keeping its local copy in the file at cachePath.
+//
+// If the cache file exists its contents are used, including any
+// changes not yet sent to the server. Otherwise a new cache file is
+// created, and NewOfflineClient fetches the document from the server.
+//
+// If resolve is nil, Sync reports conflicts with ErrConflict and
+// leaves the local changes pending.
+func NewOfflineClient[Data any](url, cachePath string, resolve ConflictFunc[Data]) (*OfflineClient[Data], error) {
+	c := &OfflineClient[Data]{url: url, http: http.DefaultClient, resolve: resolve}
+	var err error
+	c.cache, err = jsonfile.Load[offlineState[Data]](cachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		c.cache, err = jsonfile.New[offlineState[Data]](cachePath)
+		if err == nil {
+			err = c.Sync()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jsonfilehttp.NewOfflineClient: %w", err)
+	}
+	return c, nil
+}
+
+// Read calls fn with the local copy of the data.
+func (c *OfflineClient[Data]) Read(fn func(data *Data)) {
+	c.cache.Read(func(s *offlineState[Data]) { fn(&s.Local) })
+}
+
+// Write calls fn with a copy of the data, then saves the changes to
+// the local cache and tries to send them to the server. If fn returns
+// an error, Write does not change the data and returns the error.
+//
+// Write only reports errors saving the local copy. If the server cannot
+// be reached, the changes stay pending until a later Sync.
+func (c *OfflineClient[Data]) Write(fn func(*Data) error) error {
+	err := c.cache.Write(func(s *offlineState[Data]) error {
+		before, err := json.Marshal(s.Local)
+		if err != nil {
+			return err
+		}
+		if err := fn(&s.Local); err != nil {
+			return err
+		}
+		after, err := json.Marshal(s.Local)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(before, after) {
+			s.Pending = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.Sync() // best effort, the write stays pending on failure
+	return nil
+}
+
+// Pending reports whether there are local changes that have not been
+// sent to the server.
+func (c *OfflineClient[Data]) Pending() (pending bool) {
+	c.cache.Read(func(s *offlineState[Data]) { pending = s.Pending })
+	return pending
+}
+
+// Sync exchanges changes with the server. Pending local changes are
+// sent to the server, merging them with any remote changes using the
+// ConflictFunc. If there are no pending changes, the local copy is
+// updated from the server.
+func (c *OfflineClient[Data]) Sync() error {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+
+	for i := 0; i < maxAttempts; i++ {
+		var s offlineState[Data]
+		var local []byte
+		var err error
+		c.cache.Read(func(cur *offlineState[Data]) {
+			s = *cur
+			local, err = json.Marshal(cur.Local)
+		})
+		if err != nil {
+			return fmt.Errorf("jsonfilehttp.Sync: %w", err)
+		}
+
+		if !s.Pending || s.ETag == "" {
+			b, tag, err := fetch(c.http, c.url, s.ETag)
+			if err != nil {
+				return fmt.Errorf("jsonfilehttp.Sync: %w", err)
+			}
+			if s.Pending {
+				// Changes were made before the server was first
+				// reached. Treat them as a conflict with an
+				// empty base.
+				err = c.merge(nil, local, b, tag)
+			} else if b != nil {
+				err = c.replace(local, b, tag)
+			}
+			if errors.Is(err, errLocalChanged) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("jsonfilehttp.Sync: %w", err)
+			}
+			if s.Pending {
+				continue // send the merged value
+			}
+			return nil
+		}
+
+		tag, err := put(c.http, c.url, s.ETag, local)
+		if errors.Is(err, errPrecondition) {
+			b, tag, err := fetch(c.http, c.url, "")
+			if err == nil {
+				err = c.merge(s.Base, local, b, tag)
+			}
+			if err != nil && !errors.Is(err, errLocalChanged) {
+				return fmt.Errorf("jsonfilehttp.Sync: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("jsonfilehttp.Sync: %w", err)
+		}
+		err = c.cache.Write(func(cur *offlineState[Data]) error {
+			cur.ETag = tag
+			cur.Base = local
+			if b, err := json.Marshal(cur.Local); err == nil && bytes.Equal(b, local) {
+				cur.Pending = false
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("jsonfilehttp.Sync: %w", err)
+		}
+		if !c.Pending() {
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+// errLocalChanged reports that a Write changed the local copy while
+// Sync was talking to the server. Sync starts over.
+var errLocalChanged = errors.New("local copy changed during sync")
+
+// replace replaces the local copy with the remote document b,
+// unless the local copy has changed since Sync read it.
+func (c *OfflineClient[Data]) replace(local, b []byte, tag string) error {
+	remote := new(Data)
+	if err := json.Unmarshal(b, remote); err != nil {
+		return err
+	}
+	return c.cache.Write(func(cur *offlineState[Data]) error {
+		if err := checkLocal(&cur.Local, local); err != nil {
+			return err
+		}
+		cur.ETag = tag
+		cur.Base = b
+		cur.Local = *remote
+		return nil
+	})
+}
+
+// merge resolves a conflict between the local changes and the remote
+// document b. The merged value is saved as pending local changes based
+// on the remote document.
+func (c *OfflineClient[Data]) merge(base, local, b []byte, tag string) error {
+	if c.resolve == nil {
+		return ErrConflict
+	}
+	baseData, localData, remote := new(Data), new(Data), new(Data)
+	if base != nil {
+		if err := json.Unmarshal(base, baseData); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(local, localData); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, remote); err != nil {
+		return err
+	}
+	if err := c.resolve(baseData, localData, remote); err != nil {
+		return err
+	}
+	return c.cache.Write(func(cur *offlineState[Data]) error {
+		if err := checkLocal(&cur.Local, local); err != nil {
+			return err
+		}
+		cur.ETag = tag
+		cur.Base = b
+		cur.Local = *remote
+		cur.Pending = true
+		return nil
+	})
+}
+
+func checkLocal[Data any](cur *Data, local []byte) error {
+	b, err := json.Marshal(cur)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(b, local) {
+		return errLocalChanged
+	}
+	return nil
+}
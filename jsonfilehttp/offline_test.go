@@ -0,0 +1,149 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// flakyServer serves db, failing every request while down is set.
+func flakyServer(t *testing.T) (*jsonfile.JSONFile[testDB], *httptest.Server, *atomic.Bool) {
+	t.Helper()
+	db, err := jsonfile.New[testDB](filepath.Join(t.TempDir(), "db.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := new(atomic.Bool)
+	h := Handler(db)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return db, srv, down
+}
+
+func TestOfflineClient(t *testing.T) {
+	t.Parallel()
+	db, srv, down := flakyServer(t)
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+
+	var conflicts int
+	resolve := func(base, local, remote *testDB) error {
+		conflicts++
+		remote.Count += local.Count - base.Count
+		return nil
+	}
+	c, err := NewOfflineClient[testDB](srv.URL, cachePath, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(func(db *testDB) error { db.Count = 1; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if c.Pending() {
+		t.Fatal("write pending while online")
+	}
+
+	down.Store(true)
+	if err := c.Write(func(db *testDB) error { db.Count += 2; return nil }); err != nil {
+		t.Fatalf("offline Write: %v", err)
+	}
+	if !c.Pending() {
+		t.Fatal("offline write not pending")
+	}
+	c.Read(func(db *testDB) {
+		if db.Count != 3 {
+			t.Errorf("local Count=%d, want 3", db.Count)
+		}
+	})
+	if err := c.Sync(); err == nil {
+		t.Error("Sync succeeded while offline")
+	}
+
+	// A restarted client picks up the pending change from the cache.
+	c, err = NewOfflineClient[testDB](srv.URL, cachePath, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Pending() {
+		t.Fatal("pending write lost on reload")
+	}
+
+	// Server changes while the client is offline.
+	mustWrite(t, db, func(db *testDB) { db.Name = "server"; db.Count += 10 })
+
+	down.Store(false)
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if conflicts != 1 {
+		t.Errorf("conflicts=%d, want 1", conflicts)
+	}
+	if c.Pending() {
+		t.Error("write still pending after Sync")
+	}
+	want := testDB{Name: "server", Count: 13}
+	db.Read(func(db *testDB) {
+		if *db != want {
+			t.Errorf("server db=%+v, want %+v", *db, want)
+		}
+	})
+	c.Read(func(db *testDB) {
+		if *db != want {
+			t.Errorf("local db=%+v, want %+v", *db, want)
+		}
+	})
+
+	// With no pending changes, Sync picks up server changes.
+	mustWrite(t, db, func(db *testDB) { db.Name = "again" })
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	c.Read(func(db *testDB) {
+		if db.Name != "again" {
+			t.Errorf("local Name=%q, want again", db.Name)
+		}
+	})
+}
+
+func TestOfflineClientNoResolve(t *testing.T) {
+	t.Parallel()
+	db, srv, down := flakyServer(t)
+
+	c, err := NewOfflineClient[testDB](srv.URL, filepath.Join(t.TempDir(), "cache.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	down.Store(true)
+	if err := c.Write(func(db *testDB) error { db.Count = 1; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *testDB) { db.Count = 2 })
+	down.Store(false)
+
+	if err := c.Sync(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Sync err=%v, want ErrConflict", err)
+	}
+	if !c.Pending() {
+		t.Error("conflicting write no longer pending")
+	}
+}
+
+func mustWrite[Data any](t *testing.T, db *jsonfile.JSONFile[Data], fn func(db *Data)) {
+	t.Helper()
+	if err := db.Write(func(db *Data) error { fn(db); return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
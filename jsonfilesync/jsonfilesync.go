@@ -0,0 +1,297 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package jsonfilesync keeps a jsonfile.JSONFile in sync between two
+// machines.
+//
+// Each side of a pair is a Peer. A Peer remembers the last value the
+// two sides agreed on, the base. When two peers connect, the peer that
+// dialed compares both values against the base, merges them with a
+// MergeFunc if both have changed, and sends the result back, so both
+// sides end up with the same value and a new shared base.
+//
+// If the JSONFile has a jsonfile.Clock, peers exchange the stamps of
+// their data, so the stamp of a merged value is after both.
+//
+// Peers talk over any net.Conn, so two stores can be paired over TCP,
+// a Unix socket, or an overlay network that provides a net.Listener.
+//
+// A Peer trusts the remote peer: it takes the value the remote sends as
+// a change to the data, and the protocol neither authenticates peers
+// nor encrypts the data. Only accept connections from trusted peers,
+// such as over TLS with client certificates (see crypto/tls) or an
+// overlay network that authenticates its members.
+package jsonfilesync
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+)
+
+// protoVersion is the version of the sync protocol.
+const protoVersion = 1
+
+// syncTimeout bounds a sync over a connection accepted by Serve, or
+// dialed by Dial with a context that has no deadline.
+const syncTimeout = time.Minute
+
+// ErrConflict is returned when both peers changed the data and there
+// is no MergeFunc to reconcile them.
+var ErrConflict = errors.New("jsonfilesync: conflicting changes")
+
+// errChanged is returned if the local file changed during a sync.
+var errChanged = errors.New("jsonfilesync: data changed during sync")
+
+// A MergeFunc reconciles changes made on two peers. base is the last
+// value both peers agreed on, or the zero value if they have never
+// synced. MergeFunc stores the merged value in local.
+//
+// A MergeFunc is only called on the peer that dialed the connection.
+type MergeFunc[Data any] func(base, local, remote *Data) error
+
+// Peer syncs a JSONFile with a remote Peer.
+type Peer[Data any] struct {
+	db    *jsonfile.JSONFile[Data]
+	state *jsonfile.JSONFile[peerState]
+	merge MergeFunc[Data]
+
+	timeout time.Duration // syncTimeout, changed by tests
+
+	// mu is held while a sync reads the values it starts from and
+	// while it commits the result, but not during network I/O,
+	// so a slow remote peer does not hold up other syncs.
+	mu sync.Mutex
+}
+
+// peerState is the content of a Peer's state file.
+type peerState struct {
+	Base json.RawMessage // last value agreed on with the remote peer
+}
+
+// NewPeer creates a Peer for db. The base value is kept in a state
+// file at statePath, which is created if it does not exist.
+// If merge is nil, syncing fails with ErrConflict when both peers have
+// changed the data.
+func NewPeer[Data any](db *jsonfile.JSONFile[Data], statePath string, merge MergeFunc[Data]) (*Peer[Data], error) {
+	state, err := jsonfile.Load[peerState](statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		state, err = jsonfile.New[peerState](statePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jsonfilesync.NewPeer: %w", err)
+	}
+	return &Peer[Data]{db: db, state: state, merge: merge, timeout: syncTimeout}, nil
+}
+
+// Dial connects to the Peer at address and syncs with it. The sync
+// must finish by the deadline of ctx, or within a minute if it has
+// none.
+func (p *Peer[Data]) Dial(ctx context.Context, network, address string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return fmt.Errorf("jsonfilesync.Dial: %w", err)
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(p.timeout)
+	}
+	conn.SetDeadline(deadline)
+	return p.Sync(conn, true)
+}
+
+// Serve accepts connections on l and syncs with each remote Peer
+// that connects. A connection is closed if its sync does not finish
+// within a minute. Serve returns when l.Accept fails.
+func (p *Peer[Data]) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(p.timeout))
+			p.Sync(conn, false)
+		}()
+	}
+}
+
+// hello is the first message sent by each peer.
+type hello struct {
+	Version int
+	Base    string // hash of the base value
+	Value   json.RawMessage
+	Stamp   string `json:",omitempty"` // stamp of Value, from jsonfile.WithClock
+}
+
+// result is sent by the dialing peer with the merged value.
+type result struct {
+	Value json.RawMessage `json:",omitempty"`
+	Err   string          `json:",omitempty"`
+}
+
+// Sync syncs with a remote Peer over conn.
+// Exactly one side of the connection must be the dialer.
+// Sync does not set deadlines on conn: set them to bound a sync with
+// a remote peer that stops responding.
+func (p *Peer[Data]) Sync(conn net.Conn, dialer bool) error {
+	if err := p.sync(conn, dialer); err != nil {
+		return fmt.Errorf("jsonfilesync: %w", err)
+	}
+	return nil
+}
+
+func (p *Peer[Data]) sync(conn net.Conn, dialer bool) error {
+	var local, base []byte
+	var err error
+	p.mu.Lock()
+	p.db.Read(func(data *Data) { local, err = json.Marshal(data) })
+	p.state.Read(func(s *peerState) { base = s.Base })
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	enc, dec := json.NewEncoder(conn), json.NewDecoder(conn)
+	mine := hello{Version: protoVersion, Base: hash(base), Value: local, Stamp: p.db.Stamp().String()}
+	var theirs hello
+	if dialer {
+		// Send first: a synchronous connection would deadlock
+		// if both sides wrote at once.
+		if err := enc.Encode(mine); err != nil {
+			return err
+		}
+		if err := dec.Decode(&theirs); err != nil {
+			return err
+		}
+	} else {
+		if err := dec.Decode(&theirs); err != nil {
+			return err
+		}
+		if err := enc.Encode(mine); err != nil {
+			return err
+		}
+	}
+	if theirs.Version != protoVersion {
+		return fmt.Errorf("unsupported protocol version %d", theirs.Version)
+	}
+	if c := p.db.Clock(); c != nil && theirs.Stamp != "" {
+		stamp, err := jsonfile.ParseStamp(theirs.Stamp)
+		if err != nil {
+			return err
+		}
+		c.Observe(stamp)
+	}
+
+	if !dialer {
+		var res result
+		if err := dec.Decode(&res); err != nil {
+			return err
+		}
+		if res.Err != "" {
+			return errors.New(res.Err)
+		}
+		return p.commit(local, res.Value)
+	}
+
+	if theirs.Base != mine.Base {
+		base = nil // never synced, or last sync did not complete
+	}
+	merged, err := p.reconcile(base, local, theirs.Value)
+	if err != nil {
+		enc.Encode(result{Err: err.Error()})
+		return err
+	}
+	if err := enc.Encode(result{Value: merged}); err != nil {
+		return err
+	}
+	return p.commit(local, merged)
+}
+
+// reconcile returns the value both peers should have.
+func (p *Peer[Data]) reconcile(base, local, remote []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(local, remote):
+		return local, nil
+	case base != nil && bytes.Equal(local, base):
+		return remote, nil
+	case base != nil && bytes.Equal(remote, base):
+		return local, nil
+	case p.merge == nil:
+		return nil, ErrConflict
+	}
+
+	baseData, localData, remoteData := new(Data), new(Data), new(Data)
+	if base != nil {
+		if err := json.Unmarshal(base, baseData); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(local, localData); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(remote, remoteData); err != nil {
+		return nil, err
+	}
+	if err := p.merge(baseData, localData, remoteData); err != nil {
+		return nil, err
+	}
+	return json.Marshal(localData)
+}
+
+// commit replaces the local value with merged and records it as the
+// new base. It fails if the local value changed during the sync.
+func (p *Peer[Data]) commit(local, merged []byte) error {
+	newData := new(Data)
+	if err := json.Unmarshal(merged, newData); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.db.Write(func(data *Data) error {
+		cur, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(cur, local) {
+			return errChanged
+		}
+		*data = *newData
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Store the value as the database encodes it, so the base
+	// hash matches the remote peer's.
+	var base []byte
+	p.db.Read(func(data *Data) { base, err = json.Marshal(data) })
+	if err != nil {
+		return err
+	}
+	return p.state.Write(func(s *peerState) error {
+		s.Base = base
+		return nil
+	})
+}
+
+func hash(b []byte) string {
+	if b == nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
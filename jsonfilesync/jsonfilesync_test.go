@@ -0,0 +1,237 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfilesync
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"crawshaw.dev/jsonfile"
+)
+
+type todos struct {
+	Items []string
+}
+
+// union merges two to-do lists by keeping every item added on either side.
+func union(base, local, remote *todos) error {
+	seen := make(map[string]bool)
+	var items []string
+	for _, list := range [][]string{local.Items, remote.Items} {
+		for _, item := range list {
+			if !seen[item] {
+				seen[item] = true
+				items = append(items, item)
+			}
+		}
+	}
+	sort.Strings(items)
+	local.Items = items
+	return nil
+}
+
+func newPeer(t *testing.T, merge MergeFunc[todos], opts ...jsonfile.Option) (*jsonfile.JSONFile[todos], *Peer[todos]) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := jsonfile.New[todos](filepath.Join(dir, "todos.json"), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPeer(db, filepath.Join(dir, "todos.sync.json"), merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, p
+}
+
+func syncPipe(t *testing.T, dialer, server *Peer[todos]) (dialErr, serverErr error) {
+	t.Helper()
+	c1, c2 := net.Pipe()
+	done := make(chan error)
+	go func() {
+		defer c2.Close()
+		done <- server.Sync(c2, false)
+	}()
+	dialErr = dialer.Sync(c1, true)
+	c1.Close()
+	return dialErr, <-done
+}
+
+func add(t *testing.T, db *jsonfile.JSONFile[todos], item string) {
+	t.Helper()
+	if err := db.Write(func(d *todos) error {
+		d.Items = append(d.Items, item)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func items(db *jsonfile.JSONFile[todos]) (items []string) {
+	db.Read(func(d *todos) { items = append(items, d.Items...) })
+	return items
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+	laptopDB, laptop := newPeer(t, union)
+	desktopDB, desktop := newPeer(t, union)
+
+	add(t, laptopDB, "milk")
+	add(t, desktopDB, "eggs")
+	if err1, err2 := syncPipe(t, laptop, desktop); err1 != nil || err2 != nil {
+		t.Fatalf("sync: %v, %v", err1, err2)
+	}
+	want := []string{"eggs", "milk"}
+	if got := items(laptopDB); !equal(got, want) {
+		t.Errorf("laptop items=%v, want %v", got, want)
+	}
+	if got := items(desktopDB); !equal(got, want) {
+		t.Errorf("desktop items=%v, want %v", got, want)
+	}
+
+	// A change on one side only is copied without merging.
+	add(t, desktopDB, "bread")
+	laptop.merge = func(base, local, remote *todos) error {
+		t.Error("merge called for one-sided change")
+		return nil
+	}
+	if err1, err2 := syncPipe(t, laptop, desktop); err1 != nil || err2 != nil {
+		t.Fatalf("sync: %v, %v", err1, err2)
+	}
+	want = []string{"eggs", "milk", "bread"}
+	if got := items(laptopDB); !equal(got, want) {
+		t.Errorf("laptop items=%v, want %v", got, want)
+	}
+}
+
+func TestSyncConflict(t *testing.T) {
+	t.Parallel()
+	aDB, a := newPeer(t, nil)
+	bDB, b := newPeer(t, nil)
+
+	add(t, aDB, "a")
+	add(t, bDB, "b")
+	err1, err2 := syncPipe(t, a, b)
+	if !errors.Is(err1, ErrConflict) {
+		t.Errorf("dialer err=%v, want ErrConflict", err1)
+	}
+	if err2 == nil {
+		t.Errorf("server err=nil, want error")
+	}
+	if got := items(bDB); !equal(got, []string{"b"}) {
+		t.Errorf("server items=%v after conflict, want [b]", got)
+	}
+}
+
+func TestDialServe(t *testing.T) {
+	t.Parallel()
+	aDB, a := newPeer(t, union)
+	bDB, b := newPeer(t, union)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go b.Serve(l)
+
+	add(t, aDB, "a")
+	if err := a.Dial(context.Background(), "tcp", l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	// The server commits after the dialer, so wait for it
+	// by syncing again: the second sync sees matching bases.
+	if err := a.Dial(context.Background(), "tcp", l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if got := items(bDB); !equal(got, []string{"a"}) {
+		t.Errorf("server items=%v, want [a]", got)
+	}
+}
+
+func TestServeTimeout(t *testing.T) {
+	t.Parallel()
+	aDB, a := newPeer(t, union)
+	bDB, b := newPeer(t, union)
+	b.timeout = 50 * time.Millisecond
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go b.Serve(l)
+
+	// A peer that connects and sends nothing does not hold up other
+	// syncs, and is disconnected.
+	stalled, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+	add(t, aDB, "a")
+	if err1, err2 := syncPipe(t, a, b); err1 != nil || err2 != nil {
+		t.Fatalf("sync: %v, %v", err1, err2)
+	}
+	if got := items(bDB); !equal(got, []string{"a"}) {
+		t.Errorf("server items=%v, want [a]", got)
+	}
+	stalled.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := stalled.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("stalled connection read err=%v, want it closed by Serve", err)
+	}
+}
+
+// aheadClock is a jsonfile.Clock on a machine whose clock is wrong.
+type aheadClock struct {
+	mu      sync.Mutex
+	logical uint32
+}
+
+func (c *aheadClock) Now() jsonfile.Stamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logical++
+	return jsonfile.Stamp{Wall: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano(), Logical: c.logical, Node: "ahead"}
+}
+
+func (c *aheadClock) Observe(jsonfile.Stamp) {}
+
+func TestSyncClock(t *testing.T) {
+	t.Parallel()
+	laptopDB, laptop := newPeer(t, union, jsonfile.WithClock(new(aheadClock)))
+	desktopDB, desktop := newPeer(t, union, jsonfile.WithClock(jsonfile.NewHLC("desktop")))
+
+	add(t, laptopDB, "milk")
+	add(t, desktopDB, "eggs")
+	ahead := laptopDB.Stamp()
+	if err1, err2 := syncPipe(t, laptop, desktop); err1 != nil || err2 != nil {
+		t.Fatalf("sync: %v, %v", err1, err2)
+	}
+	// The desktop stamps its changes after those it has seen.
+	add(t, desktopDB, "bread")
+	if got := desktopDB.Stamp(); got.Compare(ahead) <= 0 {
+		t.Errorf("desktop stamp %v is not after laptop stamp %v", got, ahead)
+	}
+}
@@ -0,0 +1,492 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// maxSchemaErrors is the most schema violations reported by an error.
+const maxSchemaErrors = 10
+
+// WithJSONSchema checks the data against the JSON Schema schema when
+// the file is read and before each Write is committed. A file that does
+// not match fails to load, and a Write that would make data that does
+// not match fails, leaving the data unchanged.
+//
+// The schema is checked with the validation keywords of JSON Schema
+// 2020-12 for types, enum and const, objects (properties, required,
+// additionalProperties, patternProperties, propertyNames,
+// minProperties, maxProperties), arrays (items, prefixItems, contains,
+// minItems, maxItems, uniqueItems), strings (minLength, maxLength,
+// pattern), numbers (minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, multipleOf), and combinations (allOf, anyOf,
+// oneOf, not, if, then, else), with $ref to definitions in the same
+// schema. Other keywords, such as format, are ignored.
+func WithJSONSchema(schema []byte) Option {
+	s, err := compileJSONSchema(schema)
+	return func(o *options) {
+		o.jsonSchema = s
+		o.jsonSchemaErr = err
+	}
+}
+
+type jsonSchema struct {
+	root     any
+	patterns map[string]*regexp.Regexp
+}
+
+func compileJSONSchema(b []byte) (*jsonSchema, error) {
+	root, err := jsonvalue.Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("WithJSONSchema: %w", err)
+	}
+	switch root.(type) {
+	case bool, map[string]any:
+	default:
+		return nil, errors.New("WithJSONSchema: schema is not an object or boolean")
+	}
+	s := &jsonSchema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	if err := s.compile(root); err != nil {
+		return nil, fmt.Errorf("WithJSONSchema: %w", err)
+	}
+	return s, nil
+}
+
+// compile checks the schema sch, and compiles its patterns. It walks
+// every object in the schema except values, as the keywords it looks
+// for may be in any subschema.
+func (s *jsonSchema) compile(sch any) error {
+	switch sch := sch.(type) {
+	case []any:
+		for _, e := range sch {
+			if err := s.compile(e); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		var patterns []string
+		if p, ok := sch["pattern"].(string); ok {
+			patterns = append(patterns, p)
+		}
+		if pp, ok := sch["patternProperties"].(map[string]any); ok {
+			for p := range pp {
+				patterns = append(patterns, p)
+			}
+		}
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("pattern %q: %w", p, err)
+			}
+			s.patterns[p] = re
+		}
+		if ref, ok := sch["$ref"].(string); ok {
+			if _, err := s.resolve(ref); err != nil {
+				return err
+			}
+		}
+		for k, v := range sch {
+			switch k {
+			case "enum", "const", "default", "examples":
+				continue // values, not schemas
+			}
+			if err := s.compile(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the schema at ref, a JSON Pointer fragment.
+func (s *jsonSchema) resolve(ref string) (any, error) {
+	ptr, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("$ref %q: only references within the schema are supported", ref)
+	}
+	v := s.root
+	if ptr == "" {
+		return v, nil
+	}
+	for _, tok := range strings.Split(strings.TrimPrefix(ptr, "/"), "/") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+		if v, ok = m[jsonvalue.UnescapePointer(tok)]; !ok {
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+	}
+	return v, nil
+}
+
+// validate reports whether b, a JSON document, matches the schema.
+func (s *jsonSchema) validate(b []byte) error {
+	v, err := jsonvalue.Decode(b)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	s.check(s.root, v, "", &errs, 0)
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) > maxSchemaErrors {
+		errs = append(errs[:maxSchemaErrors], fmt.Sprintf("and %d more", len(errs)-maxSchemaErrors))
+	}
+	return fmt.Errorf("does not match JSON Schema: %s", strings.Join(errs, "; "))
+}
+
+// checkJSONSchema checks b, the encoded data, with WithJSONSchema.
+func (o *options) checkJSONSchema(b []byte) error {
+	if o.jsonSchema == nil {
+		return nil
+	}
+	if err := o.jsonSchema.validate(b); err != nil {
+		return fmt.Errorf("invalid data: %w", err)
+	}
+	return nil
+}
+
+// matches reports whether v matches sch, without recording why not.
+func (s *jsonSchema) matches(sch, v any, path string, depth int) bool {
+	var errs []string
+	s.check(sch, v, path, &errs, depth)
+	return len(errs) == 0
+}
+
+// check appends to errs the ways v, at path, does not match sch.
+func (s *jsonSchema) check(sch, v any, path string, errs *[]string, depth int) {
+	fail := func(format string, args ...any) {
+		p := path
+		if p == "" {
+			p = "/"
+		}
+		*errs = append(*errs, p+": "+fmt.Sprintf(format, args...))
+	}
+	if depth > 100 {
+		fail("schema nested too deeply, is a $ref recursive?")
+		return
+	}
+	depth++
+	m, ok := sch.(map[string]any)
+	if !ok {
+		if sch == false {
+			fail("not allowed")
+		}
+		return
+	}
+
+	if ref, ok := m["$ref"].(string); ok {
+		if target, err := s.resolve(ref); err != nil {
+			fail("%v", err)
+		} else {
+			s.check(target, v, path, errs, depth)
+		}
+	}
+	if t, ok := m["type"]; ok && !typeMatches(t, v) {
+		fail("is %s, want %s", jsonTypeOf(v), typeNames(t))
+	}
+	if enum, ok := m["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if jsonValueEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("is not one of the values of enum")
+		}
+	}
+	if c, ok := m["const"]; ok && !jsonValueEqual(c, v) {
+		fail("is not the value of const")
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		s.checkObject(m, v, path, errs, depth, fail)
+	case []any:
+		s.checkArray(m, v, path, errs, depth, fail)
+	case string:
+		n := utf8.RuneCountInString(v)
+		if min, ok := schemaInt(m["minLength"]); ok && n < min {
+			fail("is shorter than %d characters", min)
+		}
+		if max, ok := schemaInt(m["maxLength"]); ok && n > max {
+			fail("is longer than %d characters", max)
+		}
+		if p, ok := m["pattern"].(string); ok && s.patterns[p] != nil && !s.patterns[p].MatchString(v) {
+			fail("does not match pattern %q", p)
+		}
+	case json.Number:
+		x, _ := new(big.Rat).SetString(string(v))
+		if x == nil {
+			break
+		}
+		bound := func(key string) *big.Rat {
+			n, ok := m[key].(json.Number)
+			if !ok {
+				return nil
+			}
+			r, _ := new(big.Rat).SetString(string(n))
+			return r
+		}
+		if b := bound("minimum"); b != nil && x.Cmp(b) < 0 {
+			fail("is less than %s", m["minimum"])
+		}
+		if b := bound("maximum"); b != nil && x.Cmp(b) > 0 {
+			fail("is greater than %s", m["maximum"])
+		}
+		if b := bound("exclusiveMinimum"); b != nil && x.Cmp(b) <= 0 {
+			fail("is not greater than %s", m["exclusiveMinimum"])
+		}
+		if b := bound("exclusiveMaximum"); b != nil && x.Cmp(b) >= 0 {
+			fail("is not less than %s", m["exclusiveMaximum"])
+		}
+		if b := bound("multipleOf"); b != nil && b.Sign() > 0 && !new(big.Rat).Quo(x, b).IsInt() {
+			fail("is not a multiple of %s", m["multipleOf"])
+		}
+	}
+
+	if all, ok := m["allOf"].([]any); ok {
+		for _, sub := range all {
+			s.check(sub, v, path, errs, depth)
+		}
+	}
+	if anyOf, ok := m["anyOf"].([]any); ok {
+		found := false
+		for _, sub := range anyOf {
+			if s.matches(sub, v, path, depth) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("matches none of the schemas of anyOf")
+		}
+	}
+	if oneOf, ok := m["oneOf"].([]any); ok {
+		n := 0
+		for _, sub := range oneOf {
+			if s.matches(sub, v, path, depth) {
+				n++
+			}
+		}
+		if n != 1 {
+			fail("matches %d of the schemas of oneOf, want 1", n)
+		}
+	}
+	if not, ok := m["not"]; ok && s.matches(not, v, path, depth) {
+		fail("matches the schema of not")
+	}
+	if cond, ok := m["if"]; ok {
+		if s.matches(cond, v, path, depth) {
+			if then, ok := m["then"]; ok {
+				s.check(then, v, path, errs, depth)
+			}
+		} else if els, ok := m["else"]; ok {
+			s.check(els, v, path, errs, depth)
+		}
+	}
+}
+
+func (s *jsonSchema) checkObject(m map[string]any, v map[string]any, path string, errs *[]string, depth int, fail func(string, ...any)) {
+	if req, ok := m["required"].([]any); ok {
+		for _, r := range req {
+			if name, ok := r.(string); ok {
+				if _, ok := v[name]; !ok {
+					fail("missing required member %q", name)
+				}
+			}
+		}
+	}
+	if min, ok := schemaInt(m["minProperties"]); ok && len(v) < min {
+		fail("has fewer than %d members", min)
+	}
+	if max, ok := schemaInt(m["maxProperties"]); ok && len(v) > max {
+		fail("has more than %d members", max)
+	}
+	props, _ := m["properties"].(map[string]any)
+	patternProps, _ := m["patternProperties"].(map[string]any)
+	additional, hasAdditional := m["additionalProperties"]
+	names, hasNames := m["propertyNames"]
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // report errors in a stable order
+	for _, k := range keys {
+		p := path + "/" + jsonvalue.EscapePointer(k)
+		if hasNames {
+			s.check(names, k, p, errs, depth)
+		}
+		matched := false
+		if sub, ok := props[k]; ok {
+			matched = true
+			s.check(sub, v[k], p, errs, depth)
+		}
+		for pat, sub := range patternProps {
+			if re := s.patterns[pat]; re != nil && re.MatchString(k) {
+				matched = true
+				s.check(sub, v[k], p, errs, depth)
+			}
+		}
+		if !matched && hasAdditional {
+			if additional == false {
+				fail("member %q is not allowed", k)
+			} else {
+				s.check(additional, v[k], p, errs, depth)
+			}
+		}
+	}
+}
+
+func (s *jsonSchema) checkArray(m map[string]any, v []any, path string, errs *[]string, depth int, fail func(string, ...any)) {
+	if min, ok := schemaInt(m["minItems"]); ok && len(v) < min {
+		fail("has fewer than %d items", min)
+	}
+	if max, ok := schemaInt(m["maxItems"]); ok && len(v) > max {
+		fail("has more than %d items", max)
+	}
+	prefix, _ := m["prefixItems"].([]any)
+	for i, e := range v {
+		p := fmt.Sprintf("%s/%d", path, i)
+		if i < len(prefix) {
+			s.check(prefix[i], e, p, errs, depth)
+		} else if items, ok := m["items"]; ok {
+			s.check(items, e, p, errs, depth)
+		}
+	}
+	if contains, ok := m["contains"]; ok {
+		found := false
+		for i, e := range v {
+			if s.matches(contains, e, fmt.Sprintf("%s/%d", path, i), depth) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("has no item matching the schema of contains")
+		}
+	}
+	if m["uniqueItems"] == true {
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if jsonValueEqual(v[i], v[j]) {
+					fail("items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+}
+
+// jsonTypeOf returns the JSON Schema type of v, decoded by jsonvalue.Decode.
+func jsonTypeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if r, ok := new(big.Rat).SetString(string(v)); ok && r.IsInt() {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// typeMatches reports whether v has the type, or one of the types, t.
+func typeMatches(t, v any) bool {
+	got := jsonTypeOf(v)
+	match := func(want any) bool {
+		return want == got || want == "number" && got == "integer"
+	}
+	if list, ok := t.([]any); ok {
+		for _, want := range list {
+			if match(want) {
+				return true
+			}
+		}
+		return false
+	}
+	return match(t)
+}
+
+func typeNames(t any) string {
+	if list, ok := t.([]any); ok {
+		names := make([]string, len(list))
+		for i, n := range list {
+			names[i] = fmt.Sprint(n)
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// schemaInt returns the value of a keyword that is a count.
+func schemaInt(v any) (int, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return int(i), err == nil
+}
+
+// jsonValueEqual reports whether a and b, decoded by jsonvalue.Decode, are
+// the same JSON value. Numbers are compared by value.
+func jsonValueEqual(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, ok1 := new(big.Rat).SetString(string(a))
+		y, ok2 := new(big.Rat).SetString(string(b))
+		return ok1 && ok2 && x.Cmp(y) == 0
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonValueEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !jsonValueEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -0,0 +1,120 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaKeywords(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		schema, doc string
+		ok          bool
+	}{
+		{`true`, `{"a":1}`, true},
+		{`false`, `{}`, false},
+		{`{"type":"object"}`, `[]`, false},
+		{`{"type":["string","null"]}`, `null`, true},
+		{`{"type":"integer"}`, `1.0`, true},
+		{`{"type":"integer"}`, `1.5`, false},
+		{`{"type":"number"}`, `3`, true},
+		{`{"enum":[1,"a"]}`, `1.0`, true},
+		{`{"enum":[1,"a"]}`, `"b"`, false},
+		{`{"const":{"a":[1]}}`, `{"a":[1]}`, true},
+		{`{"required":["id"]}`, `{"name":"x"}`, false},
+		{`{"properties":{"id":{"type":"string","minLength":1}}}`, `{"id":""}`, false},
+		{`{"properties":{"id":{}},"additionalProperties":false}`, `{"id":1,"other":2}`, false},
+		{`{"patternProperties":{"^x-":{"type":"string"}},"additionalProperties":false}`, `{"x-a":"b"}`, true},
+		{`{"patternProperties":{"^x-":{"type":"string"}}}`, `{"x-a":1}`, false},
+		{`{"propertyNames":{"maxLength":2}}`, `{"abc":1}`, false},
+		{`{"minProperties":1}`, `{}`, false},
+		{`{"items":{"type":"integer"},"maxItems":2}`, `[1,2]`, true},
+		{`{"items":{"type":"integer"}}`, `[1,"2"]`, false},
+		{`{"prefixItems":[{"type":"string"}],"items":{"type":"integer"}}`, `["a",1]`, true},
+		{`{"contains":{"const":3}}`, `[1,2]`, false},
+		{`{"uniqueItems":true}`, `[1,{"a":1},{"a":1.0}]`, false},
+		{`{"pattern":"^[a-z]+$"}`, `"abc"`, true},
+		{`{"pattern":"^[a-z]+$"}`, `"aBc"`, false},
+		{`{"maxLength":2}`, `"日本"`, true},
+		{`{"minimum":0,"exclusiveMaximum":10}`, `10`, false},
+		{`{"multipleOf":0.1}`, `0.3`, true},
+		{`{"anyOf":[{"type":"string"},{"minimum":5}]}`, `3`, false},
+		{`{"oneOf":[{"minimum":0},{"maximum":10}]}`, `5`, false},
+		{`{"not":{"type":"null"}}`, `null`, false},
+		{`{"if":{"properties":{"kind":{"const":"a"}}},"then":{"required":["a"]},"else":{"required":["b"]}}`, `{"kind":"b","b":1}`, true},
+		{`{"$defs":{"id":{"type":"string"}},"properties":{"ids":{"items":{"$ref":"#/$defs/id"}}}}`, `{"ids":["a",2]}`, false},
+	}
+	for _, test := range tests {
+		s, err := compileJSONSchema([]byte(test.schema))
+		if err != nil {
+			t.Errorf("%s: %v", test.schema, err)
+			continue
+		}
+		if err := s.validate([]byte(test.doc)); (err == nil) != test.ok {
+			t.Errorf("schema %s, document %s: %v, want ok=%v", test.schema, test.doc, err, test.ok)
+		}
+	}
+
+	for _, bad := range []string{`[]`, `{"pattern":"("}`, `{"$ref":"#/$defs/missing"}`, `{"$ref":"other.json"}`} {
+		if _, err := compileJSONSchema([]byte(bad)); err == nil {
+			t.Errorf("schema %s: no error", bad)
+		}
+	}
+}
+
+func TestWithJSONSchema(t *testing.T) {
+	t.Parallel()
+	type User struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	type DB struct {
+		Users []User `json:"users"`
+	}
+	schema := []byte(`{
+		"type": "object",
+		"properties": {
+			"users": {"type": ["array", "null"], "items": {"$ref": "#/$defs/user"}}
+		},
+		"$defs": {
+			"user": {"required": ["id"], "properties": {"id": {"type": "string", "minLength": 1}}}
+		}
+	}`)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testschema.json")
+	db, err := New[DB](path, WithJSONSchema(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustWrite(t, db, func(db *DB) { db.Users = append(db.Users, User{ID: "1"}) })
+	err = db.Write(func(db *DB) error {
+		db.Users = append(db.Users, User{Name: "no id"})
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "/users/1/id: is shorter than 1 characters") {
+		t.Errorf("Write: %v", err)
+	}
+	db.Read(func(db *DB) {
+		if len(db.Users) != 1 {
+			t.Errorf("invalid Write changed the data: %v", db.Users)
+		}
+	})
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"users":[{"name":"x"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load[DB](bad, WithJSONSchema(schema)); err == nil || !strings.Contains(err.Error(), `missing required member "id"`) {
+		t.Errorf("Load: %v", err)
+	}
+	if _, err := Load[DB](bad, WithJSONSchema([]byte(`{"type":`))); err == nil {
+		t.Error("Load with an invalid schema: no error")
+	}
+}
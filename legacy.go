@@ -0,0 +1,66 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"fmt"
+	"os"
+)
+
+type legacyDecoder struct {
+	detect func(b []byte) bool
+	decode func(b []byte, v any) error
+}
+
+// WithLegacyDecoder lets Load read a file written in an older format,
+// such as INI, CSV, or a gob blob, by a program that did not use this
+// package. If detect reports that the contents of the file are in the
+// legacy format, Load decodes them with decode and rewrites the file
+// in the format of the other options. The original file is kept, with
+// ".legacy" appended to its path, and later Loads read the new file.
+//
+// detect is called only by Load, with the contents of the file as they
+// are on disk, and must report false for files written by this package.
+func WithLegacyDecoder[Data any](detect func(b []byte) bool, decode func(b []byte, data *Data) error) Option {
+	return func(o *options) {
+		o.legacy = &legacyDecoder{
+			detect: detect,
+			decode: func(b []byte, v any) error {
+				data, ok := v.(*Data)
+				if !ok {
+					return fmt.Errorf("WithLegacyDecoder for %T used with %T", data, v)
+				}
+				return decode(b, data)
+			},
+		}
+	}
+}
+
+// loadLegacy reads the file if it is in the format of WithLegacyDecoder,
+// and rewrites it. It reports whether the file was in the format.
+// It is called by Load.
+func (p *JSONFile[Data]) loadLegacy() (bool, error) {
+	if p.opts.legacy == nil {
+		return false, nil
+	}
+	raw, err := os.ReadFile(p.path)
+	if err != nil || !p.opts.legacy.detect(raw) {
+		return false, err
+	}
+	if err := p.opts.legacy.decode(raw, p.data); err != nil {
+		return true, fmt.Errorf("legacy format: %w", err)
+	}
+	b, err := p.opts.marshal(p.data)
+	if err != nil {
+		return true, err
+	}
+	if err := atomicWrite(osFS{}, p.path+".legacy", raw, true, p.opts.syncDir, nil); err != nil {
+		return true, err
+	}
+	if err := p.writeFile(b, false); err != nil {
+		return true, err
+	}
+	p.bytes = b
+	return true, nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLegacyDecoder(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Vals map[string]string }
+
+	path := filepath.Join(t.TempDir(), "testlegacy.json")
+	legacy := []byte("a=1\nb=2\n")
+	if err := os.WriteFile(path, legacy, 0666); err != nil {
+		t.Fatal(err)
+	}
+	detect := func(b []byte) bool { return !json.Valid(b) }
+	decode := func(b []byte, db *DB) error {
+		db.Vals = make(map[string]string)
+		for _, line := range strings.Fields(string(b)) {
+			k, v, _ := strings.Cut(line, "=")
+			db.Vals[k] = v
+		}
+		return nil
+	}
+	opt := WithLegacyDecoder(detect, decode)
+
+	db, err := Load[DB](path, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Vals["a"] != "1" || db.Vals["b"] != "2" {
+			t.Errorf("Vals=%v", db.Vals)
+		}
+	})
+	if got, _ := os.ReadFile(path + ".legacy"); !bytes.Equal(got, legacy) {
+		t.Errorf("legacy copy is %q", got)
+	}
+	if b, _ := os.ReadFile(path); !json.Valid(b) {
+		t.Errorf("file not rewritten as JSON: %q", b)
+	}
+	mustWrite(t, db, func(db *DB) { db.Vals["c"] = "3" })
+
+	db, err = Load[DB](path, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if len(db.Vals) != 3 {
+			t.Errorf("Vals=%v after second Load", db.Vals)
+		}
+	})
+
+	type Other struct{}
+	if _, err := Load[Other](path, opt); err != nil {
+		t.Errorf("Load of a JSON file with a decoder for another type: %v", err)
+	}
+	os.WriteFile(path, legacy, 0666)
+	if _, err := Load[Other](path, opt); err == nil {
+		t.Error("Load with a decoder for another type succeeded")
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrClosed is returned by Write after the JSONFile has been closed,
+// deleted, or archived.
+var ErrClosed = errors.New("jsonfile: closed")
+
+// Close writes any changes delayed by WithAutosave, compacts the
+// journal of WithJournal into the file, stops Watch and RunScrubber,
+// and releases the file lock. After Close, Write returns
+// ErrClosed. Read continues to return the last data.
+//
+// Close waits for any Write in progress. Closing a JSONFile that is
+// already closed, deleted, or archived does nothing.
+func (p *JSONFile[Data]) Close() error {
+	p.writing <- struct{}{}
+	defer func() { <-p.writing }()
+	if p.closed {
+		return nil
+	}
+	err := p.flush()
+	if err == nil {
+		err = p.compactJournal()
+	}
+	p.markClosed()
+	if err != nil {
+		return fmt.Errorf("JSONFile.Close: %w", err)
+	}
+	return nil
+}
+
+// isClosed reports whether p has been closed.
+func (p *JSONFile[Data]) isClosed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// markClosed ends use of the file. It is called with p.writing held.
+func (p *JSONFile[Data]) markClosed() {
+	p.event(Event{Event: "closed"})
+	p.closed = true
+	p.dirty = false
+	if p.flushTimer != nil {
+		p.flushTimer.Stop()
+		p.flushTimer = nil
+	}
+	close(p.done)
+	p.closeJournal()
+	p.replica.close()
+	p.unlockFile()
+}
+
+// Delete deletes the file by renaming it to the path with ".deleted"
+// appended, replacing any previous deleted copy, so a mistaken Delete
+// can be undone. Delete waits for any Write in progress, then discards
+// any changes delayed by WithAutosave and closes the JSONFile as Close
+// does.
+func (p *JSONFile[Data]) Delete() error {
+	p.writing <- struct{}{}
+	defer func() { <-p.writing }()
+	if p.closed {
+		return fmt.Errorf("JSONFile.Delete: %w", ErrClosed)
+	}
+	if err := p.opts.fileSystem().Rename(p.path, p.path+".deleted"); err != nil {
+		return fmt.Errorf("JSONFile.Delete: %w", err)
+	}
+	p.markClosed()
+	return nil
+}
+
+// Archive moves the file into the directory destDir, keeping its name.
+// It fails if destDir already contains a file with that name. Archive
+// waits for any Write in progress, writes any changes delayed by
+// WithAutosave and compacts any journal, then closes the JSONFile as
+// Close does.
+func (p *JSONFile[Data]) Archive(destDir string) error {
+	p.writing <- struct{}{}
+	defer func() { <-p.writing }()
+	if p.closed {
+		return fmt.Errorf("JSONFile.Archive: %w", ErrClosed)
+	}
+	if p.opts.fsys != nil {
+		return errors.New("JSONFile.Archive: cannot be used with WithFS")
+	}
+	if err := p.flush(); err != nil {
+		return fmt.Errorf("JSONFile.Archive: %w", err)
+	}
+	if err := p.compactJournal(); err != nil {
+		return fmt.Errorf("JSONFile.Archive: %w", err)
+	}
+	if err := moveNoReplace(p.path, filepath.Join(destDir, filepath.Base(p.path))); err != nil {
+		return fmt.Errorf("JSONFile.Archive: %w", err)
+	}
+	p.markClosed()
+	return nil
+}
+
+// tombstone renames the file at path to path + ".deleted".
+func tombstone(path string) error {
+	return os.Rename(path, path+".deleted")
+}
+
+// moveNoReplace moves the file at src to dst, failing if dst exists.
+// It hard links then removes src, falling back to copying between file
+// systems.
+func moveNoReplace(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return os.Remove(src)
+	} else if errors.Is(err, os.ErrExist) {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if err1 := out.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
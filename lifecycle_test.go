@@ -0,0 +1,128 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testclose.json")
+	db, err := New[DB](path, WithExclusiveLock(), WithAutosave(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := db.Watch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != `{"Val":1}` {
+		t.Errorf("file = %s, want {\"Val\":1}", b)
+	}
+	if err := db.Write(func(db *DB) error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Close err=%v, want %v", err, ErrClosed)
+	}
+	if err := db.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	for range changes {
+		// Watch stops when the JSONFile is closed.
+	}
+
+	// The lock was released.
+	if _, err := Load[DB](path, WithExclusiveLock()); err != nil {
+		t.Errorf("Load after Close: %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testdelete.json")
+	db, err := New[DB](path, WithExclusiveLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if err := db.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file exists after Delete: %v", err)
+	}
+	if b, _ := os.ReadFile(path + ".deleted"); string(b) != `{"Val":1}` {
+		t.Errorf("tombstone = %s, want {\"Val\":1}", b)
+	}
+	if err := db.Write(func(db *DB) error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Delete err=%v, want %v", err, ErrClosed)
+	}
+	if err := db.Delete(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Delete err=%v, want %v", err, ErrClosed)
+	}
+
+	// The lock was released.
+	if _, err := New[DB](path, WithExclusiveLock()); err != nil {
+		t.Errorf("New after Delete: %v", err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testarchive.json")
+	archive := filepath.Join(dir, "archive")
+	if err := os.Mkdir(archive, 0777); err != nil {
+		t.Fatal(err)
+	}
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if err := db.Archive(archive); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file exists after Archive: %v", err)
+	}
+	archived, err := Load[DB](filepath.Join(archive, "testarchive.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived.Read(func(db *DB) {
+		if db.Val != 1 {
+			t.Errorf("archived Val=%d, want 1", db.Val)
+		}
+	})
+	if err := db.Write(func(db *DB) error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Archive err=%v, want %v", err, ErrClosed)
+	}
+
+	// Archiving over an existing file fails and leaves both in place.
+	db, err = New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Archive(archive); !errors.Is(err, os.ErrExist) {
+		t.Errorf("Archive over existing file err=%v, want %v", err, os.ErrExist)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file missing after failed Archive: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrLocked is returned by New and Load when another process holds a
+// conflicting lock on the file.
+var ErrLocked = errors.New("jsonfile: file is locked by another process")
+
+// ErrReadOnly is returned by Write on a JSONFile that cannot be written.
+var ErrReadOnly = errors.New("jsonfile: read-only")
+
+type lockMode int
+
+const (
+	lockNone lockMode = iota
+	lockShared
+	lockExclusive
+)
+
+// WithExclusiveLock takes an advisory lock on the file so that no other
+// process using this package can open it with a lock, enforcing a
+// single writer. If another process holds a lock, New and Load return
+// an error wrapping ErrLocked.
+//
+// The lock is held on a separate file, the path with ".lock" appended,
+// because Write replaces the data file. It is held while the JSONFile
+// is in use. Locks are not supported on Solaris, AIX, and platforms
+// other than Unix and Windows, where New and Load return an error.
+func WithExclusiveLock() Option {
+	return func(o *options) { o.lock = lockExclusive }
+}
+
+// WithSharedLock takes a shared advisory lock on the file. Any number
+// of processes can hold a shared lock, but not while another process
+// holds an exclusive lock. A JSONFile with a shared lock is read-only:
+// Write returns ErrReadOnly, and New fails.
+func WithSharedLock() Option {
+	return func(o *options) { o.lock = lockShared }
+}
+
+// lockFile takes the lock requested by the options. It is called by
+// New and Load before the data file is touched.
+func (p *JSONFile[Data]) lockFile() error {
+	if p.opts.lock == lockNone {
+		return nil
+	}
+	f, err := os.OpenFile(p.path+".lock", os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+	if err := lock(f, p.opts.lock == lockExclusive); err != nil {
+		f.Close()
+		return fmt.Errorf("lock: %w", err)
+	}
+	p.lock = f
+	return nil
+}
+
+// unlockFile releases the lock, if any.
+func (p *JSONFile[Data]) unlockFile() error {
+	if p.lock == nil {
+		return nil
+	}
+	err := unlock(p.lock)
+	if err1 := p.lock.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	p.lock = nil
+	return err
+}
@@ -0,0 +1,17 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build (!unix && !windows) || solaris || aix
+
+package jsonfile
+
+import (
+	"errors"
+	"os"
+)
+
+func lock(f *os.File, exclusive bool) error {
+	return errors.New("file locking not supported on this platform")
+}
+
+func unlock(f *os.File) error { return nil }
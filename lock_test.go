@@ -0,0 +1,78 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestExclusiveLock(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testlock.json")
+	db, err := New[DB](path, WithExclusiveLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+
+	if _, err := Load[DB](path, WithExclusiveLock()); !errors.Is(err, ErrLocked) {
+		t.Errorf("second exclusive Load err=%v, want %v", err, ErrLocked)
+	}
+	if _, err := Load[DB](path, WithSharedLock()); !errors.Is(err, ErrLocked) {
+		t.Errorf("shared Load err=%v, want %v", err, ErrLocked)
+	}
+	if _, err := Load[DB](path); err != nil {
+		t.Errorf("unlocked Load: %v", err)
+	}
+
+	if err := db.unlockFile(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Load[DB](path, WithExclusiveLock())
+	if err != nil {
+		t.Fatalf("Load after unlock: %v", err)
+	}
+	db.unlockFile()
+}
+
+func TestSharedLock(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testlock.json")
+	if _, err := New[DB](path, WithSharedLock()); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("shared New err=%v, want %v", err, ErrReadOnly)
+	}
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+
+	r1, err := Load[DB](path, WithSharedLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := Load[DB](path, WithSharedLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2.Read(func(db *DB) {
+		if db.Val != 1 {
+			t.Errorf("Val = %d, want 1", db.Val)
+		}
+	})
+	if err := r1.Write(func(db *DB) error { return nil }); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("shared Write err=%v, want %v", err, ErrReadOnly)
+	}
+	if _, err := Load[DB](path, WithExclusiveLock()); !errors.Is(err, ErrLocked) {
+		t.Errorf("exclusive Load err=%v, want %v", err, ErrLocked)
+	}
+	r1.unlockFile()
+	r2.unlockFile()
+}
@@ -0,0 +1,67 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger logs the events of WithEvents to l, so operators can see
+// what a JSONFile does without wrapping every call. Commits and
+// rollbacks are logged at the Debug level, opening, reloading, and
+// closing the file at Info, conflicts, recoveries, decoding issues,
+// and failures to replicate at Warn, and failed writes and corrupt
+// files at Error.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// eventLevels are the levels at which the events are logged.
+// Events not listed are logged at Info.
+var eventLevels = map[string]slog.Level{
+	"wrote":        slog.LevelDebug,
+	"rolledback":   slog.LevelDebug,
+	"conflicted":   slog.LevelWarn,
+	"recovered":    slog.LevelWarn,
+	"decoded":      slog.LevelWarn,
+	"unreplicated": slog.LevelWarn,
+	"failed":       slog.LevelError,
+	"corrupted":    slog.LevelError,
+}
+
+// logEvent logs ev to the logger of WithLogger, if any.
+func (o *options) logEvent(ev Event) {
+	if o.logger == nil {
+		return
+	}
+	level, ok := eventLevels[ev.Event]
+	if !ok {
+		level = slog.LevelInfo
+	}
+	ctx := context.Background()
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("path", ev.Path)}
+	if ev.Revision != 0 {
+		attrs = append(attrs, slog.Uint64("revision", ev.Revision))
+	}
+	if ev.Bytes != 0 {
+		attrs = append(attrs, slog.Int("bytes", ev.Bytes))
+	}
+	if ev.From != "" {
+		attrs = append(attrs, slog.String("from", ev.From))
+	}
+	if ev.Error != "" {
+		attrs = append(attrs, slog.String("error", ev.Error))
+	}
+	if len(ev.Unknown) > 0 {
+		attrs = append(attrs, slog.Any("unknown", ev.Unknown))
+	}
+	if len(ev.Mismatched) > 0 {
+		attrs = append(attrs, slog.Any("mismatched", ev.Mismatched))
+	}
+	o.logger.LogAttrs(ctx, level, "jsonfile: "+ev.Event, attrs...)
+}
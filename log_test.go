@@ -0,0 +1,70 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	path := filepath.Join(t.TempDir(), "db.json")
+	db, err := New[DB](path, WithLogger(logger), WithConflictDetection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if err := db.Write(func(*DB) error { return errors.New("no") }); err == nil {
+		t.Fatal("Write succeeded")
+	}
+	if err := os.WriteFile(path, []byte(`{"Val":2}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Write(func(db *DB) error { db.Val = 3; return nil }); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Write err=%v, want ErrConflict", err)
+	}
+	if err := db.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	var got []string
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var rec struct {
+			Level, Msg, Path string
+		}
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			t.Fatalf("%s: %v", s.Bytes(), err)
+		}
+		if rec.Path != path {
+			t.Errorf("record without path: %s", s.Bytes())
+		}
+		got = append(got, rec.Level+" "+rec.Msg)
+	}
+	want := []string{
+		"DEBUG jsonfile: wrote",
+		"INFO jsonfile: created",
+		"DEBUG jsonfile: wrote",
+		"DEBUG jsonfile: rolledback",
+		"WARN jsonfile: conflicted",
+		"INFO jsonfile: reloaded",
+		"INFO jsonfile: closed",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("logged:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) to the data in a
+// Write: members of the patch replace those of the data, objects are
+// merged, and null members are removed, leaving their fields zero.
+// This is the natural body of an HTTP PATCH request. As in Load,
+// members the Data type does not have are ignored. If the patched data
+// does not decode as Data, MergePatch returns an error and does not
+// change the file.
+func (p *JSONFile[Data]) MergePatch(patch []byte) error {
+	if err := p.mergePatch(patch); err != nil {
+		return fmt.Errorf("JSONFile.MergePatch: %w", err)
+	}
+	return nil
+}
+
+func (p *JSONFile[Data]) mergePatch(patch []byte) error {
+	if !p.opts.isJSON() {
+		return errors.New("data is not JSON")
+	}
+	pv, err := jsonvalue.Decode(patch)
+	if err != nil {
+		return fmt.Errorf("invalid patch: %w", err)
+	}
+	return p.Write(func(data *Data) error {
+		b, err := p.opts.marshal(data)
+		if err != nil {
+			return err
+		}
+		doc, err := jsonvalue.Decode(b)
+		if err != nil {
+			return err
+		}
+		if b, err = json.Marshal(jsonvalue.MergePatch(doc, pv)); err != nil {
+			return err
+		}
+		patched := new(Data)
+		if err := p.opts.unmarshal(b, patched); err != nil {
+			return err
+		}
+		*data = *patched
+		return nil
+	})
+}
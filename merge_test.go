@@ -0,0 +1,54 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	t.Parallel()
+	type Limits struct{ MaxUsers, MaxFiles int }
+	type DB struct {
+		Name   string
+		Tags   []string
+		Limits Limits
+	}
+
+	path := filepath.Join(t.TempDir(), "testmerge.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustWrite(t, db, func(db *DB) {
+		db.Name = "a"
+		db.Tags = []string{"x"}
+		db.Limits = Limits{MaxUsers: 1, MaxFiles: 2}
+	})
+
+	if err := db.MergePatch([]byte(`{"Limits":{"MaxUsers":10},"Tags":null}`)); err != nil {
+		t.Fatal(err)
+	}
+	want := DB{Name: "a", Limits: Limits{MaxUsers: 10, MaxFiles: 2}}
+	db.Read(func(db *DB) {
+		if !reflect.DeepEqual(*db, want) {
+			t.Errorf("got %+v, want %+v", *db, want)
+		}
+	})
+
+	if err := db.MergePatch([]byte(`{"Name":5}`)); err == nil {
+		t.Error("no error for a patch of the wrong type")
+	}
+	if err := db.MergePatch([]byte(`{`)); err == nil {
+		t.Error("no error for invalid JSON")
+	}
+	db.Read(func(db *DB) {
+		if !reflect.DeepEqual(*db, want) {
+			t.Errorf("failed patches changed the data: %+v", *db)
+		}
+	})
+}
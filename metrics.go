@@ -0,0 +1,40 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// counters count the activity of a JSONFile since it was opened,
+// for Stat. They are updated without locks, so reads are not slowed.
+type counters struct {
+	reads       atomic.Uint64
+	writes      atomic.Uint64
+	writeErrors atomic.Uint64
+	lastWrite   atomic.Int64 // Unix time in nanoseconds
+	marshal     atomic.Int64 // total time spent marshaling, in nanoseconds
+}
+
+// countWriteResult counts a write of the file that returned err.
+func (c *counters) countWriteResult(err error) {
+	if err != nil {
+		c.writeErrors.Add(1)
+		return
+	}
+	c.writes.Add(1)
+	c.lastWrite.Store(time.Now().UnixNano())
+}
+
+// fill sets the counts in st.
+func (c *counters) fill(st *FileStat) {
+	st.Reads = c.reads.Load()
+	st.Writes = c.writes.Load()
+	st.WriteErrors = c.writeErrors.Load()
+	if t := c.lastWrite.Load(); t != 0 {
+		st.LastWrite = time.Unix(0, t)
+	}
+	st.MarshalTime = time.Duration(c.marshal.Load())
+}
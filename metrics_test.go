@@ -0,0 +1,52 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"expvar"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatCounters(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "db.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := db.Stat()
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+	db.Read(func(*DB) {})
+	db.ReadWithGeneration(func(*DB, uint64) {})
+
+	st := db.Stat()
+	if got := st.Writes - before.Writes; got != 2 {
+		t.Errorf("Writes grew by %d, want 2", got)
+	}
+	if got := st.Reads - before.Reads; got != 2 {
+		t.Errorf("Reads grew by %d, want 2", got)
+	}
+	if st.WriteErrors != 0 {
+		t.Errorf("WriteErrors=%d", st.WriteErrors)
+	}
+	if st.LastWrite.IsZero() || st.LastWrite.Before(before.LastWrite) {
+		t.Errorf("LastWrite=%v, before %v", st.LastWrite, before.LastWrite)
+	}
+	if st.MarshalTime <= before.MarshalTime {
+		t.Errorf("MarshalTime=%v did not grow from %v", st.MarshalTime, before.MarshalTime)
+	}
+
+	v := expvar.Func(func() any { return db.Stat() })
+	var got struct{ Writes uint64 }
+	if err := json.Unmarshal([]byte(v.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Writes != st.Writes {
+		t.Errorf("expvar Writes=%d, want %d", got.Writes, st.Writes)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import "context"
+
+// A WriteFunc performs a Write, or the rest of a chain of write
+// middleware, with the given context.
+type WriteFunc func(ctx context.Context) error
+
+// A ReadFunc performs a Read, or the rest of a chain of read
+// middleware, with the given context.
+type ReadFunc func(ctx context.Context) error
+
+// WithWriteMiddleware wraps each Write in mw, so that logging,
+// metrics, authorization, rate limiting, or tracing can be added
+// without an option for each. Each middleware is called with the next
+// step of the Write, and can run code around it, change its context,
+// or return an error without calling it:
+//
+//	jsonfile.WithWriteMiddleware(func(next jsonfile.WriteFunc) jsonfile.WriteFunc {
+//		return func(ctx context.Context) error {
+//			start := time.Now()
+//			err := next(ctx)
+//			log.Printf("write took %v: %v", time.Since(start), err)
+//			return err
+//		}
+//	})
+//
+// The first middleware given is the outermost. Write, WriteCtx,
+// WriteBatch, WriteInfo, and WriteIfGeneration all use the chain.
+// WithWriteMiddleware can be used more than once.
+func WithWriteMiddleware(mw ...func(next WriteFunc) WriteFunc) Option {
+	return func(o *options) { o.writeMiddleware = append(o.writeMiddleware, mw...) }
+}
+
+// WithReadMiddleware is like WithWriteMiddleware for Read, ReadCtx,
+// ReadMany, and ReadWithGeneration. Read has no error result, so it
+// does not call fn if a middleware returns an error.
+func WithReadMiddleware(mw ...func(next ReadFunc) ReadFunc) Option {
+	return func(o *options) { o.readMiddleware = append(o.readMiddleware, mw...) }
+}
+
+// aroundWrite calls do wrapped in the write middleware.
+func (p *JSONFile[Data]) aroundWrite(ctx context.Context, do WriteFunc) (err error) {
+	ctx, end := p.opts.startSpan(ctx, "jsonfile.Write")
+	defer func() { end(err) }()
+	for i := len(p.opts.writeMiddleware) - 1; i >= 0; i-- {
+		do = p.opts.writeMiddleware[i](do)
+	}
+	return do(ctx)
+}
+
+// aroundRead calls do wrapped in the read middleware.
+func (p *JSONFile[Data]) aroundRead(ctx context.Context, do ReadFunc) error {
+	p.counters.reads.Add(1)
+	for i := len(p.opts.readMiddleware) - 1; i >= 0; i-- {
+		do = p.opts.readMiddleware[i](do)
+	}
+	return do(ctx)
+}
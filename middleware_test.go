@@ -0,0 +1,65 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	var calls []string
+	trace := func(name string) func(WriteFunc) WriteFunc {
+		return func(next WriteFunc) WriteFunc {
+			return func(ctx context.Context) error {
+				calls = append(calls, name+" before")
+				err := next(ctx)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	denied := errors.New("denied")
+	type denyKey struct{}
+	deny := func(next ReadFunc) ReadFunc {
+		return func(ctx context.Context) error {
+			if ctx.Value(denyKey{}) != nil {
+				return denied
+			}
+			return next(ctx)
+		}
+	}
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testmiddleware.json"),
+		WithWriteMiddleware(trace("outer"), trace("inner")),
+		WithReadMiddleware(deny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls = nil
+	mustWrite(t, db, func(db *DB) {
+		calls = append(calls, "fn")
+		db.Val = 1
+	})
+	want := []string{"outer before", "inner before", "fn", "inner after", "outer after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+
+	ctx := context.WithValue(context.Background(), denyKey{}, true)
+	if err := db.ReadCtx(ctx, func(*DB) { t.Error("read fn called") }); !errors.Is(err, denied) {
+		t.Errorf("ReadCtx err=%v, want %v", err, denied)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 1 {
+			t.Errorf("Val=%d, want 1", db.Val)
+		}
+	})
+}
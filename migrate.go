@@ -0,0 +1,201 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MigrateOptions configures MigrateAll.
+type MigrateOptions struct {
+	// Parallelism is the number of files migrated at once.
+	// If it is zero, files are migrated one at a time.
+	Parallelism int
+
+	// Journal, if set, is the path of a journal listing the files
+	// migrated, one name per line. Files it lists are skipped, so a
+	// run that fails or is interrupted can be resumed by running
+	// MigrateAll again with the same journal.
+	Journal string
+
+	// BackupDir, if set, is a directory that receives a copy of each
+	// file, under its own name, before it is migrated.
+	BackupDir string
+
+	// Progress, if set, is called after each file is migrated or
+	// fails. Calls are not concurrent.
+	Progress func(MigrateProgress)
+}
+
+// MigrateProgress reports the migration of a file by MigrateAll.
+type MigrateProgress struct {
+	Name    string // file name in the Dir
+	Changed bool   // whether the migration changed the file
+	Err     error  // why the migration failed, if it did
+	Done    int    // files done so far, including skipped and failed ones
+	Total   int    // files in the Dir
+}
+
+// MigrateAll applies migrate to the data of every file in d, for
+// upgrading many files, such as one per tenant, to a new version of
+// their Data type. Each file is migrated in a Write, so migrate should
+// return SkipWrite for a file that is already up to date. A file open
+// in d is migrated in place. Others are loaded with d's options for
+// the migration and closed after it, so they should not be opened by
+// Open while MigrateAll runs.
+//
+// MigrateAll goes on after a file fails, and returns an error joining
+// the failures of all files. If ctx is done, it stops migrating files
+// and returns an error wrapping ctx.Err().
+func MigrateAll[Data any](ctx context.Context, d *Dir, migrate func(name string, data *Data) error, opts MigrateOptions) error {
+	names, err := d.List()
+	if err != nil {
+		return fmt.Errorf("jsonfile.MigrateAll: %w", err)
+	}
+	done := make(map[string]bool)
+	if opts.Journal != "" {
+		lines, size, err := readLines(opts.Journal)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("jsonfile.MigrateAll: journal: %w", err)
+		}
+		if err := truncateFile(opts.Journal, size); err != nil {
+			return fmt.Errorf("jsonfile.MigrateAll: journal: %w", err)
+		}
+		for _, line := range lines {
+			done[string(line)] = true
+		}
+	}
+	if opts.BackupDir != "" {
+		if err := os.MkdirAll(opts.BackupDir, 0777); err != nil {
+			return fmt.Errorf("jsonfile.MigrateAll: %w", err)
+		}
+	}
+
+	var (
+		mu       sync.Mutex // guards the following
+		progress = MigrateProgress{Total: len(names)}
+		errs     []error
+	)
+	finish := func(name string, changed bool, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil && opts.Journal != "" {
+			err = appendLine(opts.Journal, name)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			errs = append(errs, err)
+		}
+		progress.Name, progress.Changed, progress.Err = name, changed, err
+		progress.Done++
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < max(opts.Parallelism, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				changed, err := migrateFile(ctx, d, name, migrate, opts.BackupDir)
+				finish(name, changed, err)
+			}
+		}()
+	}
+	for _, name := range names {
+		if ctx.Err() != nil {
+			break
+		}
+		if done[name] {
+			finish(name, false, nil)
+			continue
+		}
+		work <- name
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("jsonfile.MigrateAll: %w", err)
+	}
+	return nil
+}
+
+// migrateFile migrates the named file of d, reporting whether it
+// changed. A file not open in d is loaded for the migration, and it
+// has not been migrated unless closing it succeeds, as Close writes
+// any changes delayed by the options.
+func migrateFile[Data any](ctx context.Context, d *Dir, name string, migrate func(string, *Data) error, backupDir string) (bool, error) {
+	d.mu.Lock()
+	f, open := d.openFile(name)
+	d.mu.Unlock()
+	if open {
+		p, ok := f.(*JSONFile[Data])
+		if !ok {
+			return false, fmt.Errorf("open with type %T", f)
+		}
+		return migrateData(ctx, p, name, migrate, backupDir)
+	}
+	p, err := Load[Data](d.filePath(name), d.opts...)
+	if err != nil {
+		return false, err
+	}
+	changed, err := migrateData(ctx, p, name, migrate, backupDir)
+	if err1 := p.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return changed, err
+}
+
+// migrateData migrates the data of p, the named file.
+func migrateData[Data any](ctx context.Context, p *JSONFile[Data], name string, migrate func(string, *Data) error, backupDir string) (bool, error) {
+	res, err := p.WriteInfo(ctx, func(data *Data) error {
+		if backupDir != "" {
+			// p.writing is held, so p.bytes is the data before migrate.
+			b, err := p.encodeFile(p.bytes)
+			if err == nil {
+				err = atomicWrite(osFS{}, filepath.Join(backupDir, filepath.Base(p.path)), b, true, false, nil)
+			}
+			if err != nil {
+				return fmt.Errorf("backup: %w", err)
+			}
+		}
+		return migrate(name, data)
+	})
+	if err != nil {
+		return false, err
+	}
+	if res.Changed {
+		p.event(Event{Event: "migrated"})
+	}
+	return res.Changed, nil
+}
+
+// appendLine appends line and a newline to the file at path, and
+// syncs it.
+func appendLine(path, line string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(line + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
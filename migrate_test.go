@@ -0,0 +1,141 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrateAll(t *testing.T) {
+	t.Parallel()
+	type Tenant struct {
+		Version int
+		Plan    string
+	}
+
+	dir := t.TempDir()
+	d, err := OpenDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		p, err := New[Tenant](filepath.Join(dir, fmt.Sprintf("t%d.json", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustWrite(t, p, func(db *Tenant) { db.Version = 1 })
+		p.Close()
+	}
+	open, err := Open[Tenant](d, "t0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrate := func(name string, db *Tenant) error {
+		if name == "t7" {
+			return errors.New("bad tenant")
+		}
+		if db.Version >= 2 {
+			return SkipWrite
+		}
+		db.Version, db.Plan = 2, "free"
+		return nil
+	}
+	journal := filepath.Join(dir, "migrate.journal")
+	backups := filepath.Join(t.TempDir(), "backups")
+	var last MigrateProgress
+	opts := MigrateOptions{
+		Parallelism: 3,
+		Journal:     journal,
+		BackupDir:   backups,
+		Progress:    func(p MigrateProgress) { last = p },
+	}
+	err = MigrateAll(context.Background(), d, migrate, opts)
+	if err == nil || !strings.Contains(err.Error(), "t7: bad tenant") {
+		t.Errorf("MigrateAll err=%v, want t7 failure", err)
+	}
+	if last.Done != 10 || last.Total != 10 {
+		t.Errorf("last progress %+v", last)
+	}
+	open.Read(func(db *Tenant) {
+		if db.Version != 2 {
+			t.Errorf("open file not migrated: %+v", *db)
+		}
+	})
+	lines, _, err := readLines(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 9 {
+		t.Errorf("journal lists %d files, want 9", len(lines))
+	}
+	backup, err := Load[Tenant](filepath.Join(backups, "t3.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup.Read(func(db *Tenant) {
+		if db.Version != 1 {
+			t.Errorf("backup Version=%d, want 1", db.Version)
+		}
+	})
+
+	// Resuming migrates only the file that failed.
+	var migrated []string
+	opts.Progress = func(p MigrateProgress) {
+		if p.Changed {
+			migrated = append(migrated, p.Name)
+		}
+	}
+	err = MigrateAll(context.Background(), d, func(name string, db *Tenant) error {
+		return migrate("", db)
+	}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 1 || migrated[0] != "t7" {
+		t.Errorf("resumed migration changed %v, want [t7]", migrated)
+	}
+	if _, err := os.Stat(filepath.Join(backups, "t7.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigrateAllCloseError(t *testing.T) {
+	t.Parallel()
+	type Tenant struct{ Version int }
+
+	dir := t.TempDir()
+	p, err := New[Tenant](filepath.Join(dir, "t0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	// With WithAutosave the change is written by Close, which then
+	// fails in the read-only directory.
+	d, err := OpenDir(dir, WithAutosave(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal := filepath.Join(t.TempDir(), "migrate.journal")
+	err = MigrateAll(context.Background(), d, func(name string, db *Tenant) error {
+		db.Version = 2
+		return os.Chmod(dir, 0500)
+	}, MigrateOptions{Journal: journal})
+	os.Chmod(dir, 0700)
+	if err == nil {
+		t.Error("MigrateAll succeeded, though closing the file failed")
+	}
+	if lines, _, err := readLines(journal); err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	} else if len(lines) != 0 {
+		t.Errorf("journal lists %q, though closing the file failed", lines)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+type mirror struct {
+	path    string
+	marshal func(v any) ([]byte, error)
+}
+
+// WithMirror writes a second copy of the data to path after each Write
+// that changes the file, encoded by marshal. This is useful for keeping
+// a copy in a format meant for people, such as indented JSON or YAML,
+// in version control:
+//
+//	jsonfile.WithMirror("state.yaml", yaml.Marshal)
+//	jsonfile.WithMirror("state.pretty.json", func(v any) ([]byte, error) {
+//		return json.MarshalIndent(v, "", "\t")
+//	})
+//
+// The mirror is written atomically, but on a best-effort basis: a
+// failure does not fail the Write, and the mirror is brought up to
+// date by the next Write. WithMirror can be used more than once. With
+// WithFS, path is a name in the WriteFS.
+func WithMirror(path string, marshal func(v any) ([]byte, error)) Option {
+	return func(o *options) {
+		o.mirrors = append(o.mirrors, mirror{path: path, marshal: marshal})
+	}
+}
+
+// writeMirrors writes data to each mirror, ignoring errors.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) writeMirrors(data *Data) {
+	for _, m := range p.opts.mirrors {
+		b, err := m.marshal(data)
+		if err != nil {
+			continue
+		}
+		atomicWrite(p.opts.fileSystem(), m.path, b, false, false, nil)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMirror(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testmirror.json")
+	pretty := filepath.Join(dir, "testmirror.pretty.json")
+	db, err := New[DB](path,
+		WithMirror(pretty, func(v any) ([]byte, error) {
+			return json.MarshalIndent(v, "", "\t")
+		}),
+		WithMirror(filepath.Join(dir, "broken"), func(v any) ([]byte, error) {
+			return nil, errors.New("broken mirror")
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+
+	b, err := os.ReadFile(pretty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n\t\"Val\": 1\n}"; string(b) != want {
+		t.Errorf("mirror = %q, want %q", b, want)
+	}
+}
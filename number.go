@@ -0,0 +1,15 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+// WithUseNumber decodes numbers in the file into interface{} values of
+// the data as json.Number rather than float64, so large integer IDs and
+// decimals with many digits are written back exactly as they were read,
+// instead of being rounded to the nearest float64. Numbers decoded
+// into fields of number types are not affected.
+//
+// WithUseNumber cannot be used with WithCodec.
+func WithUseNumber() Option {
+	return func(o *options) { o.useNumber = true }
+}
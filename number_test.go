@@ -0,0 +1,43 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUseNumber(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Extra map[string]any
+		Val   int
+	}
+	const doc = `{"Extra":{"id":9007199254740993,"price":0.10000000000000000555},"Val":1}`
+	path := filepath.Join(t.TempDir(), "testnumber.json")
+	if err := os.WriteFile(path, []byte(doc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Load[DB](path, WithUseNumber())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.Read(func(db *DB) {
+		if id, ok := db.Extra["id"].(json.Number); !ok || id != "9007199254740993" {
+			t.Errorf("id = %#v, want a json.Number", db.Extra["id"])
+		}
+	})
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"Extra":{"id":9007199254740993,"price":0.10000000000000000555},"Val":2}`
+	if string(b) != want {
+		t.Errorf("file = %s, want %s", b, want)
+	}
+}
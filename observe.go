@@ -0,0 +1,50 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import "time"
+
+// WithObserver tells o about each write of the file, so services that
+// keep their state in a JSONFile can export metrics and alert on slow
+// or failing persistence. jsonfilehttp.Metrics is an Observer that
+// serves Prometheus metrics.
+func WithObserver(o Observer) Option {
+	return func(opts *options) { opts.observe = o }
+}
+
+// An Observer is told about the writes of a JSONFile. It may be shared
+// by several files, so its methods are called concurrently. They are
+// called while the file is held for writing, so they should be quick.
+type Observer interface {
+	ObserveWrite(WriteObservation)
+}
+
+// A WriteObservation describes a write of the file.
+type WriteObservation struct {
+	Path string
+
+	// Duration is how long the write took, from the start of the
+	// Write that made it, including the time spent encoding the data.
+	Duration time.Duration
+
+	// Bytes is the size of the file written, or of the record
+	// appended to the journal of WithJournal, or 0 if Err is set.
+	Bytes int
+
+	// Err is the error writing the file, or nil. It wraps ErrConflict
+	// if the file was changed by another program.
+	Err error
+}
+
+// observeWrite reports a write of b bytes that started at start.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) observeWrite(start time.Time, bytes int, err error) {
+	if p.opts.observe == nil {
+		return
+	}
+	if err != nil {
+		bytes = 0
+	}
+	p.opts.observe.ObserveWrite(WriteObservation{Path: p.path, Duration: time.Since(start), Bytes: bytes, Err: err})
+}
@@ -0,0 +1,58 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type testObserver struct {
+	mu     sync.Mutex
+	writes []WriteObservation
+}
+
+func (o *testObserver) ObserveWrite(w WriteObservation) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.writes = append(o.writes, w)
+}
+
+func TestObserver(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "db.json")
+	obs := new(testObserver)
+	db, err := New[DB](path, WithObserver(obs), WithConflictDetection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	mustWrite(t, db, func(db *DB) {}) // no change, not written
+
+	other, err := Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, other, func(db *DB) { db.Val = 2 })
+	if err := db.Write(func(db *DB) error { db.Val = 3; return nil }); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Write err=%v, want ErrConflict", err)
+	}
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	if len(obs.writes) != 3 {
+		t.Fatalf("observed %d writes, want 3 (New, Write, conflict): %+v", len(obs.writes), obs.writes)
+	}
+	w := obs.writes[1]
+	if w.Path != path || w.Err != nil || w.Bytes != len(`{"Val":1}`) || w.Duration <= 0 {
+		t.Errorf("write observed as %+v", w)
+	}
+	if w := obs.writes[2]; !errors.Is(w.Err, ErrConflict) || w.Bytes != 0 {
+		t.Errorf("conflict observed as %+v", w)
+	}
+}
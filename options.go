@@ -0,0 +1,84 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"log/slog"
+	"time"
+)
+
+// An Option configures a JSONFile. Options are passed to New and Load.
+type Option func(*options)
+
+type options struct {
+	sync    SyncPolicy
+	syncDir bool
+	backup  backupOptions
+	lock    lockMode
+	churn   churnOptions
+	mirrors []mirror
+	gitRepo string
+	events  *eventSink
+	observe Observer
+	logger  *slog.Logger
+
+	recoveryBackup bool
+	rotatedBackups int
+	revisionFiles  int
+	history        bool
+	auditLog       string
+
+	groupCommit bool
+	autosave    time.Duration
+	journal     int64
+
+	fieldCodecs []fieldCodec
+	schema      schemaOptions
+	checksum    checksumOptions
+	unsigned    bool  // WithUnsignedFiles
+	codec       Codec // nil for encoding/json
+	hujson      bool
+	compression Compression
+	encryption  encryptionOptions
+	unencrypted bool // WithUnencryptedFiles
+	legacy      *legacyDecoder
+	validators  []func(any) error
+	hooks       []hooks
+	equal       func(old, new any) (bool, error)
+	equalSet    bool // equal is set by WithEqual, even to nil
+
+	jsonSchema    *jsonSchema
+	jsonSchemaErr error
+
+	writeMiddleware []func(WriteFunc) WriteFunc
+	readMiddleware  []func(ReadFunc) ReadFunc
+	readSampler     *readSampler
+	decodeStats     *decodeStats
+
+	pruneRules []PruneRule
+	diffs      bool
+	clock      Clock
+
+	unknownFields bool
+	strict        bool
+	useNumber     bool
+
+	detectConflicts bool
+	onCritical      func(error)
+	writeStats      bool
+	noEscapeHTML    bool
+	trailingNewline bool
+	canonical       bool
+	fsys            WriteFS // nil for the operating system's
+	replica         *replicaOptions
+	tracer          Tracer
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,117 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// WithoutHTMLEscaping writes the characters <, >, and & in strings as
+// they are, rather than as \u003c, \u003e, and \u0026, which encoding/json
+// uses so its output can be put in HTML. Files holding URLs or markup
+// are then easier for people and diff tools to read.
+func WithoutHTMLEscaping() Option {
+	return func(o *options) { o.noEscapeHTML = true }
+}
+
+// WithTrailingNewline ends the file with a newline, as text editors and
+// many tools expect. A file with HuJSON always ends with one.
+func WithTrailingNewline() Option {
+	return func(o *options) { o.trailingNewline = true }
+}
+
+// marshalJSON encodes v as JSON, as encoding/json, escaping HTML unless
+// WithoutHTMLEscaping is used.
+func (o *options) marshalJSON(v any) ([]byte, error) {
+	if !o.noEscapeHTML {
+		return json.Marshal(v)
+	}
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// WithCanonical writes the data in a canonical form: the members of
+// every object sorted by key, and numbers in the shortest form that
+// reads back as the same value, as in RFC 8785 (JSON Canonicalization
+// Scheme), except that integers too large for a float64 are kept
+// exactly. Equal data is then written as equal bytes by any version of
+// the program, for content-addressed storage and stable git diffs.
+//
+// WithCanonical cannot be used with WithCodec or WithHuJSON.
+func WithCanonical() Option {
+	return func(o *options) { o.canonical = true }
+}
+
+// canonicalize returns the JSON document b in canonical form, if
+// WithCanonical is used.
+func (o *options) canonicalize(b []byte) ([]byte, error) {
+	if !o.canonical {
+		return b, nil
+	}
+	v, err := jsonvalue.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return o.marshalJSON(canonicalNumbers(v)) // objects are encoded sorted by key
+}
+
+// canonicalNumbers replaces the numbers in v, decoded by jsonvalue.Decode,
+// with their canonical forms.
+func canonicalNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		return canonicalNumber(v)
+	case map[string]any:
+		for k, e := range v {
+			v[k] = canonicalNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = canonicalNumbers(e)
+		}
+	}
+	return v
+}
+
+// canonicalNumber returns the canonical form of n: an integer as its
+// digits, and any other number as the shortest decimal of its float64,
+// in exponent form if it is below 1e-6 or from 1e21.
+func canonicalNumber(n json.Number) json.Number {
+	s := string(n)
+	if len(strings.TrimLeft(strings.TrimPrefix(s, "-"), "0123456789")) == 0 {
+		if s = strings.TrimLeft(strings.TrimPrefix(s, "-"), "0"); s == "" {
+			return "0"
+		}
+		if n[0] == '-' {
+			s = "-" + s
+		}
+		return json.Number(s)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return n // out of range of a float64
+	}
+	if f == 0 {
+		return "0" // and not -0
+	}
+	if abs := math.Abs(f); abs < 1e-6 || abs >= 1e21 {
+		s = strconv.FormatFloat(f, 'e', -1, 64)
+		mant, exp, _ := strings.Cut(s, "e")
+		sign := exp[:1]
+		exp = strings.TrimLeft(exp[1:], "0")
+		return json.Number(mant + "e" + sign + exp)
+	}
+	return json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+}
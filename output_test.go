@@ -0,0 +1,94 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithoutHTMLEscaping(t *testing.T) {
+	t.Parallel()
+	type DB struct{ URL string }
+	const url = "https://example.com/?a=1&b=<2>"
+	for _, opts := range [][]Option{
+		{WithoutHTMLEscaping()},
+		{WithoutHTMLEscaping(), WithChecksum(nil)},
+	} {
+		path := filepath.Join(t.TempDir(), "testescape.json")
+		db, err := New[DB](path, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustWrite(t, db, func(db *DB) { db.URL = url })
+		db.Close()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(b, []byte(`"URL":"`+url+`"`)) {
+			t.Errorf("file = %s, want the URL unescaped", b)
+		}
+		db, err = Load[DB](path, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Read(func(db *DB) {
+			if db.URL != url {
+				t.Errorf("URL=%q, want %q", db.URL, url)
+			}
+		})
+		db.Close()
+	}
+}
+
+func TestTrailingNewline(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+	path := filepath.Join(t.TempDir(), "testnewline.json")
+	db, err := New[DB](path, WithTrailingNewline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if b, _ := os.ReadFile(path); string(b) != "{\"Val\":1}\n" {
+		t.Errorf("file = %q, want a trailing newline", b)
+	}
+	db.Close()
+
+	db, err = Load[DB](path, WithTrailingNewline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	gen := db.Stat().Generation
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if got := db.Stat().Generation; got != gen {
+		t.Errorf("Generation=%d after writing the same data, want %d", got, gen)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Zebra int
+		Apple map[string]any
+	}
+	path := filepath.Join(t.TempDir(), "testcanonical.json")
+	if err := os.WriteFile(path, []byte(`{"Apple":{"y":1.50,"x":[1E2,-0,1e-7,1e21,123456789012345678901234567890]}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Load[DB](path, WithCanonical(), WithUseNumber())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustWrite(t, db, func(db *DB) { db.Zebra = 1 })
+	const want = `{"Apple":{"x":[100,0,1e-7,1e+21,123456789012345678901234567890],"y":1.5},"Zebra":1}`
+	if b, _ := os.ReadFile(path); string(b) != want {
+		t.Errorf("file = %s, want %s", b, want)
+	}
+}
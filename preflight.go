@@ -0,0 +1,163 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// A PreflightReport is the result of Preflight.
+type PreflightReport struct {
+	Path   string
+	Exists bool // the file exists
+	Checks []PreflightCheck
+}
+
+// A PreflightCheck is one check made by Preflight.
+type PreflightCheck struct {
+	Name string // "options", "permissions", "space", "lock", "file", or "schema"
+	Err  error  // why the check failed, or nil
+}
+
+// Err returns the errors of the failed checks, joined, or nil if every
+// check passed.
+func (r PreflightReport) Err() error {
+	var errs []error
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, c.Err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("jsonfile.Preflight: %s: %w", r.Path, errors.Join(errs...))
+}
+
+// Preflight checks that the file at path can be opened by Load with
+// opts, without opening it, so a service can fail at startup with a
+// useful message before it serves traffic:
+//
+//	if err := jsonfile.Preflight[Data](path).Err(); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// It checks that the options can be used together, that the file and
+// its directory have the permissions a Write needs, that there is
+// space in the directory for another copy of the file, that the lock
+// requested by the options is free, and that the file decodes as Data
+// with a compatible schema fingerprint. Checks that do not apply to
+// the options, or to a file that does not exist, are left out. With
+// WithFS the file is checked in the WriteFS, and free space is not
+// checked.
+//
+// Preflight creates the lock file, if the options take a lock, and a
+// temporary file that it removes. Its checks can be invalidated by
+// other programs before Load is called.
+func Preflight[Data any](path string, opts ...Option) PreflightReport {
+	r := PreflightReport{Path: path}
+	add := func(name string, err error) {
+		r.Checks = append(r.Checks, PreflightCheck{Name: name, Err: err})
+	}
+	p := newJSONFile[Data](path, opts)
+	if err := p.opts.check(); err != nil {
+		add("options", err)
+		return r
+	}
+	add("options", nil)
+
+	fi, err := p.opts.fileSystem().Stat(path)
+	switch {
+	case err == nil:
+		r.Exists = true
+	case !errors.Is(err, fs.ErrNotExist):
+		add("permissions", err)
+		return r
+	}
+	add("permissions", p.checkPermissions())
+	if !p.readOnly && p.opts.fsys == nil {
+		var size int64
+		if r.Exists {
+			size = fi.Size()
+		}
+		add("space", checkSpace(filepath.Dir(path), size))
+	}
+	if p.opts.lock != lockNone {
+		err := p.lockFile()
+		if err == nil {
+			err = p.unlockFile()
+		}
+		add("lock", err)
+	}
+	if r.Exists {
+		checkSchema := p.opts.schema.enabled
+		fileErr, schemaErr := p.checkFile()
+		add("file", fileErr)
+		if checkSchema {
+			add("schema", schemaErr)
+		}
+	}
+	return r
+}
+
+// checkPermissions checks that the file can be read, and that the
+// files a Write creates can be created in its directory.
+func (p *JSONFile[Data]) checkPermissions() error {
+	fsys := p.opts.fileSystem()
+	f, err := fsys.Open(p.path)
+	if err == nil {
+		f.Close()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if p.readOnly {
+		return nil
+	}
+	dir, base := splitPath(fsys, p.path)
+	tmp, err := fsys.CreateTemp(dir, base+".tmp")
+	if err != nil {
+		return fmt.Errorf("cannot write files in directory: %w", err)
+	}
+	tmp.Close()
+	return fsys.Remove(tmp.Name())
+}
+
+// checkSpace checks that dir has room for a new file of size bytes,
+// as a Write needs while the old file is in place.
+func checkSpace(dir string, size int64) error {
+	free, err := diskFree(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if free <= uint64(size) {
+		return fmt.Errorf("%d bytes free in %s, the file is %d bytes", free, dir, size)
+	}
+	return nil
+}
+
+// checkFile reads the file from disk and decodes it, as Load does.
+// It reports a schema fingerprint that does not match the Data type
+// separately, as schemaErr, even if the options ask only for a warning.
+func (p *JSONFile[Data]) checkFile() (fileErr, schemaErr error) {
+	raw, err := fs.ReadFile(p.opts.fileSystem(), p.path)
+	if err != nil {
+		return err, nil
+	}
+	p.opts.schema.warn = nil
+	b, err := p.decodeFile(raw)
+	if errors.Is(err, ErrSchemaChanged) {
+		schemaErr = err
+		p.opts.schema.enabled = false
+		b, err = p.decodeFile(raw)
+	}
+	if err == nil {
+		err = p.opts.unmarshal(b, p.data)
+	}
+	return err, schemaErr
+}
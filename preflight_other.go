@@ -0,0 +1,10 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux && !darwin && !freebsd && !windows
+
+package jsonfile
+
+import "errors"
+
+func diskFree(dir string) (uint64, error) { return 0, errors.ErrUnsupported }
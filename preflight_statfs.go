@@ -0,0 +1,18 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build linux || darwin || freebsd
+
+package jsonfile
+
+import "syscall"
+
+// diskFree returns the bytes available to unprivileged users in the
+// file system holding dir.
+func diskFree(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
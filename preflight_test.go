@@ -0,0 +1,69 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testpreflight.json")
+	checks := func(r PreflightReport) (names []string) {
+		for _, c := range r.Checks {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	r := Preflight[DB](path)
+	if r.Exists || r.Err() != nil {
+		t.Errorf("missing file: Exists=%v, Err=%v", r.Exists, r.Err())
+	}
+
+	db, err := New[DB](path, WithExclusiveLock(), WithSchemaFingerprint(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = Preflight[DB](path, WithExclusiveLock(), WithSchemaFingerprint(nil))
+	if !r.Exists {
+		t.Error("Exists=false")
+	}
+	if got := checks(r); len(got) != 6 {
+		t.Errorf("checks=%v, want every check", got)
+	}
+	if err := r.Err(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Err=%v, want %v", err, ErrLocked)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Preflight[DB](path, WithExclusiveLock(), WithSchemaFingerprint(nil)).Err(); err != nil {
+		t.Errorf("after Close: %v", err)
+	}
+
+	type Other struct{ Name string }
+	if err := Preflight[Other](path, WithSchemaFingerprint(func(error) {})).Err(); !errors.Is(err, ErrSchemaChanged) {
+		t.Errorf("other type: Err=%v, want %v", err, ErrSchemaChanged)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"Val":`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	r = Preflight[DB](path)
+	if r.Err() == nil {
+		t.Error("truncated file passed")
+	}
+	for _, c := range r.Checks {
+		if (c.Err != nil) != (c.Name == "file") {
+			t.Errorf("check %s: %v", c.Name, c.Err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// A PruneRule removes old entries from an array or object in the
+// data, for Prune.
+type PruneRule struct {
+	path  string
+	prune func(v reflect.Value, now time.Time) (int, error)
+}
+
+// KeepLast is a PruneRule that keeps only the last n elements of the
+// array at the JSON Pointer path.
+func KeepLast(path string, n int) PruneRule {
+	return PruneRule{path: path, prune: func(v reflect.Value, now time.Time) (int, error) {
+		if v.Kind() != reflect.Slice {
+			return 0, fmt.Errorf("KeepLast: %s is %s, not an array", path, v.Type())
+		}
+		drop := v.Len() - n
+		if drop <= 0 {
+			return 0, nil
+		}
+		s := reflect.MakeSlice(v.Type(), n, n)
+		reflect.Copy(s, v.Slice(drop, v.Len()))
+		v.Set(s)
+		return drop, nil
+	}}
+}
+
+// DropOlderThan is a PruneRule that removes the elements of the array
+// or object at the JSON Pointer path whose time, at the JSON Pointer
+// timePath within the element, is more than age ago. The time must be
+// a time.Time. Elements without one, or with the zero time, are kept.
+func DropOlderThan(path, timePath string, age time.Duration) PruneRule {
+	return PruneRule{path: path, prune: func(v reflect.Value, now time.Time) (int, error) {
+		cutoff := now.Add(-age)
+		old := func(e reflect.Value) (bool, error) {
+			tv, err := lookupPointer(e, timePath)
+			if err != nil {
+				return false, nil // no time
+			}
+			if tv = derefValue(tv); !tv.IsValid() {
+				return false, nil
+			}
+			t, ok := tv.Interface().(time.Time)
+			if !ok {
+				return false, fmt.Errorf("DropOlderThan: %s%s is %s, not time.Time", path, timePath, tv.Type())
+			}
+			return !t.IsZero() && t.Before(cutoff), nil
+		}
+		dropped := 0
+		switch v.Kind() {
+		case reflect.Slice:
+			s := reflect.MakeSlice(v.Type(), 0, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				isOld, err := old(v.Index(i))
+				if err != nil {
+					return 0, err
+				}
+				if isOld {
+					dropped++
+				} else {
+					s = reflect.Append(s, v.Index(i))
+				}
+			}
+			if dropped > 0 {
+				v.Set(s)
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				isOld, err := old(iter.Value())
+				if err != nil {
+					return 0, err
+				}
+				if isOld {
+					v.SetMapIndex(iter.Key(), reflect.Value{})
+					dropped++
+				}
+			}
+		default:
+			return 0, fmt.Errorf("DropOlderThan: %s is %s, not an array or object", path, v.Type())
+		}
+		return dropped, nil
+	}}
+}
+
+// Prune applies rules to the data in a Write, so that arrays and
+// objects that grow with use do not grow without bound. It returns the
+// number of entries removed. If there were none, nothing is written.
+//
+// Rules given by WithPruning are applied by RunScrubber.
+func (p *JSONFile[Data]) Prune(ctx context.Context, rules ...PruneRule) (int, error) {
+	removed := 0
+	err := p.WriteCtx(ctx, func(data *Data) error {
+		removed = 0
+		now := time.Now()
+		for _, r := range rules {
+			v, err := lookupPointer(reflect.ValueOf(data), r.path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", r.path, err)
+			}
+			if v = derefValue(v); !v.IsValid() {
+				continue // null
+			}
+			if !v.CanSet() {
+				return fmt.Errorf("%s: cannot prune a value held in a map, use a map of pointers", r.path)
+			}
+			n, err := r.prune(v, now)
+			if err != nil {
+				return err
+			}
+			removed += n
+		}
+		if removed == 0 {
+			return SkipWrite
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("JSONFile.Prune: %w", err)
+	}
+	return removed, nil
+}
+
+// WithPruning has RunScrubber call Prune with rules each interval,
+// after it calls Scrub.
+func WithPruning(rules ...PruneRule) Option {
+	return func(o *options) { o.pruneRules = append(o.pruneRules, rules...) }
+}
+
+// runPrune applies the rules of WithPruning, for RunScrubber.
+func (p *JSONFile[Data]) runPrune(ctx context.Context) error {
+	if len(p.opts.pruneRules) == 0 || p.readOnly {
+		return nil
+	}
+	_, err := p.Prune(ctx, p.opts.pruneRules...)
+	if errors.Is(err, ErrClosed) {
+		return nil // stopping
+	}
+	return err
+}
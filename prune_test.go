@@ -0,0 +1,75 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+	type Session struct {
+		Created time.Time `json:"created"`
+	}
+	type DB struct {
+		Events   []string
+		Sessions map[string]*Session
+		Logins   []Session
+	}
+
+	path := filepath.Join(t.TempDir(), "testprune.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	old := now.Add(-100 * 24 * time.Hour)
+	mustWrite(t, db, func(db *DB) {
+		for i := 0; i < 10; i++ {
+			db.Events = append(db.Events, fmt.Sprint(i))
+		}
+		db.Sessions = map[string]*Session{"a": {Created: old}, "b": {Created: now}, "c": {}}
+		db.Logins = []Session{{Created: old}, {Created: now}}
+	})
+
+	ctx := context.Background()
+	rules := []PruneRule{
+		KeepLast("/Events", 3),
+		DropOlderThan("/Sessions", "/created", 90*24*time.Hour),
+		DropOlderThan("/Logins", "/created", 90*24*time.Hour),
+	}
+	n, err := db.Prune(ctx, rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 9 {
+		t.Errorf("Prune removed %d, want 9", n)
+	}
+	db.Read(func(db *DB) {
+		if fmt.Sprint(db.Events) != "[7 8 9]" {
+			t.Errorf("Events=%v", db.Events)
+		}
+		if len(db.Sessions) != 2 || db.Sessions["a"] != nil {
+			t.Errorf("Sessions=%v", db.Sessions)
+		}
+		if len(db.Logins) != 1 {
+			t.Errorf("Logins=%v", db.Logins)
+		}
+	})
+
+	gen := db.Stat().Generation
+	if n, err := db.Prune(ctx, rules...); err != nil || n != 0 {
+		t.Errorf("second Prune = %d, %v", n, err)
+	}
+	if db.Stat().Generation != gen {
+		t.Error("Prune with nothing to remove wrote the file")
+	}
+	if _, err := db.Prune(ctx, KeepLast("/Sessions", 1)); err == nil {
+		t.Error("KeepLast of an object succeeded")
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// WithRecoveryBackup keeps the previous version of the file, with
+// ".bak" appended to its path, each time a Write replaces it. The
+// copy is a hard link where the file system supports them, so it
+// costs little. LoadWithRecovery uses it when the file is corrupt.
+func WithRecoveryBackup() Option {
+	return func(o *options) { o.recoveryBackup = true }
+}
+
+// WithBackups keeps the n previous versions of the file each time a
+// Write replaces it, as the path with ".1" appended for the newest,
+// ".2" for the one before, and so on to ".n". This is insurance against
+// bugs that write bad data, which the data file alone cannot undo.
+// Like WithRecoveryBackup, the copies are hard links where possible,
+// and LoadWithRecovery uses them when the file is corrupt.
+func WithBackups(n int) Option {
+	return func(o *options) { o.rotatedBackups = n }
+}
+
+// keepPrevious keeps the file at p.path, if any, as the backups of
+// WithRecoveryBackup and WithBackups.
+// It is called by writeFile with p.writing held.
+func (p *JSONFile[Data]) keepPrevious() error {
+	if _, err := os.Stat(p.path); errors.Is(err, os.ErrNotExist) {
+		return nil // nothing to keep
+	}
+	if p.opts.recoveryBackup {
+		if err := linkFile(p.path, p.path+".bak"); err != nil {
+			return err
+		}
+	}
+	if n := p.opts.rotatedBackups; n > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", p.path, n))
+		for i := n - 1; i > 0; i-- {
+			err := os.Rename(fmt.Sprintf("%s.%d", p.path, i), fmt.Sprintf("%s.%d", p.path, i+1))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := linkFile(p.path, p.path+".1"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkFile replaces the file at dst with a hard link to src, or if
+// that is not possible, a copy of it.
+func linkFile(src, dst string) error {
+	tmp := dst + ".new"
+	os.Remove(tmp)
+	if err := os.Link(src, tmp); err != nil {
+		b, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		return atomicWrite(osFS{}, dst, b, false, false, nil)
+	}
+	return os.Rename(tmp, dst)
+}
+
+// recoveryPaths returns the copies LoadWithRecovery tries, in order.
+func (o *options) recoveryPaths(path string) []string {
+	var paths []string
+	if o.recoveryBackup {
+		paths = append(paths, path+".bak")
+	}
+	for i := 1; i <= o.rotatedBackups; i++ {
+		paths = append(paths, fmt.Sprintf("%s.%d", path, i))
+	}
+	return paths
+}
+
+// A Recovery reports how LoadWithRecovery loaded a file.
+type Recovery struct {
+	Path string // the copy the data was loaded from
+	Err  error  // why the file itself could not be loaded, or nil
+}
+
+// LoadWithRecovery is Load, except that if the file is corrupt, it
+// loads the newest good copy kept by WithRecoveryBackup or WithBackups,
+// one of which must be in opts. The corrupt file is then kept, with
+// ".corrupt" appended to its path, and replaced by the data recovered.
+// The Recovery reports which copy was used.
+func LoadWithRecovery[Data any](path string, opts ...Option) (*JSONFile[Data], Recovery, error) {
+	p, err := Load[Data](path, opts...)
+	if err == nil {
+		return p, Recovery{Path: path}, nil
+	}
+	if !errors.Is(err, ErrCorrupt) {
+		return nil, Recovery{}, err
+	}
+	rec := Recovery{Err: err}
+	p = newJSONFile[Data](path, opts)
+	paths := p.opts.recoveryPaths(path)
+	if len(paths) == 0 {
+		return nil, Recovery{}, err
+	}
+	if err := p.lockFile(); err != nil {
+		return nil, Recovery{}, fmt.Errorf("jsonfile.LoadWithRecovery: %w", err)
+	}
+	for _, rec.Path = range paths {
+		if err = p.recover(rec.Path); err == nil {
+			break
+		}
+		p.data = new(Data) // discard partial decoding
+	}
+	if err != nil {
+		p.unlockFile()
+		return nil, Recovery{}, fmt.Errorf("jsonfile.LoadWithRecovery: %v, recovery failed: %w", rec.Err, err)
+	}
+	p.event(Event{Event: "recovered", From: rec.Path, Error: rec.Err.Error()})
+	p.scheduledBackup(p.bytes)
+	return p, rec, nil
+}
+
+// recover loads the data from the copy at src, and rewrites the file.
+func (p *JSONFile[Data]) recover(src string) error {
+	raw, _, err := readFile(p.opts.fileSystem(), src)
+	if err != nil {
+		return err
+	}
+	if p.bytes, err = p.decodeFile(raw); err != nil {
+		return err
+	}
+	if p.opts.hujson {
+		p.hujsonText = raw
+	}
+	p.recordDecode(p.bytes)
+	if err := p.opts.checkJSONSchema(p.bytes); err != nil {
+		return err
+	}
+	if err := p.opts.unmarshal(p.bytes, p.data); err != nil {
+		return err
+	}
+	if err := p.rememberUnknown(p.bytes, p.data); err != nil {
+		return err
+	}
+	if err := p.opts.fileSystem().Rename(p.path, p.path+".corrupt"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := p.writeFile(p.bytes, false); err != nil {
+		return err
+	}
+	p.modTime, p.size = p.diskState.modTime, p.diskState.size
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadWithRecovery(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testrecovery.json")
+	opts := []Option{WithRecoveryBackup(), WithChecksum(nil)}
+	db, err := New[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+
+	db, rec, err := LoadWithRecovery[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Path != path || rec.Err != nil {
+		t.Errorf("Recovery=%+v, want the file", rec)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"jsonfile":1,"data":{"Val":`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	db, rec, err = LoadWithRecovery[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Path != path+".bak" || !errors.Is(rec.Err, ErrCorrupt) {
+		t.Errorf("Recovery=%+v, want the .bak copy", rec)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 1 {
+			t.Errorf("Val=%d after recovery, want the previous version, 1", db.Val)
+		}
+	})
+	if _, err := os.Stat(path + ".corrupt"); err != nil {
+		t.Errorf("corrupt file not kept: %v", err)
+	}
+	if _, err := Load[DB](path, opts...); err != nil {
+		t.Errorf("Load after recovery: %v", err)
+	}
+
+	// With the .bak copy corrupt too, recovery fails.
+	os.WriteFile(path, []byte("{"), 0666)
+	os.WriteFile(path+".bak", []byte("{"), 0666)
+	if _, _, err := LoadWithRecovery[DB](path, opts...); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("LoadWithRecovery of two corrupt copies err=%v, want %v", err, ErrCorrupt)
+	}
+}
+
+func TestBackups(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testbackups.json")
+	db, err := New[DB](path, WithBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		i := i
+		mustWrite(t, db, func(db *DB) { db.Val = i })
+	}
+	for _, tt := range []struct {
+		ext string
+		val int
+	}{{".1", 3}, {".2", 2}} {
+		old, err := Load[DB](path + tt.ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		old.Read(func(db *DB) {
+			if db.Val != tt.val {
+				t.Errorf("%s: Val=%d, want %d", tt.ext, db.Val, tt.val)
+			}
+		})
+	}
+	if _, err := os.Stat(path + ".3"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s.3 exists, want 2 backups", path)
+	}
+
+	// Recovery skips backups that are corrupt too.
+	os.WriteFile(path, []byte("{"), 0666)
+	os.WriteFile(path+".1", []byte("{"), 0666)
+	db, rec, err := LoadWithRecovery[DB](path, WithBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Path != path+".2" {
+		t.Errorf("recovered from %s, want %s.2", rec.Path, path)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 2 {
+			t.Errorf("Val=%d after recovery, want 2", db.Val)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// A Registry opens the JSONFiles of an application together. Each file
+// is declared with Register, then Init opens, migrates, and validates
+// them all, and Stats and Health report on them in one place.
+//
+//	var reg jsonfile.Registry
+//	var config *jsonfile.JSONFile[Config]
+//	jsonfile.Register(&reg, &config, jsonfile.Spec[Config]{
+//		Name: "config",
+//		Path: "config.json",
+//	})
+//	if err := reg.Init(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// The zero Registry is ready to use.
+type Registry struct {
+	mu      sync.Mutex
+	entries []registryEntry
+	opened  bool
+}
+
+// Spec describes a file for Register.
+type Spec[Data any] struct {
+	Name    string   // names the file in errors and Stats
+	Path    string   // path of the file
+	Options []Option // options for Load and New
+
+	// Create makes Init create the file with New if it does not exist.
+	Create bool
+
+	// Migrate, if set, is called by Init in a Write to bring the data up
+	// to date. It should return SkipWrite if there is nothing to do.
+	Migrate func(data *Data) error
+
+	// Validate, if set, is called by Init with the data, and an error
+	// from it fails Init.
+	Validate func(data *Data) error
+}
+
+// A RegistryStat describes a file of a Registry.
+type RegistryStat struct {
+	Name string
+	Path string
+	FileStat
+}
+
+type registryEntry interface {
+	name() string
+	open(ctx context.Context) error
+	stat() RegistryStat
+	scrub() error
+	close() error
+}
+
+// Register adds the file described by spec to r. Init sets *db to the
+// opened JSONFile. Register panics if called after Init, or if the
+// name is already registered.
+func Register[Data any](r *Registry, db **JSONFile[Data], spec Spec[Data]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.opened {
+		panic("jsonfile.Register: called after Init")
+	}
+	for _, e := range r.entries {
+		if e.name() == spec.Name {
+			panic(fmt.Sprintf("jsonfile.Register: %q registered twice", spec.Name))
+		}
+	}
+	r.entries = append(r.entries, &entry[Data]{spec: spec, db: db})
+}
+
+// Init opens each registered file, in the order registered. It runs
+// the file's Migrate, then its Validate. If any step fails, Init
+// closes the files it opened and returns the error.
+func (r *Registry) Init(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.opened {
+		return errors.New("jsonfile.Registry.Init: called twice")
+	}
+	for i, e := range r.entries {
+		err := ctx.Err()
+		if err == nil {
+			err = e.open(ctx)
+		}
+		if err != nil {
+			for _, e := range r.entries[:i] {
+				e.close()
+			}
+			return fmt.Errorf("jsonfile.Registry.Init: %s: %w", e.name(), err)
+		}
+	}
+	r.opened = true
+	return nil
+}
+
+// Stats reports the state of each file, in the order registered.
+func (r *Registry) Stats() []RegistryStat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.opened {
+		return nil
+	}
+	stats := make([]RegistryStat, len(r.entries))
+	for i, e := range r.entries {
+		stats[i] = e.stat()
+	}
+	return stats
+}
+
+// Health scrubs each file, as Scrub does without repair, and returns
+// the errors found, joined.
+func (r *Registry) Health() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.opened {
+		return errors.New("jsonfile.Registry.Health: not initialized")
+	}
+	var errs []error
+	for _, e := range r.entries {
+		if err := e.scrub(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes each file, and returns the errors, joined.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.opened {
+		return nil
+	}
+	var errs []error
+	for _, e := range r.entries {
+		if err := e.close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type entry[Data any] struct {
+	spec Spec[Data]
+	db   **JSONFile[Data]
+}
+
+func (e *entry[Data]) name() string { return e.spec.Name }
+
+func (e *entry[Data]) open(ctx context.Context) error {
+	db, err := Load[Data](e.spec.Path, e.spec.Options...)
+	if errors.Is(err, os.ErrNotExist) && e.spec.Create {
+		db, err = New[Data](e.spec.Path, e.spec.Options...)
+	}
+	if err != nil {
+		return err
+	}
+	if e.spec.Migrate != nil {
+		res, err := db.WriteInfo(ctx, e.spec.Migrate)
+		if err != nil {
+			db.Close()
+			return fmt.Errorf("migrate: %w", err)
+		}
+		if res.Changed {
+			db.event(Event{Event: "migrated"})
+		}
+	}
+	if e.spec.Validate != nil {
+		var err error
+		db.Read(func(data *Data) { err = e.spec.Validate(data) })
+		if err != nil {
+			db.Close()
+			return fmt.Errorf("validate: %w", err)
+		}
+	}
+	*e.db = db
+	return nil
+}
+
+func (e *entry[Data]) stat() RegistryStat {
+	return RegistryStat{Name: e.spec.Name, Path: e.spec.Path, FileStat: (*e.db).Stat()}
+}
+
+func (e *entry[Data]) scrub() error { return (*e.db).Scrub(false) }
+func (e *entry[Data]) close() error { return (*e.db).Close() }
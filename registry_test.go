@@ -0,0 +1,94 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+	type Config struct{ Version int }
+	type Users struct{ Names []string }
+
+	dir := t.TempDir()
+	var reg Registry
+	var config *JSONFile[Config]
+	var users *JSONFile[Users]
+	Register(&reg, &config, Spec[Config]{
+		Name:   "config",
+		Path:   filepath.Join(dir, "config.json"),
+		Create: true,
+		Migrate: func(c *Config) error {
+			if c.Version == 2 {
+				return SkipWrite
+			}
+			c.Version = 2
+			return nil
+		},
+	})
+	Register(&reg, &users, Spec[Users]{
+		Name:    "users",
+		Path:    filepath.Join(dir, "users.json"),
+		Options: []Option{WithExclusiveLock()},
+		Create:  true,
+	})
+	if err := reg.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	config.Read(func(c *Config) {
+		if c.Version != 2 {
+			t.Errorf("Version=%d after migration, want 2", c.Version)
+		}
+	})
+	mustWrite(t, users, func(u *Users) { u.Names = []string{"a"} })
+
+	stats := reg.Stats()
+	if len(stats) != 2 || stats[0].Name != "config" || stats[1].Name != "users" {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	if err := reg.Health(); err != nil {
+		t.Errorf("Health: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "users.json"), []byte("{}"), 0666)
+	if err := reg.Health(); !errors.Is(err, ErrDiverged) {
+		t.Errorf("Health err=%v, want %v", err, ErrDiverged)
+	}
+	if err := reg.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryInitError(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	dir := t.TempDir()
+	var reg Registry
+	var a, b *JSONFile[DB]
+	invalid := errors.New("invalid")
+	Register(&reg, &a, Spec[DB]{
+		Name:    "a",
+		Path:    filepath.Join(dir, "a.json"),
+		Options: []Option{WithExclusiveLock()},
+		Create:  true,
+	})
+	Register(&reg, &b, Spec[DB]{
+		Name:     "b",
+		Path:     filepath.Join(dir, "b.json"),
+		Create:   true,
+		Validate: func(*DB) error { return invalid },
+	})
+	if err := reg.Init(context.Background()); !errors.Is(err, invalid) {
+		t.Fatalf("Init err=%v, want %v", err, invalid)
+	}
+	// The file opened before the error was closed.
+	if _, err := Load[DB](filepath.Join(dir, "a.json"), WithExclusiveLock()); err != nil {
+		t.Errorf("Load after failed Init: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replicaRetry bounds the wait between attempts to ship a version
+// that failed.
+const (
+	replicaRetryMin = time.Second
+	replicaRetryMax = time.Minute
+)
+
+type replicaOptions struct {
+	store  Store
+	prefix string
+	keep   int
+}
+
+// WithReplica copies each version of the file written to disk to s in
+// the background, so the file can be rebuilt by Restore on another
+// host if this one is lost. Versions are named by prefix and a
+// sequence number, "prefix/0000000000000001", and the name of the
+// newest is kept in "prefix/latest". The newest keep versions are
+// kept, or all of them if keep is 0.
+//
+// Writes do not wait for their version to be shipped. If several are
+// written while one is shipped, only the newest of them is shipped
+// next, and a version that fails to ship is retried until a newer one
+// replaces it. Close ships the last version before it returns.
+// Failures are reported by an "unreplicated" Event.
+//
+// WithReplica cannot be used with WithJournal.
+func WithReplica(s Store, prefix string, keep int) Option {
+	return func(o *options) { o.replica = &replicaOptions{store: s, prefix: prefix, keep: keep} }
+}
+
+// Restore rebuilds the file at path from the newest version copied to
+// s by WithReplica with prefix, replacing any file at path. Load the
+// file after it is restored.
+func Restore(ctx context.Context, s Store, prefix, path string) error {
+	latest, _, err := s.Get(ctx, prefix+"/latest")
+	if err != nil {
+		return fmt.Errorf("jsonfile.Restore: %w", err)
+	}
+	b, _, err := s.Get(ctx, strings.TrimSpace(string(latest)))
+	if err != nil {
+		return fmt.Errorf("jsonfile.Restore: %w", err)
+	}
+	if err := atomicWrite(osFS{}, path, b, true, false, nil); err != nil {
+		return fmt.Errorf("jsonfile.Restore: %w", err)
+	}
+	return nil
+}
+
+// replicate queues the file contents b to be shipped to the replica.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) replicate(b []byte) {
+	if p.opts.replica == nil {
+		return
+	}
+	if p.replica == nil {
+		p.replica = newReplicator(*p.opts.replica, p.event)
+	}
+	p.replica.queue(b)
+}
+
+// A replicator ships versions of a file to a Store.
+type replicator struct {
+	replicaOptions
+	event func(Event)
+
+	mu      sync.Mutex
+	pending []byte // the newest version not shipped, or nil
+	queued  uint64 // versions queued, to tell whether pending is newer
+
+	seq uint64 // of the last version shipped, or 0 if not known, used by run
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newReplicator(o replicaOptions, event func(Event)) *replicator {
+	r := &replicator{
+		replicaOptions: o,
+		event:          event,
+		kick:           make(chan struct{}, 1),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *replicator) queue(b []byte) {
+	r.mu.Lock()
+	r.pending = b
+	r.queued++
+	r.mu.Unlock()
+	select {
+	case r.kick <- struct{}{}:
+	default:
+	}
+}
+
+// close ships the pending version, if any, and stops the replicator.
+func (r *replicator) close() {
+	if r == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+}
+
+func (r *replicator) run() {
+	defer close(r.done)
+	retry := replicaRetryMin
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-r.stop:
+			r.ship()
+			return
+		case <-r.kick:
+		case <-timer:
+		}
+		timer = nil
+		if err := r.ship(); err != nil {
+			timer = time.After(retry)
+			retry = min(2*retry, replicaRetryMax)
+		} else {
+			retry = replicaRetryMin
+		}
+	}
+}
+
+// ship puts the pending version, if any.
+func (r *replicator) ship() error {
+	r.mu.Lock()
+	b, n := r.pending, r.queued
+	r.mu.Unlock()
+	if b == nil {
+		return nil
+	}
+	if err := r.put(b); err != nil {
+		r.event(Event{Event: "unreplicated", Error: err.Error()})
+		return err
+	}
+	r.mu.Lock()
+	if r.queued == n {
+		r.pending = nil // no newer version was queued
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *replicator) put(b []byte) error {
+	ctx := context.Background()
+	if r.seq == 0 {
+		latest, _, err := r.store.Get(ctx, r.prefix+"/latest")
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		default:
+			name := strings.TrimSpace(string(latest))
+			seq, err := strconv.ParseUint(strings.TrimPrefix(name, r.prefix+"/"), 10, 64)
+			if err != nil {
+				return fmt.Errorf("replica %s/latest: bad version %q", r.prefix, name)
+			}
+			r.seq = seq
+		}
+	}
+	seq := r.seq + 1
+	name := r.version(seq)
+	if _, err := r.store.Put(ctx, name, b, ""); err != nil {
+		return err
+	}
+	if _, err := r.store.Put(ctx, r.prefix+"/latest", []byte(name+"\n"), ""); err != nil {
+		return err
+	}
+	r.seq = seq
+	if r.keep > 0 && seq > uint64(r.keep) {
+		r.store.Delete(ctx, r.version(seq-uint64(r.keep))) // best effort
+	}
+	return nil
+}
+
+func (r *replicator) version(seq uint64) string {
+	return fmt.Sprintf("%s/%016d", r.prefix, seq)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplica(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+	storeDir := t.TempDir()
+	store := DirStore(storeDir)
+
+	path := filepath.Join(t.TempDir(), "testreplica.json")
+	db, err := New[DB](path, WithReplica(store, "db", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		i := i
+		mustWrite(t, db, func(db *DB) { db.Val = i })
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Join(storeDir, "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 3 { // latest and 2 versions
+		t.Errorf("%d objects kept, want at most 3", len(entries))
+	}
+
+	latest := func() string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(storeDir, "db", "latest"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	first := latest()
+
+	// Restore the file on another host.
+	restored := filepath.Join(t.TempDir(), "restored.json")
+	if err := Restore(context.Background(), store, "db", restored); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Load[DB](restored, WithReplica(store, "db", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 3 {
+			t.Errorf("restored Val=%d, want 3", db.Val)
+		}
+	})
+
+	// Numbering continues from the newest version.
+	mustWrite(t, db, func(db *DB) { db.Val = 4 })
+	db.Close()
+	if got := latest(); got <= first {
+		t.Errorf("latest = %q after another write, want after %q", got, first)
+	}
+}
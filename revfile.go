@@ -0,0 +1,108 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WithRevisionFiles writes each version of the file to its own
+// revision file, the path with ".rev-NNNNNNNN.json" appended, and
+// points the path with ".current" appended at the newest one. The
+// pointer is a symbolic link to the revision file, or where symbolic
+// links cannot be made, a file holding its name followed by a newline.
+//
+// Revision files are never modified once written, so other programs
+// can read the data without taking part in any locking protocol:
+//
+//	cat state.json.current
+//
+// The data file itself is written as usual. Revision files are hard
+// links to it where the file system supports them. The newest keep
+// revision files are kept; keep is at least 2 so that a reader that
+// has just followed the pointer can still open the file.
+func WithRevisionFiles(keep int) Option {
+	return func(o *options) { o.revisionFiles = max(keep, 2) }
+}
+
+// revisionPath returns the name of revision file rev of path.
+func revisionPath(path string, rev uint64) string {
+	return fmt.Sprintf("%s.rev-%08d.json", path, rev)
+}
+
+// revisionFiles returns the revision numbers of the revision files of
+// path, in no particular order.
+func revisionFiles(path string) ([]uint64, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var revs []uint64
+	for _, e := range entries {
+		s, ok := strings.CutPrefix(e.Name(), base+".rev-")
+		if !ok {
+			continue
+		}
+		s, ok = strings.CutSuffix(s, ".json")
+		if !ok {
+			continue
+		}
+		rev, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// writeRevision makes tmp, the next version of the file, a new
+// revision file, points the current pointer at it, and removes
+// revision files beyond those kept.
+// It is called by writeFile before tmp is renamed over p.path,
+// with p.writing held.
+func (p *JSONFile[Data]) writeRevision(tmp string) error {
+	revs, err := revisionFiles(p.path)
+	if err != nil {
+		return err
+	}
+	var rev uint64
+	for _, r := range revs {
+		rev = max(rev, r)
+	}
+	rev++
+	name := revisionPath(p.path, rev)
+	if err := linkFile(tmp, name); err != nil {
+		return err
+	}
+	if err := setCurrent(p.path+".current", filepath.Base(name)); err != nil {
+		return err
+	}
+	revs = append(revs, rev)
+	for _, r := range revs {
+		if r+uint64(p.opts.revisionFiles) <= rev {
+			os.Remove(revisionPath(p.path, r))
+		}
+	}
+	return nil
+}
+
+// setCurrent atomically replaces the pointer at path with a symbolic
+// link to name, or a file holding name.
+func setCurrent(path, name string) error {
+	tmp := path + ".new"
+	os.Remove(tmp)
+	if err := os.Symlink(name, tmp); err != nil {
+		return atomicWrite(osFS{}, path, []byte(name+"\n"), false, false, nil)
+	}
+	return os.Rename(tmp, path)
+}
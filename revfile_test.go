@@ -0,0 +1,76 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRevisionFiles(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testrev.json")
+	db, err := New[DB](path, WithRevisionFiles(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		mustWrite(t, db, func(db *DB) { db.Val = i })
+	}
+	db.Close()
+
+	// New wrote revision 1, and the Writes revisions 2 through 5.
+	revs, err := revisionFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 3 {
+		t.Fatalf("revision files: %v, want 3", revs)
+	}
+	for _, rev := range []uint64{3, 4, 5} {
+		if _, err := os.Stat(revisionPath(path, rev)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	current := func() DB {
+		t.Helper()
+		b, err := os.ReadFile(path + ".current")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name, ok := strings.CutSuffix(string(b), "\n"); ok && !json.Valid(b) {
+			b, err = os.ReadFile(filepath.Join(filepath.Dir(path), name))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		var v DB
+		if err := json.Unmarshal(b, &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	if got := current(); got.Val != 4 {
+		t.Errorf("current Val=%d, want 4", got.Val)
+	}
+
+	// Numbering continues after the file is loaded again.
+	db, err = Load[DB](path, WithRevisionFiles(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 5 })
+	if _, err := os.Stat(revisionPath(path, 6)); err != nil {
+		t.Error(err)
+	}
+	if got := current(); got.Val != 5 {
+		t.Errorf("current Val=%d, want 5", got.Val)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// OpenRevision opens the data as it was at time at, from the newest
+// backup made by WithScheduledBackups at or before at. Revisions are
+// found by time because generations, as reported by Stat, start again
+// each time a file is loaded.
+//
+// The returned JSONFile is read-only: Read and the other read methods
+// work as they do on the live file, and Write returns ErrReadOnly.
+// It does not lock the backup and has only the options of p that
+// decode the file, such as WithFieldCodec.
+// If there is no backup at or before at, OpenRevision returns an error
+// that can be checked with errors.Is(err, os.ErrNotExist).
+func (p *JSONFile[Data]) OpenRevision(at time.Time) (*JSONFile[Data], error) {
+	if p.opts.backup.dir == "" {
+		return nil, errors.New("JSONFile.OpenRevision: no backup directory configured")
+	}
+	names, err := p.backupNames()
+	if err != nil {
+		return nil, fmt.Errorf("JSONFile.OpenRevision: %w", err)
+	}
+	i := -1
+	for j, name := range names {
+		if p.backupTime(name).After(at) {
+			break // names are sorted by time
+		}
+		i = j
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("JSONFile.OpenRevision: no backup at or before %s: %w", at.Format(time.RFC3339), os.ErrNotExist)
+	}
+
+	r := newJSONFile[Data](filepath.Join(p.opts.backup.dir, names[i]), nil)
+	r.readOnly = true
+	r.opts.fieldCodecs = p.opts.fieldCodecs
+	r.opts.schema = p.opts.schema
+	r.opts.codec = p.opts.codec
+	if isPatch(names[i]) {
+		r.bytes, err = p.readBackup(names, i)
+		r.modTime, r.size = p.backupTime(names[i]), int64(len(r.bytes))
+	} else {
+		r.bytes, r.diskState, err = readFile(osFS{}, r.path)
+		r.modTime, r.size = r.diskState.modTime, r.diskState.size
+	}
+	if err == nil {
+		r.bytes, err = p.decodeFile(r.bytes)
+	}
+	if err == nil {
+		err = r.opts.unmarshal(r.bytes, r.data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("JSONFile.OpenRevision: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenRevision(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	dir := t.TempDir()
+	backups := filepath.Join(dir, "backups")
+	db, err := New[DB](filepath.Join(dir, "testrevision.json"), WithScheduledBackups(backups, time.Hour, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().Add(-time.Second)
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if err := db.Backup(); err != nil {
+		t.Fatal(err)
+	}
+	mid := time.Now()
+	time.Sleep(time.Millisecond)
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+	if err := db.Backup(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		at   time.Time
+		want int
+	}{
+		{mid, 1},
+		{time.Now(), 2},
+	} {
+		r, err := db.OpenRevision(tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Read(func(db *DB) {
+			if db.Val != tt.want {
+				t.Errorf("OpenRevision(%v) Val=%d, want %d", tt.at, db.Val, tt.want)
+			}
+		})
+		if err := r.Write(func(db *DB) error { return nil }); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("Write err=%v, want %v", err, ErrReadOnly)
+		}
+	}
+
+	if _, err := db.OpenRevision(before.Add(-time.Hour)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenRevision before first backup err=%v, want %v", err, os.ErrNotExist)
+	}
+}
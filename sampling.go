@@ -0,0 +1,191 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+type readSampler struct {
+	rate float64
+
+	mu    sync.Mutex
+	paths map[string]uint64
+}
+
+// WithReadSampling records the JSON Pointers read by ReadPath in a
+// fraction rate, between 0 and 1, of its calls, and reports the counts
+// in the ReadPaths of Stat. Developers can use it to find which parts
+// of a large document are used, and so plan to split or prune it.
+func WithReadSampling(rate float64) Option {
+	return func(o *options) { o.readSampler = &readSampler{rate: rate} }
+}
+
+// record counts a read of pointer, if it is sampled. Array indexes
+// and map keys in pointer are counted together as "*".
+func (s *readSampler) record(v reflect.Value, pointer string) {
+	if s == nil || rand.Float64() >= s.rate {
+		return
+	}
+	var path strings.Builder
+	for _, tok := range strings.Split(pointer, "/")[1:] {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		path.WriteString("/")
+		if v.Kind() == reflect.Struct {
+			path.WriteString(jsonvalue.EscapePointer(jsonvalue.UnescapePointer(tok)))
+		} else {
+			path.WriteString("*")
+		}
+		if v, _ = lookupField(v, jsonvalue.UnescapePointer(tok)); !v.IsValid() {
+			break
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paths == nil {
+		s.paths = make(map[string]uint64)
+	}
+	s.paths[path.String()]++
+}
+
+func (s *readSampler) counts() map[string]uint64 {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := make(map[string]uint64, len(s.paths))
+	for k, v := range s.paths {
+		m[k] = v
+	}
+	return m
+}
+
+// ReadPath returns the value in the data at the JSON Pointer (RFC 6901)
+// pointer, as named by its JSON encoding, converted to T. Like the data
+// given to fn by Read, the value must not be modified.
+//
+// Reads made by ReadPath rather than Read can be counted by
+// WithReadSampling.
+func ReadPath[T any, Data any](p *JSONFile[Data], pointer string) (T, error) {
+	var res T
+	err := p.aroundRead(context.Background(), func(context.Context) error {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		root := reflect.ValueOf(p.data)
+		v, err := lookupPointer(root, pointer)
+		if err != nil {
+			return err
+		}
+		p.opts.readSampler.record(root, pointer)
+		t := reflect.TypeOf(&res).Elem()
+		for v.IsValid() && !v.Type().AssignableTo(t) {
+			if k := v.Kind(); k != reflect.Pointer && k != reflect.Interface {
+				return fmt.Errorf("value is %s, not %s", v.Type(), t)
+			}
+			v = v.Elem() // the invalid Value if nil
+		}
+		if v.IsValid() { // not null
+			reflect.ValueOf(&res).Elem().Set(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return res, fmt.Errorf("jsonfile.ReadPath: %s: %w", pointer, err)
+	}
+	return res, nil
+}
+
+// lookupPointer returns the value at pointer in v. A nil pointer or
+// interface on the way is the invalid Value.
+func lookupPointer(v reflect.Value, pointer string) (reflect.Value, error) {
+	if pointer == "" {
+		return v, nil
+	}
+	if pointer[0] != '/' {
+		return reflect.Value{}, fmt.Errorf("invalid JSON pointer")
+	}
+	for _, tok := range strings.Split(pointer[1:], "/") {
+		v = derefValue(v)
+		if !v.IsValid() {
+			return v, nil
+		}
+		var err error
+		if v, err = lookupField(v, jsonvalue.UnescapePointer(tok)); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return v, nil
+}
+
+func derefValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// lookupField returns the member of v named tok.
+func lookupField(v reflect.Value, tok string) (reflect.Value, error) {
+	v = derefValue(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		if f, ok := structField(v, tok); ok {
+			return f, nil
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if e := v.MapIndex(reflect.ValueOf(tok).Convert(v.Type().Key())); e.IsValid() {
+			return e, nil
+		}
+	case reflect.Slice, reflect.Array:
+		if i, err := strconv.Atoi(tok); err == nil && i >= 0 && i < v.Len() {
+			return v.Index(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("no member %q", tok)
+}
+
+// structField returns the field of the struct v that encoding/json
+// names name, looking in embedded structs.
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() && !f.Anonymous || tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			if e := derefValue(v.Field(i)); e.Kind() == reflect.Struct {
+				if fv, ok := structField(e, name); ok {
+					return fv, true
+				}
+			}
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if tag == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
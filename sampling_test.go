@@ -0,0 +1,64 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReadPath(t *testing.T) {
+	t.Parallel()
+	type User struct {
+		Name  string `json:"name"`
+		Admin bool   `json:"admin,omitempty"`
+	}
+	type Meta struct{ Version int }
+	type DB struct {
+		Meta
+		Users map[string]*User
+		Log   []string
+	}
+
+	path := filepath.Join(t.TempDir(), "testreadpath.json")
+	db, err := New[DB](path, WithReadSampling(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) {
+		db.Version = 3
+		db.Users = map[string]*User{"a": {Name: "Ann"}, "b/c": {Name: "Bo"}}
+		db.Log = []string{"x", "y"}
+	})
+
+	if v, err := ReadPath[int](db, "/Version"); err != nil || v != 3 {
+		t.Errorf("/Version = %v, %v", v, err)
+	}
+	if v, err := ReadPath[string](db, "/Users/a/name"); err != nil || v != "Ann" {
+		t.Errorf("/Users/a/name = %q, %v", v, err)
+	}
+	if v, err := ReadPath[string](db, "/Users/b~1c/name"); err != nil || v != "Bo" {
+		t.Errorf("/Users/b~1c/name = %q, %v", v, err)
+	}
+	if v, err := ReadPath[string](db, "/Log/1"); err != nil || v != "y" {
+		t.Errorf("/Log/1 = %q, %v", v, err)
+	}
+	if v, err := ReadPath[*User](db, "/Users/a"); err != nil || v.Name != "Ann" {
+		t.Errorf("/Users/a = %v, %v", v, err)
+	}
+	if _, err := ReadPath[string](db, "/Version"); err == nil {
+		t.Error("ReadPath of an int as a string succeeded")
+	}
+	if _, err := ReadPath[string](db, "/Users/z/name"); err == nil {
+		t.Error("ReadPath of a missing member succeeded")
+	}
+
+	got := db.Stat().ReadPaths
+	want := map[string]uint64{"/Version": 2, "/Users/*/name": 2, "/Log/*": 1, "/Users/*": 1}
+	for k, n := range want {
+		if got[k] != n {
+			t.Errorf("ReadPaths[%s]=%d, want %d (%v)", k, got[k], n, got)
+		}
+	}
+}
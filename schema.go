@@ -0,0 +1,331 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrSchemaChanged is returned by Load when the file was written by
+// a program whose Data type differs from this one's.
+var ErrSchemaChanged = errors.New("jsonfile: file written with a different Data type")
+
+type schemaOptions struct {
+	enabled bool
+	warn    func(error)
+}
+
+// WithSchemaFingerprint records a fingerprint of the Data type, made
+// from the JSON names and types of its fields, in the file on each
+// Write, and checks it when the file is read. This catches a binary
+// with an old or new Data type being deployed against a file by
+// mistake.
+//
+// If warn is nil, reading a file with a different fingerprint fails
+// with an error wrapping ErrSchemaChanged. Otherwise warn is called
+// with the error and the file is read as usual, and the next Write
+// records the new fingerprint. A file with no fingerprint is read
+// without a check.
+//
+// The fingerprint is kept in an envelope around the data, so the file
+// is no longer the plain encoding of Data.
+func WithSchemaFingerprint(warn func(error)) Option {
+	return func(o *options) { o.schema = schemaOptions{enabled: true, warn: warn} }
+}
+
+// checkSchema checks the fingerprint recorded in a file.
+func (p *JSONFile[Data]) checkSchema(stored string) error {
+	if !p.opts.schema.enabled || stored == "" || stored == p.fingerprint() {
+		return nil
+	}
+	err := fmt.Errorf("%w: file has schema %s, Data has %s", ErrSchemaChanged, stored, p.fingerprint())
+	if p.opts.schema.warn != nil {
+		p.opts.schema.warn(err)
+		return nil
+	}
+	return err
+}
+
+// fingerprint returns the fingerprint of the Data type.
+func (p *JSONFile[Data]) fingerprint() string {
+	return schemaOf(reflect.TypeOf(p.data).Elem(), make(map[reflect.Type]int)).Fingerprint()
+}
+
+// A Schema describes the parts of a Go type that affect its JSON
+// encoding, as fingerprinted by WithSchemaFingerprint. A program can
+// save the Schema of its Data type, encoded as JSON, for CheckCompat to
+// compare with the Data type of a later version.
+type Schema struct {
+	// Kind is the name of the reflect.Kind of the type, with pointers
+	// replaced by what they point to and arrays described as slices.
+	// It is "time" for time.Time, and "ref" for a struct already
+	// being described, such as in a recursive type.
+	Kind   string        `json:"kind"`
+	Elem   *Schema       `json:"elem,omitempty"`   // element of a slice or map
+	Key    *Schema       `json:"key,omitempty"`    // key of a map
+	Fields []SchemaField `json:"fields,omitempty"` // fields of a struct, in order
+	Ref    int           `json:"ref,omitempty"`    // for "ref", the struct, numbered from 0 in order of appearance
+
+	// Nullable and Marshal describe the encoding of the type for
+	// JSONSchema, and are not part of its fingerprint. Nullable is
+	// set for a pointer, and Marshal is "json" or "text" for a type
+	// encoded by its own MarshalJSON or MarshalText method.
+	Nullable bool   `json:"nullable,omitempty"`
+	Marshal  string `json:"marshal,omitempty"`
+}
+
+// A SchemaField is a field of a struct described by a Schema.
+type SchemaField struct {
+	Name string  `json:"name"` // JSON name
+	Type *Schema `json:"type"`
+}
+
+// SchemaOf returns the Schema of the type Data.
+func SchemaOf[Data any]() *Schema {
+	return schemaOf(reflect.TypeOf((*Data)(nil)).Elem(), make(map[reflect.Type]int))
+}
+
+// schemaOf returns the Schema of t. Structs already being described,
+// seen holds their numbers, are described as a reference.
+func schemaOf(t reflect.Type, seen map[reflect.Type]int) *Schema {
+	if n, ok := seen[t]; ok {
+		return &Schema{Kind: "ref", Ref: n}
+	}
+	if t.Kind() == reflect.Pointer {
+		s := schemaOf(t.Elem(), seen)
+		s.Nullable = true
+		return s
+	}
+	s := schemaOfKind(t, seen)
+	if s.Kind != "time" {
+		s.Marshal = marshalOf(t)
+	}
+	return s
+}
+
+func schemaOfKind(t reflect.Type, seen map[reflect.Type]int) *Schema {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return &Schema{Kind: "slice", Elem: schemaOf(t.Elem(), seen)}
+	case reflect.Map:
+		return &Schema{Kind: "map", Key: schemaOf(t.Key(), seen), Elem: schemaOf(t.Elem(), seen)}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Kind: "time"}
+		}
+		seen[t] = len(seen)
+		s := &Schema{Kind: "struct"}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() && !f.Anonymous || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			s.Fields = append(s.Fields, SchemaField{Name: name, Type: schemaOf(f.Type, seen)})
+		}
+		return s
+	default:
+		return &Schema{Kind: t.Kind().String()}
+	}
+}
+
+// marshalOf returns the Marshal of a Schema of t.
+func marshalOf(t reflect.Type) string {
+	pt := reflect.PointerTo(t)
+	switch {
+	case t.Implements(marshalerType) || pt.Implements(marshalerType):
+		return "json"
+	case t.Implements(textMarshalType) || pt.Implements(textMarshalType):
+		return "text"
+	}
+	return ""
+}
+
+// Fingerprint returns the fingerprint of the schema, as recorded in
+// files by WithSchemaFingerprint.
+func (s *Schema) Fingerprint() string {
+	var desc strings.Builder
+	s.describe(&desc)
+	sum := sha256.Sum256([]byte(desc.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// describe writes the description of s that is fingerprinted.
+func (s *Schema) describe(w *strings.Builder) {
+	switch s.Kind {
+	case "ref":
+		fmt.Fprintf(w, "#%d", s.Ref)
+	case "slice":
+		w.WriteString("[]")
+		s.Elem.describe(w)
+	case "map":
+		w.WriteString("map[")
+		s.Key.describe(w)
+		w.WriteString("]")
+		s.Elem.describe(w)
+	case "struct":
+		w.WriteString("{")
+		for _, f := range s.Fields {
+			w.WriteString(f.Name)
+			w.WriteString(" ")
+			f.Type.describe(w)
+			w.WriteString(";")
+		}
+		w.WriteString("}")
+	default:
+		w.WriteString(s.Kind)
+	}
+}
+
+// JSONSchema returns a JSON Schema (2020-12) of the JSON encoding of
+// the type described by s, for programs in other languages to read the
+// file, and for validation tools and WithJSONSchema. Members of objects
+// are not required, as decoding a JSON object does not require them,
+// and null is allowed where the type may encode as null.
+func (s *Schema) JSONSchema() []byte {
+	g := &jsonSchemaGen{refs: make(map[int]bool), defs: make(map[string]any)}
+	g.findRefs(s)
+	root := g.gen(s)
+	if m, ok := root.(map[string]any); ok && len(g.defs) > 0 {
+		m["$defs"] = g.defs
+	} else if len(g.defs) > 0 {
+		root = map[string]any{"allOf": []any{root}, "$defs": g.defs}
+	}
+	if m, ok := root.(map[string]any); ok {
+		m["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	}
+	b, _ := json.Marshal(root)
+	return b
+}
+
+type jsonSchemaGen struct {
+	refs    map[int]bool   // numbers of the structs referred to
+	defs    map[string]any // by name in $defs
+	structs int            // structs described so far
+}
+
+func (g *jsonSchemaGen) findRefs(s *Schema) {
+	if s == nil {
+		return
+	}
+	if s.Kind == "ref" {
+		g.refs[s.Ref] = true
+	}
+	g.findRefs(s.Key)
+	g.findRefs(s.Elem)
+	for _, f := range s.Fields {
+		g.findRefs(f.Type)
+	}
+}
+
+// gen returns the JSON Schema of s, as a value to encode.
+func (g *jsonSchemaGen) gen(s *Schema) any {
+	n := g.structs
+	v := g.genKind(s)
+	switch s.Marshal {
+	case "json":
+		v = true // encoded by its own method, to anything
+	case "text":
+		v = g.nullable(map[string]any{"type": "string"}, s.Nullable)
+	default:
+		return v
+	}
+	if s.Kind == "struct" && g.refs[n] {
+		g.defs[fmt.Sprintf("s%d", n)] = v
+	}
+	return v
+}
+
+func (g *jsonSchemaGen) genKind(s *Schema) any {
+	var m map[string]any
+	nullable := s.Nullable
+	switch {
+	case s.Kind == "ref":
+		m = map[string]any{"$ref": fmt.Sprintf("#/$defs/s%d", s.Ref)}
+	case s.Kind == "struct":
+		n := g.structs
+		g.structs++
+		props := make(map[string]any, len(s.Fields))
+		for _, f := range s.Fields {
+			props[f.Name] = g.gen(f.Type)
+		}
+		m = map[string]any{"type": "object", "properties": props}
+		if g.refs[n] {
+			g.defs[fmt.Sprintf("s%d", n)] = m
+			m = map[string]any{"$ref": fmt.Sprintf("#/$defs/s%d", n)}
+		}
+	case s.Kind == "slice" && s.Elem.Kind == "uint8" && s.Elem.Marshal == "":
+		m = map[string]any{"type": "string", "contentEncoding": "base64"}
+		nullable = true
+	case s.Kind == "slice":
+		m = map[string]any{"type": "array", "items": g.gen(s.Elem)}
+		nullable = true
+	case s.Kind == "map":
+		m = map[string]any{"type": "object", "additionalProperties": g.gen(s.Elem)}
+		if _, _, ok := intRange(s.Key.Kind); ok && s.Key.Marshal != "text" {
+			m["propertyNames"] = map[string]any{"pattern": "^-?[0-9]+$"}
+		}
+		nullable = true
+	case s.Kind == "time":
+		m = map[string]any{"type": "string", "format": "date-time"}
+	case s.Kind == "bool", s.Kind == "string":
+		m = map[string]any{"type": s.Kind}
+	case s.Kind == "float32", s.Kind == "float64":
+		m = map[string]any{"type": "number"}
+	default:
+		min, max, ok := intRange(s.Kind)
+		if !ok {
+			return true // an interface, or not encodable
+		}
+		m = map[string]any{"type": "integer", "minimum": min, "maximum": max}
+	}
+	return g.nullable(m, nullable)
+}
+
+// nullable returns the schema m, allowing null if it is set.
+func (g *jsonSchemaGen) nullable(m map[string]any, nullable bool) any {
+	if !nullable {
+		return m
+	}
+	if typ, ok := m["type"].(string); ok {
+		m["type"] = []string{typ, "null"}
+		return m
+	}
+	return map[string]any{"anyOf": []any{m, map[string]any{"type": "null"}}}
+}
+
+// intRange returns the range of the integer kind, as JSON numbers,
+// and reports whether kind is an integer kind.
+func intRange(kind string) (min, max json.Number, ok bool) {
+	unsigned := strings.HasPrefix(kind, "uint")
+	size, ok := strings.CutPrefix(strings.TrimPrefix(kind, "u"), "int")
+	if !ok {
+		return "", "", false
+	}
+	bits := 64
+	switch size {
+	case "", "ptr":
+	case "8", "16", "32", "64":
+		bits, _ = strconv.Atoi(size)
+	default:
+		return "", "", false
+	}
+	one := big.NewInt(1)
+	if unsigned {
+		return "0", json.Number(new(big.Int).Sub(new(big.Int).Lsh(one, uint(bits)), one).String()), true
+	}
+	lim := new(big.Int).Lsh(one, uint(bits-1))
+	return json.Number(new(big.Int).Neg(lim).String()), json.Number(new(big.Int).Sub(lim, one).String()), true
+}
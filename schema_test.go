@@ -0,0 +1,139 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaFingerprint(t *testing.T) {
+	t.Parallel()
+	type V1 struct{ Name string }
+	type V2 struct {
+		Name  string
+		Email string
+	}
+
+	path := filepath.Join(t.TempDir(), "testschema.json")
+	db, err := New[V1](path, WithSchemaFingerprint(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *V1) { db.Name = "a" })
+	if b, _ := os.ReadFile(path); !strings.HasPrefix(string(b), `{"jsonfile":1,"schema":"`) {
+		t.Errorf("file = %s, want an envelope", b)
+	}
+
+	// The same type loads, in another program or not.
+	if _, err := Load[V1](path, WithSchemaFingerprint(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load[V2](path, WithSchemaFingerprint(nil)); !errors.Is(err, ErrSchemaChanged) {
+		t.Fatalf("Load of V2 err=%v, want %v", err, ErrSchemaChanged)
+	}
+
+	var warned error
+	db2, err := Load[V2](path, WithSchemaFingerprint(func(err error) { warned = err }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(warned, ErrSchemaChanged) {
+		t.Errorf("warn called with %v, want %v", warned, ErrSchemaChanged)
+	}
+	db2.Read(func(db *V2) {
+		if db.Name != "a" {
+			t.Errorf("Name=%q, want a", db.Name)
+		}
+	})
+
+	// Without the option the envelope is not mistaken for data.
+	if db, err := Load[V2](path); err == nil {
+		db.Close()
+		t.Error("Load without fingerprint succeeded, want error")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+	type Node struct {
+		Name string `json:"name"`
+		Kids []*Node
+	}
+	type Renamed struct {
+		Name string `json:"title"`
+		Kids []*Renamed
+	}
+	type Other struct {
+		Title string `json:"title"`
+		Kids  []*Other
+		skip  int
+	}
+	a := (&JSONFile[Node]{data: new(Node)}).fingerprint()
+	b := (&JSONFile[Renamed]{data: new(Renamed)}).fingerprint()
+	c := (&JSONFile[Other]{data: new(Other)}).fingerprint()
+	if a == b {
+		t.Error("renaming a JSON field did not change the fingerprint")
+	}
+	if b != c {
+		t.Error("a type with the same JSON encoding has a different fingerprint")
+	}
+}
+
+func TestSchemaJSONSchema(t *testing.T) {
+	t.Parallel()
+	type Node struct {
+		Name string
+		Kids []*Node
+	}
+	type DB struct {
+		Root    Node
+		Count   int8
+		Ratio   *float64
+		When    time.Time
+		Blob    []byte
+		Addr    netip.Addr
+		ByID    map[int]string
+		Any     any
+		Skipped string `json:"-"`
+	}
+	schema := SchemaOf[DB]().JSONSchema()
+
+	path := filepath.Join(t.TempDir(), "testjsonschema.json")
+	db, err := New[DB](path, WithJSONSchema(schema))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, schema)
+	}
+	defer db.Close()
+	mustWrite(t, db, func(db *DB) {
+		db.Root = Node{Name: "a", Kids: []*Node{{Name: "b"}, nil}}
+		db.Count = -3
+		db.When = time.Now()
+		db.Blob = []byte("blob")
+		db.Addr = netip.MustParseAddr("127.0.0.1")
+		db.ByID = map[int]string{7: "seven"}
+		db.Any = []any{1, "two"}
+	})
+
+	s, err := compileJSONSchema(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range []string{
+		`{"Count":200}`,
+		`{"Root":{"Kids":[{"Name":1}]}}`,
+		`{"ByID":{"x":"y"}}`,
+		`{"Addr":{}}`,
+		`{"When":3}`,
+	} {
+		if s.validate([]byte(doc)) == nil {
+			t.Errorf("%s: valid, want invalid by schema\n%s", doc, schema)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// ErrDiverged is returned by Scrub when the file on disk does not match
+// the data held in memory.
+var ErrDiverged = errors.New("jsonfile: file on disk does not match memory")
+
+// Scrub re-reads the file and checks that it matches the data held in
+// memory, catching bit rot, truncation, and edits by other programs.
+// If it does not match, Scrub returns an error wrapping ErrDiverged.
+//
+// If repair is set, a diverged file is rewritten from memory. The error
+// still reports the divergence, along with any error rewriting it.
+func (p *JSONFile[Data]) Scrub(repair bool) error {
+	p.writing <- struct{}{}
+	defer func() { <-p.writing }()
+	if p.closed {
+		return fmt.Errorf("JSONFile.Scrub: %w", ErrClosed)
+	}
+	if err := p.flush(); err != nil {
+		return fmt.Errorf("JSONFile.Scrub: %w", err)
+	}
+
+	b, err := fs.ReadFile(p.opts.fileSystem(), p.path)
+	var decErr error
+	if err == nil {
+		b, decErr = p.decodeFile(b)
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		err = fmt.Errorf("%w: file missing", ErrDiverged)
+	case err != nil:
+		return fmt.Errorf("JSONFile.Scrub: %w", err)
+	case decErr != nil:
+		err = fmt.Errorf("%w: %v", ErrDiverged, decErr)
+	case p.sameData(b):
+		return nil
+	case p.opts.isJSON() && !json.Valid(b):
+		err = fmt.Errorf("%w: file is not valid JSON", ErrDiverged)
+	default:
+		err = fmt.Errorf("%w: file contents differ", ErrDiverged)
+	}
+	repaired := false
+	if repair && !p.readOnly {
+		if rerr := p.writeFile(p.bytes, false); rerr != nil {
+			err = fmt.Errorf("%w, repair failed: %v", err, rerr)
+		} else {
+			err = fmt.Errorf("%w, repaired", err)
+			repaired = true
+		}
+	}
+	if !repaired {
+		p.critical(fmt.Errorf("JSONFile.Scrub: %s: %w", p.path, err))
+	}
+	return fmt.Errorf("JSONFile.Scrub: %w", err)
+}
+
+// RunScrubber calls Scrub every interval until ctx is done or the
+// JSONFile is closed, passing each error it reports to onError. It
+// also applies any rules given by WithPruning.
+// RunScrubber blocks, so it is usually run in its own goroutine.
+func (p *JSONFile[Data]) RunScrubber(ctx context.Context, interval time.Duration, repair bool, onError func(error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.done:
+			return
+		case <-t.C:
+		}
+		if err := p.Scrub(repair); err != nil && onError != nil {
+			onError(err)
+		}
+		if err := p.runPrune(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScrub(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testscrub.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if err := db.Scrub(false); err != nil {
+		t.Fatalf("Scrub of good file: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"Val":`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Scrub(false); !errors.Is(err, ErrDiverged) {
+		t.Fatalf("Scrub err=%v, want %v", err, ErrDiverged)
+	}
+	if err := db.Scrub(true); !errors.Is(err, ErrDiverged) {
+		t.Fatalf("Scrub err=%v, want %v", err, ErrDiverged)
+	}
+	if b, _ := os.ReadFile(path); string(b) != `{"Val":1}` {
+		t.Errorf("file = %s after repair, want {\"Val\":1}", b)
+	}
+	if err := db.Scrub(false); err != nil {
+		t.Fatalf("Scrub after repair: %v", err)
+	}
+
+	os.Remove(path)
+	if err := db.Scrub(true); !errors.Is(err, ErrDiverged) {
+		t.Fatalf("Scrub of missing file err=%v, want %v", err, ErrDiverged)
+	}
+	if _, err := Load[DB](path); err != nil {
+		t.Errorf("Load after repair: %v", err)
+	}
+}
+
+func TestRunScrubber(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testscrub.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"Val":2}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		db.RunScrubber(ctx, time.Millisecond, true, func(err error) {
+			select {
+			case errc <- err:
+			default:
+			}
+		})
+	}()
+	if err := <-errc; !errors.Is(err, ErrDiverged) {
+		t.Errorf("scrubber reported %v, want %v", err, ErrDiverged)
+	}
+	cancel()
+	<-done
+}
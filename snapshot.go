@@ -0,0 +1,78 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Snapshot is an immutable view of the data in a JSONFile at one
+// generation. Create one with JSONFile.Snapshot.
+type Snapshot[Data any] struct {
+	data *Data
+	gen  uint64
+}
+
+// Snapshot returns a Snapshot of the current data.
+//
+// Reading a Snapshot takes no locks, so a slow reader, such as a report
+// generator, does not hold up Writes for its whole duration. Taking a
+// Snapshot does not copy the data: Write never modifies data that has
+// been read, it replaces it with a new copy.
+func (p *JSONFile[Data]) Snapshot() *Snapshot[Data] {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return &Snapshot[Data]{data: p.data, gen: p.gen}
+}
+
+// Read calls fn with the data in the snapshot. fn must not modify it.
+func (s *Snapshot[Data]) Read(fn func(data *Data)) {
+	fn(s.data)
+}
+
+// Generation returns the generation of the data in the snapshot.
+func (s *Snapshot[Data]) Generation() uint64 {
+	return s.gen
+}
+
+// ReadMany calls each of fns concurrently with the same copy of the
+// data, and returns their results in order. The lock is acquired once
+// for all of them, so ReadMany is cheaper than a series of Reads and
+// sees a consistent view of the data.
+//
+// If ctx is done before the fns are called, ReadMany returns an error
+// wrapping ctx.Err() and does not call them. If any of the fns panics,
+// ReadMany panics with the same value once all of them have returned.
+func (p *JSONFile[Data]) ReadMany(ctx context.Context, fns ...func(data *Data) any) ([]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("JSONFile.ReadMany: %w", err)
+	}
+	results := make([]any, len(fns))
+	err := p.aroundRead(ctx, func(context.Context) error {
+		data := p.Snapshot().data
+		var wg sync.WaitGroup
+		panics := make([]any, len(fns))
+		for i, fn := range fns {
+			wg.Add(1)
+			go func(i int, fn func(*Data) any) {
+				defer wg.Done()
+				defer func() { panics[i] = recover() }()
+				results[i] = fn(data)
+			}(i, fn)
+		}
+		wg.Wait()
+		for _, v := range panics {
+			if v != nil {
+				panic(v) // in the caller's goroutine, as Read would
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,89 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Vals []int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testsnapshot.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Vals = []int{1} })
+	snap := db.Snapshot()
+
+	// Writes proceed while the snapshot is being read,
+	// and do not change it.
+	snap.Read(func(data *DB) {
+		mustWrite(t, db, func(db *DB) { db.Vals = append(db.Vals, 2) })
+		if len(data.Vals) != 1 {
+			t.Errorf("snapshot Vals=%v, want [1]", data.Vals)
+		}
+	})
+	if snap.Generation() != 2 {
+		t.Errorf("snapshot Generation=%d, want 2", snap.Generation())
+	}
+	db.Read(func(db *DB) {
+		if len(db.Vals) != 2 {
+			t.Errorf("Vals=%v, want [1 2]", db.Vals)
+		}
+	})
+}
+
+func TestReadMany(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Name  string
+		Items []string
+	}
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testreadmany.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Name, db.Items = "list", []string{"a", "b"} })
+
+	res, err := db.ReadMany(context.Background(),
+		func(db *DB) any { return db.Name },
+		func(db *DB) any { return len(db.Items) },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0].(string) != "list" || res[1].(int) != 2 {
+		t.Errorf("ReadMany = %v, want [list 2]", res)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.ReadMany(ctx, func(db *DB) any {
+		t.Error("fn called with canceled context")
+		return nil
+	}); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadMany err=%v, want %v", err, context.Canceled)
+	}
+
+	// A panic in a fn reaches the caller, and leaves db usable.
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Errorf("recovered %v, want boom", v)
+			}
+		}()
+		db.ReadMany(context.Background(),
+			func(db *DB) any { return db.Name },
+			func(db *DB) any { panic("boom") },
+		)
+		t.Error("ReadMany returned after a panic")
+	}()
+	mustWrite(t, db, func(db *DB) { db.Name = "after" })
+}
@@ -0,0 +1,168 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FileStat describes the state of a JSONFile.
+type FileStat struct {
+	// Generation counts the changes made to the data since the
+	// JSONFile was created or loaded. It never decreases, so it can
+	// be used as a cheap check for changes or as an ETag.
+	Generation uint64
+
+	// ModTime is when the data last changed. For a loaded file that
+	// has not been written, it is the file's modification time.
+	ModTime time.Time
+
+	// Size is the size of the file on disk in bytes.
+	Size int64
+
+	// ReadPaths counts the JSON Pointers read by ReadPath, in the
+	// calls sampled by WithReadSampling. Array indexes and map keys
+	// are counted together as "*", so reads of "/Users/7/Name" are
+	// counted as "/Users/*/Name".
+	ReadPaths map[string]uint64
+
+	// UnknownFields and TypeMismatches count the members found by
+	// WithDecodeStats with no field in the Data type, and of the wrong
+	// type for their field, by JSON Pointer, with indexes and map keys
+	// counted together as for ReadPaths.
+	UnknownFields  map[string]uint64
+	TypeMismatches map[string]uint64
+
+	// WriteStats are the statistics of the writes of the file, kept
+	// in it by WithWriteStats.
+	WriteStats WriteStats
+
+	// Reads counts the calls that read the data, and Writes and
+	// WriteErrors the writes of the file that succeeded and failed,
+	// since the JSONFile was opened. LastWrite is when the file was
+	// last written by this JSONFile.
+	Reads       uint64
+	Writes      uint64
+	WriteErrors uint64
+	LastWrite   time.Time
+
+	// MarshalTime is the total time Writes have spent encoding the data.
+	MarshalTime time.Duration
+}
+
+// Stat returns the current state of the file.
+//
+// A FileStat encodes as JSON, so Stat can be published on a debug
+// endpoint with expvar:
+//
+//	expvar.Publish("db", expvar.Func(func() any { return db.Stat() }))
+func (p *JSONFile[Data]) Stat() FileStat {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	st := FileStat{Generation: p.gen, ModTime: p.modTime, Size: p.size, ReadPaths: p.opts.readSampler.counts(), WriteStats: p.writeStats}
+	st.UnknownFields, st.TypeMismatches = p.opts.decodeStats.counts()
+	p.counters.fill(&st)
+	return st
+}
+
+// ErrStale is returned by WriteIfGeneration when the data has changed
+// since the given generation.
+var ErrStale = errors.New("jsonfile: data changed since it was read")
+
+// ReadWithGeneration calls fn with the current copy of the data and
+// its generation, for a later WriteIfGeneration.
+func (p *JSONFile[Data]) ReadWithGeneration(fn func(data *Data, gen uint64)) {
+	p.aroundRead(context.Background(), func(context.Context) error {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		fn(p.data, p.gen)
+		return nil
+	})
+}
+
+// WriteIfGeneration is like Write, but only calls fn if the data is
+// still at generation gen. Otherwise it returns an error wrapping
+// ErrStale.
+//
+// Together with ReadWithGeneration it allows optimistic concurrency:
+// read the data, work on it without holding any lock, then write
+// the result only if nobody else has written in the meantime.
+func (p *JSONFile[Data]) WriteIfGeneration(gen uint64, fn func(*Data) error) error {
+	// Not part of a group commit: p.gen does not change between
+	// the fns in a group.
+	return p.aroundWrite(context.Background(), func(ctx context.Context) error {
+		_, err := p.writeAlone(ctx, func(data *Data) error {
+			// p.gen only changes while writing, which is held.
+			if p.gen != gen {
+				return fmt.Errorf("JSONFile.WriteIfGeneration: %w", ErrStale)
+			}
+			return fn(data)
+		})
+		return err
+	})
+}
+
+// Result describes a Write made by WriteInfo.
+type Result struct {
+	// Revision is the generation of the data after the Write,
+	// as reported by Stat.
+	Revision uint64
+
+	// BytesWritten is the number of bytes written to the file.
+	// It is zero if the data did not change, or if the write to
+	// the file was delayed by WithAutosave.
+	BytesWritten int
+
+	// Changed reports whether fn changed the data. If it did not,
+	// nothing was written.
+	Changed bool
+
+	// Duration is how long the Write took, including any time
+	// spent waiting for other Writes.
+	Duration time.Duration
+}
+
+// WriteInfo is like WriteCtx, but also reports what the Write did.
+// Callers can use the Result to skip work when nothing changed.
+//
+// WriteInfo is never part of a group commit, so that its Result
+// describes only its own fn.
+func (p *JSONFile[Data]) WriteInfo(ctx context.Context, fn func(*Data) error) (Result, error) {
+	start := time.Now()
+	if err := ctx.Err(); err != nil {
+		return Result{}, fmt.Errorf("JSONFile.Write: %w", err)
+	}
+	var res Result
+	err := p.aroundWrite(ctx, func(ctx context.Context) (err error) {
+		res, err = p.writeAlone(ctx, fn)
+		return err
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	res.Duration = time.Since(start)
+	return res, nil
+}
+
+// WaitForRevision blocks until the data reaches generation rev, as
+// reported by Stat, or ctx is done. Combined with Watch, it lets a
+// process wait to read a change written by another.
+func (p *JSONFile[Data]) WaitForRevision(ctx context.Context, rev uint64) error {
+	for {
+		p.mu.RLock()
+		gen, ch := p.gen, p.genCh
+		p.mu.RUnlock()
+		if gen >= rev {
+			return nil
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return fmt.Errorf("JSONFile.WaitForRevision: %w", ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStat(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "teststat.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := db.Stat()
+	if st.Generation != 1 || st.Size != int64(len(`{"Val":0}`)) || st.ModTime.IsZero() {
+		t.Errorf("Stat after New = %+v", st)
+	}
+
+	mustWrite(t, db, func(db *DB) { db.Val = 10 })
+	mustWrite(t, db, func(db *DB) {}) // no change
+	st2 := db.Stat()
+	if st2.Generation != 2 {
+		t.Errorf("Generation=%d, want 2", st2.Generation)
+	}
+	if st2.ModTime.Before(st.ModTime) {
+		t.Errorf("ModTime went backwards: %v then %v", st.ModTime, st2.ModTime)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st2.Size != fi.Size() {
+		t.Errorf("Size=%d, want %d", st2.Size, fi.Size())
+	}
+
+	db, err = Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := db.Stat(); st.Generation != 0 || !st.ModTime.Equal(fi.ModTime()) || st.Size != fi.Size() {
+		t.Errorf("Stat after Load = %+v, want generation 0, file mod time and size", st)
+	}
+}
+
+func TestWriteIfGeneration(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testcas.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var val int
+	var gen uint64
+	db.ReadWithGeneration(func(db *DB, g uint64) { val, gen = db.Val, g })
+
+	if err := db.WriteIfGeneration(gen, func(db *DB) error {
+		db.Val = val + 1
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// The same generation is now stale.
+	err = db.WriteIfGeneration(gen, func(db *DB) error {
+		t.Error("fn called for stale generation")
+		return nil
+	})
+	if !errors.Is(err, ErrStale) {
+		t.Fatalf("WriteIfGeneration err=%v, want %v", err, ErrStale)
+	}
+	db.ReadWithGeneration(func(db *DB, g uint64) {
+		if db.Val != 1 || g != gen+1 {
+			t.Errorf("Val=%d gen=%d, want 1, %d", db.Val, g, gen+1)
+		}
+	})
+}
+
+func TestWaitForRevision(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testwait.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := db.WaitForRevision(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- db.WaitForRevision(ctx, 3) }()
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	select {
+	case err := <-done:
+		t.Fatalf("returned early at generation 2: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := db.WaitForRevision(ctx, 100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForRevision err=%v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWriteInfo(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testinfo.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := db.Stat().Generation
+	ctx := context.Background()
+
+	res, err := db.WriteInfo(ctx, func(db *DB) error {
+		db.Val = 1
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Changed || res.Revision != gen+1 || res.BytesWritten != len(`{"Val":1}`) {
+		t.Errorf("Result=%+v, want changed at revision %d", res, gen+1)
+	}
+
+	res, err = db.WriteInfo(ctx, func(db *DB) error {
+		db.Val = 1
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Changed || res.Revision != gen+1 || res.BytesWritten != 0 {
+		t.Errorf("no-op Result=%+v, want unchanged at revision %d", res, gen+1)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A Store keeps whole objects by name, as object storage such as S3 or
+// GCS does. It is a simpler way than WriteFS to keep a JSONFile
+// somewhere other than a local file system, used with WithStore.
+//
+// A Store need not support renames or partial writes: a JSONFile writes
+// its file with one Put, so a Store whose Put is atomic, as object
+// storage PUTs are, replaces the file atomically.
+type Store interface {
+	// Get returns the contents of the object name and its version,
+	// or an error wrapping fs.ErrNotExist if there is none.
+	Get(ctx context.Context, name string) (b []byte, version string, err error)
+
+	// Put replaces the object name with b and returns its new version.
+	// If ifVersion is not empty and the object's version is not
+	// ifVersion, Put fails with an error wrapping ErrConflict, as a
+	// conditional PUT with If-Match does.
+	Put(ctx context.Context, name string, b []byte, ifVersion string) (version string, err error)
+
+	// Delete removes the object name.
+	Delete(ctx context.Context, name string) error
+}
+
+// WithStore keeps the file in s, with the path given to New or Load as
+// the object's name. Each Write puts the object on the condition that
+// it is still the version last read or written by this JSONFile, so
+// a Write never overwrites the change of another program; it fails
+// with an error wrapping ErrConflict instead.
+//
+// Calls to s are made with context.Background(). WithStore is WithFS
+// of s, and the same options cannot be used with it.
+func WithStore(s Store) Option {
+	return WithFS(&storeFS{store: s, versions: make(map[string]string), temps: make(map[string][]byte)})
+}
+
+// storeFS is the WriteFS of a Store. Temporary files are kept in
+// memory until they are renamed, which puts them.
+type storeFS struct {
+	store Store
+
+	mu       sync.Mutex
+	versions map[string]string // of the objects last read or put, by name
+	temps    map[string][]byte // written temporary files, by name
+	n        int               // temporary files made
+}
+
+func (s *storeFS) Open(name string) (fs.File, error) {
+	b, version, err := s.store.Get(context.Background(), name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	s.mu.Lock()
+	s.versions[name] = version
+	s.mu.Unlock()
+	return &storeObject{Reader: bytes.NewReader(b), info: storeInfo{name: path.Base(name), size: int64(len(b))}}, nil
+}
+
+func (s *storeFS) Stat(name string) (fs.FileInfo, error) {
+	s.mu.Lock()
+	b, ok := s.temps[name]
+	s.mu.Unlock()
+	if !ok {
+		var err error
+		if b, _, err = s.store.Get(context.Background(), name); err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+		}
+	}
+	return storeInfo{name: path.Base(name), size: int64(len(b))}, nil
+}
+
+func (s *storeFS) CreateTemp(dir, prefix string) (File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	return &storeTemp{fs: s, name: path.Join(dir, fmt.Sprintf("%s%d", prefix, s.n))}, nil
+}
+
+func (s *storeFS) Rename(oldname, newname string) error {
+	ctx := context.Background()
+	s.mu.Lock()
+	b, ok := s.temps[oldname]
+	ifVersion := s.versions[newname]
+	s.mu.Unlock()
+	if !ok {
+		// Not a temporary file, as for Delete: copy the object.
+		var err error
+		if b, _, err = s.store.Get(ctx, oldname); err != nil {
+			return &fs.PathError{Op: "rename", Path: oldname, Err: err}
+		}
+		ifVersion = ""
+	}
+	version, err := s.store.Put(ctx, newname, b, ifVersion)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: newname, Err: err}
+	}
+	s.mu.Lock()
+	delete(s.temps, oldname)
+	s.versions[newname] = version
+	s.mu.Unlock()
+	if !ok {
+		return s.Remove(oldname)
+	}
+	return nil
+}
+
+func (s *storeFS) Remove(name string) error {
+	s.mu.Lock()
+	_, ok := s.temps[name]
+	delete(s.temps, name)
+	delete(s.versions, name)
+	s.mu.Unlock()
+	if ok {
+		return nil
+	}
+	if err := s.store.Delete(context.Background(), name); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+// storeTemp is a temporary file of a storeFS.
+type storeTemp struct {
+	fs   *storeFS
+	name string
+	buf  bytes.Buffer
+}
+
+func (f *storeTemp) Write(b []byte) (int, error) { return f.buf.Write(b) }
+func (f *storeTemp) Sync() error                 { return nil }
+func (f *storeTemp) Name() string                { return f.name }
+
+func (f *storeTemp) Close() error {
+	f.fs.mu.Lock()
+	f.fs.temps[f.name] = f.buf.Bytes()
+	f.fs.mu.Unlock()
+	return nil
+}
+
+// storeObject is an object of a Store opened for reading.
+type storeObject struct {
+	*bytes.Reader
+	info storeInfo
+}
+
+func (o *storeObject) Stat() (fs.FileInfo, error) { return o.info, nil }
+func (o *storeObject) Close() error               { return nil }
+
+// storeInfo describes an object of a Store. Stores do not report
+// modification times, so a JSONFile compares the contents of objects
+// to find changes.
+type storeInfo struct {
+	name string
+	size int64
+}
+
+func (i storeInfo) Name() string       { return i.name }
+func (i storeInfo) Size() int64        { return i.size }
+func (i storeInfo) Mode() fs.FileMode  { return 0666 }
+func (i storeInfo) ModTime() time.Time { return time.Time{} }
+func (i storeInfo) IsDir() bool        { return false }
+func (i storeInfo) Sys() any           { return nil }
+
+// DirStore returns a Store that keeps objects as files in the directory
+// dir, for WithStore in development and tests. The version of an object
+// is the SHA-256 of its contents. Puts are atomic, but their condition
+// is checked only just before, as by WithConflictDetection.
+func DirStore(dir string) Store {
+	return dirStore(dir)
+}
+
+type dirStore string
+
+func (d dirStore) path(name string) string { return filepath.Join(string(d), filepath.FromSlash(name)) }
+
+func (d dirStore) Get(ctx context.Context, name string) ([]byte, string, error) {
+	b, err := os.ReadFile(d.path(name))
+	if err != nil {
+		return nil, "", err
+	}
+	return b, contentVersion(b), nil
+}
+
+func (d dirStore) Put(ctx context.Context, name string, b []byte, ifVersion string) (string, error) {
+	if ifVersion != "" {
+		cur, err := os.ReadFile(d.path(name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if err != nil || contentVersion(cur) != ifVersion {
+			return "", ErrConflict
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(d.path(name)), 0777); err != nil {
+		return "", err
+	}
+	if err := atomicWrite(osFS{}, d.path(name), b, true, false, nil); err != nil {
+		return "", err
+	}
+	return contentVersion(b), nil
+}
+
+func (d dirStore) Delete(ctx context.Context, name string) error {
+	return os.Remove(d.path(name))
+}
+
+func contentVersion(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
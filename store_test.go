@@ -0,0 +1,63 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+	dir := t.TempDir()
+	store := DirStore(dir)
+	db, err := New[DB]("db.json", WithStore(store), WithChecksum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	if err := Verify(filepath.Join(dir, "db.json")); err != nil {
+		t.Error(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files in the store directory, want 1", len(entries))
+	}
+
+	db2, err := Load[DB]("db.json", WithStore(store), WithChecksum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	db2.Read(func(db *DB) {
+		if db.Val != 1 {
+			t.Errorf("Val=%d, want 1", db.Val)
+		}
+	})
+	mustWrite(t, db2, func(db *DB) { db.Val = 2 })
+
+	// The first JSONFile has not read the second's change.
+	if err := db.Write(func(db *DB) error { db.Val = 3; return nil }); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Write over another's change err=%v, want %v", err, ErrConflict)
+	}
+	if err := db.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val++ })
+	db.Read(func(db *DB) {
+		if db.Val != 3 {
+			t.Errorf("Val=%d after Reload and increment, want 3", db.Val)
+		}
+	})
+
+	if err := db2.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "db.json.deleted")); err != nil {
+		t.Errorf("Delete left no deleted copy: %v", err)
+	}
+}
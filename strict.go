@@ -0,0 +1,20 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+// WithStrictDecoding makes decoding the file fail if it has object
+// members the Data type has no field for, so typos and data written by
+// other versions of the program are caught when the file is loaded or
+// reloaded, instead of being dropped by the next Write.
+func WithStrictDecoding() Option {
+	return func(o *options) { o.strict = true }
+}
+
+// unmarshalStrict decodes b into v, rejecting unknown object members.
+func (o *options) unmarshalStrict(b []byte, v any) error {
+	if _, ok := o.codec.(jsonV2Codec); ok {
+		return unmarshalV2Strict(b, v)
+	}
+	return o.decode(b, v)
+}
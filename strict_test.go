@@ -0,0 +1,53 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStrictDecoding(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Name  string
+		Inner struct{ Val int }
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "teststrict.json")
+	if err := os.WriteFile(path, []byte(`{"Name":"a","Inner":{"Vall":1}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load[DB](path, WithStrictDecoding()); err == nil || !strings.Contains(err.Error(), `unknown field "Vall"`) {
+		t.Errorf("Load: %v, want unknown field error", err)
+	}
+	db, err := Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := os.WriteFile(path, []byte(`{"Name":"a","Inner":{"Val":1}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Load[DB](path, WithStrictDecoding())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustWrite(t, db, func(db *DB) { db.Name = "b" })
+	if err := os.WriteFile(path, []byte(`{"Nmae":"c"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Reload(); err == nil {
+		t.Error("Reload: no error for an unknown field")
+	}
+
+	if _, err := New[DB](filepath.Join(dir, "other.json"), WithStrictDecoding(), WithUnknownFields()); err == nil {
+		t.Error("WithStrictDecoding and WithUnknownFields: no error")
+	}
+}
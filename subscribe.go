@@ -0,0 +1,73 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+// subscribeBuffer is the number of events buffered for a subscriber.
+const subscribeBuffer = 16
+
+// A ChangeEvent reports a change to the data in a JSONFile.
+type ChangeEvent[Data any] struct {
+	// Generation counts the changes made to the data since the
+	// JSONFile was created or loaded. A gap between the generations
+	// of two events means events were dropped.
+	Generation uint64
+
+	// Data is the new data. It is shared with readers of the
+	// JSONFile and must not be modified.
+	Data *Data
+
+	// Diff is the change from the data of the generation before,
+	// if the JSONFile was opened WithDiffs. A subscriber that sees a
+	// gap in generations has missed changes, and must read Data.
+	Diff []PatchOp
+
+	// Stamp is the stamp of the change, if the JSONFile was opened
+	// WithClock.
+	Stamp Stamp
+}
+
+// Subscribe returns a channel that receives an event each time the
+// data changes, by a Write or by reloading the file. The returned
+// function cancels the subscription and closes the channel.
+//
+// Events are sent without blocking the Write. If the subscriber falls
+// too far behind, events are dropped, which shows up as a gap in the
+// Generation numbers.
+func (p *JSONFile[Data]) Subscribe() (<-chan ChangeEvent[Data], func()) {
+	ch := make(chan ChangeEvent[Data], subscribeBuffer)
+	p.subMu.Lock()
+	if p.subs == nil {
+		p.subs = make(map[chan ChangeEvent[Data]]struct{})
+	}
+	p.subs[ch] = struct{}{}
+	p.subMu.Unlock()
+
+	cancel := func() {
+		p.subMu.Lock()
+		defer p.subMu.Unlock()
+		if _, ok := p.subs[ch]; ok {
+			delete(p.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// subscribed reports whether there are any subscribers.
+func (p *JSONFile[Data]) subscribed() bool {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	return len(p.subs) > 0
+}
+
+func (p *JSONFile[Data]) notify(ev ChangeEvent[Data]) {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	for ch := range p.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
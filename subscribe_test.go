@@ -0,0 +1,59 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testsubscribe.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, cancel := db.Subscribe()
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	mustWrite(t, db, func(db *DB) {}) // no change, no event
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+
+	for _, want := range []int{1, 2} {
+		ev := <-ch
+		if ev.Data.Val != want {
+			t.Errorf("event Val=%d, want %d", ev.Data.Val, want)
+		}
+		if ev.Generation != uint64(want)+1 { // New wrote generation 1
+			t.Errorf("event Generation=%d, want %d", ev.Generation, want+1)
+		}
+	}
+
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed by cancel")
+	}
+	cancel() // idempotent
+	mustWrite(t, db, func(db *DB) { db.Val = 3 })
+}
+
+func TestSubscribeDrop(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	db, err := New[DB](filepath.Join(t.TempDir(), "testsubscribe.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, cancel := db.Subscribe()
+	defer cancel()
+	for i := 1; i <= subscribeBuffer+5; i++ {
+		mustWrite(t, db, func(db *DB) { db.Val = i })
+	}
+	if n := len(ch); n != subscribeBuffer {
+		t.Errorf("%d buffered events, want %d", n, subscribeBuffer)
+	}
+}
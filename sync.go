@@ -0,0 +1,78 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"fmt"
+	"time"
+)
+
+// SyncPolicy controls when Write calls fsync on the new file before
+// renaming it into place.
+//
+// Without an fsync, a rename can reach the disk before the file
+// contents, so a power failure may leave an empty or partial file.
+// An fsync costs a disk flush per Write, which is slow on some systems.
+type SyncPolicy struct {
+	every    bool
+	interval time.Duration
+}
+
+var (
+	// SyncNone never calls fsync, leaving durability to the OS.
+	// It is the default.
+	SyncNone = SyncPolicy{}
+
+	// SyncAlways calls fsync on every Write.
+	SyncAlways = SyncPolicy{every: true}
+)
+
+// SyncInterval calls fsync on a Write only if at least d has passed
+// since the last fsync. It bounds the rate of disk flushes for
+// programs that write often.
+func SyncInterval(d time.Duration) SyncPolicy {
+	if d <= 0 {
+		return SyncAlways
+	}
+	return SyncPolicy{interval: d}
+}
+
+func (s SyncPolicy) String() string {
+	switch {
+	case s.every:
+		return "SyncAlways"
+	case s.interval > 0:
+		return fmt.Sprintf("SyncInterval(%v)", s.interval)
+	default:
+		return "SyncNone"
+	}
+}
+
+// WithSync sets the SyncPolicy used by Write.
+func WithSync(policy SyncPolicy) Option {
+	return func(o *options) { o.sync = policy }
+}
+
+// WithSyncDir makes Write fsync the file's directory after renaming
+// the new file into place, so the rename itself survives a power
+// failure. The directory is synced on the Writes that fsync the file
+// under the SyncPolicy, so WithSyncDir has no effect with SyncNone.
+//
+// Directory sync is not supported on Windows, where it is a no-op.
+func WithSyncDir() Option {
+	return func(o *options) { o.syncDir = true }
+}
+
+// shouldSync reports whether a write at time now should fsync,
+// given the time of the last fsync.
+func (s SyncPolicy) shouldSync(last, now time.Time) bool {
+	switch {
+	case s.every:
+		return true
+	case s.interval > 0:
+		return now.Sub(last) >= s.interval
+	default:
+		return false
+	}
+}
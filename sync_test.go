@@ -0,0 +1,71 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncPolicy(t *testing.T) {
+	t.Parallel()
+	for _, policy := range []SyncPolicy{SyncNone, SyncAlways, SyncInterval(time.Hour)} {
+		for _, syncDir := range []bool{false, true} {
+			policy, syncDir := policy, syncDir
+			name := policy.String()
+			opts := []Option{WithSync(policy)}
+			if syncDir {
+				name += "/SyncDir"
+				opts = append(opts, WithSyncDir())
+			}
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				testSyncPolicy(t, opts)
+			})
+		}
+	}
+}
+
+func testSyncPolicy(t *testing.T, opts []Option) {
+	type DB struct{ Val int }
+	path := filepath.Join(t.TempDir(), "testsync.json")
+	db, err := New[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+
+	db, err = Load[DB](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 2 {
+			t.Errorf("Val = %d, want 2", db.Val)
+		}
+	})
+}
+
+func TestSyncInterval(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	policy := SyncInterval(time.Minute)
+	if !policy.shouldSync(time.Time{}, now) {
+		t.Errorf("first write did not sync")
+	}
+	if policy.shouldSync(now.Add(-time.Second), now) {
+		t.Errorf("write within interval synced")
+	}
+	if !policy.shouldSync(now.Add(-2*time.Minute), now) {
+		t.Errorf("write after interval did not sync")
+	}
+	if SyncNone.shouldSync(time.Time{}, now) {
+		t.Errorf("SyncNone synced")
+	}
+	if SyncInterval(0) != SyncAlways {
+		t.Errorf("SyncInterval(0) = %v, want SyncAlways", SyncInterval(0))
+	}
+}
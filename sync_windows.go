@@ -0,0 +1,7 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+// syncDir is a no-op: Windows does not support fsync on directories.
+func syncDir(dir string) error { return nil }
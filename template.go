@@ -0,0 +1,96 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"regexp"
+
+	"crawshaw.dev/jsonfile/internal/jsonvalue"
+)
+
+// NewFromTemplate creates a new JSONFile at path holding the JSON
+// document name in fsys, for programs that ship a default
+// configuration rather than starting from the zero value of Data. The
+// template is often embedded in the program with embed.FS. To use a
+// file on disk, pass os.DirFS(dir).
+//
+// Each ${NAME} in a string of the template is replaced by vars[NAME].
+// The template must decode as Data, without members that Data does
+// not have and without variables missing from vars, or
+// NewFromTemplate fails without creating the file.
+func NewFromTemplate[Data any](path string, fsys fs.FS, name string, vars map[string]string, opts ...Option) (*JSONFile[Data], error) {
+	tmpl, err := readTemplate[Data](fsys, name, vars)
+	if err != nil {
+		return nil, fmt.Errorf("jsonfile.NewFromTemplate: %s: %w", name, err)
+	}
+	p := newJSONFile[Data](path, opts)
+	if err := p.create(func(data *Data) error { *data = *tmpl; return nil }); err != nil {
+		return nil, fmt.Errorf("jsonfile.NewFromTemplate: %w", err)
+	}
+	return p, nil
+}
+
+var templateVar = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+func readTemplate[Data any](fsys fs.FS, name string, vars map[string]string) (*Data, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	v, err := jsonvalue.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	if v, err = substitute(v, vars); err != nil {
+		return nil, err
+	}
+	if b, err = json.Marshal(v); err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	data := new(Data)
+	if err := d.Decode(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// substitute replaces the variables in the strings of v.
+func substitute(v any, vars map[string]string) (any, error) {
+	switch v := v.(type) {
+	case string:
+		var err error
+		s := templateVar.ReplaceAllStringFunc(v, func(m string) string {
+			name := m[2 : len(m)-1]
+			val, ok := vars[name]
+			if !ok && err == nil {
+				err = fmt.Errorf("no value for variable ${%s}", name)
+			}
+			return val
+		})
+		return s, err
+	case map[string]any:
+		for k, e := range v {
+			e, err := substitute(e, vars)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = e
+		}
+	case []any:
+		for i, e := range v {
+			e, err := substitute(e, vars)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = e
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewFromTemplate(t *testing.T) {
+	t.Parallel()
+	type Server struct {
+		Addr    string
+		Workers int
+	}
+	type Config struct {
+		Name    string
+		Servers []Server
+	}
+
+	fsys := fstest.MapFS{
+		"default.json": {Data: []byte(`{"Name": "${APP}", "Servers": [{"Addr": "${HOST}:80", "Workers": 4}]}`)},
+		"typo.json":    {Data: []byte(`{"Nmae": "x"}`)},
+	}
+	vars := map[string]string{"APP": "shop", "HOST": "localhost"}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	db, err := NewFromTemplate[Config](path, fsys, "default.json", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(c *Config) {
+		if c.Name != "shop" || len(c.Servers) != 1 || c.Servers[0].Addr != "localhost:80" || c.Servers[0].Workers != 4 {
+			t.Errorf("got %+v", *c)
+		}
+	})
+	db, err = Load[Config](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Read(func(c *Config) {
+		if c.Name != "shop" {
+			t.Errorf("Name=%q after Load", c.Name)
+		}
+	})
+
+	for _, name := range []string{"typo.json", "missing.json"} {
+		path := filepath.Join(dir, name)
+		if _, err := NewFromTemplate[Config](path, fsys, name, vars); err == nil {
+			t.Errorf("NewFromTemplate(%s) succeeded", name)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s created by failed NewFromTemplate", name)
+		}
+	}
+	if _, err := NewFromTemplate[Config](filepath.Join(dir, "novars.json"), fsys, "default.json", nil); err == nil {
+		t.Error("NewFromTemplate without vars succeeded")
+	}
+}
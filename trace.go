@@ -0,0 +1,123 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"io/fs"
+)
+
+// WithTracer records the phases of each Write and Load as spans of t,
+// so users can see where the time goes when a Write of a big file is
+// slow. The spans of a Write are children of any span in the context
+// given to WriteCtx:
+//
+//	jsonfile.Write       the Write, including waiting for other Writes
+//	  jsonfile.copy      copying the data for fn, by decoding it
+//	  jsonfile.fn        the fn passed to Write
+//	  jsonfile.marshal   encoding the data
+//	  jsonfile.writeFile writing the file, with any envelope,
+//	                     compression, and encryption
+//	    jsonfile.fsync   syncing the temporary file, as WithSync requires
+//	    jsonfile.rename  renaming it into place
+//	jsonfile.Load        Load reading the file
+//	  jsonfile.read      reading and decoding the envelope
+//	  jsonfile.unmarshal decoding the data
+func WithTracer(t Tracer) Option {
+	return func(o *options) { o.tracer = t }
+}
+
+// A Tracer records spans of work, such as an OpenTelemetry tracer.
+// An adapter for go.opentelemetry.io/otel/trace is a few lines:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+//		ctx, span := t.Start(ctx, name)
+//		return ctx, func(err error) {
+//			if err != nil {
+//				span.RecordError(err)
+//				span.SetStatus(codes.Error, err.Error())
+//			}
+//			span.End()
+//		}
+//	}
+type Tracer interface {
+	// StartSpan starts a span named name, a child of any span in ctx.
+	// It returns a context holding the span, and a function that ends
+	// it with the error of the work, or nil.
+	StartSpan(ctx context.Context, name string) (context.Context, func(err error))
+}
+
+func noSpan(error) {}
+
+// startSpan starts a span of the Tracer from WithTracer, if any.
+func (o *options) startSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	if o.tracer == nil {
+		return ctx, noSpan
+	}
+	return o.tracer.StartSpan(ctx, name)
+}
+
+// span starts a span of the current Write or Load, and returns the
+// function that ends it. It is called with p.writing held.
+func (p *JSONFile[Data]) span(name string) func(error) {
+	if p.opts.tracer == nil {
+		return noSpan
+	}
+	_, end := p.opts.startSpan(p.traceContext(), name)
+	return end
+}
+
+// traceContext returns the context of the current Write, which holds
+// its span. It is called with p.writing held.
+func (p *JSONFile[Data]) traceContext() context.Context {
+	if p.traceCtx == nil {
+		return context.Background() // written outside of a Write, by WithAutosave
+	}
+	return p.traceCtx
+}
+
+// tracingFS records the syncs and renames of files written in a
+// WriteFS as spans.
+type tracingFS struct {
+	WriteFS
+	ctx    context.Context
+	tracer Tracer
+}
+
+func (t tracingFS) CreateTemp(dir, prefix string) (File, error) {
+	f, err := t.WriteFS.CreateTemp(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return tracingFile{File: f, fs: t}, nil
+}
+
+func (t tracingFS) Rename(oldname, newname string) error {
+	_, end := t.tracer.StartSpan(t.ctx, "jsonfile.rename")
+	err := t.WriteFS.Rename(oldname, newname)
+	end(err)
+	return err
+}
+
+type tracingFile struct {
+	File
+	fs tracingFS
+}
+
+func (f tracingFile) Sync() error {
+	_, end := f.fs.tracer.StartSpan(f.fs.ctx, "jsonfile.fsync")
+	err := f.File.Sync()
+	end(err)
+	return err
+}
+
+// untraced returns the file system wrapped by a tracingFS.
+func untraced(fsys fs.FS) fs.FS {
+	if t, ok := fsys.(tracingFS); ok {
+		return t.WriteFS
+	}
+	return fsys
+}
@@ -0,0 +1,90 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type spanKey struct{}
+
+// testTracer records ended spans by their path from the root span.
+type testTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (t *testTracer) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	if parent, ok := ctx.Value(spanKey{}).(string); ok {
+		name = parent + "/" + name
+	}
+	return context.WithValue(ctx, spanKey{}, name), func(err error) {
+		if err != nil {
+			name += " error"
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.spans = append(t.spans, name)
+	}
+}
+
+func (t *testTracer) take() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := strings.Join(t.spans, "\n")
+	t.spans = nil
+	return s
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "db.json")
+	tr := new(testTracer)
+	db, err := New[DB](path, WithTracer(tr), WithSync(SyncAlways))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.take()
+
+	ctx := context.WithValue(context.Background(), spanKey{}, "request")
+	if err := db.WriteCtx(ctx, func(db *DB) error { db.Val = 1; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"request/jsonfile.Write/jsonfile.copy",
+		"request/jsonfile.Write/jsonfile.fn",
+		"request/jsonfile.Write/jsonfile.marshal",
+		"request/jsonfile.Write/jsonfile.writeFile/jsonfile.fsync",
+		"request/jsonfile.Write/jsonfile.writeFile/jsonfile.rename",
+		"request/jsonfile.Write/jsonfile.writeFile",
+		"request/jsonfile.Write",
+	}, "\n")
+	if got := tr.take(); got != want {
+		t.Errorf("Write spans:\n%s\nwant:\n%s", got, want)
+	}
+
+	errFn := errors.New("fn failed")
+	if err := db.Write(func(db *DB) error { return errFn }); err != errFn {
+		t.Fatalf("Write err=%v", err)
+	}
+	want = "jsonfile.Write/jsonfile.copy\njsonfile.Write/jsonfile.fn error\njsonfile.Write error"
+	if got := tr.take(); got != want {
+		t.Errorf("failed Write spans:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := Load[DB](path, WithTracer(tr)); err != nil {
+		t.Fatal(err)
+	}
+	want = "jsonfile.Load/jsonfile.read\njsonfile.Load/jsonfile.unmarshal\njsonfile.Load"
+	if got := tr.take(); got != want {
+		t.Errorf("Load spans:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// WithUnknownFields keeps the members of JSON objects in the file that
+// the Data type has no field for, and writes them back by each Write,
+// so programs built with older and newer versions of the type can share
+// a file without losing each other's fields.
+//
+// Unknown members are found when the file is read, in objects the data
+// holds after it is decoded; they are added at the end of their object.
+// A member the Data type does have a field for is never replaced, and
+// an object removed from the data is not written back.
+func WithUnknownFields() Option {
+	return func(o *options) { o.unknownFields = true }
+}
+
+// unknownFields are the members of an object, and of the objects in
+// it, that are not in the encoding of the data.
+type unknownFields struct {
+	members  []member
+	children map[string]*unknownFields // by key in the encoded data
+}
+
+type member struct {
+	key   string
+	value json.RawMessage
+}
+
+// rememberUnknown records the members of b, read from the file, that
+// are dropped by decoding it as data.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) rememberUnknown(b []byte, data *Data) error {
+	if !p.opts.unknownFields {
+		return nil
+	}
+	known, err := p.opts.marshal(data)
+	if err != nil {
+		return err
+	}
+	p.unknown = findUnknown(b, known)
+	return nil
+}
+
+// findUnknown returns the members of the JSON object file that are not
+// in known, or nil if there are none.
+func findUnknown(file, known []byte) *unknownFields {
+	fm, ok := objectMembers(file)
+	if !ok {
+		return nil
+	}
+	km, ok := objectMembers(known)
+	if !ok {
+		return nil
+	}
+	u := new(unknownFields)
+	for _, f := range fm {
+		k := findMember(km, f.key)
+		if k == nil {
+			u.members = append(u.members, f)
+			continue
+		}
+		if child := findUnknown(f.value, k.value); child != nil {
+			if u.children == nil {
+				u.children = make(map[string]*unknownFields)
+			}
+			u.children[k.key] = child
+		}
+	}
+	if len(u.members) == 0 && len(u.children) == 0 {
+		return nil
+	}
+	return u
+}
+
+// merge returns the JSON object b with the unknown members added.
+func (u *unknownFields) merge(b []byte) []byte {
+	if u == nil {
+		return b
+	}
+	ms, ok := objectMembers(b)
+	if !ok {
+		return b
+	}
+	for i := range ms {
+		if child := u.children[ms[i].key]; child != nil {
+			ms[i].value = child.merge(ms[i].value)
+		}
+	}
+	for _, m := range u.members {
+		if findMember(ms, m.key) == nil {
+			ms = append(ms, m)
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range ms {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(m.value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// findMember returns the member of ms with key, matched without case
+// as encoding/json decodes, or nil.
+func findMember(ms []member, key string) *member {
+	var fold *member
+	for i := range ms {
+		if ms[i].key == key {
+			return &ms[i]
+		}
+		if fold == nil && strings.EqualFold(ms[i].key, key) {
+			fold = &ms[i]
+		}
+	}
+	return fold
+}
+
+// objectMembers returns the members of b, in order, and reports
+// whether b is a JSON object.
+func objectMembers(b []byte) ([]member, bool) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := d.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+	var ms []member
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, false
+		}
+		var m member
+		m.key, _ = tok.(string)
+		if err := d.Decode(&m.value); err != nil {
+			return nil, false
+		}
+		ms = append(ms, m)
+	}
+	if _, err := d.Token(); err != nil {
+		return nil, false
+	}
+	return ms, true
+}
@@ -0,0 +1,68 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnknownFields(t *testing.T) {
+	t.Parallel()
+	type Old struct {
+		Name  string
+		Items map[string]struct{ A int }
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testunknown.json")
+	// Written by a newer version of the program, and by hand.
+	const file = `{"Name":"a","Added":[1,2],"Items":{"x":{"A":1,"B":true},"y":{"A":2,"B":false}},"name2":"z"}`
+	if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Load[Old](path, WithUnknownFields())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, db, func(db *Old) {
+		db.Name = "b"
+		delete(db.Items, "y")
+	})
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Name":"b","Items":{"x":{"A":1,"B":true}},"Added":[1,2],"name2":"z"}`
+	if string(b) != want {
+		t.Errorf("file:\n%s\nwant:\n%s", b, want)
+	}
+	db.Close()
+
+	if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Load[Old](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustWrite(t, db, func(db *Old) { db.Name = "b" })
+	if b, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Name":"b","Items":{"x":{"A":1},"y":{"A":2}}}`; string(b) != want {
+		t.Errorf("without WithUnknownFields, file:\n%s\nwant:\n%s", b, want)
+	}
+}
+
+func TestFindUnknownFold(t *testing.T) {
+	t.Parallel()
+	// encoding/json decodes "name" into the field Name.
+	u := findUnknown([]byte(`{"name":"a","Other":1}`), []byte(`{"Name":"a"}`))
+	if got, want := string(u.merge([]byte(`{"Name":"b"}`))), `{"Name":"b","Other":1}`; got != want {
+		t.Errorf("merge: %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import "fmt"
+
+// WithValidator checks the data after the function given to each Write
+// has changed it, before it is encoded and written. If validate returns
+// an error, the Write fails with the error, wrapped, and the data is
+// unchanged. Use it to enforce invariants, such as non-empty IDs or
+// references between records, in one place instead of in every Write.
+//
+// Validators run in the order given. With WithGroupCommit, each Write
+// in a group is validated on its own, and only invalid ones fail.
+// validate must not modify the data.
+func WithValidator[Data any](validate func(data *Data) error) Option {
+	return func(o *options) {
+		o.validators = append(o.validators, func(v any) error {
+			data, ok := v.(*Data)
+			if !ok {
+				return fmt.Errorf("WithValidator for %T used with %T", data, v)
+			}
+			return validate(data)
+		})
+	}
+}
+
+// validate runs the validators of WithValidator on data.
+func (o *options) validate(data any) error {
+	for _, validate := range o.validators {
+		if err := validate(data); err != nil {
+			return fmt.Errorf("invalid data: %w", err)
+		}
+	}
+	return nil
+}
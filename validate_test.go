@@ -0,0 +1,82 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		IDs []string
+	}
+	errEmpty := errors.New("empty ID")
+	noEmpty := func(db *DB) error {
+		for _, id := range db.IDs {
+			if id == "" {
+				return errEmpty
+			}
+		}
+		return nil
+	}
+
+	dir := t.TempDir()
+	db, err := New[DB](filepath.Join(dir, "testvalidator.json"), WithValidator(noEmpty))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustWrite(t, db, func(db *DB) { db.IDs = append(db.IDs, "a") })
+	err = db.Write(func(db *DB) error {
+		db.IDs = append(db.IDs, "")
+		return nil
+	})
+	if !errors.Is(err, errEmpty) {
+		t.Errorf("Write: %v, want %v", err, errEmpty)
+	}
+	db.Read(func(db *DB) {
+		if len(db.IDs) != 1 {
+			t.Errorf("invalid Write changed the data: %v", db.IDs)
+		}
+	})
+
+	// In a group, only the invalid Write fails.
+	gdb, err := New[DB](filepath.Join(dir, "testvalidatorgroup.json"), WithValidator(noEmpty), WithGroupCommit())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gdb.Close()
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = gdb.Write(func(db *DB) error {
+				if i == 3 {
+					db.IDs = append(db.IDs, "")
+				} else {
+					db.IDs = append(db.IDs, "x")
+				}
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if (i == 3) != errors.Is(err, errEmpty) {
+			t.Errorf("Write %d: %v", i, err)
+		}
+	}
+	gdb.Read(func(db *DB) {
+		if len(db.IDs) != 9 {
+			t.Errorf("got %d IDs, want 9", len(db.IDs))
+		}
+	})
+}
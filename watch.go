@@ -0,0 +1,164 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// pollInterval is how often Watch checks the file when the platform
+// has no file change notifications.
+const pollInterval = time.Second
+
+// Watch watches the file for changes made by other programs. When the
+// file changes, Watch reloads it and sends on the returned channel.
+// Sends do not block: several changes in quick succession may be
+// reported by a single send. Writes made through this JSONFile are not
+// reported.
+//
+// Changes that cannot be decoded, such as a partially saved file, are
+// ignored until the file is valid again. With WithAutosave, changes are
+// not reloaded while there are unsaved changes in memory.
+//
+// On Linux, Watch uses inotify. On other platforms it polls the file
+// every second. The channel is closed when ctx is done or the JSONFile
+// is closed.
+func (p *JSONFile[Data]) Watch(ctx context.Context) (<-chan struct{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var events <-chan struct{}
+	if p.opts.fsys == nil {
+		var err error
+		if events, err = notify(ctx, p.path); err != nil {
+			cancel()
+			return nil, fmt.Errorf("JSONFile.Watch: %w", err)
+		}
+	}
+	if events == nil {
+		events = poll(ctx, p.opts.fileSystem(), p.path, pollInterval)
+	}
+	go func() {
+		defer cancel()
+		select {
+		case <-ctx.Done():
+		case <-p.done:
+		}
+	}()
+	ch := make(chan struct{}, 1)
+	p.mu.Lock()
+	p.watches++
+	p.mu.Unlock()
+	go func() {
+		defer close(ch)
+		defer func() {
+			p.mu.Lock()
+			p.watches--
+			p.mu.Unlock()
+		}()
+		for range events {
+			if changed, _ := p.reloadIfChanged(); changed {
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// reloadIfChanged reloads the file if its contents differ from the
+// data in memory, and reports whether it did.
+func (p *JSONFile[Data]) reloadIfChanged() (bool, error) {
+	p.writing <- struct{}{}
+	defer func() { <-p.writing }()
+	if p.dirty {
+		return false, nil // do not discard unsaved changes
+	}
+
+	b, state, err := p.readFile()
+	if err != nil {
+		return false, err
+	}
+	if p.sameData(b) {
+		return false, nil
+	}
+	if err := p.replaceData(b, state); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// poll checks the file at path in fsys every interval, and sends on the
+// returned channel whenever its size or modification time changes.
+// The channel is closed when ctx is done.
+func poll(ctx context.Context, fsys fs.StatFS, path string, interval time.Duration) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		var last fs.FileInfo
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			fi, err := fsys.Stat(path)
+			if err != nil {
+				continue
+			}
+			if last != nil && fi.Size() == last.Size() && fi.ModTime().Equal(last.ModTime()) {
+				continue
+			}
+			last = fi
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch
+}
+
+// WaitUntilInitialized waits until another program, such as an init
+// container or a sibling process that provisions the file, has created
+// the file at path. It returns when the file exists and is valid, as
+// checked by Verify, or when ctx is done. Use it before Load instead of
+// retrying Load until the file exists.
+//
+// A file being written by a program that does not replace it
+// atomically is waited for until it is valid.
+func WaitUntilInitialized(ctx context.Context, path string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Start watching before the first check, so a file created
+	// between the two is not missed.
+	events, err := notify(ctx, path)
+	if err != nil || events == nil {
+		events = poll(ctx, osFS{}, path, pollInterval) // the directory may not exist yet
+	}
+	for {
+		b, err := os.ReadFile(path)
+		if err == nil && verify(b) == nil {
+			return nil
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("jsonfile.WaitUntilInitialized: %w", err)
+		}
+		select {
+		case _, ok := <-events:
+			if !ok && ctx.Err() == nil {
+				events = poll(ctx, osFS{}, path, pollInterval) // notifications failed
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("jsonfile.WaitUntilInitialized: %w", ctx.Err())
+		}
+	}
+}
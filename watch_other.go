@@ -0,0 +1,14 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux
+
+package jsonfile
+
+import "context"
+
+// notify returns nil: file change notifications are not implemented
+// on this platform, so Watch polls.
+func notify(ctx context.Context, path string) (<-chan struct{}, error) {
+	return nil, nil
+}
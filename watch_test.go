@@ -0,0 +1,112 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testwatch.json")
+	db, err := New[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := db.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Our own writes are not reported.
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+
+	other, err := Load[DB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, other, func(db *DB) { db.Val = 2 })
+
+	select {
+	case <-ch:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no change reported")
+	}
+	db.Read(func(db *DB) {
+		if db.Val != 2 {
+			t.Errorf("Val = %d after change, want 2", db.Val)
+		}
+	})
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestPoll(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "testpoll.json")
+	if err := os.WriteFile(path, []byte("{}"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := poll(ctx, osFS{}, path, time.Millisecond)
+	<-ch // first check always reports
+
+	if err := os.WriteFile(path, []byte(`{"Val":1}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ch:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no change reported")
+	}
+	cancel()
+	for range ch {
+	}
+}
+
+func TestWaitUntilInitialized(t *testing.T) {
+	t.Parallel()
+	type DB struct{ Val int }
+
+	path := filepath.Join(t.TempDir(), "testinit.json")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := WaitUntilInitialized(ctx, path); err == nil {
+		t.Fatal("no error for a file that is never created")
+	}
+
+	done := make(chan error)
+	go func() { done <- WaitUntilInitialized(context.Background(), path) }()
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(path, []byte(`{"Val":`), 0600); err != nil {
+		t.Fatal(err) // a file being written
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("returned before the file was valid: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	db, err := New[DB](path + ".new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if err := os.Rename(path+".new", path); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// WithWriteStats keeps statistics of the writes of the file in its
+// envelope: how many there have been, and the host, process, time, and
+// duration of the last. They are kept across programs and restarts, so
+// a file found in a bad state tells who wrote it last and how often it
+// was being written. They are reported by the WriteStats of Stat.
+func WithWriteStats() Option {
+	return func(o *options) { o.writeStats = true }
+}
+
+// WriteStats are the statistics of the writes of a file kept by
+// WithWriteStats.
+type WriteStats struct {
+	Writes uint64    `json:"writes"` // writes of the file, by any program
+	Host   string    `json:"host"`   // host name of the last writer
+	PID    int       `json:"pid"`    // process ID of the last writer
+	Time   time.Time `json:"time"`   // when the last write started
+
+	// Duration is how long the last writer took to make the data, from
+	// the start of its Write to when the file was encoded, in
+	// nanoseconds. It is zero for writes not made by a Write, such as
+	// those delayed by WithAutosave.
+	Duration time.Duration `json:"duration"`
+}
+
+var hostname = sync.OnceValue(func() string {
+	h, _ := os.Hostname()
+	return h
+})
+
+// countWriteStats records the write of the file about to be made in
+// the stats, and returns a func that undoes it if the write fails.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) countWriteStats() (undo func()) {
+	if !p.opts.writeStats {
+		return func() {}
+	}
+	prev := p.writeStats
+	next := WriteStats{Writes: prev.Writes + 1, Host: hostname(), PID: os.Getpid(), Time: time.Now()}
+	if !p.writeStart.IsZero() {
+		next.Time = p.writeStart
+		next.Duration = time.Since(p.writeStart)
+	}
+	p.setWriteStats(next)
+	return func() { p.setWriteStats(prev) }
+}
+
+// setWriteStats sets the write stats of the file.
+// It is called with p.writing held.
+func (p *JSONFile[Data]) setWriteStats(s WriteStats) {
+	p.mu.Lock()
+	p.writeStats = s
+	p.mu.Unlock()
+}
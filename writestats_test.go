@@ -0,0 +1,77 @@
+// Copyright (c) David Crawshaw
+// SPDX-License-Identifier: BSD-3-Clause
+
+package jsonfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteStats(t *testing.T) {
+	t.Parallel()
+	type DB struct {
+		Val int
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testwritestats.json")
+	db, err := New[DB](path, WithWriteStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := db.Stat().WriteStats.Writes
+	before := time.Now()
+	mustWrite(t, db, func(db *DB) { db.Val = 1 })
+	mustWrite(t, db, func(db *DB) { db.Val = 2 })
+
+	st := db.Stat().WriteStats
+	if st.Writes != start+2 {
+		t.Errorf("Writes=%d, want %d", st.Writes, start+2)
+	}
+	if st.Host != hostname() || st.PID != os.Getpid() {
+		t.Errorf("last writer %s/%d, want %s/%d", st.Host, st.PID, hostname(), os.Getpid())
+	}
+	if st.Time.Before(before) || st.Duration <= 0 {
+		t.Errorf("last write at %v for %v, want after %v", st.Time, st.Duration, before)
+	}
+
+	// A failed write is not counted.
+	if err := os.Rename(dir, dir+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Write(func(db *DB) error { db.Val = 3; return nil }); err == nil {
+		t.Fatal("Write succeeded without its directory")
+	}
+	if err := os.Rename(dir+".moved", dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Stat().WriteStats; got != st {
+		t.Errorf("after failed write, stats %+v, want %+v", got, st)
+	}
+	db.Close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"stats":{"writes":`)) {
+		t.Errorf("file has no stats: %s", b)
+	}
+
+	// The count continues in the next program to write the file.
+	db, err = Load[DB](path, WithWriteStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got := db.Stat().WriteStats; !got.Time.Equal(st.Time) || got.Writes != st.Writes {
+		t.Errorf("loaded stats %+v, want %+v", got, st)
+	}
+	mustWrite(t, db, func(db *DB) { db.Val = 4 })
+	if got := db.Stat().WriteStats.Writes; got != st.Writes+1 {
+		t.Errorf("Writes=%d after Load and write, want %d", got, st.Writes+1)
+	}
+}